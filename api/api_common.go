@@ -3,10 +3,13 @@ package api
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/filecoin-project/lotus/build"
+	"github.com/libp2p/go-libp2p-core/metrics"
 	"github.com/libp2p/go-libp2p-core/network"
 	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/protocol"
 )
 
 type Permission = string
@@ -24,6 +27,19 @@ type Common interface {
 	NetAddrsListen(context.Context) (peer.AddrInfo, error)
 	NetDisconnect(context.Context, peer.ID) error
 	NetFindPeer(context.Context, peer.ID) (peer.AddrInfo, error)
+	NetPubsubScores(context.Context) ([]PubsubScore, error)
+	NetPeerInfo(context.Context, peer.ID) (*ExtendedPeerInfo, error)
+
+	NetBlockAdd(ctx context.Context, acl NetBlockList) error
+	NetBlockRemove(ctx context.Context, acl NetBlockList) error
+	NetBlockList(ctx context.Context) (NetBlockList, error)
+
+	// NetBandwidthStats returns the total bandwidth used by the node
+	NetBandwidthStats(ctx context.Context) (metrics.Stats, error)
+	// NetBandwidthStatsByPeer returns bandwidth used by the node, keyed by peer ID
+	NetBandwidthStatsByPeer(ctx context.Context) (map[string]metrics.Stats, error)
+	// NetBandwidthStatsByProtocol returns bandwidth used by the node, keyed by protocol ID
+	NetBandwidthStatsByProtocol(ctx context.Context) (map[protocol.ID]metrics.Stats, error)
 
 	// ID returns peerID of libp2p node backing this API
 	ID(context.Context) (peer.ID, error)
@@ -35,6 +51,39 @@ type Common interface {
 	LogSetLevel(context.Context, string, string) error
 }
 
+type PubsubScore struct {
+	ID    peer.ID
+	Score float64
+}
+
+type ExtendedPeerInfo struct {
+	ID        peer.ID
+	Agent     string
+	Addrs     []string
+	Protocols []string
+
+	// Hello is nil for peers which haven't said hello to us
+	Hello *HelloPeerInfo
+}
+
+// HelloPeerInfo is what a peer advertised about itself in the hello protocol
+type HelloPeerInfo struct {
+	HelloVersion  int
+	NodeType      string
+	Capabilities  []string
+	ClientVersion string
+	APIVersion    build.Version
+	Latency       time.Duration
+}
+
+// NetBlockList describes peers, IP addresses and subnets (in CIDR notation)
+// which are not allowed to connect to the node
+type NetBlockList struct {
+	Peers     []peer.ID
+	IPAddrs   []string
+	IPSubnets []string
+}
+
 // Version provides various build-time information
 type Version struct {
 	Version string
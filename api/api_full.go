@@ -17,9 +17,11 @@ import (
 	"github.com/filecoin-project/specs-actors/actors/builtin/paych"
 	"github.com/filecoin-project/specs-actors/actors/builtin/reward"
 	"github.com/filecoin-project/specs-actors/actors/crypto"
+	"github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
 
 	"github.com/filecoin-project/lotus/chain/store"
 	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/lib/sigs"
 	"github.com/filecoin-project/lotus/node/modules/dtypes"
 )
 
@@ -60,12 +62,28 @@ type FullNode interface {
 	SyncMarkBad(ctx context.Context, bcid cid.Cid) error
 	SyncCheckBad(ctx context.Context, bcid cid.Cid) (string, error)
 
+	// SyncDebugState returns the internal view of the sync scheduler
+	SyncDebugState(context.Context) (*SyncDebugState, error)
+	// SyncPause stops new syncs from being started; running syncs finish
+	SyncPause(context.Context) error
+	SyncResume(context.Context) error
+	// SyncForceTarget makes the last known head of the given peer the next
+	// sync target
+	SyncForceTarget(context.Context, peer.ID) (types.TipSetKey, error)
+	SyncSetWorkers(ctx context.Context, count int) error
+
 	// messages
 	MpoolPending(context.Context, types.TipSetKey) ([]*types.SignedMessage, error)
 	MpoolPush(context.Context, *types.SignedMessage) (cid.Cid, error)
 	MpoolPushMessage(context.Context, *types.Message) (*types.SignedMessage, error) // get nonce, sign, push
 	MpoolGetNonce(context.Context, address.Address) (uint64, error)
 	MpoolSub(context.Context) (<-chan MpoolUpdate, error)
+	// MpoolReplace re-signs the pending message from the given address with
+	// the given nonce with a higher gas price, replacing it in the mpool. With
+	// auto set, the lowest gas price accepted for the replacement is used.
+	MpoolReplace(ctx context.Context, from address.Address, nonce uint64, auto bool, gasPrice types.BigInt) (cid.Cid, error)
+	// MpoolCancel replaces the pending message with an empty send to self
+	MpoolCancel(ctx context.Context, from address.Address, nonce uint64) (cid.Cid, error)
 
 	// FullNodeStruct
 
@@ -80,7 +98,9 @@ type FullNode interface {
 
 	WalletNew(context.Context, crypto.SigType) (address.Address, error)
 	WalletHas(context.Context, address.Address) (bool, error)
-	WalletList(context.Context) ([]address.Address, error)
+	// WalletList lists the addresses the wallet holds keys for, and watch-only
+	// addresses, with their metadata
+	WalletList(context.Context) ([]WalletAddress, error)
 	WalletBalance(context.Context, address.Address) (types.BigInt, error)
 	WalletSign(context.Context, address.Address, []byte) (*crypto.Signature, error)
 	WalletSignMessage(context.Context, address.Address, *types.Message) (*types.SignedMessage, error)
@@ -89,6 +109,37 @@ type FullNode interface {
 	WalletSetDefault(context.Context, address.Address) error
 	WalletExport(context.Context, address.Address) (*types.KeyInfo, error)
 	WalletImport(context.Context, *types.KeyInfo) (address.Address, error)
+	// WalletSignData signs a payload for off-chain use, wrapped in an envelope
+	// with the purpose and the network name, so that the signature can't be
+	// used as a message signature, or for another purpose
+	WalletSignData(ctx context.Context, addr address.Address, purpose string, payload []byte) (*sigs.SignedData, error)
+	// WalletDelete removes an address from the wallet. Keys are kept in a
+	// backup, and can be restored with WalletUndelete.
+	WalletDelete(context.Context, address.Address) error
+	// WalletUndelete restores a deleted key. A key which was the default is
+	// restored as the default, unless another default was set since.
+	WalletUndelete(context.Context, address.Address) error
+	WalletSetLabel(context.Context, address.Address, string) error
+	WalletSetTags(context.Context, address.Address, []string) error
+	// WalletAddWatch adds an address without a key to the wallet
+	WalletAddWatch(ctx context.Context, addr address.Address, label string) error
+	// WalletHistory returns the indexed messages and internal value transfers
+	// of a wallet address at and below fromHeight, newest first. A negative
+	// fromHeight starts from the latest entries, limit 0 returns all entries.
+	WalletHistory(ctx context.Context, addr address.Address, fromHeight abi.ChainEpoch, limit int) ([]WalletHistoryEntry, error)
+
+	// WalletHDNew derives the next address of the given type from the HD
+	// seed, creating the seed if the wallet doesn't have one yet. The
+	// mnemonic is only returned when a new seed was created.
+	WalletHDNew(ctx context.Context, typ crypto.SigType, passphrase string) (*HDNewResult, error)
+	// WalletHDRestore sets up the HD seed from a BIP-39 mnemonic, and derives
+	// the given number of secp256k1 and BLS addresses
+	WalletHDRestore(ctx context.Context, mnemonic string, passphrase string, secpCount, blsCount int) ([]address.Address, error)
+	// WalletHDDerive derives the address of the given type at index, or at the
+	// next unused index if index is negative
+	WalletHDDerive(ctx context.Context, typ crypto.SigType, index int64, passphrase string) (address.Address, error)
+	// WalletHDUnlock decrypts the HD seed, so that derived addresses can sign
+	WalletHDUnlock(ctx context.Context, passphrase string) error
 
 	// Other
 
@@ -144,14 +195,48 @@ type FullNode interface {
 	StateCompute(context.Context, abi.ChainEpoch, []*types.Message, types.TipSetKey) (*ComputeStateOutput, error)
 
 	MsigGetAvailableBalance(context.Context, address.Address, types.TipSetKey) (types.BigInt, error)
-
-	MarketEnsureAvailable(context.Context, address.Address, address.Address, types.BigInt) error
-	// MarketFreeBalance
+	// MsigGetPending returns the pending transactions of a multisig, with the
+	// target method and params decoded where possible
+	MsigGetPending(context.Context, address.Address, types.TipSetKey) ([]*MsigTransaction, error)
+	// MsigCreate creates a multisig wallet, and returns the CID of the
+	// creation message. The multisig address is in the receipt return value.
+	MsigCreate(ctx context.Context, required int64, signers []address.Address, value types.BigInt, src address.Address) (cid.Cid, error)
+	MsigPropose(ctx context.Context, msig address.Address, to address.Address, value types.BigInt, src address.Address, method uint64, params []byte) (cid.Cid, error)
+	MsigApprove(ctx context.Context, msig address.Address, txID int64, src address.Address) (cid.Cid, error)
+	MsigCancel(ctx context.Context, msig address.Address, txID int64, src address.Address) (cid.Cid, error)
+	// Signer and threshold changes must be made by the multisig itself, so
+	// these propose a transaction from src to the multisig, which then needs
+	// to be approved by the other signers
+	MsigAddSigner(ctx context.Context, msig address.Address, src address.Address, signer address.Address, increase bool) (cid.Cid, error)
+	MsigRemoveSigner(ctx context.Context, msig address.Address, src address.Address, signer address.Address, decrease bool) (cid.Cid, error)
+	MsigSwapSigner(ctx context.Context, msig address.Address, src address.Address, oldSigner address.Address, newSigner address.Address) (cid.Cid, error)
+	MsigChangeThreshold(ctx context.Context, msig address.Address, src address.Address, threshold int64) (cid.Cid, error)
+
+	// MarketEnsureAvailable reserves funds in the market escrow of addr for the
+	// deal with the given proposal, adding funds from wallet if the escrow
+	// doesn't cover all reservations. Concurrent requests are covered by a
+	// single message.
+	MarketEnsureAvailable(ctx context.Context, deal cid.Cid, addr, wallet address.Address, amt types.BigInt) error
+	// MarketReleaseFunds drops the reservation of a deal, once it was
+	// published or failed
+	MarketReleaseFunds(ctx context.Context, deal cid.Cid) error
+	// MarketGetReserved returns the funds reserved for deals of addr
+	MarketGetReserved(ctx context.Context, addr address.Address) (types.BigInt, error)
+	// MarketWithdraw withdraws funds which aren't locked or reserved from the
+	// market escrow of addr
+	MarketWithdraw(ctx context.Context, wallet, addr address.Address, amt types.BigInt) (cid.Cid, error)
 
 	PaychGet(ctx context.Context, from, to address.Address, ensureFunds types.BigInt) (*ChannelInfo, error)
 	PaychList(context.Context) ([]address.Address, error)
 	PaychStatus(context.Context, address.Address) (*PaychStatus, error)
+	// PaychSettle starts the settlement period of the channel. Once it's over
+	// the node collects the channel funds, submitting the best spendable
+	// vouchers of inbound channels first.
+	PaychSettle(context.Context, address.Address) (cid.Cid, error)
+	// PaychClose is an alias of PaychSettle
 	PaychClose(context.Context, address.Address) (cid.Cid, error)
+	// PaychCollect pays out the channel funds, after the settlement period
+	PaychCollect(context.Context, address.Address) (cid.Cid, error)
 	PaychAllocateLane(ctx context.Context, ch address.Address) (uint64, error)
 	PaychNewPayment(ctx context.Context, from, to address.Address, vouchers []VoucherSpec) (*PaymentInfo, error)
 	PaychVoucherCheckValid(context.Context, address.Address, *paych.SignedVoucher) error
@@ -162,6 +247,41 @@ type FullNode interface {
 	PaychVoucherSubmit(context.Context, address.Address, *paych.SignedVoucher) (cid.Cid, error)
 }
 
+type WalletAddress struct {
+	Address address.Address
+
+	// KeyType is secp256k1 or bls, empty for watch-only addresses
+	KeyType   string
+	HD        bool
+	WatchOnly bool
+
+	Label string
+	Tags  []string
+}
+
+type WalletHistoryEntry struct {
+	Height   abi.ChainEpoch
+	TipSet   types.TipSetKey
+	Message  cid.Cid
+	Internal bool
+
+	From         address.Address
+	To           address.Address
+	Counterparty address.Address
+	// Outgoing is set when the value was sent by the address
+	Outgoing bool
+
+	Method   abi.MethodNum
+	Value    types.BigInt
+	Fee      types.BigInt
+	ExitCode exitcode.ExitCode
+}
+
+type HDNewResult struct {
+	Mnemonic string
+	Address  address.Address
+}
+
 type FileRef struct {
 	Path  string
 	IsCAR bool
@@ -232,6 +352,33 @@ const (
 type PaychStatus struct {
 	ControlAddr address.Address
 	Direction   PCHDir
+
+	Target     address.Address
+	State      string // open, settling or collected
+	SettlingAt abi.ChainEpoch
+
+	// on-chain state, empty once the channel is collected
+	Balance types.BigInt
+	ToSend  types.BigInt
+	Lanes   []PaychLaneStatus
+
+	// outbound channels only: funds not committed to vouchers, and funds
+	// sent to the channel which didn't land on chain yet
+	AvailableFunds types.BigInt
+	PendingFunds   types.BigInt
+}
+
+type PaychLaneStatus struct {
+	Lane uint64
+
+	// redeemed on chain
+	Redeemed types.BigInt
+	Nonce    uint64
+
+	// best voucher stored locally
+	Best      types.BigInt
+	BestNonce uint64
+	Vouchers  int
 }
 
 type ChannelInfo struct {
@@ -310,6 +457,7 @@ type RetrievalOrder struct {
 }
 
 type InvocResult struct {
+	MsgCid             cid.Cid
 	Msg                *types.Message
 	MsgRct             *types.MessageReceipt
 	InternalExecutions []*types.ExecutionResult
@@ -351,6 +499,59 @@ type SyncState struct {
 	ActiveSyncs []ActiveSync
 }
 
+type SyncPeerHead struct {
+	Peer   peer.ID
+	Head   types.TipSetKey
+	Height abi.ChainEpoch
+}
+
+type SyncBucket struct {
+	Tips []types.TipSetKey
+
+	// Heaviest is the tipset that will be synced when this bucket is picked
+	Heaviest types.TipSetKey
+	Height   abi.ChainEpoch
+
+	// Count is the number of times tipsets in this bucket were reported
+	Count int
+}
+
+type SyncWorkerState struct {
+	ID    int
+	State ActiveSync
+
+	LastError     string
+	LastErrorTime time.Time
+}
+
+type SyncDebugState struct {
+	BootstrapState string
+	Paused         bool
+
+	PeerHeads []SyncPeerHead
+
+	ActiveSyncs    []types.TipSetKey
+	NextTarget     *SyncBucket
+	SyncQueue      []SyncBucket
+	ActiveSyncTips []SyncBucket
+
+	Workers []SyncWorkerState
+}
+
+type MsigTransaction struct {
+	ID     int64
+	To     address.Address
+	Value  types.BigInt
+	Method abi.MethodNum
+	Params []byte
+
+	// MethodName and DecodedParams are only set for methods of builtin actors
+	MethodName    string
+	DecodedParams interface{}
+
+	Approved []address.Address
+}
+
 type SyncStateStage int
 
 const (
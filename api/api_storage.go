@@ -3,16 +3,20 @@ package api
 import (
 	"bytes"
 	"context"
+	"time"
 
 	"github.com/ipfs/go-cid"
 
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-fil-markets/storagemarket"
 	sectorstorage "github.com/filecoin-project/sector-storage"
+	"github.com/filecoin-project/sector-storage/sealtasks"
 	"github.com/filecoin-project/sector-storage/stores"
 	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
 
 	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/node/config"
 	sealing "github.com/filecoin-project/storage-fsm"
 )
 
@@ -27,6 +31,14 @@ type StorageMiner interface {
 	// Temp api for testing
 	PledgeSector(context.Context) error
 
+	// PledgeGetConfig returns the config of the pledge scheduler, which
+	// pledges sectors to keep a target number of sectors sealing
+	PledgeGetConfig(context.Context) (config.PledgeConfig, error)
+	// PledgeSetConfig changes the config of the pledge scheduler until the
+	// miner is restarted
+	PledgeSetConfig(context.Context, config.PledgeConfig) error
+	PledgeStatus(context.Context) (PledgeStatus, error)
+
 	// Get the status of a given sector by ID
 	SectorsStatus(context.Context, abi.SectorNumber) (SectorInfo, error)
 
@@ -37,13 +49,70 @@ type StorageMiner interface {
 
 	SectorsUpdate(context.Context, abi.SectorNumber, sealing.SectorState) error
 
+	// SectorsPackingStatus returns the deals waiting to be packed into
+	// sectors
+	SectorsPackingStatus(context.Context) (PackingStatus, error)
+	// SectorsSealNow hands all deals waiting for packing to sealing
+	SectorsSealNow(context.Context) error
+
+	// SectorsCheckHealth checks that the files of the given sectors, or of
+	// the whole proving set if none are given, are present and readable
+	SectorsCheckHealth(ctx context.Context, sectors []abi.SectorNumber, sample bool) ([]SectorHealth, error)
+
+	// SectorsRemove stops sealing a sector which isn't committed on chain, and
+	// removes its files and sealing state. Without force, only failed sectors
+	// without deals can be removed.
+	SectorsRemove(ctx context.Context, sector abi.SectorNumber, force bool) error
+	// SectorsExtend extends the expiration of committed sectors
+	SectorsExtend(ctx context.Context, sectors []abi.SectorNumber, newExpiration abi.ChainEpoch) ([]cid.Cid, error)
+	// SectorsTerminate terminates committed sectors early. Without force,
+	// sectors with active deals aren't terminated.
+	SectorsTerminate(ctx context.Context, sectors []abi.SectorNumber, force bool) (cid.Cid, error)
+
+	// ProvingStatus returns the state of the current proving period, and of
+	// the last fallback PoSt
+	ProvingStatus(context.Context) (*ProvingStatus, error)
+	// ProvingHistory returns fallback PoSt attempts, newest first. limit 0
+	// returns all attempts.
+	ProvingHistory(ctx context.Context, limit int) ([]PoStRecord, error)
+	// ProvingDryRun computes a fallback PoSt against the current head without
+	// submitting it or declaring faults
+	ProvingDryRun(context.Context) (*PoStRecord, error)
+
 	StorageList(ctx context.Context) (map[stores.ID][]stores.Decl, error)
 	StorageLocal(ctx context.Context) (map[stores.ID]string, error)
 	StorageStat(ctx context.Context, id stores.ID) (stores.FsStat, error)
+	// StorageDetach stops using a local storage path. Paths still storing
+	// sector files, or with sectors being moved from or to them, can't be
+	// detached. The sector manager keeps the path until the miner is
+	// restarted.
+	StorageDetach(ctx context.Context, id stores.ID) error
+	// StorageSetConfig changes the weight and use of a local storage path.
+	// The new config is used once the miner is restarted.
+	StorageSetConfig(ctx context.Context, id stores.ID, cfg StoragePathConfig) error
+
+	// SectorsMove moves the files of a proving sector to another local
+	// storage path. The move runs in the background, and is resumed when the
+	// miner restarts.
+	SectorsMove(ctx context.Context, sector abi.SectorNumber, dest stores.ID) error
+	// SectorsMoves returns moves in progress, and moves which ended since the
+	// miner started
+	SectorsMoves(context.Context) ([]SectorMove, error)
 
 	// WorkerConnect tells the node to connect to workers RPC
 	WorkerConnect(context.Context, string) error
 	WorkerStats(context.Context) (map[uint64]sectorstorage.WorkerStats, error)
+	// WorkerJobs returns the sealing jobs running on remote workers, by
+	// worker URL
+	WorkerJobs(context.Context) (map[string][]WorkerJob, error)
+	// WorkerHeartbeat is called periodically by the worker at the URL. It
+	// returns false if the worker isn't connected, e.g. after the miner was
+	// restarted, and has to connect again.
+	WorkerHeartbeat(ctx context.Context, url string) (bool, error)
+	// WorkerDrain stops sending new jobs to a worker, jobs in progress are
+	// finished
+	WorkerDrain(ctx context.Context, url string, drain bool) error
+	WorkerStates(context.Context) (map[string]WorkerState, error)
 
 	stores.SectorIndex
 
@@ -54,6 +123,12 @@ type StorageMiner interface {
 
 	DealsImportData(ctx context.Context, dealPropCid cid.Cid, file string) error
 	DealsList(ctx context.Context) ([]storagemarket.StorageDeal, error)
+	// DealsGetPolicy returns the policy deciding which deal proposals are
+	// accepted
+	DealsGetPolicy(context.Context) (config.DealPolicy, error)
+	// DealsSetPolicy changes the deal policy until the miner is restarted.
+	// The filter command can only be changed in the config.
+	DealsSetPolicy(context.Context, config.DealPolicy) error
 
 	StorageAddLocal(ctx context.Context, path string) error
 }
@@ -90,6 +165,156 @@ type SectorInfo struct {
 	Log []SectorLog
 }
 
+type WorkerJob struct {
+	ID     uint64
+	Sector abi.SectorID
+	Task   sealtasks.TaskType
+	Start  time.Time
+}
+
+type WorkerStateKind string
+
+const (
+	WorkerOk       WorkerStateKind = "ok"
+	WorkerDraining WorkerStateKind = "draining"
+	WorkerDead     WorkerStateKind = "dead"
+)
+
+type WorkerState struct {
+	State         WorkerStateKind
+	LastHeartbeat time.Time
+}
+
+type PledgeStatus struct {
+	Enabled bool
+
+	// Sealing is the number of sectors sealing, including pledged sectors
+	// which the sealing state machine hasn't started yet
+	Sealing    uint64
+	Pending    uint64
+	PreCommit1 uint64
+	PreCommit2 uint64
+	Commit     uint64
+
+	// Waiting is why sectors aren't pledged, if the target isn't reached
+	Waiting string
+
+	LastPledge time.Time
+	LastErr    string
+}
+
+type StoragePathConfig struct {
+	Weight   uint64
+	CanSeal  bool
+	CanStore bool
+}
+
+type SectorMoveStage string
+
+const (
+	SectorMoveCopying  SectorMoveStage = "copying"
+	SectorMoveRemoving SectorMoveStage = "removing"
+	SectorMoveDone     SectorMoveStage = "done"
+	SectorMoveFailed   SectorMoveStage = "failed"
+)
+
+type SectorMove struct {
+	Sector abi.SectorID
+	From   stores.ID
+	To     stores.ID
+	Stage  SectorMoveStage
+
+	// Copied and Total are the bytes copied so far, and the size of all files
+	// of the sector
+	Copied uint64
+	Total  uint64
+
+	Start time.Time
+	Err   string
+}
+
+type SectorHealthStatus string
+
+const (
+	SectorHealthOk     SectorHealthStatus = "ok"
+	SectorHealthFaulty SectorHealthStatus = "faulty"
+	// SectorHealthUnchecked is reported for sectors with files only in
+	// storage of other machines
+	SectorHealthUnchecked SectorHealthStatus = "unchecked"
+)
+
+type SectorHealth struct {
+	Sector abi.SectorNumber
+	Status SectorHealthStatus
+	Err    string
+}
+
+type PoStStatus string
+
+const (
+	PoStComputing PoStStatus = "computing"
+	PoStSubmitted PoStStatus = "submitted"
+	PoStOk        PoStStatus = "ok"
+	PoStFailed    PoStStatus = "failed"
+)
+
+// PoStRecord describes a fallback PoSt attempt
+type PoStRecord struct {
+	// ProvingPeriodStart is the start of the proving period the proof is for
+	ProvingPeriodStart abi.ChainEpoch
+	// Height is the height of the tipset the proof was computed against
+	Height abi.ChainEpoch
+
+	Sectors uint64
+	Faults  []abi.SectorNumber
+
+	Start time.Time
+	// Duration is the time taken to compute the proof
+	Duration time.Duration
+
+	Status   PoStStatus
+	Message  *cid.Cid
+	ExitCode exitcode.ExitCode
+	Err      string
+
+	DryRun bool
+}
+
+type ProvingStatus struct {
+	Head               abi.ChainEpoch
+	ProvingPeriodStart abi.ChainEpoch
+	// ChallengeEpoch is the height at which the PoSt for the proving period
+	// is started
+	ChallengeEpoch abi.ChainEpoch
+
+	ProvingSetSize uint64
+	Faults         uint64
+
+	// Running is the PoSt being computed or waiting to land on chain
+	Running *PoStRecord
+	Last    *PoStRecord
+}
+
+type PackingPiece struct {
+	DealID     abi.DealID
+	Size       abi.UnpaddedPieceSize
+	StartEpoch abi.ChainEpoch
+	Added      time.Time
+}
+
+type PackingStatus struct {
+	Pieces []PackingPiece
+
+	// Filled is the padded size of the waiting pieces
+	Filled     abi.PaddedPieceSize
+	SectorSize abi.SectorSize
+
+	// Waiting deals are released at Deadline, or at epoch SealBy to be sealed
+	// before the earliest deal starts
+	Deadline time.Time
+	SealBy   abi.ChainEpoch
+}
+
 type SealedRef struct {
 	SectorID abi.SectorNumber
 	Offset   uint64
@@ -2,6 +2,7 @@ package api
 
 import (
 	"context"
+	"time"
 
 	"github.com/filecoin-project/specs-storage/storage"
 
@@ -11,6 +12,9 @@ import (
 	"github.com/filecoin-project/sector-storage/stores"
 )
 
+// WorkerHeartbeatInterval is how often workers send heartbeats to the miner
+const WorkerHeartbeatInterval = 10 * time.Second
+
 type WorkerApi interface {
 	Version(context.Context) (build.Version, error)
 	// TODO: Info() (name, ...) ?
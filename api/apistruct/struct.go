@@ -4,8 +4,10 @@ import (
 	"context"
 
 	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p-core/metrics"
 	"github.com/libp2p/go-libp2p-core/network"
 	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/protocol"
 
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-fil-markets/storagemarket"
@@ -24,6 +26,8 @@ import (
 	"github.com/filecoin-project/lotus/build"
 	"github.com/filecoin-project/lotus/chain/store"
 	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/lib/sigs"
+	"github.com/filecoin-project/lotus/node/config"
 	"github.com/filecoin-project/lotus/node/modules/dtypes"
 	sealing "github.com/filecoin-project/storage-fsm"
 )
@@ -42,6 +46,15 @@ type CommonStruct struct {
 		NetAddrsListen   func(context.Context) (peer.AddrInfo, error)                  `perm:"read"`
 		NetDisconnect    func(context.Context, peer.ID) error                          `perm:"write"`
 		NetFindPeer      func(context.Context, peer.ID) (peer.AddrInfo, error)         `perm:"read"`
+		NetPubsubScores  func(context.Context) ([]api.PubsubScore, error)              `perm:"read"`
+		NetPeerInfo      func(context.Context, peer.ID) (*api.ExtendedPeerInfo, error) `perm:"read"`
+		NetBlockAdd      func(context.Context, api.NetBlockList) error                 `perm:"admin"`
+		NetBlockRemove   func(context.Context, api.NetBlockList) error                 `perm:"admin"`
+		NetBlockList     func(context.Context) (api.NetBlockList, error)               `perm:"read"`
+
+		NetBandwidthStats           func(ctx context.Context) (metrics.Stats, error)                 `perm:"read"`
+		NetBandwidthStatsByPeer     func(ctx context.Context) (map[string]metrics.Stats, error)      `perm:"read"`
+		NetBandwidthStatsByProtocol func(ctx context.Context) (map[protocol.ID]metrics.Stats, error) `perm:"read"`
 
 		ID      func(context.Context) (peer.ID, error)     `perm:"read"`
 		Version func(context.Context) (api.Version, error) `perm:"read"`
@@ -81,27 +94,45 @@ type FullNodeStruct struct {
 		SyncIncomingBlocks func(ctx context.Context) (<-chan *types.BlockHeader, error) `perm:"read"`
 		SyncMarkBad        func(ctx context.Context, bcid cid.Cid) error                `perm:"admin"`
 		SyncCheckBad       func(ctx context.Context, bcid cid.Cid) (string, error)      `perm:"read"`
-
-		MpoolPending     func(context.Context, types.TipSetKey) ([]*types.SignedMessage, error) `perm:"read"`
-		MpoolPush        func(context.Context, *types.SignedMessage) (cid.Cid, error)           `perm:"write"`
-		MpoolPushMessage func(context.Context, *types.Message) (*types.SignedMessage, error)    `perm:"sign"`
-		MpoolGetNonce    func(context.Context, address.Address) (uint64, error)                 `perm:"read"`
-		MpoolSub         func(context.Context) (<-chan api.MpoolUpdate, error)                  `perm:"read"`
+		SyncDebugState     func(context.Context) (*api.SyncDebugState, error)           `perm:"read"`
+		SyncPause          func(context.Context) error                                  `perm:"admin"`
+		SyncResume         func(context.Context) error                                  `perm:"admin"`
+		SyncForceTarget    func(context.Context, peer.ID) (types.TipSetKey, error)      `perm:"admin"`
+		SyncSetWorkers     func(ctx context.Context, count int) error                   `perm:"admin"`
+
+		MpoolPending     func(context.Context, types.TipSetKey) ([]*types.SignedMessage, error)              `perm:"read"`
+		MpoolPush        func(context.Context, *types.SignedMessage) (cid.Cid, error)                        `perm:"write"`
+		MpoolPushMessage func(context.Context, *types.Message) (*types.SignedMessage, error)                 `perm:"sign"`
+		MpoolGetNonce    func(context.Context, address.Address) (uint64, error)                              `perm:"read"`
+		MpoolSub         func(context.Context) (<-chan api.MpoolUpdate, error)                               `perm:"read"`
+		MpoolReplace     func(context.Context, address.Address, uint64, bool, types.BigInt) (cid.Cid, error) `perm:"sign"`
+		MpoolCancel      func(context.Context, address.Address, uint64) (cid.Cid, error)                     `perm:"sign"`
 
 		MinerGetBaseInfo func(context.Context, address.Address, types.TipSetKey) (*api.MiningBaseInfo, error) `perm:"read"`
 		MinerCreateBlock func(context.Context, *api.BlockTemplate) (*types.BlockMsg, error)                   `perm:"write"`
 
-		WalletNew            func(context.Context, crypto.SigType) (address.Address, error)                       `perm:"write"`
-		WalletHas            func(context.Context, address.Address) (bool, error)                                 `perm:"write"`
-		WalletList           func(context.Context) ([]address.Address, error)                                     `perm:"write"`
-		WalletBalance        func(context.Context, address.Address) (types.BigInt, error)                         `perm:"read"`
-		WalletSign           func(context.Context, address.Address, []byte) (*crypto.Signature, error)            `perm:"sign"`
-		WalletSignMessage    func(context.Context, address.Address, *types.Message) (*types.SignedMessage, error) `perm:"sign"`
-		WalletVerify         func(context.Context, address.Address, []byte, *crypto.Signature) bool               `perm:"read"`
-		WalletDefaultAddress func(context.Context) (address.Address, error)                                       `perm:"write"`
-		WalletSetDefault     func(context.Context, address.Address) error                                         `perm:"admin"`
-		WalletExport         func(context.Context, address.Address) (*types.KeyInfo, error)                       `perm:"admin"`
-		WalletImport         func(context.Context, *types.KeyInfo) (address.Address, error)                       `perm:"admin"`
+		WalletNew            func(context.Context, crypto.SigType) (address.Address, error)                                `perm:"write"`
+		WalletHas            func(context.Context, address.Address) (bool, error)                                          `perm:"write"`
+		WalletList           func(context.Context) ([]api.WalletAddress, error)                                            `perm:"write"`
+		WalletBalance        func(context.Context, address.Address) (types.BigInt, error)                                  `perm:"read"`
+		WalletSign           func(context.Context, address.Address, []byte) (*crypto.Signature, error)                     `perm:"sign"`
+		WalletSignMessage    func(context.Context, address.Address, *types.Message) (*types.SignedMessage, error)          `perm:"sign"`
+		WalletVerify         func(context.Context, address.Address, []byte, *crypto.Signature) bool                        `perm:"read"`
+		WalletDefaultAddress func(context.Context) (address.Address, error)                                                `perm:"write"`
+		WalletSetDefault     func(context.Context, address.Address) error                                                  `perm:"admin"`
+		WalletExport         func(context.Context, address.Address) (*types.KeyInfo, error)                                `perm:"admin"`
+		WalletImport         func(context.Context, *types.KeyInfo) (address.Address, error)                                `perm:"admin"`
+		WalletSignData       func(context.Context, address.Address, string, []byte) (*sigs.SignedData, error)              `perm:"sign"`
+		WalletDelete         func(context.Context, address.Address) error                                                  `perm:"admin"`
+		WalletUndelete       func(context.Context, address.Address) error                                                  `perm:"admin"`
+		WalletSetLabel       func(context.Context, address.Address, string) error                                          `perm:"write"`
+		WalletSetTags        func(context.Context, address.Address, []string) error                                        `perm:"write"`
+		WalletAddWatch       func(context.Context, address.Address, string) error                                          `perm:"write"`
+		WalletHistory        func(context.Context, address.Address, abi.ChainEpoch, int) ([]api.WalletHistoryEntry, error) `perm:"read"`
+		WalletHDNew          func(context.Context, crypto.SigType, string) (*api.HDNewResult, error)                       `perm:"admin"`
+		WalletHDRestore      func(context.Context, string, string, int, int) ([]address.Address, error)                    `perm:"admin"`
+		WalletHDDerive       func(context.Context, crypto.SigType, int64, string) (address.Address, error)                 `perm:"admin"`
+		WalletHDUnlock       func(context.Context, string) error                                                           `perm:"admin"`
 
 		ClientImport      func(ctx context.Context, ref api.FileRef) (cid.Cid, error)                                          `perm:"admin"`
 		ClientListImports func(ctx context.Context) ([]api.Import, error)                                                      `perm:"write"`
@@ -144,14 +175,28 @@ type FullNodeStruct struct {
 		StateListRewards         func(context.Context, address.Address, types.TipSetKey) ([]reward.Reward, error)                                    `perm:"read"`
 		StateCompute             func(context.Context, abi.ChainEpoch, []*types.Message, types.TipSetKey) (*api.ComputeStateOutput, error)           `perm:"read"`
 
-		MsigGetAvailableBalance func(context.Context, address.Address, types.TipSetKey) (types.BigInt, error) `perm:"read"`
-
-		MarketEnsureAvailable func(context.Context, address.Address, address.Address, types.BigInt) error `perm:"sign"`
+		MsigGetAvailableBalance func(context.Context, address.Address, types.TipSetKey) (types.BigInt, error)                                           `perm:"read"`
+		MsigGetPending          func(context.Context, address.Address, types.TipSetKey) ([]*api.MsigTransaction, error)                                 `perm:"read"`
+		MsigCreate              func(context.Context, int64, []address.Address, types.BigInt, address.Address) (cid.Cid, error)                         `perm:"sign"`
+		MsigPropose             func(context.Context, address.Address, address.Address, types.BigInt, address.Address, uint64, []byte) (cid.Cid, error) `perm:"sign"`
+		MsigApprove             func(context.Context, address.Address, int64, address.Address) (cid.Cid, error)                                         `perm:"sign"`
+		MsigCancel              func(context.Context, address.Address, int64, address.Address) (cid.Cid, error)                                         `perm:"sign"`
+		MsigAddSigner           func(context.Context, address.Address, address.Address, address.Address, bool) (cid.Cid, error)                         `perm:"sign"`
+		MsigRemoveSigner        func(context.Context, address.Address, address.Address, address.Address, bool) (cid.Cid, error)                         `perm:"sign"`
+		MsigSwapSigner          func(context.Context, address.Address, address.Address, address.Address, address.Address) (cid.Cid, error)              `perm:"sign"`
+		MsigChangeThreshold     func(context.Context, address.Address, address.Address, int64) (cid.Cid, error)                                         `perm:"sign"`
+
+		MarketEnsureAvailable func(context.Context, cid.Cid, address.Address, address.Address, types.BigInt) error   `perm:"sign"`
+		MarketReleaseFunds    func(context.Context, cid.Cid) error                                                   `perm:"sign"`
+		MarketGetReserved     func(context.Context, address.Address) (types.BigInt, error)                           `perm:"read"`
+		MarketWithdraw        func(context.Context, address.Address, address.Address, types.BigInt) (cid.Cid, error) `perm:"sign"`
 
 		PaychGet                   func(ctx context.Context, from, to address.Address, ensureFunds types.BigInt) (*api.ChannelInfo, error)   `perm:"sign"`
 		PaychList                  func(context.Context) ([]address.Address, error)                                                          `perm:"read"`
 		PaychStatus                func(context.Context, address.Address) (*api.PaychStatus, error)                                          `perm:"read"`
+		PaychSettle                func(context.Context, address.Address) (cid.Cid, error)                                                   `perm:"sign"`
 		PaychClose                 func(context.Context, address.Address) (cid.Cid, error)                                                   `perm:"sign"`
+		PaychCollect               func(context.Context, address.Address) (cid.Cid, error)                                                   `perm:"sign"`
 		PaychAllocateLane          func(context.Context, address.Address) (uint64, error)                                                    `perm:"sign"`
 		PaychNewPayment            func(ctx context.Context, from, to address.Address, vouchers []api.VoucherSpec) (*api.PaymentInfo, error) `perm:"sign"`
 		PaychVoucherCheck          func(context.Context, *paych.SignedVoucher) error                                                         `perm:"read"`
@@ -180,19 +225,40 @@ type StorageMinerStruct struct {
 		MarketListIncompleteDeals func(ctx context.Context) ([]storagemarket.MinerDeal, error)   `perm:"read"`
 		MarketSetPrice            func(context.Context, types.BigInt) error                      `perm:"admin"`
 
-		PledgeSector func(context.Context) error `perm:"write"`
-
-		SectorsStatus func(context.Context, abi.SectorNumber) (api.SectorInfo, error)    `perm:"read"`
-		SectorsList   func(context.Context) ([]abi.SectorNumber, error)                  `perm:"read"`
-		SectorsRefs   func(context.Context) (map[string][]api.SealedRef, error)          `perm:"read"`
-		SectorsUpdate func(context.Context, abi.SectorNumber, sealing.SectorState) error `perm:"write"`
-
-		WorkerConnect func(context.Context, string) error                                 `perm:"admin"` // TODO: worker perm
-		WorkerStats   func(context.Context) (map[uint64]sectorstorage.WorkerStats, error) `perm:"admin"`
+		PledgeSector    func(context.Context) error                        `perm:"write"`
+		PledgeGetConfig func(context.Context) (config.PledgeConfig, error) `perm:"read"`
+		PledgeSetConfig func(context.Context, config.PledgeConfig) error   `perm:"admin"`
+		PledgeStatus    func(context.Context) (api.PledgeStatus, error)    `perm:"read"`
+
+		SectorsStatus        func(context.Context, abi.SectorNumber) (api.SectorInfo, error)              `perm:"read"`
+		SectorsList          func(context.Context) ([]abi.SectorNumber, error)                            `perm:"read"`
+		SectorsRefs          func(context.Context) (map[string][]api.SealedRef, error)                    `perm:"read"`
+		SectorsUpdate        func(context.Context, abi.SectorNumber, sealing.SectorState) error           `perm:"write"`
+		SectorsPackingStatus func(context.Context) (api.PackingStatus, error)                             `perm:"read"`
+		SectorsSealNow       func(context.Context) error                                                  `perm:"write"`
+		SectorsCheckHealth   func(context.Context, []abi.SectorNumber, bool) ([]api.SectorHealth, error)  `perm:"admin"`
+		SectorsRemove        func(context.Context, abi.SectorNumber, bool) error                          `perm:"admin"`
+		SectorsExtend        func(context.Context, []abi.SectorNumber, abi.ChainEpoch) ([]cid.Cid, error) `perm:"admin"`
+		SectorsTerminate     func(context.Context, []abi.SectorNumber, bool) (cid.Cid, error)             `perm:"admin"`
+		SectorsMove          func(context.Context, abi.SectorNumber, stores.ID) error                     `perm:"admin"`
+		SectorsMoves         func(context.Context) ([]api.SectorMove, error)                              `perm:"read"`
+
+		ProvingStatus  func(context.Context) (*api.ProvingStatus, error)    `perm:"read"`
+		ProvingHistory func(context.Context, int) ([]api.PoStRecord, error) `perm:"read"`
+		ProvingDryRun  func(context.Context) (*api.PoStRecord, error)       `perm:"admin"`
+
+		WorkerConnect   func(context.Context, string) error                                 `perm:"admin"` // TODO: worker perm
+		WorkerStats     func(context.Context) (map[uint64]sectorstorage.WorkerStats, error) `perm:"admin"`
+		WorkerJobs      func(context.Context) (map[string][]api.WorkerJob, error)           `perm:"admin"`
+		WorkerHeartbeat func(context.Context, string) (bool, error)                         `perm:"admin"`
+		WorkerDrain     func(context.Context, string, bool) error                           `perm:"admin"`
+		WorkerStates    func(context.Context) (map[string]api.WorkerState, error)           `perm:"admin"`
 
 		StorageList          func(context.Context) (map[stores.ID][]stores.Decl, error)                                            `perm:"admin"`
 		StorageLocal         func(context.Context) (map[stores.ID]string, error)                                                   `perm:"admin"`
 		StorageStat          func(context.Context, stores.ID) (stores.FsStat, error)                                               `perm:"admin"`
+		StorageDetach        func(context.Context, stores.ID) error                                                                `perm:"admin"`
+		StorageSetConfig     func(context.Context, stores.ID, api.StoragePathConfig) error                                         `perm:"admin"`
 		StorageAttach        func(context.Context, stores.StorageInfo, stores.FsStat) error                                        `perm:"admin"`
 		StorageDeclareSector func(context.Context, stores.ID, abi.SectorID, stores.SectorFileType) error                           `perm:"admin"`
 		StorageDropSector    func(context.Context, stores.ID, abi.SectorID, stores.SectorFileType) error                           `perm:"admin"`
@@ -202,6 +268,8 @@ type StorageMinerStruct struct {
 
 		DealsImportData func(ctx context.Context, dealPropCid cid.Cid, file string) error `perm:"write"`
 		DealsList       func(ctx context.Context) ([]storagemarket.StorageDeal, error)    `perm:"read"`
+		DealsGetPolicy  func(context.Context) (config.DealPolicy, error)                  `perm:"read"`
+		DealsSetPolicy  func(context.Context, config.DealPolicy) error                    `perm:"admin"`
 
 		StorageAddLocal func(ctx context.Context, path string) error `perm:"admin"`
 	}
@@ -257,6 +325,38 @@ func (c *CommonStruct) NetFindPeer(ctx context.Context, p peer.ID) (peer.AddrInf
 	return c.Internal.NetFindPeer(ctx, p)
 }
 
+func (c *CommonStruct) NetPubsubScores(ctx context.Context) ([]api.PubsubScore, error) {
+	return c.Internal.NetPubsubScores(ctx)
+}
+
+func (c *CommonStruct) NetPeerInfo(ctx context.Context, p peer.ID) (*api.ExtendedPeerInfo, error) {
+	return c.Internal.NetPeerInfo(ctx, p)
+}
+
+func (c *CommonStruct) NetBlockAdd(ctx context.Context, acl api.NetBlockList) error {
+	return c.Internal.NetBlockAdd(ctx, acl)
+}
+
+func (c *CommonStruct) NetBlockRemove(ctx context.Context, acl api.NetBlockList) error {
+	return c.Internal.NetBlockRemove(ctx, acl)
+}
+
+func (c *CommonStruct) NetBlockList(ctx context.Context) (api.NetBlockList, error) {
+	return c.Internal.NetBlockList(ctx)
+}
+
+func (c *CommonStruct) NetBandwidthStats(ctx context.Context) (metrics.Stats, error) {
+	return c.Internal.NetBandwidthStats(ctx)
+}
+
+func (c *CommonStruct) NetBandwidthStatsByPeer(ctx context.Context) (map[string]metrics.Stats, error) {
+	return c.Internal.NetBandwidthStatsByPeer(ctx)
+}
+
+func (c *CommonStruct) NetBandwidthStatsByProtocol(ctx context.Context) (map[protocol.ID]metrics.Stats, error) {
+	return c.Internal.NetBandwidthStatsByProtocol(ctx)
+}
+
 // ID implements API.ID
 func (c *CommonStruct) ID(ctx context.Context) (peer.ID, error) {
 	return c.Internal.ID(ctx)
@@ -326,6 +426,14 @@ func (c *FullNodeStruct) MpoolSub(ctx context.Context) (<-chan api.MpoolUpdate,
 	return c.Internal.MpoolSub(ctx)
 }
 
+func (c *FullNodeStruct) MpoolReplace(ctx context.Context, from address.Address, nonce uint64, auto bool, gasPrice types.BigInt) (cid.Cid, error) {
+	return c.Internal.MpoolReplace(ctx, from, nonce, auto, gasPrice)
+}
+
+func (c *FullNodeStruct) MpoolCancel(ctx context.Context, from address.Address, nonce uint64) (cid.Cid, error) {
+	return c.Internal.MpoolCancel(ctx, from, nonce)
+}
+
 func (c *FullNodeStruct) MinerGetBaseInfo(ctx context.Context, maddr address.Address, tsk types.TipSetKey) (*api.MiningBaseInfo, error) {
 	return c.Internal.MinerGetBaseInfo(ctx, maddr, tsk)
 }
@@ -354,7 +462,7 @@ func (c *FullNodeStruct) WalletHas(ctx context.Context, addr address.Address) (b
 	return c.Internal.WalletHas(ctx, addr)
 }
 
-func (c *FullNodeStruct) WalletList(ctx context.Context) ([]address.Address, error) {
+func (c *FullNodeStruct) WalletList(ctx context.Context) ([]api.WalletAddress, error) {
 	return c.Internal.WalletList(ctx)
 }
 
@@ -390,6 +498,50 @@ func (c *FullNodeStruct) WalletImport(ctx context.Context, ki *types.KeyInfo) (a
 	return c.Internal.WalletImport(ctx, ki)
 }
 
+func (c *FullNodeStruct) WalletSignData(ctx context.Context, addr address.Address, purpose string, payload []byte) (*sigs.SignedData, error) {
+	return c.Internal.WalletSignData(ctx, addr, purpose, payload)
+}
+
+func (c *FullNodeStruct) WalletDelete(ctx context.Context, addr address.Address) error {
+	return c.Internal.WalletDelete(ctx, addr)
+}
+
+func (c *FullNodeStruct) WalletUndelete(ctx context.Context, addr address.Address) error {
+	return c.Internal.WalletUndelete(ctx, addr)
+}
+
+func (c *FullNodeStruct) WalletSetLabel(ctx context.Context, addr address.Address, label string) error {
+	return c.Internal.WalletSetLabel(ctx, addr, label)
+}
+
+func (c *FullNodeStruct) WalletSetTags(ctx context.Context, addr address.Address, tags []string) error {
+	return c.Internal.WalletSetTags(ctx, addr, tags)
+}
+
+func (c *FullNodeStruct) WalletAddWatch(ctx context.Context, addr address.Address, label string) error {
+	return c.Internal.WalletAddWatch(ctx, addr, label)
+}
+
+func (c *FullNodeStruct) WalletHistory(ctx context.Context, addr address.Address, fromHeight abi.ChainEpoch, limit int) ([]api.WalletHistoryEntry, error) {
+	return c.Internal.WalletHistory(ctx, addr, fromHeight, limit)
+}
+
+func (c *FullNodeStruct) WalletHDNew(ctx context.Context, typ crypto.SigType, passphrase string) (*api.HDNewResult, error) {
+	return c.Internal.WalletHDNew(ctx, typ, passphrase)
+}
+
+func (c *FullNodeStruct) WalletHDRestore(ctx context.Context, mnemonic string, passphrase string, secpCount, blsCount int) ([]address.Address, error) {
+	return c.Internal.WalletHDRestore(ctx, mnemonic, passphrase, secpCount, blsCount)
+}
+
+func (c *FullNodeStruct) WalletHDDerive(ctx context.Context, typ crypto.SigType, index int64, passphrase string) (address.Address, error) {
+	return c.Internal.WalletHDDerive(ctx, typ, index, passphrase)
+}
+
+func (c *FullNodeStruct) WalletHDUnlock(ctx context.Context, passphrase string) error {
+	return c.Internal.WalletHDUnlock(ctx, passphrase)
+}
+
 func (c *FullNodeStruct) MpoolGetNonce(ctx context.Context, addr address.Address) (uint64, error) {
 	return c.Internal.MpoolGetNonce(ctx, addr)
 }
@@ -478,6 +630,26 @@ func (c *FullNodeStruct) SyncCheckBad(ctx context.Context, bcid cid.Cid) (string
 	return c.Internal.SyncCheckBad(ctx, bcid)
 }
 
+func (c *FullNodeStruct) SyncDebugState(ctx context.Context) (*api.SyncDebugState, error) {
+	return c.Internal.SyncDebugState(ctx)
+}
+
+func (c *FullNodeStruct) SyncPause(ctx context.Context) error {
+	return c.Internal.SyncPause(ctx)
+}
+
+func (c *FullNodeStruct) SyncResume(ctx context.Context) error {
+	return c.Internal.SyncResume(ctx)
+}
+
+func (c *FullNodeStruct) SyncForceTarget(ctx context.Context, p peer.ID) (types.TipSetKey, error) {
+	return c.Internal.SyncForceTarget(ctx, p)
+}
+
+func (c *FullNodeStruct) SyncSetWorkers(ctx context.Context, count int) error {
+	return c.Internal.SyncSetWorkers(ctx, count)
+}
+
 func (c *FullNodeStruct) StateNetworkName(ctx context.Context) (dtypes.NetworkName, error) {
 	return c.Internal.StateNetworkName(ctx)
 }
@@ -598,8 +770,56 @@ func (c *FullNodeStruct) MsigGetAvailableBalance(ctx context.Context, a address.
 	return c.Internal.MsigGetAvailableBalance(ctx, a, tsk)
 }
 
-func (c *FullNodeStruct) MarketEnsureAvailable(ctx context.Context, addr, wallet address.Address, amt types.BigInt) error {
-	return c.Internal.MarketEnsureAvailable(ctx, addr, wallet, amt)
+func (c *FullNodeStruct) MsigGetPending(ctx context.Context, a address.Address, tsk types.TipSetKey) ([]*api.MsigTransaction, error) {
+	return c.Internal.MsigGetPending(ctx, a, tsk)
+}
+
+func (c *FullNodeStruct) MsigCreate(ctx context.Context, req int64, addrs []address.Address, val types.BigInt, src address.Address) (cid.Cid, error) {
+	return c.Internal.MsigCreate(ctx, req, addrs, val, src)
+}
+
+func (c *FullNodeStruct) MsigPropose(ctx context.Context, msig address.Address, to address.Address, amt types.BigInt, src address.Address, method uint64, params []byte) (cid.Cid, error) {
+	return c.Internal.MsigPropose(ctx, msig, to, amt, src, method, params)
+}
+
+func (c *FullNodeStruct) MsigApprove(ctx context.Context, msig address.Address, txID int64, src address.Address) (cid.Cid, error) {
+	return c.Internal.MsigApprove(ctx, msig, txID, src)
+}
+
+func (c *FullNodeStruct) MsigCancel(ctx context.Context, msig address.Address, txID int64, src address.Address) (cid.Cid, error) {
+	return c.Internal.MsigCancel(ctx, msig, txID, src)
+}
+
+func (c *FullNodeStruct) MsigAddSigner(ctx context.Context, msig address.Address, src address.Address, signer address.Address, increase bool) (cid.Cid, error) {
+	return c.Internal.MsigAddSigner(ctx, msig, src, signer, increase)
+}
+
+func (c *FullNodeStruct) MsigRemoveSigner(ctx context.Context, msig address.Address, src address.Address, signer address.Address, decrease bool) (cid.Cid, error) {
+	return c.Internal.MsigRemoveSigner(ctx, msig, src, signer, decrease)
+}
+
+func (c *FullNodeStruct) MsigSwapSigner(ctx context.Context, msig address.Address, src address.Address, oldSigner address.Address, newSigner address.Address) (cid.Cid, error) {
+	return c.Internal.MsigSwapSigner(ctx, msig, src, oldSigner, newSigner)
+}
+
+func (c *FullNodeStruct) MsigChangeThreshold(ctx context.Context, msig address.Address, src address.Address, threshold int64) (cid.Cid, error) {
+	return c.Internal.MsigChangeThreshold(ctx, msig, src, threshold)
+}
+
+func (c *FullNodeStruct) MarketEnsureAvailable(ctx context.Context, deal cid.Cid, addr, wallet address.Address, amt types.BigInt) error {
+	return c.Internal.MarketEnsureAvailable(ctx, deal, addr, wallet, amt)
+}
+
+func (c *FullNodeStruct) MarketReleaseFunds(ctx context.Context, deal cid.Cid) error {
+	return c.Internal.MarketReleaseFunds(ctx, deal)
+}
+
+func (c *FullNodeStruct) MarketGetReserved(ctx context.Context, addr address.Address) (types.BigInt, error) {
+	return c.Internal.MarketGetReserved(ctx, addr)
+}
+
+func (c *FullNodeStruct) MarketWithdraw(ctx context.Context, wallet, addr address.Address, amt types.BigInt) (cid.Cid, error) {
+	return c.Internal.MarketWithdraw(ctx, wallet, addr, amt)
 }
 
 func (c *FullNodeStruct) PaychGet(ctx context.Context, from, to address.Address, ensureFunds types.BigInt) (*api.ChannelInfo, error) {
@@ -634,10 +854,18 @@ func (c *FullNodeStruct) PaychVoucherList(ctx context.Context, pch address.Addre
 	return c.Internal.PaychVoucherList(ctx, pch)
 }
 
+func (c *FullNodeStruct) PaychSettle(ctx context.Context, a address.Address) (cid.Cid, error) {
+	return c.Internal.PaychSettle(ctx, a)
+}
+
 func (c *FullNodeStruct) PaychClose(ctx context.Context, a address.Address) (cid.Cid, error) {
 	return c.Internal.PaychClose(ctx, a)
 }
 
+func (c *FullNodeStruct) PaychCollect(ctx context.Context, a address.Address) (cid.Cid, error) {
+	return c.Internal.PaychCollect(ctx, a)
+}
+
 func (c *FullNodeStruct) PaychAllocateLane(ctx context.Context, ch address.Address) (uint64, error) {
 	return c.Internal.PaychAllocateLane(ctx, ch)
 }
@@ -662,6 +890,18 @@ func (c *StorageMinerStruct) PledgeSector(ctx context.Context) error {
 	return c.Internal.PledgeSector(ctx)
 }
 
+func (c *StorageMinerStruct) PledgeGetConfig(ctx context.Context) (config.PledgeConfig, error) {
+	return c.Internal.PledgeGetConfig(ctx)
+}
+
+func (c *StorageMinerStruct) PledgeSetConfig(ctx context.Context, cfg config.PledgeConfig) error {
+	return c.Internal.PledgeSetConfig(ctx, cfg)
+}
+
+func (c *StorageMinerStruct) PledgeStatus(ctx context.Context) (api.PledgeStatus, error) {
+	return c.Internal.PledgeStatus(ctx)
+}
+
 // Get the status of a given sector by ID
 func (c *StorageMinerStruct) SectorsStatus(ctx context.Context, sid abi.SectorNumber) (api.SectorInfo, error) {
 	return c.Internal.SectorsStatus(ctx, sid)
@@ -680,6 +920,66 @@ func (c *StorageMinerStruct) SectorsUpdate(ctx context.Context, id abi.SectorNum
 	return c.Internal.SectorsUpdate(ctx, id, state)
 }
 
+func (c *StorageMinerStruct) SectorsPackingStatus(ctx context.Context) (api.PackingStatus, error) {
+	return c.Internal.SectorsPackingStatus(ctx)
+}
+
+func (c *StorageMinerStruct) SectorsSealNow(ctx context.Context) error {
+	return c.Internal.SectorsSealNow(ctx)
+}
+
+func (c *StorageMinerStruct) WorkerJobs(ctx context.Context) (map[string][]api.WorkerJob, error) {
+	return c.Internal.WorkerJobs(ctx)
+}
+
+func (c *StorageMinerStruct) WorkerHeartbeat(ctx context.Context, url string) (bool, error) {
+	return c.Internal.WorkerHeartbeat(ctx, url)
+}
+
+func (c *StorageMinerStruct) WorkerDrain(ctx context.Context, url string, drain bool) error {
+	return c.Internal.WorkerDrain(ctx, url, drain)
+}
+
+func (c *StorageMinerStruct) WorkerStates(ctx context.Context) (map[string]api.WorkerState, error) {
+	return c.Internal.WorkerStates(ctx)
+}
+
+func (c *StorageMinerStruct) SectorsCheckHealth(ctx context.Context, sectors []abi.SectorNumber, sample bool) ([]api.SectorHealth, error) {
+	return c.Internal.SectorsCheckHealth(ctx, sectors, sample)
+}
+
+func (c *StorageMinerStruct) SectorsRemove(ctx context.Context, sector abi.SectorNumber, force bool) error {
+	return c.Internal.SectorsRemove(ctx, sector, force)
+}
+
+func (c *StorageMinerStruct) SectorsExtend(ctx context.Context, sectors []abi.SectorNumber, newExpiration abi.ChainEpoch) ([]cid.Cid, error) {
+	return c.Internal.SectorsExtend(ctx, sectors, newExpiration)
+}
+
+func (c *StorageMinerStruct) SectorsTerminate(ctx context.Context, sectors []abi.SectorNumber, force bool) (cid.Cid, error) {
+	return c.Internal.SectorsTerminate(ctx, sectors, force)
+}
+
+func (c *StorageMinerStruct) SectorsMove(ctx context.Context, sector abi.SectorNumber, dest stores.ID) error {
+	return c.Internal.SectorsMove(ctx, sector, dest)
+}
+
+func (c *StorageMinerStruct) SectorsMoves(ctx context.Context) ([]api.SectorMove, error) {
+	return c.Internal.SectorsMoves(ctx)
+}
+
+func (c *StorageMinerStruct) ProvingStatus(ctx context.Context) (*api.ProvingStatus, error) {
+	return c.Internal.ProvingStatus(ctx)
+}
+
+func (c *StorageMinerStruct) ProvingHistory(ctx context.Context, limit int) ([]api.PoStRecord, error) {
+	return c.Internal.ProvingHistory(ctx, limit)
+}
+
+func (c *StorageMinerStruct) ProvingDryRun(ctx context.Context) (*api.PoStRecord, error) {
+	return c.Internal.ProvingDryRun(ctx)
+}
+
 func (c *StorageMinerStruct) WorkerConnect(ctx context.Context, url string) error {
 	return c.Internal.WorkerConnect(ctx, url)
 }
@@ -716,6 +1016,14 @@ func (c *StorageMinerStruct) StorageStat(ctx context.Context, id stores.ID) (sto
 	return c.Internal.StorageStat(ctx, id)
 }
 
+func (c *StorageMinerStruct) StorageDetach(ctx context.Context, id stores.ID) error {
+	return c.Internal.StorageDetach(ctx, id)
+}
+
+func (c *StorageMinerStruct) StorageSetConfig(ctx context.Context, id stores.ID, cfg api.StoragePathConfig) error {
+	return c.Internal.StorageSetConfig(ctx, id, cfg)
+}
+
 func (c *StorageMinerStruct) StorageInfo(ctx context.Context, id stores.ID) (stores.StorageInfo, error) {
 	return c.Internal.StorageInfo(ctx, id)
 }
@@ -748,6 +1056,14 @@ func (c *StorageMinerStruct) DealsList(ctx context.Context) ([]storagemarket.Sto
 	return c.Internal.DealsList(ctx)
 }
 
+func (c *StorageMinerStruct) DealsGetPolicy(ctx context.Context) (config.DealPolicy, error) {
+	return c.Internal.DealsGetPolicy(ctx)
+}
+
+func (c *StorageMinerStruct) DealsSetPolicy(ctx context.Context, policy config.DealPolicy) error {
+	return c.Internal.DealsSetPolicy(ctx, policy)
+}
+
 func (c *StorageMinerStruct) StorageAddLocal(ctx context.Context, path string) error {
 	return c.Internal.StorageAddLocal(ctx, path)
 }
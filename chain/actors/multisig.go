@@ -0,0 +1,164 @@
+package actors
+
+import (
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	init_ "github.com/filecoin-project/specs-actors/actors/builtin/init"
+	samsig "github.com/filecoin-project/specs-actors/actors/builtin/multisig"
+
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// The functions below build unsigned multisig messages, with the nonce left
+// unset. They are shared by the node API, which signs and pushes them, and
+// by the CLI for offline signing.
+
+func MsigCreateMessage(src address.Address, signers []address.Address, required int64, value types.BigInt) (*types.Message, error) {
+	if len(signers) == 0 {
+		return nil, xerrors.Errorf("must provide at least one signer")
+	}
+
+	if required == 0 {
+		required = int64(len(signers))
+	}
+
+	if required < 1 || required > int64(len(signers)) {
+		return nil, xerrors.Errorf("required approvals (%d) must be between 1 and the number of signers (%d)", required, len(signers))
+	}
+
+	enc, err := SerializeParams(&samsig.ConstructorParams{
+		Signers:               signers,
+		NumApprovalsThreshold: required,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// new actors are created by invoking 'exec' on the init actor with the constructor params
+	enc, err = SerializeParams(&init_.ExecParams{
+		CodeCID:           builtin.MultisigActorCodeID,
+		ConstructorParams: enc,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.Message{
+		To:       builtin.InitActorAddr,
+		From:     src,
+		Method:   builtin.MethodsInit.Exec,
+		Params:   enc,
+		GasPrice: types.NewInt(1),
+		GasLimit: 1000000,
+		Value:    value,
+	}, nil
+}
+
+func MsigProposeMessage(msig address.Address, src address.Address, to address.Address, value types.BigInt, method abi.MethodNum, params []byte) (*types.Message, error) {
+	enc, err := SerializeParams(&samsig.ProposeParams{
+		To:     to,
+		Value:  abi.TokenAmount(value),
+		Method: method,
+		Params: params,
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("failed to serialize propose params: %w", err)
+	}
+
+	return msigMessage(msig, src, builtin.MethodsMultisig.Propose, enc)
+}
+
+func MsigApproveMessage(msig address.Address, src address.Address, txID int64) (*types.Message, error) {
+	enc, err := SerializeParams(&samsig.TxnIDParams{
+		ID: samsig.TxnID(txID),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return msigMessage(msig, src, builtin.MethodsMultisig.Approve, enc)
+}
+
+func MsigCancelMessage(msig address.Address, src address.Address, txID int64) (*types.Message, error) {
+	enc, err := SerializeParams(&samsig.TxnIDParams{
+		ID: samsig.TxnID(txID),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return msigMessage(msig, src, builtin.MethodsMultisig.Cancel, enc)
+}
+
+// Signer and threshold changes must be made by the multisig itself, so the
+// messages below propose a transaction from the multisig to itself
+
+func MsigAddSignerMessage(msig address.Address, src address.Address, signer address.Address, increase bool) (*types.Message, error) {
+	enc, err := SerializeParams(&samsig.AddSignerParams{
+		Signer:   signer,
+		Increase: increase,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return MsigProposeMessage(msig, src, msig, types.NewInt(0), builtin.MethodsMultisig.AddSigner, enc)
+}
+
+func MsigRemoveSignerMessage(msig address.Address, src address.Address, signer address.Address, decrease bool) (*types.Message, error) {
+	enc, err := SerializeParams(&samsig.RemoveSignerParams{
+		Signer:   signer,
+		Decrease: decrease,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return MsigProposeMessage(msig, src, msig, types.NewInt(0), builtin.MethodsMultisig.RemoveSigner, enc)
+}
+
+func MsigSwapSignerMessage(msig address.Address, src address.Address, oldSigner address.Address, newSigner address.Address) (*types.Message, error) {
+	enc, err := SerializeParams(&samsig.SwapSignerParams{
+		From: oldSigner,
+		To:   newSigner,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return MsigProposeMessage(msig, src, msig, types.NewInt(0), builtin.MethodsMultisig.SwapSigner, enc)
+}
+
+func MsigChangeThresholdMessage(msig address.Address, src address.Address, threshold int64) (*types.Message, error) {
+	if threshold < 1 {
+		return nil, xerrors.Errorf("threshold must be at least 1")
+	}
+
+	enc, err := SerializeParams(&samsig.ChangeNumApprovalsThresholdParams{
+		NewThreshold: threshold,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return MsigProposeMessage(msig, src, msig, types.NewInt(0), builtin.MethodsMultisig.ChangeNumApprovalsThreshold, enc)
+}
+
+func msigMessage(msig address.Address, src address.Address, method abi.MethodNum, params []byte) (*types.Message, error) {
+	if msig == address.Undef {
+		return nil, xerrors.Errorf("must provide multisig address")
+	}
+
+	return &types.Message{
+		To:       msig,
+		From:     src,
+		Value:    types.NewInt(0),
+		Method:   method,
+		Params:   params,
+		GasLimit: 100000,
+		GasPrice: types.NewInt(1),
+	}, nil
+}
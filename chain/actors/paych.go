@@ -0,0 +1,62 @@
+package actors
+
+import (
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/builtin/paych"
+
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// PaychUpdateMessage builds an unsigned message submitting a voucher to the
+// payment channel
+func PaychUpdateMessage(ch address.Address, from address.Address, sv *paych.SignedVoucher) (*types.Message, error) {
+	if sv.Extra != nil || len(sv.SecretPreimage) > 0 {
+		return nil, xerrors.Errorf("cant handle more advanced payment channel stuff yet")
+	}
+
+	enc, err := SerializeParams(&paych.UpdateChannelStateParams{
+		Sv: *sv,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.Message{
+		From:     from,
+		To:       ch,
+		Value:    types.NewInt(0),
+		Method:   builtin.MethodsPaych.UpdateChannelState,
+		Params:   enc,
+		GasLimit: 100000,
+		GasPrice: types.NewInt(0),
+	}, nil
+}
+
+// PaychSettleMessage builds an unsigned message starting the settlement
+// period of the payment channel
+func PaychSettleMessage(ch address.Address, from address.Address) *types.Message {
+	return &types.Message{
+		From:     from,
+		To:       ch,
+		Value:    types.NewInt(0),
+		Method:   builtin.MethodsPaych.Settle,
+		GasLimit: 100000,
+		GasPrice: types.NewInt(0),
+	}
+}
+
+// PaychCollectMessage builds an unsigned message paying out the payment
+// channel funds once the settlement period is over
+func PaychCollectMessage(ch address.Address, from address.Address) *types.Message {
+	return &types.Message{
+		From:     from,
+		To:       ch,
+		Value:    types.NewInt(0),
+		Method:   builtin.MethodsPaych.Collect,
+		GasLimit: 100000,
+		GasPrice: types.NewInt(0),
+	}
+}
@@ -80,7 +80,7 @@ func (bs *BlockSync) GetBlocks(ctx context.Context, tsk types.TipSetKey, count i
 		Options:       BSOptBlocks,
 	}
 
-	peers := bs.getPeers()
+	peers := bs.getPeers(peermgr.CapBlockSync)
 	// randomize the first few peers so we don't always pick the same peer
 	shufflePrefix(peers)
 
@@ -179,7 +179,7 @@ func (bs *BlockSync) GetChainMessages(ctx context.Context, h *types.TipSet, coun
 	ctx, span := trace.StartSpan(ctx, "GetChainMessages")
 	defer span.End()
 
-	peers := bs.getPeers()
+	peers := bs.getPeers(peermgr.CapBlockSyncMessages)
 	// randomize the first few peers so we don't always pick the same peer
 	shufflePrefix(peers)
 
@@ -356,8 +356,10 @@ func (bs *BlockSync) RemovePeer(p peer.ID) {
 	bs.syncPeers.removePeer(p)
 }
 
-func (bs *BlockSync) getPeers() []peer.ID {
-	return bs.syncPeers.prefSortedPeers()
+// getPeers returns sync peers which can serve requests needing the given
+// capabilities, best peers first
+func (bs *BlockSync) getPeers(caps peermgr.Capabilities) []peer.ID {
+	return bs.syncPeers.prefSortedPeers(caps)
 }
 
 func (bs *BlockSync) FetchMessagesByCids(ctx context.Context, cids []cid.Cid) ([]*types.Message, error) {
@@ -478,12 +480,15 @@ const (
 	newPeerMul = 0.9
 )
 
-func (bpt *bsPeerTracker) prefSortedPeers() []peer.ID {
+func (bpt *bsPeerTracker) prefSortedPeers(caps peermgr.Capabilities) []peer.ID {
 	// TODO: this could probably be cached, but as long as its not too many peers, fine for now
 	bpt.lk.Lock()
 	defer bpt.lk.Unlock()
 	out := make([]peer.ID, 0, len(bpt.peers))
 	for p := range bpt.peers {
+		if !bpt.canServe(p, caps) {
+			continue
+		}
 		out = append(out, p)
 	}
 
@@ -535,6 +540,21 @@ const (
 	globalInvAlpha = 20 // 86% of the value is the last 39
 )
 
+// canServe checks what the peer advertised in hello. Peers we haven't heard
+// a hello from yet are assumed to be capable.
+func (bpt *bsPeerTracker) canServe(p peer.ID, caps peermgr.Capabilities) bool {
+	if bpt.pmgr == nil {
+		return true
+	}
+
+	info, ok := bpt.pmgr.GetPeerInfo(p)
+	if !ok {
+		return true
+	}
+
+	return info.NodeType != peermgr.NodeLight && info.Capabilities.Has(caps)
+}
+
 func (bpt *bsPeerTracker) logGlobalSuccess(dur time.Duration) {
 	bpt.lk.Lock()
 	defer bpt.lk.Unlock()
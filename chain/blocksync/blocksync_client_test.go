@@ -0,0 +1,80 @@
+package blocksync
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+
+	"github.com/filecoin-project/lotus/lib/peermgr"
+)
+
+func TestPeerTrackerCapabilities(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	h, err := mocknet.New(ctx).GenPeer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pmgr := peermgr.NewPeerMgr(h, nil, nil)
+
+	full, light, headers, none, legacy, unknown := peer.ID("full"), peer.ID("light"), peer.ID("headers"), peer.ID("none"), peer.ID("legacy"), peer.ID("unknown")
+
+	pmgr.AddFilecoinPeerInfo(full, peermgr.PeerInfo{
+		HelloVersion: 2,
+		NodeType:     peermgr.NodeFull,
+		Capabilities: peermgr.CapBlockSync | peermgr.CapBlockSyncMessages,
+	})
+	pmgr.AddFilecoinPeerInfo(light, peermgr.PeerInfo{
+		HelloVersion: 2,
+		NodeType:     peermgr.NodeLight,
+		Capabilities: peermgr.CapBlockSync | peermgr.CapBlockSyncMessages,
+	})
+	pmgr.AddFilecoinPeerInfo(headers, peermgr.PeerInfo{
+		HelloVersion: 2,
+		NodeType:     peermgr.NodeFull,
+		Capabilities: peermgr.CapBlockSync,
+	})
+	pmgr.AddFilecoinPeerInfo(none, peermgr.PeerInfo{
+		HelloVersion: 2,
+		NodeType:     peermgr.NodeMiner,
+	})
+	pmgr.AddFilecoinPeer(legacy)
+
+	bpt := newPeerTracker(pmgr)
+	for _, p := range []peer.ID{full, light, headers, none, legacy, unknown} {
+		bpt.addPeer(p)
+	}
+
+	for _, c := range []struct {
+		caps   peermgr.Capabilities
+		expect []peer.ID
+	}{
+		// peers we haven't heard a hello from yet are tried
+		{peermgr.CapBlockSync, []peer.ID{full, headers, legacy, unknown}},
+		{peermgr.CapBlockSyncMessages, []peer.ID{full, legacy, unknown}},
+	} {
+		got := bpt.prefSortedPeers(c.caps)
+		sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+		sort.Slice(c.expect, func(i, j int) bool { return c.expect[i] < c.expect[j] })
+
+		if len(got) != len(c.expect) {
+			t.Errorf("%v: expected peers %v, got %v", c.caps.Strings(), c.expect, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != c.expect[i] {
+				t.Errorf("%v: expected peers %v, got %v", c.caps.Strings(), c.expect, got)
+				break
+			}
+		}
+	}
+
+	// without a peer manager all peers are tried
+	if !newPeerTracker(nil).canServe(none, peermgr.CapBlockSync) {
+		t.Error("expected peers to be usable without a peer manager")
+	}
+}
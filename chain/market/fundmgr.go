@@ -2,64 +2,205 @@ package market
 
 import (
 	"context"
+	"encoding/json"
 	"sync"
 
 	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/builtin/market"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
+	"github.com/ipfs/go-datastore/query"
 	logging "github.com/ipfs/go-log"
+	"go.uber.org/fx"
 	"golang.org/x/xerrors"
 
 	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/chain/actors"
 	"github.com/filecoin-project/lotus/chain/stmgr"
 	"github.com/filecoin-project/lotus/chain/types"
 	"github.com/filecoin-project/lotus/node/impl/full"
+	"github.com/filecoin-project/lotus/node/modules/dtypes"
+	"github.com/filecoin-project/lotus/node/modules/helpers"
 )
 
 var log = logging.Logger("market_adapter")
 
+// fundMgrState is the chain state access needed by FundMgr
+type fundMgrState interface {
+	MarketBalance(ctx context.Context, addr address.Address, ts *types.TipSet) (api.MarketBalance, error)
+	WaitForMessage(ctx context.Context, mcid cid.Cid) (*types.TipSet, *types.MessageReceipt, error)
+}
+
+type fundMgrMpool interface {
+	MpoolPushMessage(ctx context.Context, msg *types.Message) (*types.SignedMessage, error)
+}
+
+// FundMgr manages the market escrow of local addresses. Funds needed by deals
+// which weren't published yet are reserved, so that they aren't counted as
+// available for other deals, or withdrawn. Reservations are kept per deal
+// proposal and persisted. They are released once the deal is published, when
+// the funds get locked on chain, or when the deal fails.
 type FundMgr struct {
-	sm    *stmgr.StateManager
-	mpool full.MpoolAPI
+	ctx   context.Context
+	sm    fundMgrState
+	mpool fundMgrMpool
+	ds    datastore.Batching
 
-	lk        sync.Mutex
-	available map[address.Address]types.BigInt
+	lk       sync.Mutex
+	reserved map[cid.Cid]reservation
+	batches  map[address.Address]*addBatch
 }
 
-func NewFundMgr(sm *stmgr.StateManager, mpool full.MpoolAPI) *FundMgr {
-	return &FundMgr{
+type reservation struct {
+	Addr   address.Address
+	Amount types.BigInt
+}
+
+// addBatch collects the requests for an address which arrive while an
+// AddBalance message is in flight. They are then covered by a single message
+// per wallet.
+type addBatch struct {
+	waiters []addWaiter
+}
+
+type addWaiter struct {
+	wallet address.Address
+	done   chan error
+}
+
+func NewFundMgr(mctx helpers.MetricsCtx, lc fx.Lifecycle, sm *stmgr.StateManager, mpool full.MpoolAPI, ds dtypes.MetadataDS) (*FundMgr, error) {
+	return newFundMgr(helpers.LifecycleCtx(mctx, lc), sm, &mpool, ds)
+}
+
+func newFundMgr(ctx context.Context, sm fundMgrState, mpool fundMgrMpool, ds datastore.Batching) (*FundMgr, error) {
+	fm := &FundMgr{
+		ctx:   ctx,
 		sm:    sm,
 		mpool: mpool,
+		ds:    namespace.Wrap(ds, datastore.NewKey("/fundmgr/deals/")),
 
-		available: map[address.Address]types.BigInt{},
+		reserved: map[cid.Cid]reservation{},
+		batches:  map[address.Address]*addBatch{},
 	}
-}
 
-func (fm *FundMgr) EnsureAvailable(ctx context.Context, addr, wallet address.Address, amt types.BigInt) error {
-	fm.lk.Lock()
-	avail, ok := fm.available[addr]
-	if !ok {
-		bal, err := fm.sm.MarketBalance(ctx, addr, nil)
+	res, err := fm.ds.Query(query.Query{})
+	if err != nil {
+		return nil, xerrors.Errorf("querying reservations: %w", err)
+	}
+	defer res.Close() // nolint: errcheck
+
+	for r := range res.Next() {
+		if r.Error != nil {
+			return nil, xerrors.Errorf("loading reservations: %w", r.Error)
+		}
+
+		deal, err := cid.Decode(datastore.NewKey(r.Key).BaseNamespace())
 		if err != nil {
-			fm.lk.Unlock()
-			return err
+			return nil, xerrors.Errorf("parsing reservation key %s: %w", r.Key, err)
 		}
 
-		avail = types.BigSub(bal.Escrow, bal.Locked)
+		var rs reservation
+		if err := json.Unmarshal(r.Value, &rs); err != nil {
+			return nil, xerrors.Errorf("decoding reservation for %s: %w", deal, err)
+		}
+
+		fm.reserved[deal] = rs
+	}
+
+	return fm, nil
+}
+
+// EnsureAvailable reserves amt for the deal with the given proposal, and adds
+// funds to the escrow of addr if it doesn't cover all reservations. The
+// reservation is dropped if the funds couldn't be added, or ctx is canceled.
+func (fm *FundMgr) EnsureAvailable(ctx context.Context, deal cid.Cid, addr, wallet address.Address, amt types.BigInt) error {
+	fm.lk.Lock()
+	if err := fm.reserve(deal, reservation{Addr: addr, Amount: amt}); err != nil {
+		fm.lk.Unlock()
+		return xerrors.Errorf("reserving funds: %w", err)
 	}
 
-	toAdd := types.NewInt(0)
-	avail = types.BigSub(avail, amt)
-	if avail.LessThan(types.NewInt(0)) {
-		// TODO: some rules around adding more to avoid doing stuff on-chain
-		//  all the time
-		toAdd = types.BigSub(toAdd, avail)
-		avail = types.NewInt(0)
+	done := make(chan error, 1)
+
+	b, running := fm.batches[addr]
+	if !running {
+		b = &addBatch{}
+		fm.batches[addr] = b
 	}
-	fm.available[addr] = avail
+	b.waiters = append(b.waiters, addWaiter{wallet: wallet, done: done})
 
+	if !running {
+		go fm.runAdds(addr)
+	}
 	fm.lk.Unlock()
 
 	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	if err != nil {
+		if rerr := fm.Release(deal); rerr != nil {
+			log.Errorf("releasing reserved funds for deal %s: %s", deal, rerr)
+		}
+	}
+	return err
+}
+
+func (fm *FundMgr) runAdds(addr address.Address) {
+	for {
+		fm.lk.Lock()
+		b := fm.batches[addr]
+		if len(b.waiters) == 0 {
+			delete(fm.batches, addr)
+			fm.lk.Unlock()
+			return
+		}
+
+		// each message is sent from the wallet of the requests it covers,
+		// requests for other wallets are handled in the next round
+		wallet := b.waiters[0].wallet
+		var waiters, rest []addWaiter
+		for _, w := range b.waiters {
+			if w.wallet == wallet {
+				waiters = append(waiters, w)
+			} else {
+				rest = append(rest, w)
+			}
+		}
+		b.waiters = rest
+		fm.lk.Unlock()
+
+		err := fm.topUp(fm.ctx, addr, wallet)
+		if err != nil {
+			log.Errorf("adding market funds for %s: %s", addr, err)
+		}
+
+		for _, w := range waiters {
+			w.done <- err
+		}
+	}
+}
+
+// topUp sends a single AddBalance message covering the difference between
+// the reserved funds and the funds available in escrow, and waits for it
+func (fm *FundMgr) topUp(ctx context.Context, addr, wallet address.Address) error {
+	bal, err := fm.sm.MarketBalance(ctx, addr, nil)
+	if err != nil {
+		return err
+	}
+
+	reserved := fm.Reserved(addr)
+
+	toAdd := types.BigSub(reserved, types.BigSub(bal.Escrow, bal.Locked))
+	if !toAdd.GreaterThan(types.NewInt(0)) {
+		return nil
+	}
+
 	params, err := actors.SerializeParams(&addr)
 	if err != nil {
 		return err
@@ -88,3 +229,93 @@ func (fm *FundMgr) EnsureAvailable(ctx context.Context, addr, wallet address.Add
 	}
 	return nil
 }
+
+// Release drops the reservation of a deal, once the funds are locked by the
+// published deal, or aren't needed anymore. Releasing a deal without a
+// reservation, e.g. again after a restart, does nothing.
+func (fm *FundMgr) Release(deal cid.Cid) error {
+	fm.lk.Lock()
+	defer fm.lk.Unlock()
+
+	if _, ok := fm.reserved[deal]; !ok {
+		return nil
+	}
+
+	if err := fm.ds.Delete(datastore.NewKey(deal.String())); err != nil {
+		return err
+	}
+
+	delete(fm.reserved, deal)
+	return nil
+}
+
+// Reserved returns the funds reserved for deals of addr
+func (fm *FundMgr) Reserved(addr address.Address) types.BigInt {
+	fm.lk.Lock()
+	defer fm.lk.Unlock()
+
+	out := types.NewInt(0)
+	for _, r := range fm.reserved {
+		if r.Addr == addr {
+			out = types.BigAdd(out, r.Amount)
+		}
+	}
+	return out
+}
+
+// Withdraw sends a message withdrawing amt from the escrow of addr. Funds
+// which are locked on chain, or reserved for deals, can't be withdrawn.
+func (fm *FundMgr) Withdraw(ctx context.Context, addr, wallet address.Address, amt types.BigInt) (cid.Cid, error) {
+	bal, err := fm.sm.MarketBalance(ctx, addr, nil)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	reserved := fm.Reserved(addr)
+
+	avail := types.BigSub(types.BigSub(bal.Escrow, bal.Locked), reserved)
+	if amt.GreaterThan(avail) {
+		return cid.Undef, xerrors.Errorf("can't withdraw more than available funds: %s > %s (escrow %s, locked %s, reserved %s)",
+			types.FIL(amt), types.FIL(avail), types.FIL(bal.Escrow), types.FIL(bal.Locked), types.FIL(reserved))
+	}
+
+	params, err := actors.SerializeParams(&market.WithdrawBalanceParams{
+		ProviderOrClientAddress: addr,
+		Amount:                  amt,
+	})
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	smsg, err := fm.mpool.MpoolPushMessage(ctx, &types.Message{
+		To:       builtin.StorageMarketActorAddr,
+		From:     wallet,
+		Value:    types.NewInt(0),
+		GasPrice: types.NewInt(0),
+		GasLimit: 1000000,
+		Method:   builtin.MethodsMarket.WithdrawBalance,
+		Params:   params,
+	})
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	return smsg.Cid(), nil
+}
+
+// reserve records the reservation of a deal. A deal which reserves funds
+// again, e.g. after a restart, keeps a single reservation.
+// Must be called with fm.lk held.
+func (fm *FundMgr) reserve(deal cid.Cid, r reservation) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	if err := fm.ds.Put(datastore.NewKey(deal.String()), b); err != nil {
+		return err
+	}
+
+	fm.reserved[deal] = r
+	return nil
+}
@@ -0,0 +1,250 @@
+package market
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/multiformats/go-multihash"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+type fakeFundState struct {
+	lk     sync.Mutex
+	escrow types.BigInt
+
+	pushErr error
+	// when set, pushing a message waits for it to be closed
+	block chan struct{}
+	msgs  []*types.Message
+}
+
+func (f *fakeFundState) MarketBalance(ctx context.Context, addr address.Address, ts *types.TipSet) (api.MarketBalance, error) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+
+	return api.MarketBalance{Escrow: f.escrow, Locked: types.NewInt(0)}, nil
+}
+
+func (f *fakeFundState) WaitForMessage(ctx context.Context, mcid cid.Cid) (*types.TipSet, *types.MessageReceipt, error) {
+	return nil, &types.MessageReceipt{}, nil
+}
+
+func (f *fakeFundState) MpoolPushMessage(ctx context.Context, msg *types.Message) (*types.SignedMessage, error) {
+	if f.block != nil {
+		select {
+		case <-f.block:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	f.lk.Lock()
+	defer f.lk.Unlock()
+
+	if f.pushErr != nil {
+		return nil, f.pushErr
+	}
+
+	f.escrow = types.BigAdd(f.escrow, msg.Value)
+	f.msgs = append(f.msgs, msg)
+	return &types.SignedMessage{Message: *msg}, nil
+}
+
+func testDeal(t *testing.T, name string) cid.Cid {
+	c, err := cid.Prefix{Version: 1, Codec: cid.Raw, MhType: multihash.IDENTITY, MhLength: -1}.Sum([]byte(name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func testAddr(t *testing.T, id uint64) address.Address {
+	addr, err := address.NewIDAddress(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return addr
+}
+
+func newTestFundMgr(t *testing.T, f *fakeFundState, ds datastore.Batching) *FundMgr {
+	fm, err := newFundMgr(context.TODO(), f, f, ds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fm
+}
+
+func TestFundMgrReservePerDeal(t *testing.T) {
+	ctx := context.TODO()
+	f := &fakeFundState{escrow: types.NewInt(0)}
+	fm := newTestFundMgr(t, f, dssync.MutexWrap(datastore.NewMapDatastore()))
+
+	addr, wallet := testAddr(t, 100), testAddr(t, 101)
+	a, b := testDeal(t, "a"), testDeal(t, "b")
+
+	if err := fm.EnsureAvailable(ctx, a, addr, wallet, types.NewInt(10)); err != nil {
+		t.Fatal(err)
+	}
+	if err := fm.EnsureAvailable(ctx, b, addr, wallet, types.NewInt(5)); err != nil {
+		t.Fatal(err)
+	}
+	// a deal ensuring funds again keeps a single reservation
+	if err := fm.EnsureAvailable(ctx, a, addr, wallet, types.NewInt(10)); err != nil {
+		t.Fatal(err)
+	}
+
+	if r := fm.Reserved(addr); !r.Equals(types.NewInt(15)) {
+		t.Fatalf("expected 15 reserved, got %s", r)
+	}
+	if len(f.msgs) != 2 || !f.escrow.Equals(types.NewInt(15)) {
+		t.Fatalf("expected 2 messages adding 15, got %d adding %s", len(f.msgs), f.escrow)
+	}
+
+	// releasing twice, e.g. after a restart, only drops the deal once
+	for i := 0; i < 2; i++ {
+		if err := fm.Release(a); err != nil {
+			t.Fatal(err)
+		}
+		if r := fm.Reserved(addr); !r.Equals(types.NewInt(5)) {
+			t.Fatalf("expected 5 reserved, got %s", r)
+		}
+	}
+}
+
+func TestFundMgrReleaseOnError(t *testing.T) {
+	ctx := context.TODO()
+	f := &fakeFundState{escrow: types.NewInt(0), pushErr: xerrors.New("no funds")}
+	fm := newTestFundMgr(t, f, dssync.MutexWrap(datastore.NewMapDatastore()))
+
+	addr := testAddr(t, 100)
+	if err := fm.EnsureAvailable(ctx, testDeal(t, "a"), addr, testAddr(t, 101), types.NewInt(10)); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	if r := fm.Reserved(addr); !r.IsZero() {
+		t.Fatalf("expected the reservation to be released, got %s", r)
+	}
+}
+
+func TestFundMgrReleaseOnCancel(t *testing.T) {
+	f := &fakeFundState{escrow: types.NewInt(0), block: make(chan struct{})}
+	fm := newTestFundMgr(t, f, dssync.MutexWrap(datastore.NewMapDatastore()))
+	defer close(f.block)
+
+	addr := testAddr(t, 100)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- fm.EnsureAvailable(ctx, testDeal(t, "a"), addr, testAddr(t, 101), types.NewInt(10))
+	}()
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	if r := fm.Reserved(addr); !r.IsZero() {
+		t.Fatalf("expected the reservation to be released, got %s", r)
+	}
+}
+
+func TestFundMgrRestart(t *testing.T) {
+	ctx := context.TODO()
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	f := &fakeFundState{escrow: types.NewInt(0)}
+
+	addr, wallet := testAddr(t, 100), testAddr(t, 101)
+	a, b := testDeal(t, "a"), testDeal(t, "b")
+
+	fm := newTestFundMgr(t, f, ds)
+	if err := fm.EnsureAvailable(ctx, a, addr, wallet, types.NewInt(10)); err != nil {
+		t.Fatal(err)
+	}
+	if err := fm.EnsureAvailable(ctx, b, addr, wallet, types.NewInt(5)); err != nil {
+		t.Fatal(err)
+	}
+	if err := fm.Release(a); err != nil {
+		t.Fatal(err)
+	}
+
+	fm = newTestFundMgr(t, f, ds)
+	if r := fm.Reserved(addr); !r.Equals(types.NewInt(5)) {
+		t.Fatalf("expected 5 reserved after restart, got %s", r)
+	}
+
+	// the published deal was already released before the restart
+	if err := fm.Release(a); err != nil {
+		t.Fatal(err)
+	}
+	if r := fm.Reserved(addr); !r.Equals(types.NewInt(5)) {
+		t.Fatalf("expected 5 reserved, got %s", r)
+	}
+}
+
+func TestFundMgrBatchWallets(t *testing.T) {
+	ctx := context.TODO()
+	f := &fakeFundState{escrow: types.NewInt(0), block: make(chan struct{})}
+	fm := newTestFundMgr(t, f, dssync.MutexWrap(datastore.NewMapDatastore()))
+
+	addr := testAddr(t, 100)
+	w1, w2 := testAddr(t, 101), testAddr(t, 102)
+
+	var wg sync.WaitGroup
+	ensure := func(deal string, wallet address.Address) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := fm.EnsureAvailable(ctx, testDeal(t, deal), addr, wallet, types.NewInt(10)); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	waiting := func(n int) {
+		for {
+			fm.lk.Lock()
+			b := fm.batches[addr]
+			ok := b != nil && len(b.waiters) == n
+			fm.lk.Unlock()
+			if ok {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	// the first request sends a message, which blocks
+	ensure("a", w1)
+	waiting(0)
+
+	ensure("b", w2)
+	waiting(1)
+	ensure("c", w1)
+	waiting(2)
+
+	close(f.block)
+	wg.Wait()
+
+	// the second round covers b and c from w2, the third round for w1 has
+	// nothing left to add
+	if len(f.msgs) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(f.msgs))
+	}
+	if f.msgs[0].From != w1 || !f.msgs[0].Value.Equals(types.NewInt(10)) {
+		t.Errorf("unexpected first message from %s adding %s", f.msgs[0].From, f.msgs[0].Value)
+	}
+	if f.msgs[1].From != w2 || !f.msgs[1].Value.Equals(types.NewInt(20)) {
+		t.Errorf("unexpected second message from %s adding %s", f.msgs[1].From, f.msgs[1].Value)
+	}
+}
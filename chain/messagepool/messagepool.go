@@ -52,6 +52,10 @@ var (
 
 	ErrInvalidToAddr = errors.New("message had invalid to address")
 
+	ErrInvalidSignature = errors.New("message signature invalid")
+
+	ErrRBFTooLowPremium = errors.New("replace by fee has too low GasPrice")
+
 	ErrBroadcastAnyway = errors.New("broadcasting message despite validation fail")
 )
 
@@ -108,15 +112,15 @@ func (ms *msgSet) add(m *types.SignedMessage) error {
 	if has {
 		if m.Cid() != exms.Cid() {
 			// check if RBF passes
-			minPrice := exms.Message.GasPrice
-			minPrice = types.BigAdd(minPrice, types.BigDiv(types.BigMul(minPrice, rbfNum), rbfDenom))
-			minPrice = types.BigAdd(minPrice, types.NewInt(1))
-			if types.BigCmp(m.Message.GasPrice, minPrice) > 0 {
+			minPrice := ComputeMinRBF(exms.Message.GasPrice)
+			if types.BigCmp(m.Message.GasPrice, minPrice) >= 0 {
 				log.Infow("add with RBF", "oldprice", exms.Message.GasPrice,
 					"newprice", m.Message.GasPrice, "addr", m.Message.From, "nonce", m.Message.Nonce)
 			} else {
 				log.Info("add with duplicate nonce")
-				return xerrors.Errorf("message to %s with nonce %d already in mpool", m.Message.To, m.Message.Nonce)
+				return xerrors.Errorf("message from %s with nonce %d already in mpool,"+
+					" increase GasPrice to %s from %s to trigger replace by fee: %w",
+					m.Message.From, m.Message.Nonce, minPrice, m.Message.GasPrice, ErrRBFTooLowPremium)
 			}
 		}
 	}
@@ -125,6 +129,13 @@ func (ms *msgSet) add(m *types.SignedMessage) error {
 	return nil
 }
 
+// ComputeMinRBF returns the lowest gas price a message needs to replace a
+// pending message with the given gas price
+func ComputeMinRBF(curPrice types.BigInt) types.BigInt {
+	minPrice := types.BigAdd(curPrice, types.BigDiv(types.BigMul(curPrice, rbfNum), rbfDenom))
+	return types.BigAdd(minPrice, types.NewInt(1))
+}
+
 type Provider interface {
 	SubscribeHeadChanges(func(rev, app []*types.TipSet) error) *types.TipSet
 	PutMessage(m types.ChainMsg) (cid.Cid, error)
@@ -293,6 +304,8 @@ func (mp *MessagePool) Push(m *types.SignedMessage) (cid.Cid, error) {
 		return cid.Undef, err
 	}
 
+	prev, _ := mp.GetPending(m.Message.From, m.Message.Nonce)
+
 	if err := mp.Add(m); err != nil {
 		return cid.Undef, err
 	}
@@ -302,6 +315,12 @@ func (mp *MessagePool) Push(m *types.SignedMessage) (cid.Cid, error) {
 		mp.lk.Unlock()
 		return cid.Undef, err
 	}
+	if prev != nil && prev.Cid() != m.Cid() {
+		// the message was replaced, don't load the old one again on restart
+		if err := mp.localMsgs.Delete(datastore.NewKey(string(prev.Cid().Bytes()))); err != nil {
+			log.Warnf("removing replaced local message: %s", err)
+		}
+	}
 	mp.lk.Unlock()
 
 	return m.Cid(), mp.api.PubSubPublish(build.MessagesTopic(mp.netName), msgb)
@@ -329,7 +348,7 @@ func (mp *MessagePool) addTs(m *types.SignedMessage, curTs *types.TipSet) error
 
 	if err := sigs.Verify(&m.Signature, m.Message.From, m.Message.Cid().Bytes()); err != nil {
 		log.Warnf("mpooladd signature verification failed: %s", err)
-		return err
+		return xerrors.Errorf("%s: %w", err, ErrInvalidSignature)
 	}
 
 	snonce, err := mp.getStateNonce(m.Message.From, curTs)
@@ -387,6 +406,7 @@ func (mp *MessagePool) addLocked(m *types.SignedMessage) error {
 
 	if err := mset.add(m); err != nil {
 		log.Info(err)
+		return err
 	}
 
 	mp.changes.Pub(api.MpoolUpdate{
@@ -557,6 +577,21 @@ func (mp *MessagePool) Pending() ([]*types.SignedMessage, *types.TipSet) {
 	return out, mp.curTs
 }
 
+// GetPending returns the pending message from the given address with the
+// given nonce
+func (mp *MessagePool) GetPending(from address.Address, nonce uint64) (*types.SignedMessage, bool) {
+	mp.lk.Lock()
+	defer mp.lk.Unlock()
+
+	mset, ok := mp.pending[from]
+	if !ok {
+		return nil, false
+	}
+
+	m, ok := mset.msgs[nonce]
+	return m, ok
+}
+
 func (mp *MessagePool) pendingFor(a address.Address) []*types.SignedMessage {
 	mset := mp.pending[a]
 	if mset == nil || len(mset.msgs) == 0 {
@@ -804,6 +839,9 @@ func (mp *MessagePool) loadLocal() error {
 			if xerrors.Is(err, ErrNonceTooLow) {
 				continue // todo: drop the message from local cache (if above certain confidence threshold)
 			}
+			if xerrors.Is(err, ErrRBFTooLowPremium) {
+				continue // replaced by another local message
+			}
 
 			log.Errorf("adding local message: %+v", err)
 		}
@@ -1,6 +1,7 @@
 package messagepool
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
@@ -13,6 +14,7 @@ import (
 	"github.com/filecoin-project/specs-actors/actors/crypto"
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-datastore"
+	"golang.org/x/xerrors"
 )
 
 type testMpoolApi struct {
@@ -225,3 +227,59 @@ func TestRevertMessages(t *testing.T) {
 	}
 
 }
+
+func TestMessagePoolReplaceByFee(t *testing.T) {
+	tma := newTestMpoolApi()
+
+	w, err := wallet.NewWallet(wallet.NewMemKeyStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mp, err := New(tma, datastore.NewMapDatastore(), "mptest")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sender, err := w.GenerateKey(crypto.SigTypeBLS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := mock.Address(1001)
+
+	mkMsg := func(gasPrice uint64) *types.SignedMessage {
+		msg := &types.Message{
+			To:       target,
+			From:     sender,
+			Value:    types.NewInt(1),
+			Nonce:    0,
+			GasLimit: 1,
+			GasPrice: types.NewInt(gasPrice),
+		}
+
+		smsg, err := w.Sign(context.TODO(), sender, msg.Cid().Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &types.SignedMessage{Message: *msg, Signature: *smsg}
+	}
+
+	mustAdd(t, mp, mkMsg(100))
+
+	minPrice := ComputeMinRBF(types.NewInt(100))
+	if err := mp.Add(mkMsg(minPrice.Uint64() - 1)); !xerrors.Is(err, ErrRBFTooLowPremium) {
+		t.Fatalf("expected too low premium error, got %v", err)
+	}
+
+	replacement := mkMsg(minPrice.Uint64())
+	mustAdd(t, mp, replacement)
+
+	m, ok := mp.GetPending(sender, 0)
+	if !ok {
+		t.Fatal("expected message to be pending")
+	}
+	if m.Cid() != replacement.Cid() {
+		t.Fatal("expected message to be replaced")
+	}
+	assertNonce(t, mp, sender, 1)
+}
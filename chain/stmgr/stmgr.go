@@ -121,6 +121,7 @@ func (sm *StateManager) ExecutionTrace(ctx context.Context, ts *types.TipSet) (c
 	var trace []*api.InvocResult
 	st, _, err := sm.computeTipSetState(ctx, ts.Blocks(), func(mcid cid.Cid, msg *types.Message, ret *vm.ApplyRet) error {
 		ir := &api.InvocResult{
+			MsgCid:             mcid,
 			Msg:                msg,
 			MsgRct:             &ret.MessageReceipt,
 			InternalExecutions: ret.InternalExecutions,
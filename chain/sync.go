@@ -1326,12 +1326,32 @@ func VerifyElectionPoStVRF(ctx context.Context, evrf []byte, rand []byte, worker
 
 func (syncer *Syncer) State() []SyncerState {
 	var out []SyncerState
-	for _, ss := range syncer.syncmgr.syncStates {
+	for _, ss := range syncer.syncmgr.SyncStates() {
 		out = append(out, ss.Snapshot())
 	}
 	return out
 }
 
+func (syncer *Syncer) DebugState(ctx context.Context) (*SyncManagerState, error) {
+	return syncer.syncmgr.DebugState(ctx)
+}
+
+func (syncer *Syncer) Pause(ctx context.Context) error {
+	return syncer.syncmgr.Pause(ctx)
+}
+
+func (syncer *Syncer) Resume(ctx context.Context) error {
+	return syncer.syncmgr.Resume(ctx)
+}
+
+func (syncer *Syncer) ForceTarget(ctx context.Context, p peer.ID) (*types.TipSet, error) {
+	return syncer.syncmgr.ForceTarget(ctx, p)
+}
+
+func (syncer *Syncer) SetWorkerCount(n int) error {
+	return syncer.syncmgr.SetWorkerCount(n)
+}
+
 func (syncer *Syncer) MarkBad(blk cid.Cid) {
 	syncer.bad.Add(blk, "manually marked bad")
 }
@@ -4,9 +4,12 @@ import (
 	"context"
 	"sort"
 	"sync"
+	"time"
 
-	"github.com/filecoin-project/lotus/chain/types"
 	peer "github.com/libp2p/go-libp2p-core/peer"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/chain/types"
 )
 
 const BootstrapPeerThreshold = 2
@@ -33,18 +36,35 @@ type SyncManager struct {
 	syncTargets     chan *types.TipSet
 	syncResults     chan *syncResult
 
-	syncStates []*SyncerState
+	workerLk     sync.Mutex
+	workers      []*syncWorker
+	nextWorkerID int
 
 	doSync func(context.Context, *types.TipSet) error
 
 	stop chan struct{}
 
+	// ctl runs functions on the scheduler goroutine, which owns all of the
+	// scheduler fields below
+	ctl chan func()
+
 	// Sync Scheduler fields
 	activeSyncs    map[types.TipSetKey]*types.TipSet
 	syncQueue      syncBucketSet
 	activeSyncTips syncBucketSet
 	nextSyncTarget *syncTargetBucket
 	workerChan     chan *types.TipSet
+	paused         bool
+}
+
+type syncWorker struct {
+	id   int
+	ss   *SyncerState
+	stop chan struct{}
+
+	lk          sync.Mutex
+	lastErr     error
+	lastErrTime time.Time
 }
 
 type syncResult struct {
@@ -60,18 +80,21 @@ func NewSyncManager(sync SyncFunc) *SyncManager {
 		peerHeads:       make(map[peer.ID]*types.TipSet),
 		syncTargets:     make(chan *types.TipSet),
 		syncResults:     make(chan *syncResult),
-		syncStates:      make([]*SyncerState, syncWorkerCount),
 		incomingTipSets: make(chan *types.TipSet),
 		activeSyncs:     make(map[types.TipSetKey]*types.TipSet),
 		doSync:          sync,
 		stop:            make(chan struct{}),
+		ctl:             make(chan func()),
 	}
 }
 
 func (sm *SyncManager) Start() {
 	go sm.syncScheduler()
+
+	sm.workerLk.Lock()
+	defer sm.workerLk.Unlock()
 	for i := 0; i < syncWorkerCount; i++ {
-		go sm.syncWorker(i)
+		sm.startWorker()
 	}
 }
 
@@ -260,6 +283,13 @@ func (sm *SyncManager) selectSyncTarget() (*types.TipSet, error) {
 func (sm *SyncManager) syncScheduler() {
 
 	for {
+		// while paused, keep tracking incoming heads, but don't hand out
+		// any new work
+		workerChan := sm.workerChan
+		if sm.paused {
+			workerChan = nil
+		}
+
 		select {
 		case ts, ok := <-sm.incomingTipSets:
 			if !ok {
@@ -270,8 +300,10 @@ func (sm *SyncManager) syncScheduler() {
 			sm.scheduleIncoming(ts)
 		case res := <-sm.syncResults:
 			sm.scheduleProcessResult(res)
-		case sm.workerChan <- sm.nextSyncTarget.heaviestTipSet():
+		case workerChan <- sm.nextSyncTarget.heaviestTipSet():
 			sm.scheduleWorkSent()
+		case fn := <-sm.ctl:
+			fn()
 		case <-sm.stop:
 			log.Info("sync scheduler shutting down")
 			return
@@ -371,9 +403,20 @@ func (sm *SyncManager) scheduleWorkSent() {
 	}
 }
 
-func (sm *SyncManager) syncWorker(id int) {
-	ss := &SyncerState{}
-	sm.syncStates[id] = ss
+// startWorker must be called with workerLk held
+func (sm *SyncManager) startWorker() {
+	w := &syncWorker{
+		id:   sm.nextWorkerID,
+		ss:   &SyncerState{},
+		stop: make(chan struct{}),
+	}
+	sm.nextWorkerID++
+	sm.workers = append(sm.workers, w)
+
+	go sm.syncWorker(w)
+}
+
+func (sm *SyncManager) syncWorker(w *syncWorker) {
 	for {
 		select {
 		case ts, ok := <-sm.syncTargets:
@@ -382,16 +425,26 @@ func (sm *SyncManager) syncWorker(id int) {
 				return
 			}
 
-			ctx := context.WithValue(context.TODO(), syncStateKey{}, ss)
+			ctx := context.WithValue(context.TODO(), syncStateKey{}, w.ss)
 			err := sm.doSync(ctx, ts)
 			if err != nil {
 				log.Errorf("sync error: %+v", err)
+
+				w.lk.Lock()
+				w.lastErr = err
+				w.lastErrTime = time.Now()
+				w.lk.Unlock()
 			}
 
 			sm.syncResults <- &syncResult{
 				ts:      ts,
 				success: err == nil,
 			}
+		case <-w.stop:
+			log.Infof("sync worker %d shutting down", w.id)
+			return
+		case <-sm.stop:
+			return
 		}
 	}
 }
@@ -423,3 +476,201 @@ func (sm *SyncManager) IsBootstrapped() bool {
 	defer sm.bssLk.Unlock()
 	return sm.bootstrapState == BSStateComplete
 }
+
+// SyncStates returns the state of each of the sync workers
+func (sm *SyncManager) SyncStates() []*SyncerState {
+	sm.workerLk.Lock()
+	defer sm.workerLk.Unlock()
+
+	out := make([]*SyncerState, len(sm.workers))
+	for i, w := range sm.workers {
+		out[i] = w.ss
+	}
+	return out
+}
+
+// SetWorkerCount starts or stops sync workers until there are n of them.
+// Workers which are stopped while syncing finish their current sync first.
+func (sm *SyncManager) SetWorkerCount(n int) error {
+	if n < 1 {
+		// the bootstrap sync is handed to the workers directly by the
+		// scheduler, which would block forever with no workers
+		return xerrors.Errorf("need at least one sync worker, got %d", n)
+	}
+
+	sm.workerLk.Lock()
+	defer sm.workerLk.Unlock()
+
+	for len(sm.workers) < n {
+		sm.startWorker()
+	}
+
+	for len(sm.workers) > n {
+		w := sm.workers[len(sm.workers)-1]
+		close(w.stop)
+		sm.workers = sm.workers[:len(sm.workers)-1]
+	}
+
+	return nil
+}
+
+// runInScheduler executes fn on the scheduler goroutine, and waits for it
+// to finish
+func (sm *SyncManager) runInScheduler(ctx context.Context, fn func()) error {
+	done := make(chan struct{})
+
+	select {
+	case sm.ctl <- func() {
+		fn()
+		close(done)
+	}:
+	case <-sm.stop:
+		return xerrors.New("sync manager stopped")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Pause stops the scheduler from handing out new sync targets. Syncs which
+// are already running are not interrupted, and incoming heads are still
+// queued up.
+func (sm *SyncManager) Pause(ctx context.Context) error {
+	return sm.runInScheduler(ctx, func() {
+		sm.paused = true
+	})
+}
+
+func (sm *SyncManager) Resume(ctx context.Context) error {
+	return sm.runInScheduler(ctx, func() {
+		sm.paused = false
+	})
+}
+
+// ForceTarget makes the last head seen from the given peer the next sync
+// target, ahead of anything already queued
+func (sm *SyncManager) ForceTarget(ctx context.Context, p peer.ID) (*types.TipSet, error) {
+	sm.lk.Lock()
+	ts, ok := sm.peerHeads[p]
+	sm.lk.Unlock()
+	if !ok {
+		return nil, xerrors.Errorf("no known head for peer %s", p)
+	}
+
+	err := sm.runInScheduler(ctx, func() {
+		log.Infow("forcing sync target", "peer", p, "tipset", ts.Cids(), "height", ts.Height())
+
+		if sm.getBootstrapState() < BSStateScheduled {
+			sm.setBootstrapState(BSStateScheduled)
+		}
+
+		if sm.nextSyncTarget != nil {
+			sm.syncQueue.buckets = append(sm.syncQueue.buckets, sm.nextSyncTarget)
+		}
+
+		sm.nextSyncTarget = newSyncTargetBucket(ts)
+		sm.workerChan = sm.syncTargets
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ts, nil
+}
+
+type SyncBucketState struct {
+	Tips  []*types.TipSet
+	Count int
+}
+
+type SyncWorkerState struct {
+	ID          int
+	State       SyncerState
+	LastErr     error
+	LastErrTime time.Time
+}
+
+type SyncManagerState struct {
+	BootstrapState int
+	Paused         bool
+
+	PeerHeads map[peer.ID]*types.TipSet
+
+	ActiveSyncs    []*types.TipSet
+	NextTarget     *SyncBucketState
+	SyncQueue      []SyncBucketState
+	ActiveSyncTips []SyncBucketState
+
+	Workers []SyncWorkerState
+}
+
+func (stb *syncTargetBucket) state() SyncBucketState {
+	return SyncBucketState{
+		Tips:  append([]*types.TipSet{}, stb.tips...),
+		Count: stb.count,
+	}
+}
+
+func (sbs *syncBucketSet) state() []SyncBucketState {
+	out := make([]SyncBucketState, len(sbs.buckets))
+	for i, b := range sbs.buckets {
+		out[i] = b.state()
+	}
+	return out
+}
+
+// DebugState returns a snapshot of the sync scheduler internals
+func (sm *SyncManager) DebugState(ctx context.Context) (*SyncManagerState, error) {
+	out := &SyncManagerState{
+		BootstrapState: sm.getBootstrapState(),
+		PeerHeads:      map[peer.ID]*types.TipSet{},
+	}
+
+	// peerHeads must not be read from the scheduler goroutine, SetPeerHead
+	// holds lk while sending to the scheduler
+	sm.lk.Lock()
+	for p, ts := range sm.peerHeads {
+		out.PeerHeads[p] = ts
+	}
+	sm.lk.Unlock()
+
+	err := sm.runInScheduler(ctx, func() {
+		out.Paused = sm.paused
+
+		for _, ts := range sm.activeSyncs {
+			out.ActiveSyncs = append(out.ActiveSyncs, ts)
+		}
+
+		if sm.nextSyncTarget != nil {
+			nt := sm.nextSyncTarget.state()
+			out.NextTarget = &nt
+		}
+
+		out.SyncQueue = sm.syncQueue.state()
+		out.ActiveSyncTips = sm.activeSyncTips.state()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sm.workerLk.Lock()
+	for _, w := range sm.workers {
+		w.lk.Lock()
+		out.Workers = append(out.Workers, SyncWorkerState{
+			ID:          w.id,
+			State:       w.ss.Snapshot(),
+			LastErr:     w.lastErr,
+			LastErrTime: w.lastErrTime,
+		})
+		w.lk.Unlock()
+	}
+	sm.workerLk.Unlock()
+
+	return out, nil
+}
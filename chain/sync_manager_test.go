@@ -147,4 +147,49 @@ func TestSyncManager(t *testing.T) {
 		fmt.Println("op3: ", op3.ts.Cids())
 		op3.done()
 	})
+
+	runSyncMgrTest(t, "testPauseResume", 1, func(t *testing.T, sm *SyncManager, stc chan *syncOp) {
+		sm.SetPeerHead(ctx, "peer1", a)
+		assertGetSyncOp(t, stc, a)
+
+		if err := sm.Pause(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		sm.SetPeerHead(ctx, "peer2", b)
+		assertNoOp(t, stc)
+
+		st, err := sm.DebugState(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !st.Paused {
+			t.Fatal("expected sync manager to be paused")
+		}
+		if st.NextTarget == nil || len(st.NextTarget.Tips) != 1 {
+			t.Fatal("expected paused sync target to be queued")
+		}
+		assertTsEqual(t, st.NextTarget.Tips[0], b)
+
+		if err := sm.Resume(ctx); err != nil {
+			t.Fatal(err)
+		}
+		assertGetSyncOp(t, stc, b)
+	})
+
+	runSyncMgrTest(t, "testForceTarget", 2, func(t *testing.T, sm *SyncManager, stc chan *syncOp) {
+		sm.SetPeerHead(ctx, "peer1", c1)
+		assertNoOp(t, stc)
+
+		if _, err := sm.ForceTarget(ctx, "peer2"); err == nil {
+			t.Fatal("expected forcing target from unknown peer to fail")
+		}
+
+		ts, err := sm.ForceTarget(ctx, "peer1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertTsEqual(t, ts, c1)
+		assertGetSyncOp(t, stc, c1)
+	})
 }
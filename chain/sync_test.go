@@ -310,7 +310,9 @@ func (tu *syncTestUtil) compareSourceState(with int) {
 	sourceAccounts, err := tu.nds[source].WalletList(tu.ctx)
 	require.NoError(tu.t, err)
 
-	for _, addr := range sourceAccounts {
+	for _, wa := range sourceAccounts {
+		addr := wa.Address
+
 		sourceBalance, err := tu.nds[source].WalletBalance(tu.ctx, addr)
 		require.NoError(tu.t, err)
 		fmt.Printf("Source state check for %s, expect %s\n", addr, sourceBalance)
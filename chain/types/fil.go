@@ -1,6 +1,7 @@
 package types
 
 import (
+	"encoding/json"
 	"fmt"
 	"math/big"
 	"strings"
@@ -40,3 +41,32 @@ func ParseFIL(s string) (FIL, error) {
 
 	return FIL{r.Num()}, nil
 }
+
+func (f FIL) MarshalText() (text []byte, err error) {
+	return []byte(f.String()), nil
+}
+
+func (f *FIL) UnmarshalText(text []byte) error {
+	p, err := ParseFIL(string(text))
+	if err != nil {
+		return err
+	}
+
+	f.Int = p.Int
+	return nil
+}
+
+// MarshalJSON encodes FIL as text, like MarshalText, instead of using the
+// JSON encoding of the embedded big.Int
+func (f FIL) MarshalJSON() ([]byte, error) {
+	return json.Marshal(f.String())
+}
+
+func (f *FIL) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	return f.UnmarshalText([]byte(s))
+}
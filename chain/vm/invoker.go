@@ -5,6 +5,8 @@ import (
 	"encoding/hex"
 	"fmt"
 	"reflect"
+	goruntime "runtime"
+	"strings"
 
 	"github.com/filecoin-project/specs-actors/actors/builtin/account"
 	"github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
@@ -32,8 +34,14 @@ import (
 )
 
 type invoker struct {
-	builtInCode  map[cid.Cid]nativeCode
-	builtInState map[cid.Cid]reflect.Type
+	builtInCode    map[cid.Cid]nativeCode
+	builtInState   map[cid.Cid]reflect.Type
+	builtInMethods map[cid.Cid][]methodMeta
+}
+
+type methodMeta struct {
+	name   string
+	params reflect.Type
 }
 
 type invokeFunc func(rt runtime.Runtime, params []byte) ([]byte, aerrors.ActorError)
@@ -41,8 +49,9 @@ type nativeCode []invokeFunc
 
 func NewInvoker() *invoker {
 	inv := &invoker{
-		builtInCode:  make(map[cid.Cid]nativeCode),
-		builtInState: make(map[cid.Cid]reflect.Type),
+		builtInCode:    make(map[cid.Cid]nativeCode),
+		builtInState:   make(map[cid.Cid]reflect.Type),
+		builtInMethods: make(map[cid.Cid][]methodMeta),
 	}
 
 	// add builtInCode using: register(cid, singleton)
@@ -81,6 +90,29 @@ func (inv *invoker) Register(c cid.Cid, instance Invokee, state interface{}) {
 	}
 	inv.builtInCode[c] = code
 	inv.builtInState[c] = reflect.TypeOf(state)
+	inv.builtInMethods[c] = methods(instance)
+}
+
+func methods(instance Invokee) []methodMeta {
+	exports := instance.Exports()
+	out := make([]methodMeta, len(exports))
+	for i, m := range exports {
+		if m == nil {
+			continue
+		}
+
+		meth := reflect.ValueOf(m)
+
+		// method values are named like 'pkg.Actor.Method-fm'
+		name := goruntime.FuncForPC(meth.Pointer()).Name()
+		name = strings.TrimSuffix(name[strings.LastIndex(name, ".")+1:], "-fm")
+
+		out[i] = methodMeta{
+			name:   name,
+			params: meth.Type().In(1).Elem(),
+		}
+	}
+	return out
 }
 
 type Invokee interface {
@@ -198,3 +230,30 @@ func DumpActorState(code cid.Cid, b []byte) (interface{}, error) {
 
 	return rv.Elem().Interface(), nil
 }
+
+// DecodeMethodParams returns the name of the given builtin actor method, and
+// its decoded parameters
+func DecodeMethodParams(code cid.Cid, method abi.MethodNum, params []byte) (string, interface{}, error) {
+	if method == builtin.MethodSend {
+		return "Send", nil, nil
+	}
+
+	i := NewInvoker() // TODO: register builtins in init block
+
+	methods, ok := i.builtInMethods[code]
+	if !ok {
+		return "", nil, xerrors.Errorf("methods for actor %s not found", code)
+	}
+
+	if method >= abi.MethodNum(len(methods)) || methods[method].params == nil {
+		return "", nil, xerrors.Errorf("no method %d on actor %s", method, code)
+	}
+	meta := methods[method]
+
+	rv := reflect.New(meta.params)
+	if err := DecodeParams(params, rv.Interface()); err != nil {
+		return meta.name, nil, xerrors.Errorf("decoding params for %s: %w", meta.name, err)
+	}
+
+	return meta.name, rv.Elem().Interface(), nil
+}
@@ -3,6 +3,7 @@ package vm
 import (
 	"fmt"
 	"io"
+	"reflect"
 	"testing"
 
 	cbor "github.com/ipfs/go-ipld-cbor"
@@ -110,3 +111,14 @@ func TestInvokerBasic(t *testing.T) {
 	assert.Equal(t, exitcode.ExitCode(1), aerrors.RetCode(aerr), "return code should be 1")
 
 }
+
+func TestInvokerMethodMeta(t *testing.T) {
+	meta := methods(basicContract{})
+	assert.Len(t, meta, 11)
+
+	assert.Equal(t, "InvokeSomething0", meta[0].name)
+	assert.Equal(t, "BadParam", meta[1].name)
+	assert.Nil(t, meta[2].params)
+	assert.Equal(t, "InvokeSomething10", meta[10].name)
+	assert.Equal(t, reflect.TypeOf(basicParams{}), meta[10].params)
+}
@@ -0,0 +1,383 @@
+package wallet
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+
+	"github.com/filecoin-project/specs-actors/actors/crypto"
+	"github.com/tyler-smith/go-bip39"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/lib/hdkey"
+)
+
+const (
+	// KHDSeed is the keystore name of the encrypted HD wallet seed
+	KHDSeed = "hd-seed"
+
+	// KTHD is the type of keystore entries of derived addresses. They only
+	// hold the derivation index, the key is derived from the seed on demand.
+	KTHD = "hd"
+
+	hdMnemonicEntropy = 256
+	hdKDFRounds       = 100000
+)
+
+var (
+	ErrHDLocked = errors.New("hd wallet is locked, unlock it with the wallet passphrase")
+	ErrNoHDSeed = errors.New("hd wallet seed not found")
+)
+
+// hdSeedInfo is what's stored under KHDSeed: the seed, encrypted with a key
+// derived from the wallet passphrase, and the next unused index for each key
+// type
+type hdSeedInfo struct {
+	Salt  []byte
+	Nonce []byte
+	Seed  []byte
+
+	NextIndex map[string]uint32
+}
+
+// hdKeyInfo is stored in place of the private key of derived addresses
+type hdKeyInfo struct {
+	Type    string
+	Index   uint32
+	Address address.Address
+}
+
+// HDNew derives the next address of the given type from the HD seed. If the
+// wallet has no seed yet, a new mnemonic is generated and returned, otherwise
+// the returned mnemonic is empty.
+//
+// The mnemonic is converted to a seed without a BIP-39 passphrase, so that
+// it's enough to restore the wallet. The passphrase only encrypts the seed in
+// the keystore.
+func (w *Wallet) HDNew(typ crypto.SigType, passphrase string) (string, address.Address, error) {
+	w.lk.Lock()
+	defer w.lk.Unlock()
+
+	var mnemonic string
+
+	si, err := w.getHDSeed()
+	switch {
+	case err == nil:
+		if err := w.unlockHD(si, passphrase); err != nil {
+			return "", address.Undef, err
+		}
+	case xerrors.Is(err, ErrNoHDSeed):
+		entropy, err := bip39.NewEntropy(hdMnemonicEntropy)
+		if err != nil {
+			return "", address.Undef, xerrors.Errorf("generating entropy: %w", err)
+		}
+
+		mnemonic, err = bip39.NewMnemonic(entropy)
+		if err != nil {
+			return "", address.Undef, xerrors.Errorf("generating mnemonic: %w", err)
+		}
+
+		si, err = w.initHDSeed(bip39.NewSeed(mnemonic, ""), passphrase)
+		if err != nil {
+			return "", address.Undef, err
+		}
+	default:
+		return "", address.Undef, err
+	}
+
+	k, err := w.deriveHDAddress(si, kstoreSigType(typ), -1)
+	if err != nil {
+		return "", address.Undef, err
+	}
+
+	return mnemonic, k.Address, nil
+}
+
+// HDRestore sets up the HD seed from a mnemonic, and derives the first
+// secpCount secp256k1 and blsCount BLS addresses
+func (w *Wallet) HDRestore(mnemonic string, passphrase string, secpCount, blsCount int) ([]address.Address, error) {
+	w.lk.Lock()
+	defer w.lk.Unlock()
+
+	seed, err := bip39.NewSeedWithErrorChecking(mnemonic, "")
+	if err != nil {
+		return nil, xerrors.Errorf("invalid mnemonic: %w", err)
+	}
+
+	_, err = w.getHDSeed()
+	switch {
+	case err == nil:
+		return nil, xerrors.Errorf("wallet already has an hd seed")
+	case xerrors.Is(err, ErrNoHDSeed):
+	default:
+		return nil, err
+	}
+
+	si, err := w.initHDSeed(seed, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []address.Address
+	for _, c := range []struct {
+		typ string
+		n   int
+	}{{KTSecp256k1, secpCount}, {KTBLS, blsCount}} {
+		for i := 0; i < c.n; i++ {
+			k, err := w.deriveHDAddress(si, c.typ, int64(i))
+			if err != nil {
+				return nil, xerrors.Errorf("deriving %s key %d: %w", c.typ, i, err)
+			}
+			out = append(out, k.Address)
+		}
+	}
+
+	return out, nil
+}
+
+// HDDerive derives the address of the given type at index, or at the next
+// unused index if index is negative, and adds it to the wallet
+func (w *Wallet) HDDerive(typ crypto.SigType, index int64, passphrase string) (address.Address, error) {
+	w.lk.Lock()
+	defer w.lk.Unlock()
+
+	si, err := w.getHDSeed()
+	if err != nil {
+		return address.Undef, err
+	}
+
+	if err := w.unlockHD(si, passphrase); err != nil {
+		return address.Undef, err
+	}
+
+	k, err := w.deriveHDAddress(si, kstoreSigType(typ), index)
+	if err != nil {
+		return address.Undef, err
+	}
+
+	return k.Address, nil
+}
+
+// HDUnlock decrypts the HD seed, so that keys of derived addresses can be
+// used. The seed is only kept in memory.
+func (w *Wallet) HDUnlock(passphrase string) error {
+	w.lk.Lock()
+	defer w.lk.Unlock()
+
+	si, err := w.getHDSeed()
+	if err != nil {
+		return err
+	}
+
+	return w.unlockHD(si, passphrase)
+}
+
+// Must be called with w.lk held.
+func (w *Wallet) getHDSeed() (*hdSeedInfo, error) {
+	ki, err := w.keystore.Get(KHDSeed)
+	if err != nil {
+		if xerrors.Is(err, types.ErrKeyInfoNotFound) {
+			return nil, ErrNoHDSeed
+		}
+		return nil, xerrors.Errorf("getting hd seed from keystore: %w", err)
+	}
+
+	var si hdSeedInfo
+	if err := json.Unmarshal(ki.PrivateKey, &si); err != nil {
+		return nil, xerrors.Errorf("decoding hd seed: %w", err)
+	}
+	if si.NextIndex == nil {
+		si.NextIndex = map[string]uint32{}
+	}
+
+	return &si, nil
+}
+
+// Must be called with w.lk held.
+func (w *Wallet) putHDSeed(si *hdSeedInfo) error {
+	b, err := json.Marshal(si)
+	if err != nil {
+		return err
+	}
+
+	return w.replaceKey(KHDSeed, types.KeyInfo{
+		Type:       KHDSeed,
+		PrivateKey: b,
+	})
+}
+
+// Must be called with w.lk held.
+func (w *Wallet) initHDSeed(seed []byte, passphrase string) (*hdSeedInfo, error) {
+	si := &hdSeedInfo{
+		Salt:      make([]byte, 32),
+		NextIndex: map[string]uint32{},
+	}
+	if _, err := rand.Read(si.Salt); err != nil {
+		return nil, err
+	}
+
+	gcm, err := hdCipher(passphrase, si.Salt)
+	if err != nil {
+		return nil, err
+	}
+
+	si.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(si.Nonce); err != nil {
+		return nil, err
+	}
+	si.Seed = gcm.Seal(nil, si.Nonce, seed, nil)
+
+	if err := w.putHDSeed(si); err != nil {
+		return nil, xerrors.Errorf("saving hd seed: %w", err)
+	}
+
+	w.hdSeed = seed
+	return si, nil
+}
+
+// Must be called with w.lk held.
+func (w *Wallet) unlockHD(si *hdSeedInfo, passphrase string) error {
+	gcm, err := hdCipher(passphrase, si.Salt)
+	if err != nil {
+		return err
+	}
+
+	seed, err := gcm.Open(nil, si.Nonce, si.Seed, nil)
+	if err != nil {
+		return xerrors.Errorf("decrypting hd seed, wrong passphrase?")
+	}
+
+	w.hdSeed = seed
+	return nil
+}
+
+func hdCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key := pbkdf2.Key([]byte(passphrase), salt, hdKDFRounds, 32, sha256.New)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// deriveHDAddress derives the key at index, or the next unused index if index
+// is negative, and records the index in the keystore. Must be called with w.lk
+// held, and the seed unlocked.
+//
+// Derived addresses don't become the default address: their keys can't be
+// used after a restart until the wallet is unlocked, so the node couldn't
+// sign with its default address.
+func (w *Wallet) deriveHDAddress(si *hdSeedInfo, typ string, index int64) (*Key, error) {
+	if index < 0 {
+		index = int64(si.NextIndex[typ])
+	}
+	if index >= int64(hdkey.Hardened) {
+		return nil, xerrors.Errorf("derivation index %d out of range", index)
+	}
+
+	k, err := w.deriveHDKey(typ, uint32(index))
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := json.Marshal(&hdKeyInfo{
+		Type:    typ,
+		Index:   uint32(index),
+		Address: k.Address,
+	})
+	if err != nil {
+		return nil, err
+	}
+	ki := types.KeyInfo{
+		Type:       KTHD,
+		PrivateKey: b,
+	}
+
+	if err := w.replaceKey(KNamePrefix+k.Address.String(), ki); err != nil {
+		return nil, xerrors.Errorf("saving to keystore: %w", err)
+	}
+	w.keys[k.Address] = k
+
+	if uint32(index) >= si.NextIndex[typ] {
+		si.NextIndex[typ] = uint32(index) + 1
+		if err := w.putHDSeed(si); err != nil {
+			return nil, xerrors.Errorf("saving hd seed: %w", err)
+		}
+	}
+
+	return k, nil
+}
+
+// Must be called with w.lk held.
+func (w *Wallet) deriveHDKey(typ string, index uint32) (*Key, error) {
+	if w.hdSeed == nil {
+		return nil, ErrHDLocked
+	}
+
+	var pk []byte
+	var err error
+	switch typ {
+	case KTSecp256k1:
+		pk, err = hdkey.DeriveSecp256k1(w.hdSeed, hdkey.SecpPath(index))
+	case KTBLS:
+		pk, err = hdkey.DeriveBLS(w.hdSeed, hdkey.BLSPath(index))
+	default:
+		return nil, xerrors.Errorf("unknown key type: '%s'", typ)
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("deriving key: %w", err)
+	}
+
+	return NewKey(types.KeyInfo{
+		Type:       typ,
+		PrivateKey: pk,
+	})
+}
+
+// findHDKey derives the key of an address stored as a derivation index. Must
+// be called with w.lk held.
+func (w *Wallet) findHDKey(ki types.KeyInfo) (*Key, error) {
+	hki, err := decodeHDKeyInfo(ki)
+	if err != nil {
+		return nil, err
+	}
+
+	k, err := w.deriveHDKey(hki.Type, hki.Index)
+	if err != nil {
+		return nil, xerrors.Errorf("key %s: %w", hki.Address, err)
+	}
+
+	if k.Address != hki.Address {
+		return nil, xerrors.Errorf("derived address %s doesn't match stored address %s", k.Address, hki.Address)
+	}
+
+	return k, nil
+}
+
+func decodeHDKeyInfo(ki types.KeyInfo) (*hdKeyInfo, error) {
+	var hki hdKeyInfo
+	if err := json.Unmarshal(ki.PrivateKey, &hki); err != nil {
+		return nil, xerrors.Errorf("decoding hd key info: %w", err)
+	}
+	return &hki, nil
+}
+
+// Must be called with w.lk held.
+func (w *Wallet) replaceKey(name string, ki types.KeyInfo) error {
+	if err := w.keystore.Delete(name); err != nil {
+		if !xerrors.Is(err, types.ErrKeyInfoNotFound) {
+			return err
+		}
+	}
+
+	return w.keystore.Put(name, ki)
+}
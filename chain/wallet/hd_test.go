@@ -0,0 +1,206 @@
+package wallet
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/specs-actors/actors/crypto"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/lib/sigs"
+)
+
+// BIP-39 test vector mnemonic
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+func TestHDNewAndRestore(t *testing.T) {
+	w, err := NewWallet(NewMemKeyStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mnemonic, a0, err := w.HDNew(crypto.SigTypeSecp256k1, "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mnemonic == "" {
+		t.Fatal("expected a mnemonic for a new seed")
+	}
+
+	again, a1, err := w.HDNew(crypto.SigTypeSecp256k1, "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != "" {
+		t.Error("mnemonic returned again for an existing seed")
+	}
+	if a0 == a1 {
+		t.Error("derived the same address twice")
+	}
+
+	if _, _, err := w.HDNew(crypto.SigTypeSecp256k1, "wrong"); err == nil {
+		t.Error("expected an error with a wrong passphrase")
+	}
+
+	if def, err := w.GetDefault(); err == nil {
+		t.Errorf("expected derived addresses not to become the default, got %s", def)
+	}
+
+	// restoring the mnemonic in another wallet derives the same addresses,
+	// independently of the passphrase
+	r, err := NewWallet(NewMemKeyStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := r.HDRestore(mnemonic, "other", 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restored) != 2 || restored[0] != a0 || restored[1] != a1 {
+		t.Errorf("expected restored addresses %s %s, got %v", a0, a1, restored)
+	}
+
+	if _, err := r.HDRestore(mnemonic, "other", 1, 0); err == nil {
+		t.Error("expected an error restoring over an existing seed")
+	}
+}
+
+func TestHDRestoreInvalidMnemonic(t *testing.T) {
+	w, err := NewWallet(NewMemKeyStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// wrong checksum word
+	if _, err := w.HDRestore(testMnemonic[:len(testMnemonic)-len("about")]+"abandon", "", 1, 0); err == nil {
+		t.Error("expected an error for an invalid mnemonic")
+	}
+}
+
+func TestHDDerive(t *testing.T) {
+	w, err := NewWallet(NewMemKeyStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := w.HDRestore(testMnemonic, "pass", 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// deriving an index again gives the same address
+	a0, err := w.HDDerive(crypto.SigTypeSecp256k1, 0, "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a0 != restored[0] {
+		t.Errorf("expected %s at index 0, got %s", restored[0], a0)
+	}
+
+	// explicit indexes move the next unused index past them
+	a5, err := w.HDDerive(crypto.SigTypeSecp256k1, 5, "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, err := w.HDDerive(crypto.SigTypeSecp256k1, -1, "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a6, err := w.HDDerive(crypto.SigTypeSecp256k1, 6, "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next != a6 || next == a5 {
+		t.Errorf("expected next address %s to be at index 6 (%s)", next, a6)
+	}
+}
+
+func TestHDLocked(t *testing.T) {
+	ks := NewMemKeyStore()
+
+	w, err := NewWallet(ks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := w.HDRestore(testMnemonic, "pass", 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := restored[0]
+
+	// a wallet opened on the same keystore doesn't have the seed unlocked
+	w, err = NewWallet(ks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := w.Sign(context.Background(), addr, []byte("data")); !xerrors.Is(err, ErrHDLocked) {
+		t.Fatalf("expected ErrHDLocked, got %v", err)
+	}
+
+	if err := w.HDUnlock("wrong"); err == nil {
+		t.Fatal("expected an error with a wrong passphrase")
+	}
+	if err := w.HDUnlock("pass"); err != nil {
+		t.Fatal(err)
+	}
+
+	sig, err := w.Sign(context.Background(), addr, []byte("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sigs.Verify(sig, addr, []byte("data")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHDRestart(t *testing.T) {
+	ctx := context.Background()
+	ks := NewMemKeyStore()
+
+	w, err := NewWallet(ks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, hd, err := w.HDNew(crypto.SigTypeSecp256k1, "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gen, err := w.GenerateKey(crypto.SigTypeSecp256k1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// after a restart, the default address can sign without unlocking
+	w, err = NewWallet(ks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	def, err := w.GetDefault()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if def != gen {
+		t.Fatalf("expected generated address %s to be the default, got %s", gen, def)
+	}
+
+	sig, err := w.Sign(ctx, def, []byte("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sigs.Verify(sig, def, []byte("data")); err != nil {
+		t.Fatal(err)
+	}
+
+	// derived addresses are still listed, but locked
+	if has, err := w.HasKey(hd); err != nil || !has {
+		t.Errorf("expected the wallet to have %s (%t, %v)", hd, has, err)
+	}
+	if _, err := w.Sign(ctx, hd, []byte("data")); !xerrors.Is(err, ErrHDLocked) {
+		t.Errorf("expected ErrHDLocked, got %v", err)
+	}
+}
@@ -0,0 +1,498 @@
+// Package history indexes the messages touching the addresses in the wallet,
+// including watch-only addresses, and optionally the value transfers made to
+// them by actors.
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
+	dsq "github.com/ipfs/go-datastore/query"
+	logging "github.com/ipfs/go-log/v2"
+	"go.uber.org/fx"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/stmgr"
+	"github.com/filecoin-project/lotus/chain/store"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/wallet"
+	"github.com/filecoin-project/lotus/node/config"
+	"github.com/filecoin-project/lotus/node/modules/dtypes"
+	"github.com/filecoin-project/lotus/node/modules/helpers"
+)
+
+var log = logging.Logger("wallethistory")
+
+var headKey = datastore.NewKey("/head")
+
+// Entry is a message, or a value transfer made by a message, touching a
+// wallet address
+type Entry struct {
+	Height abi.ChainEpoch
+	TipSet types.TipSetKey
+
+	// Message is the top level message. For internal transfers, it's the
+	// message which triggered them.
+	Message  cid.Cid
+	Internal bool
+
+	From   address.Address
+	To     address.Address
+	Method abi.MethodNum
+	Value  types.BigInt
+
+	// Fee is the gas paid by the sender of a top level message, zero for
+	// internal transfers
+	Fee      types.BigInt
+	ExitCode exitcode.ExitCode
+}
+
+// Index follows the chain, and records the history of wallet addresses as
+// tipsets are applied, dropping it again when they are reverted. Addresses
+// are indexed from the moment they are in the wallet, older history isn't
+// backfilled.
+//
+// Datastore layout:
+//
+//	/head                            last indexed tipset
+//	/entries/<addr>/<height>/<n>     history entries
+//	/heights/<height>                addresses with entries at the height
+//
+// Computing the execution traces is slow, so tipsets are only traced when a
+// message is from or to the wallet, unless internal transfers are indexed.
+// Head changes only wake up a worker, which indexes up to the heaviest tipset. Head changes arriving while
+// the worker is busy are coalesced, and the head change subscription is never
+// held up by indexing.
+type Index struct {
+	ctx context.Context
+
+	cs   chainAPI
+	sm   stateAPI
+	w    walletAPI
+	ds   datastore.Batching
+	wake chan struct{}
+
+	// index value transfers made by actors, which requires tracing every
+	// tipset
+	internal bool
+}
+
+type chainAPI interface {
+	SubHeadChanges(ctx context.Context) chan []*store.HeadChange
+	GetHeaviestTipSet() *types.TipSet
+	LoadTipSet(tsk types.TipSetKey) (*types.TipSet, error)
+	ReorgOps(a, b *types.TipSet) ([]*types.TipSet, []*types.TipSet, error)
+	MessagesForTipset(ts *types.TipSet) ([]types.ChainMsg, error)
+}
+
+type stateAPI interface {
+	ExecutionTrace(ctx context.Context, ts *types.TipSet) (cid.Cid, []*api.InvocResult, error)
+	LookupID(ctx context.Context, addr address.Address, ts *types.TipSet) (address.Address, error)
+}
+
+type walletAPI interface {
+	ListAll() ([]wallet.AddrInfo, error)
+}
+
+func NewIndex(mctx helpers.MetricsCtx, lc fx.Lifecycle, sm *stmgr.StateManager, w *wallet.Wallet, ds dtypes.MetadataDS, cfg config.WalletHistory) *Index {
+	ctx, cancel := context.WithCancel(helpers.LifecycleCtx(mctx, lc))
+
+	idx := newIndex(ctx, sm.ChainStore(), sm, w, ds, cfg.InternalTransfers)
+
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go idx.run()
+			go idx.worker()
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			cancel()
+			return nil
+		},
+	})
+
+	return idx
+}
+
+func newIndex(ctx context.Context, cs chainAPI, sm stateAPI, w walletAPI, ds datastore.Batching, internal bool) *Index {
+	return &Index{
+		ctx:  ctx,
+		cs:   cs,
+		sm:   sm,
+		w:    w,
+		ds:   namespace.Wrap(ds, datastore.NewKey("/wallet-history")),
+		wake: make(chan struct{}, 1),
+
+		internal: internal,
+	}
+}
+
+// run wakes up the worker on head changes
+func (idx *Index) run() {
+	notifs := idx.cs.SubHeadChanges(idx.ctx)
+
+	for {
+		select {
+		case _, ok := <-notifs:
+			if !ok {
+				return
+			}
+
+			select {
+			case idx.wake <- struct{}{}:
+			default:
+				// the worker will index up to the latest head anyway
+			}
+		case <-idx.ctx.Done():
+			return
+		}
+	}
+}
+
+func (idx *Index) worker() {
+	for {
+		select {
+		case <-idx.wake:
+			head := idx.cs.GetHeaviestTipSet()
+			if err := idx.indexTo(head); err != nil {
+				log.Errorf("indexing wallet history (head %d): %+v", head.Height(), err)
+			}
+		case <-idx.ctx.Done():
+			return
+		}
+	}
+}
+
+// indexTo reverts and applies the tipsets between the last indexed tipset and
+// head. Progress is recorded after each tipset, so that indexing resumes from
+// there after a failure, or the next start of the node.
+func (idx *Index) indexTo(head *types.TipSet) error {
+	b, err := idx.ds.Get(headKey)
+	switch err {
+	case nil:
+	case datastore.ErrNotFound:
+		// start indexing from the current head
+		return idx.ds.Put(headKey, head.Key().Bytes())
+	default:
+		return err
+	}
+
+	tsk, err := types.TipSetKeyFromBytes(b)
+	if err != nil {
+		return xerrors.Errorf("decoding indexed head: %w", err)
+	}
+
+	last, err := idx.cs.LoadTipSet(tsk)
+	if err != nil {
+		return xerrors.Errorf("loading indexed head: %w", err)
+	}
+
+	revert, apply, err := idx.cs.ReorgOps(last, head)
+	if err != nil {
+		return xerrors.Errorf("computing reorg ops: %w", err)
+	}
+
+	for _, ts := range revert {
+		if err := idx.revert(ts); err != nil {
+			return err
+		}
+	}
+
+	// apply is ordered from the new head down
+	for i := len(apply) - 1; i >= 0; i-- {
+		if err := idx.apply(apply[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (idx *Index) apply(ts *types.TipSet) error {
+	entries, err := idx.collect(ts)
+	if err != nil {
+		return err
+	}
+
+	batch, err := idx.ds.Batch()
+	if err != nil {
+		return err
+	}
+
+	if len(entries) > 0 {
+		var addrs []address.Address
+		for addr, es := range entries {
+			addrs = append(addrs, addr)
+
+			for n, e := range es {
+				b, err := json.Marshal(e)
+				if err != nil {
+					return err
+				}
+
+				if err := batch.Put(entryKey(addr, ts.Height(), n), b); err != nil {
+					return err
+				}
+			}
+		}
+
+		b, err := json.Marshal(addrs)
+		if err != nil {
+			return err
+		}
+
+		if err := batch.Put(heightKey(ts.Height()), b); err != nil {
+			return err
+		}
+	}
+
+	if err := batch.Put(headKey, ts.Key().Bytes()); err != nil {
+		return err
+	}
+
+	return batch.Commit()
+}
+
+// collect returns the entries of the messages included in ts, by wallet
+// address
+func (idx *Index) collect(ts *types.TipSet) (map[address.Address][]*Entry, error) {
+	tracked, err := idx.trackedAddrs(ts)
+	if err != nil {
+		return nil, err
+	}
+	if len(tracked) == 0 {
+		return nil, nil
+	}
+
+	msgs, err := idx.cs.MessagesForTipset(ts)
+	if err != nil {
+		return nil, xerrors.Errorf("getting messages: %w", err)
+	}
+	if len(msgs) == 0 {
+		return nil, nil
+	}
+
+	if !idx.internal && !touchesTracked(msgs, tracked) {
+		return nil, nil
+	}
+
+	// value transfers made by actors only show up in the execution trace
+	_, trace, err := idx.sm.ExecutionTrace(idx.ctx, ts)
+	if err != nil {
+		return nil, xerrors.Errorf("computing execution trace: %w", err)
+	}
+
+	out := map[address.Address][]*Entry{}
+	add := func(e *Entry) {
+		from, fromTracked := tracked[e.From]
+		if fromTracked {
+			out[from] = append(out[from], e)
+		}
+
+		// don't record sends to self twice
+		if to, ok := tracked[e.To]; ok && !(fromTracked && to == from) {
+			out[to] = append(out[to], e)
+		}
+	}
+
+	for _, ir := range trace {
+		msg := ir.Msg
+		e := &Entry{
+			Height:   ts.Height(),
+			TipSet:   ts.Key(),
+			Message:  ir.MsgCid,
+			From:     msg.From,
+			To:       msg.To,
+			Method:   msg.Method,
+			Value:    msg.Value,
+			Fee:      types.BigMul(msg.GasPrice, types.NewInt(uint64(ir.MsgRct.GasUsed))),
+			ExitCode: ir.MsgRct.ExitCode,
+		}
+		add(e)
+
+		if ir.MsgRct.ExitCode != 0 {
+			// state changes of failed messages are reverted
+			continue
+		}
+
+		var walk func([]*types.ExecutionResult)
+		walk = func(ers []*types.ExecutionResult) {
+			for _, er := range ers {
+				if er.Msg.Value.GreaterThan(types.NewInt(0)) && er.MsgRct.ExitCode == 0 {
+					add(&Entry{
+						Height:   ts.Height(),
+						TipSet:   ts.Key(),
+						Message:  ir.MsgCid,
+						Internal: true,
+						From:     er.Msg.From,
+						To:       er.Msg.To,
+						Method:   er.Msg.Method,
+						Value:    er.Msg.Value,
+						Fee:      types.NewInt(0),
+						ExitCode: er.MsgRct.ExitCode,
+					})
+				}
+				walk(er.Subcalls)
+			}
+		}
+		walk(ir.InternalExecutions)
+	}
+
+	return out, nil
+}
+
+func touchesTracked(msgs []types.ChainMsg, tracked map[address.Address]address.Address) bool {
+	for _, m := range msgs {
+		msg := m.VMMessage()
+		if _, ok := tracked[msg.From]; ok {
+			return true
+		}
+		if _, ok := tracked[msg.To]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// trackedAddrs returns the wallet addresses, keyed by both the address and
+// its ID address, as messages may refer to either
+func (idx *Index) trackedAddrs(ts *types.TipSet) (map[address.Address]address.Address, error) {
+	infos, err := idx.w.ListAll()
+	if err != nil {
+		return nil, xerrors.Errorf("listing wallet addresses: %w", err)
+	}
+
+	out := map[address.Address]address.Address{}
+	for _, info := range infos {
+		out[info.Address] = info.Address
+
+		id, err := idx.sm.LookupID(idx.ctx, info.Address, ts)
+		if err != nil {
+			// not on chain yet
+			continue
+		}
+		out[id] = info.Address
+	}
+
+	return out, nil
+}
+
+func (idx *Index) revert(ts *types.TipSet) error {
+	batch, err := idx.ds.Batch()
+	if err != nil {
+		return err
+	}
+
+	b, err := idx.ds.Get(heightKey(ts.Height()))
+	switch err {
+	case nil:
+		var addrs []address.Address
+		if err := json.Unmarshal(b, &addrs); err != nil {
+			return xerrors.Errorf("decoding addresses at height %d: %w", ts.Height(), err)
+		}
+
+		for _, addr := range addrs {
+			keys, err := idx.keys(fmt.Sprintf("/entries/%s/%016x", addr, uint64(ts.Height())))
+			if err != nil {
+				return err
+			}
+
+			for _, k := range keys {
+				if err := batch.Delete(datastore.NewKey(k)); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := batch.Delete(heightKey(ts.Height())); err != nil {
+			return err
+		}
+	case datastore.ErrNotFound:
+	default:
+		return err
+	}
+
+	if err := batch.Put(headKey, ts.Parents().Bytes()); err != nil {
+		return err
+	}
+
+	return batch.Commit()
+}
+
+// History returns the history of addr at and below fromHeight, newest first.
+// A negative fromHeight starts from the latest entries, and limit 0 returns
+// all entries.
+func (idx *Index) History(addr address.Address, fromHeight abi.ChainEpoch, limit int) ([]*Entry, error) {
+	keys, err := idx.keys(fmt.Sprintf("/entries/%s", addr))
+	if err != nil {
+		return nil, err
+	}
+
+	// keys are zero padded, so they sort by height
+	sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+
+	var out []*Entry
+	for _, k := range keys {
+		b, err := idx.ds.Get(datastore.NewKey(k))
+		if err != nil {
+			return nil, err
+		}
+
+		var e Entry
+		if err := json.Unmarshal(b, &e); err != nil {
+			return nil, xerrors.Errorf("decoding history entry %s: %w", k, err)
+		}
+
+		if fromHeight >= 0 && e.Height > fromHeight {
+			continue
+		}
+
+		out = append(out, &e)
+		if limit > 0 && len(out) >= limit {
+			break
+		}
+	}
+
+	return out, nil
+}
+
+func (idx *Index) keys(prefix string) ([]string, error) {
+	res, err := idx.ds.Query(dsq.Query{Prefix: prefix, KeysOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	var out []string
+	for r := range res.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		if !strings.HasPrefix(r.Key, prefix+"/") {
+			// /entries/t01 also matches /entries/t010 on some datastores
+			continue
+		}
+		out = append(out, r.Key)
+	}
+
+	return out, nil
+}
+
+func entryKey(addr address.Address, h abi.ChainEpoch, n int) datastore.Key {
+	return datastore.NewKey(fmt.Sprintf("/entries/%s/%016x/%08x", addr, uint64(h), n))
+}
+
+func heightKey(h abi.ChainEpoch) datastore.Key {
+	return datastore.NewKey(fmt.Sprintf("/heights/%016x", uint64(h)))
+}
@@ -0,0 +1,311 @@
+package history
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/store"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/types/mock"
+	"github.com/filecoin-project/lotus/chain/wallet"
+)
+
+type fakeChain struct {
+	lk      sync.Mutex
+	head    *types.TipSet
+	tipsets map[types.TipSetKey]*types.TipSet
+	traces  map[types.TipSetKey][]*api.InvocResult
+
+	notifs chan []*store.HeadChange
+	// when set, computing execution traces waits for it to be closed
+	block chan struct{}
+	// number of computed execution traces
+	traced int
+}
+
+func newFakeChain(gen *types.TipSet) *fakeChain {
+	return &fakeChain{
+		head:    gen,
+		tipsets: map[types.TipSetKey]*types.TipSet{gen.Key(): gen},
+		traces:  map[types.TipSetKey][]*api.InvocResult{},
+		notifs:  make(chan []*store.HeadChange),
+	}
+}
+
+func (c *fakeChain) add(parent *types.TipSet, nonce uint64, trace ...*api.InvocResult) *types.TipSet {
+	ts := mock.TipSet(mock.MkBlock(parent, 1, nonce))
+
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	c.tipsets[ts.Key()] = ts
+	c.traces[ts.Key()] = trace
+	return ts
+}
+
+func (c *fakeChain) setHead(ts *types.TipSet) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	c.head = ts
+}
+
+func (c *fakeChain) SubHeadChanges(ctx context.Context) chan []*store.HeadChange {
+	return c.notifs
+}
+
+func (c *fakeChain) GetHeaviestTipSet() *types.TipSet {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	return c.head
+}
+
+func (c *fakeChain) LoadTipSet(tsk types.TipSetKey) (*types.TipSet, error) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	ts, ok := c.tipsets[tsk]
+	if !ok {
+		return nil, datastore.ErrNotFound
+	}
+	return ts, nil
+}
+
+func (c *fakeChain) ReorgOps(a, b *types.TipSet) ([]*types.TipSet, []*types.TipSet, error) {
+	var left, right []*types.TipSet
+	for !a.Equals(b) {
+		var err error
+		if a.Height() > b.Height() {
+			left = append(left, a)
+			a, err = c.LoadTipSet(a.Parents())
+		} else {
+			right = append(right, b)
+			b, err = c.LoadTipSet(b.Parents())
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return left, right, nil
+}
+
+func (c *fakeChain) MessagesForTipset(ts *types.TipSet) ([]types.ChainMsg, error) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	var out []types.ChainMsg
+	for _, ir := range c.traces[ts.Key()] {
+		out = append(out, ir.Msg)
+	}
+	return out, nil
+}
+
+func (c *fakeChain) ExecutionTrace(ctx context.Context, ts *types.TipSet) (cid.Cid, []*api.InvocResult, error) {
+	if c.block != nil {
+		<-c.block
+	}
+
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	c.traced++
+	return cid.Undef, c.traces[ts.Key()], nil
+}
+
+func (c *fakeChain) LookupID(ctx context.Context, addr address.Address, ts *types.TipSet) (address.Address, error) {
+	return address.Undef, datastore.ErrNotFound
+}
+
+type fakeWallet []address.Address
+
+func (w fakeWallet) ListAll() ([]wallet.AddrInfo, error) {
+	var out []wallet.AddrInfo
+	for _, a := range w {
+		out = append(out, wallet.AddrInfo{Address: a})
+	}
+	return out, nil
+}
+
+func send(from, to address.Address, value uint64, internal ...*types.ExecutionResult) *api.InvocResult {
+	msg := &types.Message{From: from, To: to, Value: types.NewInt(value), GasPrice: types.NewInt(1)}
+	return &api.InvocResult{
+		MsgCid:             msg.Cid(),
+		Msg:                msg,
+		MsgRct:             &types.MessageReceipt{GasUsed: 10},
+		InternalExecutions: internal,
+	}
+}
+
+func internalSend(from, to address.Address, value uint64) *types.ExecutionResult {
+	return &types.ExecutionResult{
+		Msg:    &types.Message{From: from, To: to, Value: types.NewInt(value)},
+		MsgRct: &types.MessageReceipt{},
+	}
+}
+
+func testAddrs(t *testing.T, ids ...uint64) []address.Address {
+	var out []address.Address
+	for _, id := range ids {
+		a, err := address.NewIDAddress(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
+func TestIndexApplyRevert(t *testing.T) {
+	addrs := testAddrs(t, 100, 101, 102)
+	mine, other, actor := addrs[0], addrs[1], addrs[2]
+
+	gen := mock.TipSet(mock.MkBlock(nil, 1, 0))
+	c := newFakeChain(gen)
+	idx := newIndex(context.TODO(), c, c, fakeWallet{mine}, dssync.MutexWrap(datastore.NewMapDatastore()), true)
+
+	// the first call only records the head
+	if err := idx.indexTo(gen); err != nil {
+		t.Fatal(err)
+	}
+
+	a1 := c.add(gen, 1, send(mine, other, 5))
+	a2 := c.add(a1, 2, send(other, actor, 7, internalSend(actor, mine, 3)))
+	b1 := c.add(gen, 3, send(other, mine, 11))
+
+	if err := idx.indexTo(a2); err != nil {
+		t.Fatal(err)
+	}
+
+	hist, err := idx.History(mine, -1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hist) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(hist))
+	}
+	// newest first
+	if !hist[0].Internal || hist[0].From != actor || !hist[0].Value.Equals(types.NewInt(3)) {
+		t.Errorf("unexpected internal transfer %+v", hist[0])
+	}
+	if hist[1].Internal || hist[1].To != other || !hist[1].Fee.Equals(types.NewInt(10)) {
+		t.Errorf("unexpected message %+v", hist[1])
+	}
+
+	// the other address isn't in the wallet
+	hist, err = idx.History(other, -1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hist) != 0 {
+		t.Errorf("expected no entries for an address outside the wallet, got %d", len(hist))
+	}
+
+	// reorg to b1 drops the entries of a1 and a2
+	if err := idx.indexTo(b1); err != nil {
+		t.Fatal(err)
+	}
+
+	hist, err = idx.History(mine, -1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hist) != 1 || hist[0].From != other || !hist[0].Value.Equals(types.NewInt(11)) {
+		t.Fatalf("expected only the entry of b1, got %+v", hist)
+	}
+}
+
+func TestIndexSkipsUntouchedTipsets(t *testing.T) {
+	addrs := testAddrs(t, 100, 101, 102)
+	mine, other, actor := addrs[0], addrs[1], addrs[2]
+
+	gen := mock.TipSet(mock.MkBlock(nil, 1, 0))
+	c := newFakeChain(gen)
+	idx := newIndex(context.TODO(), c, c, fakeWallet{mine}, dssync.MutexWrap(datastore.NewMapDatastore()), false)
+
+	if err := idx.indexTo(gen); err != nil {
+		t.Fatal(err)
+	}
+
+	a1 := c.add(gen, 1, send(other, actor, 7, internalSend(actor, mine, 3)))
+	a2 := c.add(a1, 2, send(other, mine, 11))
+
+	if err := idx.indexTo(a2); err != nil {
+		t.Fatal(err)
+	}
+
+	// only a2 has a message to the wallet
+	if c.traced != 1 {
+		t.Errorf("expected 1 execution trace, got %d", c.traced)
+	}
+
+	// the internal transfer isn't indexed
+	hist, err := idx.History(mine, -1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hist) != 1 || hist[0].Internal || !hist[0].Value.Equals(types.NewInt(11)) {
+		t.Fatalf("expected only the message of a2, got %+v", hist)
+	}
+}
+
+func TestIndexDoesntBlockHeadChanges(t *testing.T) {
+	mine := testAddrs(t, 100)[0]
+
+	gen := mock.TipSet(mock.MkBlock(nil, 1, 0))
+	c := newFakeChain(gen)
+	c.block = make(chan struct{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	idx := newIndex(ctx, c, c, fakeWallet{mine}, dssync.MutexWrap(datastore.NewMapDatastore()), false)
+	if err := idx.indexTo(gen); err != nil {
+		t.Fatal(err)
+	}
+
+	go idx.run()
+	go idx.worker()
+
+	// indexing blocks on the first tipset, while head changes keep coming
+	head := gen
+	for i := uint64(1); i <= 10; i++ {
+		head = c.add(head, i, send(mine, mine, i))
+		c.setHead(head)
+
+		select {
+		case c.notifs <- []*store.HeadChange{{Type: store.HCApply, Val: head}}:
+		case <-time.After(5 * time.Second):
+			t.Fatal("head change subscription blocked by indexing")
+		}
+	}
+
+	close(c.block)
+
+	// the head changes which arrived while the worker was busy are coalesced
+	// into one more run, which catches up to the latest head
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		hist, err := idx.History(mine, -1, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(hist) == 10 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected 10 entries, got %d", len(hist))
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
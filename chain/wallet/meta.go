@@ -0,0 +1,314 @@
+package wallet
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+const (
+	// KMetaPrefix prefixes the keystore names of address metadata
+	KMetaPrefix = "meta-"
+	// KTrashPrefix prefixes the keystore names of deleted keys, which are
+	// kept so that they can be restored
+	KTrashPrefix = "trash-"
+	// KTrashDefaultPrefix prefixes the keystore names of deleted keys which
+	// were the default, they are restored as the default
+	KTrashDefaultPrefix = "trash-default-"
+
+	KTMeta = "meta"
+)
+
+// AddrMeta is the metadata of an address, stored in the keystore next to its
+// key. Watch-only addresses only have metadata.
+type AddrMeta struct {
+	Label     string
+	Tags      []string
+	WatchOnly bool
+}
+
+// AddrInfo describes an address in the wallet
+type AddrInfo struct {
+	Address address.Address
+
+	// KeyType is the type of the key, empty for watch-only addresses
+	KeyType string
+	// HD is set for addresses derived from the HD seed
+	HD bool
+
+	AddrMeta
+}
+
+// ListAll lists addresses with keys and watch-only addresses, along with
+// their metadata
+func (w *Wallet) ListAll() ([]AddrInfo, error) {
+	w.lk.Lock()
+	defer w.lk.Unlock()
+
+	all, err := w.keystore.List()
+	if err != nil {
+		return nil, xerrors.Errorf("listing keystore: %w", err)
+	}
+
+	sort.Strings(all)
+
+	out := make([]AddrInfo, 0, len(all))
+	for _, name := range all {
+		var addrStr string
+		switch {
+		case strings.HasPrefix(name, KNamePrefix):
+			addrStr = strings.TrimPrefix(name, KNamePrefix)
+		case strings.HasPrefix(name, KMetaPrefix):
+			addrStr = strings.TrimPrefix(name, KMetaPrefix)
+		default:
+			continue
+		}
+
+		addr, err := address.NewFromString(addrStr)
+		if err != nil {
+			return nil, xerrors.Errorf("converting name to address: %w", err)
+		}
+
+		meta, err := w.getMeta(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		if strings.HasPrefix(name, KMetaPrefix) {
+			if meta.WatchOnly {
+				out = append(out, AddrInfo{Address: addr, AddrMeta: *meta})
+			}
+			continue
+		}
+
+		ki, err := w.keystore.Get(name)
+		if err != nil {
+			return nil, xerrors.Errorf("getting %s from keystore: %w", addr, err)
+		}
+
+		info := AddrInfo{
+			Address:  addr,
+			KeyType:  ki.Type,
+			AddrMeta: *meta,
+		}
+		if ki.Type == KTHD {
+			hki, err := decodeHDKeyInfo(ki)
+			if err != nil {
+				return nil, err
+			}
+			info.KeyType = hki.Type
+			info.HD = true
+		}
+
+		out = append(out, info)
+	}
+
+	return out, nil
+}
+
+// SetLabel sets the label of an address in the wallet
+func (w *Wallet) SetLabel(addr address.Address, label string) error {
+	return w.updateMeta(addr, func(meta *AddrMeta) {
+		meta.Label = label
+	})
+}
+
+// SetTags replaces the tags of an address in the wallet
+func (w *Wallet) SetTags(addr address.Address, tags []string) error {
+	return w.updateMeta(addr, func(meta *AddrMeta) {
+		meta.Tags = tags
+	})
+}
+
+// AddWatch adds an address the wallet doesn't hold a key for, so that it's
+// listed with the wallet addresses
+func (w *Wallet) AddWatch(addr address.Address, label string) error {
+	w.lk.Lock()
+	defer w.lk.Unlock()
+
+	has, err := w.hasKeyEntry(addr)
+	if err != nil {
+		return err
+	}
+	if has {
+		return xerrors.Errorf("wallet has the key of %s", addr)
+	}
+
+	meta, err := w.getMeta(addr)
+	if err != nil {
+		return err
+	}
+
+	meta.WatchOnly = true
+	if label != "" {
+		meta.Label = label
+	}
+
+	return w.putMeta(addr, meta)
+}
+
+// Delete removes an address from the wallet. The key is moved to the trash,
+// from where Undelete can restore it. Deleting a watch-only address removes
+// it along with its metadata.
+func (w *Wallet) Delete(addr address.Address) error {
+	w.lk.Lock()
+	defer w.lk.Unlock()
+
+	meta, err := w.getMeta(addr)
+	if err != nil {
+		return err
+	}
+
+	if meta.WatchOnly {
+		return w.keystore.Delete(KMetaPrefix + addr.String())
+	}
+
+	ki, err := w.keystore.Get(KNamePrefix + addr.String())
+	if err != nil {
+		return xerrors.Errorf("getting key of %s: %w", addr, err)
+	}
+
+	if err := w.replaceKey(KTrashPrefix+addr.String(), ki); err != nil {
+		return xerrors.Errorf("backing up key: %w", err)
+	}
+
+	if err := w.keystore.Delete(KNamePrefix + addr.String()); err != nil {
+		return xerrors.Errorf("deleting key: %w", err)
+	}
+	delete(w.keys, addr)
+
+	def, err := w.getDefault()
+	switch {
+	case err == nil:
+		if def == addr {
+			dki, err := w.keystore.Get(KDefault)
+			if err != nil {
+				return xerrors.Errorf("getting default key: %w", err)
+			}
+			if err := w.replaceKey(KTrashDefaultPrefix+addr.String(), dki); err != nil {
+				return xerrors.Errorf("backing up default key: %w", err)
+			}
+			if err := w.keystore.Delete(KDefault); err != nil {
+				return xerrors.Errorf("unsetting default address: %w", err)
+			}
+		}
+	case xerrors.Is(err, types.ErrKeyInfoNotFound):
+	default:
+		return err
+	}
+
+	return nil
+}
+
+// Undelete restores a key removed with Delete. A key which was the default
+// when it was deleted is restored as the default, unless another default was
+// set in the meantime.
+func (w *Wallet) Undelete(addr address.Address) error {
+	w.lk.Lock()
+	defer w.lk.Unlock()
+
+	ki, err := w.keystore.Get(KTrashPrefix + addr.String())
+	if err != nil {
+		return xerrors.Errorf("getting deleted key of %s: %w", addr, err)
+	}
+
+	if err := w.keystore.Put(KNamePrefix+addr.String(), ki); err != nil {
+		return xerrors.Errorf("restoring key: %w", err)
+	}
+
+	dki, err := w.keystore.Get(KTrashDefaultPrefix + addr.String())
+	switch {
+	case err == nil:
+		_, err := w.keystore.Get(KDefault)
+		switch {
+		case xerrors.Is(err, types.ErrKeyInfoNotFound):
+			if err := w.keystore.Put(KDefault, dki); err != nil {
+				return xerrors.Errorf("restoring default address: %w", err)
+			}
+		case err != nil:
+			return xerrors.Errorf("getting default key: %w", err)
+		}
+
+		if err := w.keystore.Delete(KTrashDefaultPrefix + addr.String()); err != nil {
+			return xerrors.Errorf("deleting default key backup: %w", err)
+		}
+	case xerrors.Is(err, types.ErrKeyInfoNotFound):
+	default:
+		return xerrors.Errorf("getting default key backup: %w", err)
+	}
+
+	return w.keystore.Delete(KTrashPrefix + addr.String())
+}
+
+func (w *Wallet) updateMeta(addr address.Address, cb func(*AddrMeta)) error {
+	w.lk.Lock()
+	defer w.lk.Unlock()
+
+	meta, err := w.getMeta(addr)
+	if err != nil {
+		return err
+	}
+
+	if !meta.WatchOnly {
+		has, err := w.hasKeyEntry(addr)
+		if err != nil {
+			return err
+		}
+		if !has {
+			return xerrors.Errorf("address %s is not in the wallet", addr)
+		}
+	}
+
+	cb(meta)
+	return w.putMeta(addr, meta)
+}
+
+// Must be called with w.lk held.
+func (w *Wallet) hasKeyEntry(addr address.Address) (bool, error) {
+	_, err := w.keystore.Get(KNamePrefix + addr.String())
+	switch {
+	case err == nil:
+		return true, nil
+	case xerrors.Is(err, types.ErrKeyInfoNotFound):
+		return false, nil
+	default:
+		return false, xerrors.Errorf("getting from keystore: %w", err)
+	}
+}
+
+// Must be called with w.lk held.
+func (w *Wallet) getMeta(addr address.Address) (*AddrMeta, error) {
+	ki, err := w.keystore.Get(KMetaPrefix + addr.String())
+	if err != nil {
+		if xerrors.Is(err, types.ErrKeyInfoNotFound) {
+			return &AddrMeta{}, nil
+		}
+		return nil, xerrors.Errorf("getting metadata of %s: %w", addr, err)
+	}
+
+	var meta AddrMeta
+	if err := json.Unmarshal(ki.PrivateKey, &meta); err != nil {
+		return nil, xerrors.Errorf("decoding metadata of %s: %w", addr, err)
+	}
+
+	return &meta, nil
+}
+
+// Must be called with w.lk held.
+func (w *Wallet) putMeta(addr address.Address, meta *AddrMeta) error {
+	b, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+
+	return w.replaceKey(KMetaPrefix+addr.String(), types.KeyInfo{
+		Type:       KTMeta,
+		PrivateKey: b,
+	})
+}
@@ -0,0 +1,185 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/crypto"
+)
+
+func TestWalletMeta(t *testing.T) {
+	w, err := NewWallet(NewMemKeyStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := w.GenerateKey(crypto.SigTypeSecp256k1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	watch, err := address.NewIDAddress(1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unknown, err := address.NewIDAddress(1001)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.SetLabel(key, "hot"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.SetTags(key, []string{"a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AddWatch(watch, "cold"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.SetLabel(unknown, "x"); err == nil {
+		t.Error("expected labeling an address outside the wallet to fail")
+	}
+	if err := w.AddWatch(key, ""); err == nil {
+		t.Error("expected watching an address with a key to fail")
+	}
+
+	all, err := w.ListAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 {
+		t.Fatalf("expected 2 addresses, got %d", len(all))
+	}
+
+	infos := map[address.Address]AddrInfo{}
+	for _, info := range all {
+		infos[info.Address] = info
+	}
+
+	if info := infos[key]; info.Label != "hot" || len(info.Tags) != 2 || info.WatchOnly || info.KeyType == "" {
+		t.Errorf("unexpected key info %+v", info)
+	}
+	if info := infos[watch]; info.Label != "cold" || !info.WatchOnly || info.KeyType != "" {
+		t.Errorf("unexpected watch-only info %+v", info)
+	}
+
+	// deleting a watch-only address drops it with its metadata
+	if err := w.Delete(watch); err != nil {
+		t.Fatal(err)
+	}
+	all, err = w.ListAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 || all[0].Address != key {
+		t.Errorf("expected only the key to be left, got %+v", all)
+	}
+}
+
+func TestWalletDeleteUndelete(t *testing.T) {
+	w, err := NewWallet(NewMemKeyStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := w.GenerateKey(crypto.SigTypeSecp256k1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := w.GenerateKey(crypto.SigTypeSecp256k1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkDefault := func(expect address.Address) {
+		t.Helper()
+
+		def, err := w.GetDefault()
+		if expect == address.Undef {
+			if err == nil {
+				t.Errorf("expected no default, got %s", def)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if def != expect {
+			t.Errorf("expected default %s, got %s", expect, def)
+		}
+	}
+
+	checkHas := func(addr address.Address, expect bool) {
+		t.Helper()
+
+		has, err := w.HasKey(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if has != expect {
+			t.Errorf("expected has key %t for %s, got %t", expect, addr, has)
+		}
+	}
+
+	// the first key is the default
+	checkDefault(a)
+
+	if err := w.Delete(b); err != nil {
+		t.Fatal(err)
+	}
+	checkHas(b, false)
+	checkDefault(a)
+
+	if err := w.Undelete(b); err != nil {
+		t.Fatal(err)
+	}
+	checkHas(b, true)
+	checkDefault(a)
+
+	if err := w.Undelete(b); err == nil {
+		t.Error("expected undeleting a key which isn't deleted to fail")
+	}
+
+	// the default is restored with its key
+	if err := w.Delete(a); err != nil {
+		t.Fatal(err)
+	}
+	checkHas(a, false)
+	checkDefault(address.Undef)
+
+	if err := w.Undelete(a); err != nil {
+		t.Fatal(err)
+	}
+	checkHas(a, true)
+	checkDefault(a)
+
+	// unless another default was set since
+	if err := w.Delete(a); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.SetDefault(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Undelete(a); err != nil {
+		t.Fatal(err)
+	}
+	checkDefault(b)
+
+	// the default backup is dropped on undelete, so a later delete and
+	// undelete of a key which isn't the default doesn't make it the default
+	if err := w.Delete(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Delete(a); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Undelete(a); err != nil {
+		t.Fatal(err)
+	}
+	checkDefault(address.Undef)
+
+	if err := w.Undelete(b); err != nil {
+		t.Fatal(err)
+	}
+	checkDefault(b)
+}
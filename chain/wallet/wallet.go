@@ -32,6 +32,9 @@ type Wallet struct {
 	keys     map[address.Address]*Key
 	keystore types.KeyStore
 
+	// decrypted HD seed, set once the HD wallet is unlocked
+	hdSeed []byte
+
 	lk sync.Mutex
 }
 
@@ -67,6 +70,26 @@ func (w *Wallet) Sign(ctx context.Context, addr address.Address, msg []byte) (*c
 	return sigs.Sign(ActSigType(ki.Type), ki.PrivateKey, msg)
 }
 
+// SignData signs a data envelope. Unlike Sign, the signed bytes are domain
+// separated, so they can't be a message, or data signed for another purpose.
+func (w *Wallet) SignData(ctx context.Context, addr address.Address, d *sigs.DataEnvelope) (*sigs.SignedData, error) {
+	b, err := d.SigningBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := w.Sign(ctx, addr, b)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sigs.SignedData{
+		DataEnvelope: *d,
+		Address:      addr,
+		Signature:    *sig,
+	}, nil
+}
+
 func (w *Wallet) findKey(addr address.Address) (*Key, error) {
 	w.lk.Lock()
 	defer w.lk.Unlock()
@@ -87,6 +110,14 @@ func (w *Wallet) findKey(addr address.Address) (*Key, error) {
 		}
 		return nil, xerrors.Errorf("getting from keystore: %w", err)
 	}
+	if ki.Type == KTHD {
+		k, err = w.findHDKey(ki)
+		if err != nil {
+			return nil, err
+		}
+		w.keys[k.Address] = k
+		return k, nil
+	}
 	k, err = NewKey(ki)
 	if err != nil {
 		return nil, xerrors.Errorf("decoding from keystore: %w", err)
@@ -117,6 +148,17 @@ func (w *Wallet) Import(ki *types.KeyInfo) (address.Address, error) {
 		return address.Undef, xerrors.Errorf("saving to keystore: %w", err)
 	}
 
+	meta, err := w.getMeta(k.Address)
+	if err != nil {
+		return address.Undef, err
+	}
+	if meta.WatchOnly {
+		meta.WatchOnly = false
+		if err := w.putMeta(k.Address, meta); err != nil {
+			return address.Undef, xerrors.Errorf("updating metadata: %w", err)
+		}
+	}
+
 	return k.Address, nil
 }
 
@@ -147,11 +189,24 @@ func (w *Wallet) GetDefault() (address.Address, error) {
 	w.lk.Lock()
 	defer w.lk.Unlock()
 
+	return w.getDefault()
+}
+
+// Must be called with w.lk held.
+func (w *Wallet) getDefault() (address.Address, error) {
 	ki, err := w.keystore.Get(KDefault)
 	if err != nil {
 		return address.Undef, xerrors.Errorf("failed to get default key: %w", err)
 	}
 
+	if ki.Type == KTHD {
+		hki, err := decodeHDKeyInfo(ki)
+		if err != nil {
+			return address.Undef, xerrors.Errorf("failed to read default key from keystore: %w", err)
+		}
+		return hki.Address, nil
+	}
+
 	k, err := NewKey(ki)
 	if err != nil {
 		return address.Undef, xerrors.Errorf("failed to read default key from keystore: %w", err)
@@ -225,6 +280,10 @@ func (w *Wallet) GenerateKey(typ crypto.SigType) (address.Address, error) {
 func (w *Wallet) HasKey(addr address.Address) (bool, error) {
 	k, err := w.findKey(addr)
 	if err != nil {
+		if xerrors.Is(err, ErrHDLocked) {
+			// the address is in the wallet, its key just can't be used yet
+			return true, nil
+		}
 		return false, err
 	}
 	return k != nil, nil
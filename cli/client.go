@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -31,6 +32,7 @@ var clientCmd = &cli.Command{
 		clientRetrieveCmd,
 		clientQueryAskCmd,
 		clientListDeals,
+		clientMarketCmd,
 	},
 }
 
@@ -436,3 +438,114 @@ var clientListDeals = &cli.Command{
 		return w.Flush()
 	},
 }
+
+var clientMarketCmd = &cli.Command{
+	Name:  "market",
+	Usage: "Manage storage market escrow funds",
+	Subcommands: []*cli.Command{
+		clientMarketBalanceCmd,
+		clientMarketWithdrawCmd,
+	},
+}
+
+var clientMarketBalanceCmd = &cli.Command{
+	Name:  "balance",
+	Usage: "Show market escrow balance, with the funds reserved for deals",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "address",
+			Usage: "market participant address, defaults to the default wallet address",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		addr, err := clientMarketAddress(cctx, api)
+		if err != nil {
+			return err
+		}
+
+		return PrintMarketBalance(ctx, api, addr)
+	},
+}
+
+var clientMarketWithdrawCmd = &cli.Command{
+	Name:      "withdraw",
+	Usage:     "Withdraw funds which aren't locked or reserved from market escrow",
+	ArgsUsage: "[amount (FIL)]",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "address",
+			Usage: "market participant address, defaults to the default wallet address",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 1 {
+			return xerrors.Errorf("must pass amount to withdraw")
+		}
+
+		amt, err := types.ParseFIL(cctx.Args().First())
+		if err != nil {
+			return xerrors.Errorf("parsing amount: %w", err)
+		}
+
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		addr, err := clientMarketAddress(cctx, api)
+		if err != nil {
+			return err
+		}
+
+		mcid, err := api.MarketWithdraw(ctx, addr, addr, types.BigInt(amt))
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Withdrawing %s in message %s\n", amt, mcid)
+		return nil
+	},
+}
+
+func clientMarketAddress(cctx *cli.Context, api lapi.FullNode) (address.Address, error) {
+	if cctx.IsSet("address") {
+		return address.NewFromString(cctx.String("address"))
+	}
+
+	return api.WalletDefaultAddress(ReqContext(cctx))
+}
+
+// PrintMarketBalance prints the market escrow of addr, split into locked,
+// reserved and available funds
+func PrintMarketBalance(ctx context.Context, api lapi.FullNode, addr address.Address) error {
+	bal, err := api.StateMarketBalance(ctx, addr, types.EmptyTSK)
+	if err != nil {
+		return xerrors.Errorf("getting market balance: %w", err)
+	}
+
+	reserved, err := api.MarketGetReserved(ctx, addr)
+	if err != nil {
+		return xerrors.Errorf("getting reserved funds: %w", err)
+	}
+
+	avail := types.BigSub(types.BigSub(bal.Escrow, bal.Locked), reserved)
+	if avail.LessThan(types.NewInt(0)) {
+		avail = types.NewInt(0)
+	}
+
+	fmt.Printf("Address:   %s\n", addr)
+	fmt.Printf("Escrow:    %s\n", types.FIL(bal.Escrow))
+	fmt.Printf("Locked:    %s\n", types.FIL(bal.Locked))
+	fmt.Printf("Reserved:  %s\n", types.FIL(reserved))
+	fmt.Printf("Available: %s\n", types.FIL(avail))
+	return nil
+}
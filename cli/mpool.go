@@ -1,9 +1,14 @@
 package cli
 
 import (
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"os"
 	"sort"
+	"strconv"
+	"strings"
 
 	"golang.org/x/xerrors"
 	"gopkg.in/urfave/cli.v2"
@@ -20,6 +25,9 @@ var mpoolCmd = &cli.Command{
 		mpoolPending,
 		mpoolSub,
 		mpoolStat,
+		mpoolPush,
+		mpoolReplaceCmd,
+		mpoolCancelCmd,
 	},
 }
 
@@ -176,3 +184,174 @@ var mpoolStat = &cli.Command{
 		return nil
 	},
 }
+
+var mpoolPush = &cli.Command{
+	Name:      "push",
+	Usage:     "Push a hex encoded signed message, as created by 'lotus-shed sign-message'",
+	ArgsUsage: "[file (or - for stdin)]",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "force",
+			Usage: "push the message even if its nonce leaves a gap",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := ReqContext(cctx)
+
+		var data []byte
+		if !cctx.Args().Present() || cctx.Args().First() == "-" {
+			data, err = ioutil.ReadAll(os.Stdin)
+		} else {
+			data, err = ioutil.ReadFile(cctx.Args().First())
+		}
+		if err != nil {
+			return xerrors.Errorf("reading message: %w", err)
+		}
+
+		b, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil {
+			return xerrors.Errorf("decoding hex: %w", err)
+		}
+
+		smsg, err := types.DecodeSignedMessage(b)
+		if err != nil {
+			return xerrors.Errorf("decoding signed message: %w", err)
+		}
+
+		act, err := api.StateGetActor(ctx, smsg.Message.From, types.EmptyTSK)
+		if err != nil {
+			return xerrors.Errorf("getting sender actor: %w", err)
+		}
+
+		if smsg.Message.Nonce < act.Nonce {
+			return xerrors.Errorf("message nonce %d was already used, actor nonce is %d", smsg.Message.Nonce, act.Nonce)
+		}
+
+		next, err := api.MpoolGetNonce(ctx, smsg.Message.From)
+		if err != nil {
+			return xerrors.Errorf("getting mpool nonce: %w", err)
+		}
+
+		switch {
+		case smsg.Message.Nonce > next && !cctx.Bool("force"):
+			return xerrors.Errorf("message nonce %d leaves a gap, next nonce is %d (use --force to push anyway)", smsg.Message.Nonce, next)
+		case smsg.Message.Nonce < next:
+			fmt.Printf("warning: a pending message with nonce %d already exists, this message may replace it\n", smsg.Message.Nonce)
+		}
+
+		if err := checkMessageFunds(act, &smsg.Message); err != nil {
+			return err
+		}
+
+		c, err := api.MpoolPush(ctx, smsg)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(c)
+		return nil
+	},
+}
+
+func parseFromNonce(cctx *cli.Context) (address.Address, uint64, error) {
+	if cctx.Args().Len() != 2 {
+		return address.Undef, 0, xerrors.Errorf("expected two arguments, sender address and nonce")
+	}
+
+	from, err := address.NewFromString(cctx.Args().Get(0))
+	if err != nil {
+		return address.Undef, 0, xerrors.Errorf("parsing sender address: %w", err)
+	}
+
+	nonce, err := strconv.ParseUint(cctx.Args().Get(1), 10, 64)
+	if err != nil {
+		return address.Undef, 0, xerrors.Errorf("parsing nonce: %w", err)
+	}
+
+	return from, nonce, nil
+}
+
+var mpoolReplaceCmd = &cli.Command{
+	Name:      "replace",
+	Usage:     "Replace a pending message with one paying a higher gas price",
+	ArgsUsage: "[from nonce]",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "gas-price",
+			Usage: "gas price for the replacement message, in AttoFIL",
+		},
+		&cli.BoolFlag{
+			Name:  "auto",
+			Usage: "use the lowest gas price which will replace the message",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := ReqContext(cctx)
+
+		from, nonce, err := parseFromNonce(cctx)
+		if err != nil {
+			return err
+		}
+
+		auto := cctx.Bool("auto")
+		if auto == cctx.IsSet("gas-price") {
+			return xerrors.Errorf("must specify exactly one of --gas-price or --auto")
+		}
+
+		gp := types.EmptyInt
+		if !auto {
+			gp, err = types.BigFromString(cctx.String("gas-price"))
+			if err != nil {
+				return xerrors.Errorf("parsing gas price: %w", err)
+			}
+		}
+
+		c, err := api.MpoolReplace(ctx, from, nonce, auto, gp)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("replaced with message: ", c)
+		return nil
+	},
+}
+
+var mpoolCancelCmd = &cli.Command{
+	Name:      "cancel",
+	Usage:     "Cancel a pending message by replacing it with an empty send to self",
+	ArgsUsage: "[from nonce]",
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := ReqContext(cctx)
+
+		from, nonce, err := parseFromNonce(cctx)
+		if err != nil {
+			return err
+		}
+
+		c, err := api.MpoolCancel(ctx, from, nonce)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("canceled with message: ", c)
+		return nil
+	},
+}
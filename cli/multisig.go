@@ -3,29 +3,22 @@ package cli
 import (
 	"bytes"
 	"context"
-	"encoding/binary"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"os"
-	"sort"
 	"strconv"
 	"text/tabwriter"
 
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/specs-actors/actors/abi"
-	"github.com/filecoin-project/specs-actors/actors/builtin"
-	init_ "github.com/filecoin-project/specs-actors/actors/builtin/init"
 	samsig "github.com/filecoin-project/specs-actors/actors/builtin/multisig"
 	cid "github.com/ipfs/go-cid"
-	"github.com/ipfs/go-hamt-ipld"
-	cbor "github.com/ipfs/go-ipld-cbor"
 	cbg "github.com/whyrusleeping/cbor-gen"
-	"golang.org/x/xerrors"
 	"gopkg.in/urfave/cli.v2"
 
-	"github.com/filecoin-project/lotus/api"
-	"github.com/filecoin-project/lotus/api/apibstore"
-	actors "github.com/filecoin-project/lotus/chain/actors"
+	lapi "github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/actors"
 	types "github.com/filecoin-project/lotus/chain/types"
 )
 
@@ -37,12 +30,18 @@ var multisigCmd = &cli.Command{
 			Name:  "source",
 			Usage: "specify the account to send propose from",
 		},
+		unsignedFlag,
 	},
 	Subcommands: []*cli.Command{
 		msigCreateCmd,
 		msigInspectCmd,
 		msigProposeCmd,
 		msigApproveCmd,
+		msigCancelCmd,
+		msigAddSignerCmd,
+		msigRemoveSignerCmd,
+		msigSwapSignerCmd,
+		msigThresholdCmd,
 	},
 }
 
@@ -78,74 +77,43 @@ var msigCreateCmd = &cli.Command{
 		}
 
 		// get the address we're going to use to create the multisig (can be one of the above, as long as they have funds)
-		sendAddr, err := api.WalletDefaultAddress(ctx)
+		sendAddr, err := msigSource(ctx, cctx, api)
 		if err != nil {
 			return err
 		}
 
-		val := cctx.String("value")
-		filval, err := types.ParseFIL(val)
+		filval, err := types.ParseFIL(cctx.String("value"))
 		if err != nil {
 			return err
 		}
 
-		required := cctx.Int64("required")
-		if required == 0 {
-			required = int64(len(addrs))
-		}
-
-		// Set up constructor parameters for multisig
-		msigParams := &samsig.ConstructorParams{
-			Signers:               addrs,
-			NumApprovalsThreshold: required,
-		}
-
-		enc, err := actors.SerializeParams(msigParams)
-		if err != nil {
-			return err
-		}
-
-		// new actors are created by invoking 'exec' on the init actor with the constructor params
-		execParams := &init_.ExecParams{
-			CodeCID:           builtin.MultisigActorCodeID,
-			ConstructorParams: enc,
+		if cctx.Bool("unsigned") {
+			msg, err := actors.MsigCreateMessage(sendAddr, addrs, cctx.Int64("required"), types.BigInt(filval))
+			if err != nil {
+				return err
+			}
+			return printUnsigned(ctx, api, msg)
 		}
 
-		enc, err = actors.SerializeParams(execParams)
+		mcid, err := api.MsigCreate(ctx, cctx.Int64("required"), addrs, types.BigInt(filval), sendAddr)
 		if err != nil {
 			return err
 		}
 
-		// now we create the message to send this with
-		msg := types.Message{
-			To:       builtin.InitActorAddr,
-			From:     sendAddr,
-			Method:   builtin.MethodsInit.Exec,
-			Params:   enc,
-			GasPrice: types.NewInt(1),
-			GasLimit: 1000000,
-			Value:    types.BigInt(filval),
-		}
-
-		// send the message out to the network
-		smsg, err := api.MpoolPushMessage(ctx, &msg)
-		if err != nil {
-			return err
-		}
+		fmt.Println("sent create in message: ", mcid)
 
 		// wait for it to get mined into a block
-		wait, err := api.StateWaitMsg(ctx, smsg.Cid())
+		wait, err := api.StateWaitMsg(ctx, mcid)
 		if err != nil {
 			return err
 		}
 
 		// check it executed successfully
 		if wait.Receipt.ExitCode != 0 {
-			fmt.Println("actor creation failed!")
-			return err
+			return fmt.Errorf("actor creation failed with exit %d", wait.Receipt.ExitCode)
 		}
 
-		// get address of newly created miner
+		// get address of newly created multisig
 		msigaddr, err := address.NewFromBytes(wait.Receipt.Return)
 		if err != nil {
 			return err
@@ -201,73 +169,74 @@ var msigInspectCmd = &cli.Command{
 			fmt.Printf("\t%s\n", s)
 		}
 
-		pending, err := GetMultisigPending(ctx, api, mstate.PendingTxns)
+		pending, err := api.MsigGetPending(ctx, maddr, types.EmptyTSK)
 		if err != nil {
 			return fmt.Errorf("reading pending transactions: %w", err)
 		}
 
 		fmt.Println("Transactions: ", len(pending))
 		if len(pending) > 0 {
-			var txids []int64
-			for txid := range pending {
-				txids = append(txids, txid)
-			}
-			sort.Slice(txids, func(i, j int) bool {
-				return txids[i] < txids[j]
-			})
-
 			w := tabwriter.NewWriter(os.Stdout, 8, 4, 0, ' ', 0)
-			fmt.Fprintf(w, "ID\tState\tTo\tValue\tMethod\tParams\n")
-			for _, txid := range txids {
-				tx := pending[txid]
-				fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\t%x\n", txid, state(tx), tx.To, types.FIL(tx.Value), tx.Method, tx.Params)
+			fmt.Fprintf(w, "ID\tApprovals\tTo\tValue\tMethod\tParams\n")
+			for _, tx := range pending {
+				fmt.Fprintf(w, "%d\t%d\t%s\t%s\t%s\t%x\n", tx.ID, len(tx.Approved), tx.To, types.FIL(tx.Value), msigMethodName(tx), tx.Params)
+			}
+			if err := w.Flush(); err != nil {
+				return err
 			}
-			w.Flush()
 		}
 
 		return nil
 	},
 }
 
-func GetMultisigPending(ctx context.Context, lapi api.FullNode, hroot cid.Cid) (map[int64]*samsig.Transaction, error) {
-	bs := apibstore.NewAPIBlockstore(lapi)
-	cst := cbor.NewCborStore(bs)
-
-	nd, err := hamt.LoadNode(ctx, cst, hroot, hamt.UseTreeBitWidth(5))
-	if err != nil {
-		return nil, err
+func msigMethodName(tx *lapi.MsigTransaction) string {
+	if tx.MethodName == "" {
+		return strconv.FormatUint(uint64(tx.Method), 10)
 	}
+	return fmt.Sprintf("%s (%d)", tx.MethodName, tx.Method)
+}
 
-	txs := make(map[int64]*samsig.Transaction)
-	err = nd.ForEach(ctx, func(k string, val interface{}) error {
-		d := val.(*cbg.Deferred)
-		var tx samsig.Transaction
-		if err := tx.UnmarshalCBOR(bytes.NewReader(d.Raw)); err != nil {
-			return err
-		}
+func msigSource(ctx context.Context, cctx *cli.Context, api lapi.FullNode) (address.Address, error) {
+	if cctx.IsSet("source") {
+		return address.NewFromString(cctx.String("source"))
+	}
 
-		txid, _ := binary.Varint([]byte(k))
+	return api.WalletDefaultAddress(ctx)
+}
 
-		txs[txid] = &tx
-		return nil
-	})
+// msigWait waits for the given message to be executed, and checks that it
+// was successful
+func msigWait(ctx context.Context, api lapi.FullNode, mcid cid.Cid, what string) (*lapi.MsgLookup, error) {
+	wait, err := api.StateWaitMsg(ctx, mcid)
 	if err != nil {
-		return nil, xerrors.Errorf("failed to iterate transactions hamt: %w", err)
+		return nil, err
 	}
 
-	return txs, nil
+	if wait.Receipt.ExitCode != 0 {
+		return nil, fmt.Errorf("%s returned exit %d", what, wait.Receipt.ExitCode)
+	}
+
+	return wait, nil
 }
 
-func state(tx *samsig.Transaction) string {
-	/* // TODO(why): I strongly disagree with not having these... but i need to move forward
-	if tx.Complete {
-		return "done"
+// msigProposed waits for a proposal, and prints the ID of the created
+// transaction
+func msigProposed(ctx context.Context, api lapi.FullNode, mcid cid.Cid) error {
+	fmt.Println("sent proposal in message: ", mcid)
+
+	wait, err := msigWait(ctx, api, mcid, "proposal")
+	if err != nil {
+		return err
 	}
-	if tx.Canceled {
-		return "canceled"
+
+	_, v, err := cbg.CborReadHeader(bytes.NewReader(wait.Receipt.Return))
+	if err != nil {
+		return err
 	}
-	*/
-	return "pending"
+
+	fmt.Printf("Transaction ID: %d\n", v)
+	return nil
 }
 
 var msigProposeCmd = &cli.Command{
@@ -322,73 +291,207 @@ var msigProposeCmd = &cli.Command{
 			params = p
 		}
 
-		enc, err := actors.SerializeParams(&samsig.ProposeParams{
-			To:     dest,
-			Value:  abi.TokenAmount(types.BigInt(value)),
-			Method: abi.MethodNum(method),
-			Params: params,
-		})
+		from, err := msigSource(ctx, cctx, api)
 		if err != nil {
 			return err
 		}
 
-		var from address.Address
-		if cctx.IsSet("source") {
-			f, err := address.NewFromString(cctx.String("source"))
+		if cctx.Bool("unsigned") {
+			msg, err := actors.MsigProposeMessage(msig, from, dest, types.BigInt(value), abi.MethodNum(method), params)
 			if err != nil {
 				return err
 			}
-			from = f
-		} else {
-			defaddr, err := api.WalletDefaultAddress(ctx)
+			return printUnsigned(ctx, api, msg)
+		}
+
+		mcid, err := api.MsigPropose(ctx, msig, dest, types.BigInt(value), from, method, params)
+		if err != nil {
+			return err
+		}
+
+		return msigProposed(ctx, api, mcid)
+	},
+}
+
+// msigPreview prints the decoded pending transaction, and returns whether
+// the caller confirmed acting on it
+func msigPreview(ctx context.Context, cctx *cli.Context, api lapi.FullNode, msig address.Address, txid int64, action string) (bool, error) {
+	pending, err := api.MsigGetPending(ctx, msig, types.EmptyTSK)
+	if err != nil {
+		return false, fmt.Errorf("reading pending transactions: %w", err)
+	}
+
+	var tx *lapi.MsigTransaction
+	for _, p := range pending {
+		if p.ID == txid {
+			tx = p
+			break
+		}
+	}
+	if tx == nil {
+		return false, fmt.Errorf("transaction %d not found in pending transactions of %s", txid, msig)
+	}
+
+	fmt.Printf("Transaction %d:\n", tx.ID)
+	fmt.Printf("\tTo:\t%s\n", tx.To)
+	fmt.Printf("\tValue:\t%s FIL\n", types.FIL(tx.Value))
+	fmt.Printf("\tMethod:\t%s\n", msigMethodName(tx))
+	if tx.DecodedParams != nil {
+		b, err := json.MarshalIndent(tx.DecodedParams, "\t", "  ")
+		if err != nil {
+			return false, err
+		}
+		fmt.Printf("\tParams:\t%s\n", string(b))
+	} else if len(tx.Params) > 0 {
+		fmt.Printf("\tParams:\t%x\n", tx.Params)
+	}
+	fmt.Printf("\tApproved by:\n")
+	for _, a := range tx.Approved {
+		fmt.Printf("\t\t%s\n", a)
+	}
+
+	if !cctx.Bool("confirm") {
+		fmt.Printf("\nRe-run with --confirm to %s this transaction\n", action)
+		return false, nil
+	}
+
+	return true, nil
+}
+
+var msigApproveCmd = &cli.Command{
+	Name:      "approve",
+	Usage:     "Approve a multisig message",
+	ArgsUsage: "[multisigAddress messageId]",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "confirm",
+			Usage: "send the approval, without this only the decoded transaction is shown",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		if cctx.Args().Len() != 2 {
+			return fmt.Errorf("must pass multisig address and message ID")
+		}
+
+		msig, err := address.NewFromString(cctx.Args().Get(0))
+		if err != nil {
+			return err
+		}
+
+		txid, err := strconv.ParseInt(cctx.Args().Get(1), 10, 64)
+		if err != nil {
+			return err
+		}
+
+		ok, err := msigPreview(ctx, cctx, api, msig, txid, "approve")
+		if err != nil || !ok {
+			return err
+		}
+
+		from, err := msigSource(ctx, cctx, api)
+		if err != nil {
+			return err
+		}
+
+		if cctx.Bool("unsigned") {
+			msg, err := actors.MsigApproveMessage(msig, from, txid)
 			if err != nil {
 				return err
 			}
-			from = defaddr
+			return printUnsigned(ctx, api, msg)
 		}
 
-		msg := &types.Message{
-			To:       msig,
-			From:     from,
-			Value:    types.NewInt(0),
-			Method:   builtin.MethodsMultisig.Propose,
-			Params:   enc,
-			GasLimit: 100000,
-			GasPrice: types.NewInt(1),
+		mcid, err := api.MsigApprove(ctx, msig, txid, from)
+		if err != nil {
+			return err
 		}
 
-		smsg, err := api.MpoolPushMessage(ctx, msg)
+		fmt.Println("sent approval in message: ", mcid)
+
+		_, err = msigWait(ctx, api, mcid, "approve")
+		return err
+	},
+}
+
+var msigCancelCmd = &cli.Command{
+	Name:      "cancel",
+	Usage:     "Cancel a multisig transaction proposed by the source address",
+	ArgsUsage: "[multisigAddress messageId]",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "confirm",
+			Usage: "send the cancellation, without this only the decoded transaction is shown",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetFullNodeAPI(cctx)
 		if err != nil {
 			return err
 		}
+		defer closer()
+		ctx := ReqContext(cctx)
 
-		fmt.Println("send proposal in message: ", smsg.Cid())
+		if cctx.Args().Len() != 2 {
+			return fmt.Errorf("must pass multisig address and message ID")
+		}
 
-		wait, err := api.StateWaitMsg(ctx, smsg.Cid())
+		msig, err := address.NewFromString(cctx.Args().Get(0))
 		if err != nil {
 			return err
 		}
 
-		if wait.Receipt.ExitCode != 0 {
-			return fmt.Errorf("proposal returned exit %d", wait.Receipt.ExitCode)
+		txid, err := strconv.ParseInt(cctx.Args().Get(1), 10, 64)
+		if err != nil {
+			return err
 		}
 
-		_, v, err := cbg.CborReadHeader(bytes.NewReader(wait.Receipt.Return))
+		ok, err := msigPreview(ctx, cctx, api, msig, txid, "cancel")
+		if err != nil || !ok {
+			return err
+		}
+
+		from, err := msigSource(ctx, cctx, api)
 		if err != nil {
 			return err
 		}
 
-		fmt.Printf("Transaction ID: %d\n", v)
+		if cctx.Bool("unsigned") {
+			msg, err := actors.MsigCancelMessage(msig, from, txid)
+			if err != nil {
+				return err
+			}
+			return printUnsigned(ctx, api, msg)
+		}
 
-		return nil
+		mcid, err := api.MsigCancel(ctx, msig, txid, from)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("sent cancel in message: ", mcid)
+
+		_, err = msigWait(ctx, api, mcid, "cancel")
+		return err
 	},
 }
 
-var msigApproveCmd = &cli.Command{
-	Name:      "approve",
-	Usage:     "Approve a multisig message",
-	ArgsUsage: "[multisigAddress messageId]",
-	Flags:     []cli.Flag{},
+var msigAddSignerCmd = &cli.Command{
+	Name:      "add-signer",
+	Usage:     "Propose adding a signer to the multisig",
+	ArgsUsage: "[multisigAddress signerAddress]",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "increase-threshold",
+			Usage: "also increase the number of required approvals by one",
+		},
+	},
 	Action: func(cctx *cli.Context) error {
 		api, closer, err := GetFullNodeAPI(cctx)
 		if err != nil {
@@ -398,7 +501,7 @@ var msigApproveCmd = &cli.Command{
 		ctx := ReqContext(cctx)
 
 		if cctx.Args().Len() != 2 {
-			return fmt.Errorf("must pass multisig address and message ID")
+			return fmt.Errorf("must pass multisig address and signer address")
 		}
 
 		msig, err := address.NewFromString(cctx.Args().Get(0))
@@ -406,59 +509,184 @@ var msigApproveCmd = &cli.Command{
 			return err
 		}
 
-		txid, err := strconv.ParseUint(cctx.Args().Get(1), 10, 64)
+		signer, err := address.NewFromString(cctx.Args().Get(1))
 		if err != nil {
 			return err
 		}
 
-		enc, err := actors.SerializeParams(&samsig.TxnIDParams{
-			ID: samsig.TxnID(txid),
-		})
+		from, err := msigSource(ctx, cctx, api)
 		if err != nil {
 			return err
 		}
 
-		var from address.Address
-		if cctx.IsSet("source") {
-			f, err := address.NewFromString(cctx.String("source"))
+		if cctx.Bool("unsigned") {
+			msg, err := actors.MsigAddSignerMessage(msig, from, signer, cctx.Bool("increase-threshold"))
 			if err != nil {
 				return err
 			}
-			from = f
-		} else {
-			defaddr, err := api.WalletDefaultAddress(ctx)
+			return printUnsigned(ctx, api, msg)
+		}
+
+		mcid, err := api.MsigAddSigner(ctx, msig, from, signer, cctx.Bool("increase-threshold"))
+		if err != nil {
+			return err
+		}
+
+		return msigProposed(ctx, api, mcid)
+	},
+}
+
+var msigRemoveSignerCmd = &cli.Command{
+	Name:      "remove-signer",
+	Usage:     "Propose removing a signer from the multisig",
+	ArgsUsage: "[multisigAddress signerAddress]",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "decrease-threshold",
+			Usage: "also decrease the number of required approvals by one",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		if cctx.Args().Len() != 2 {
+			return fmt.Errorf("must pass multisig address and signer address")
+		}
+
+		msig, err := address.NewFromString(cctx.Args().Get(0))
+		if err != nil {
+			return err
+		}
+
+		signer, err := address.NewFromString(cctx.Args().Get(1))
+		if err != nil {
+			return err
+		}
+
+		from, err := msigSource(ctx, cctx, api)
+		if err != nil {
+			return err
+		}
+
+		if cctx.Bool("unsigned") {
+			msg, err := actors.MsigRemoveSignerMessage(msig, from, signer, cctx.Bool("decrease-threshold"))
+			if err != nil {
+				return err
+			}
+			return printUnsigned(ctx, api, msg)
+		}
+
+		mcid, err := api.MsigRemoveSigner(ctx, msig, from, signer, cctx.Bool("decrease-threshold"))
+		if err != nil {
+			return err
+		}
+
+		return msigProposed(ctx, api, mcid)
+	},
+}
+
+var msigSwapSignerCmd = &cli.Command{
+	Name:      "swap-signer",
+	Usage:     "Propose replacing a signer of the multisig",
+	ArgsUsage: "[multisigAddress oldAddress newAddress]",
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		if cctx.Args().Len() != 3 {
+			return fmt.Errorf("must pass multisig address, old signer and new signer")
+		}
+
+		msig, err := address.NewFromString(cctx.Args().Get(0))
+		if err != nil {
+			return err
+		}
+
+		oldSigner, err := address.NewFromString(cctx.Args().Get(1))
+		if err != nil {
+			return err
+		}
+
+		newSigner, err := address.NewFromString(cctx.Args().Get(2))
+		if err != nil {
+			return err
+		}
+
+		from, err := msigSource(ctx, cctx, api)
+		if err != nil {
+			return err
+		}
+
+		if cctx.Bool("unsigned") {
+			msg, err := actors.MsigSwapSignerMessage(msig, from, oldSigner, newSigner)
 			if err != nil {
 				return err
 			}
-			from = defaddr
+			return printUnsigned(ctx, api, msg)
 		}
 
-		msg := &types.Message{
-			To:       msig,
-			From:     from,
-			Value:    types.NewInt(0),
-			Method:   builtin.MethodsMultisig.Approve,
-			Params:   enc,
-			GasLimit: 100000,
-			GasPrice: types.NewInt(1),
+		mcid, err := api.MsigSwapSigner(ctx, msig, from, oldSigner, newSigner)
+		if err != nil {
+			return err
 		}
 
-		smsg, err := api.MpoolPushMessage(ctx, msg)
+		return msigProposed(ctx, api, mcid)
+	},
+}
+
+var msigThresholdCmd = &cli.Command{
+	Name:      "threshold",
+	Usage:     "Propose changing the number of required approvals",
+	ArgsUsage: "[multisigAddress newThreshold]",
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetFullNodeAPI(cctx)
 		if err != nil {
 			return err
 		}
+		defer closer()
+		ctx := ReqContext(cctx)
 
-		fmt.Println("sent approval in message: ", smsg.Cid())
+		if cctx.Args().Len() != 2 {
+			return fmt.Errorf("must pass multisig address and new threshold")
+		}
 
-		wait, err := api.StateWaitMsg(ctx, smsg.Cid())
+		msig, err := address.NewFromString(cctx.Args().Get(0))
 		if err != nil {
 			return err
 		}
 
-		if wait.Receipt.ExitCode != 0 {
-			return fmt.Errorf("approve returned exit %d", wait.Receipt.ExitCode)
+		threshold, err := strconv.ParseInt(cctx.Args().Get(1), 10, 64)
+		if err != nil {
+			return err
 		}
 
-		return nil
+		from, err := msigSource(ctx, cctx, api)
+		if err != nil {
+			return err
+		}
+
+		if cctx.Bool("unsigned") {
+			msg, err := actors.MsigChangeThresholdMessage(msig, from, threshold)
+			if err != nil {
+				return err
+			}
+			return printUnsigned(ctx, api, msg)
+		}
+
+		mcid, err := api.MsigChangeThreshold(ctx, msig, from, threshold)
+		if err != nil {
+			return err
+		}
+
+		return msigProposed(ctx, api, mcid)
 	},
 }
@@ -4,11 +4,16 @@ import (
 	"bytes"
 	"encoding/base64"
 	"fmt"
+	"os"
+	"text/tabwriter"
 
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/specs-actors/actors/builtin/paych"
+	"github.com/ipfs/go-cid"
 	"gopkg.in/urfave/cli.v2"
 
+	lapi "github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/actors"
 	types "github.com/filecoin-project/lotus/chain/types"
 )
 
@@ -18,6 +23,9 @@ var paychCmd = &cli.Command{
 	Subcommands: []*cli.Command{
 		paychGetCmd,
 		paychListCmd,
+		paychStatusCmd,
+		paychSettleCmd,
+		paychCollectCmd,
 		paychVoucherCmd,
 	},
 }
@@ -88,6 +96,138 @@ var paychListCmd = &cli.Command{
 	},
 }
 
+var paychStatusCmd = &cli.Command{
+	Name:      "status",
+	Usage:     "Show the state of a payment channel",
+	ArgsUsage: "[channelAddress]",
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 1 {
+			return fmt.Errorf("must pass payment channel address")
+		}
+
+		ch, err := address.NewFromString(cctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := ReqContext(cctx)
+
+		st, err := api.PaychStatus(ctx, ch)
+		if err != nil {
+			return err
+		}
+
+		dir := "inbound"
+		if st.Direction == lapi.PCHOutbound {
+			dir = "outbound"
+		}
+
+		fmt.Printf("Channel:   %s\n", ch)
+		fmt.Printf("Direction: %s\n", dir)
+		fmt.Printf("Control:   %s\n", st.ControlAddr)
+		fmt.Printf("Target:    %s\n", st.Target)
+		fmt.Printf("State:     %s", st.State)
+		if st.SettlingAt != 0 {
+			fmt.Printf(" (settlement ends at %d)", st.SettlingAt)
+		}
+		fmt.Println()
+		fmt.Printf("Balance:   %s\n", types.FIL(st.Balance))
+		fmt.Printf("To Send:   %s\n", types.FIL(st.ToSend))
+		if st.Direction == lapi.PCHOutbound {
+			fmt.Printf("Available: %s\n", types.FIL(st.AvailableFunds))
+			fmt.Printf("Pending:   %s\n", types.FIL(st.PendingFunds))
+		}
+
+		if len(st.Lanes) == 0 {
+			return nil
+		}
+
+		fmt.Println()
+		w := tabwriter.NewWriter(os.Stdout, 8, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "Lane\tRedeemed\tNonce\tBest Voucher\tBest Nonce\tVouchers\n")
+		for _, l := range st.Lanes {
+			fmt.Fprintf(w, "%d\t%s\t%d\t%s\t%d\t%d\n", l.Lane, types.FIL(l.Redeemed), l.Nonce, types.FIL(l.Best), l.BestNonce, l.Vouchers)
+		}
+		return w.Flush()
+	},
+}
+
+var paychSettleCmd = &cli.Command{
+	Name:      "settle",
+	Usage:     "Start the settlement period of a payment channel",
+	ArgsUsage: "[channelAddress]",
+	Flags: []cli.Flag{
+		unsignedFlag,
+	},
+	Action: func(cctx *cli.Context) error {
+		return paychSettleOrCollect(cctx, false)
+	},
+}
+
+var paychCollectCmd = &cli.Command{
+	Name:      "collect",
+	Usage:     "Pay out the funds of a payment channel after the settlement period",
+	ArgsUsage: "[channelAddress]",
+	Flags: []cli.Flag{
+		unsignedFlag,
+	},
+	Action: func(cctx *cli.Context) error {
+		return paychSettleOrCollect(cctx, true)
+	},
+}
+
+func paychSettleOrCollect(cctx *cli.Context, collect bool) error {
+	if cctx.Args().Len() != 1 {
+		return fmt.Errorf("must pass payment channel address")
+	}
+
+	ch, err := address.NewFromString(cctx.Args().First())
+	if err != nil {
+		return err
+	}
+
+	api, closer, err := GetFullNodeAPI(cctx)
+	if err != nil {
+		return err
+	}
+	defer closer()
+
+	ctx := ReqContext(cctx)
+
+	if cctx.Bool("unsigned") {
+		st, err := api.PaychStatus(ctx, ch)
+		if err != nil {
+			return err
+		}
+
+		msg := actors.PaychSettleMessage(ch, st.ControlAddr)
+		if collect {
+			msg = actors.PaychCollectMessage(ch, st.ControlAddr)
+		}
+
+		return printUnsigned(ctx, api, msg)
+	}
+
+	var mcid cid.Cid
+	if collect {
+		mcid, err = api.PaychCollect(ctx, ch)
+	} else {
+		mcid, err = api.PaychSettle(ctx, ch)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(mcid)
+	return nil
+}
+
 var paychVoucherCmd = &cli.Command{
 	Name:  "voucher",
 	Usage: "Interact with payment channel vouchers",
@@ -333,6 +473,9 @@ var paychVoucherSubmitCmd = &cli.Command{
 	Name:      "submit",
 	Usage:     "Submit voucher to chain to update payment channel state",
 	ArgsUsage: "[channelAddress voucher]",
+	Flags: []cli.Flag{
+		unsignedFlag,
+	},
 	Action: func(cctx *cli.Context) error {
 		if cctx.Args().Len() != 2 {
 			return fmt.Errorf("must pass payment channel address and voucher")
@@ -356,6 +499,20 @@ var paychVoucherSubmitCmd = &cli.Command{
 
 		ctx := ReqContext(cctx)
 
+		if cctx.Bool("unsigned") {
+			st, err := api.PaychStatus(ctx, ch)
+			if err != nil {
+				return err
+			}
+
+			msg, err := actors.PaychUpdateMessage(ch, st.ControlAddr, sv)
+			if err != nil {
+				return err
+			}
+
+			return printUnsigned(ctx, api, msg)
+		}
+
 		mcid, err := api.PaychVoucherSubmit(ctx, ch, sv)
 		if err != nil {
 			return err
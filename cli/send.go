@@ -27,6 +27,7 @@ var sendCmd = &cli.Command{
 			Usage: "specify the nonce to use",
 			Value: -1,
 		},
+		unsignedFlag,
 	},
 	Action: func(cctx *cli.Context) error {
 		api, closer, err := GetFullNodeAPI(cctx)
@@ -41,6 +42,10 @@ var sendCmd = &cli.Command{
 			return fmt.Errorf("'send' expects two arguments, target and amount")
 		}
 
+		if cctx.IsSet("nonce") && cctx.Int64("nonce") < 0 {
+			return fmt.Errorf("nonce can't be negative")
+		}
+
 		toAddr, err := address.NewFromString(cctx.Args().Get(0))
 		if err != nil {
 			return err
@@ -81,7 +86,15 @@ var sendCmd = &cli.Command{
 			GasPrice: gp,
 		}
 
-		if cctx.Int64("nonce") > 0 {
+		if cctx.Bool("unsigned") {
+			if cctx.IsSet("nonce") {
+				msg.Nonce = uint64(cctx.Int64("nonce"))
+				return printMessage(ctx, api, msg)
+			}
+			return printUnsigned(ctx, api, msg)
+		}
+
+		if cctx.IsSet("nonce") {
 			msg.Nonce = uint64(cctx.Int64("nonce"))
 			sm, err := api.WalletSignMessage(ctx, fromAddr, msg)
 			if err != nil {
@@ -3,10 +3,14 @@ package cli
 import (
 	"context"
 	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
 	"time"
 
 	"github.com/filecoin-project/specs-actors/actors/abi"
 	cid "github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p-core/peer"
 	"gopkg.in/urfave/cli.v2"
 
 	"github.com/filecoin-project/lotus/api"
@@ -22,6 +26,7 @@ var syncCmd = &cli.Command{
 		syncWaitCmd,
 		syncMarkBadCmd,
 		syncCheckBadCmd,
+		syncDebugCmd,
 	},
 }
 
@@ -199,3 +204,171 @@ func SyncWait(ctx context.Context, napi api.FullNode) error {
 		}
 	}
 }
+
+var syncDebugCmd = &cli.Command{
+	Name:  "debug",
+	Usage: "Inspect and control the sync scheduler",
+	Subcommands: []*cli.Command{
+		syncDebugStateCmd,
+		syncDebugPauseCmd,
+		syncDebugResumeCmd,
+		syncDebugTargetCmd,
+		syncDebugWorkersCmd,
+	},
+}
+
+var syncDebugStateCmd = &cli.Command{
+	Name:  "state",
+	Usage: "Print the sync scheduler state",
+	Action: func(cctx *cli.Context) error {
+		napi, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		st, err := napi.SyncDebugState(ctx)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Bootstrap state: %s\n", st.BootstrapState)
+		fmt.Printf("Paused: %t\n", st.Paused)
+
+		fmt.Printf("\nPeer heads (%d):\n", len(st.PeerHeads))
+		tw := tabwriter.NewWriter(os.Stdout, 4, 4, 2, ' ', 0)
+		for _, ph := range st.PeerHeads {
+			fmt.Fprintf(tw, "\t%s\t%d\t%s\n", ph.Peer, ph.Height, ph.Head)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+
+		fmt.Printf("\nActive syncs (%d):\n", len(st.ActiveSyncs))
+		for _, tsk := range st.ActiveSyncs {
+			fmt.Printf("\t%s\n", tsk)
+		}
+
+		fmt.Println("\nNext target:")
+		if st.NextTarget != nil {
+			printSyncBucket(*st.NextTarget)
+		} else {
+			fmt.Println("\tnone")
+		}
+
+		fmt.Printf("\nSync queue (%d):\n", len(st.SyncQueue))
+		for _, b := range st.SyncQueue {
+			printSyncBucket(b)
+		}
+
+		fmt.Printf("\nWaiting on active syncs (%d):\n", len(st.ActiveSyncTips))
+		for _, b := range st.ActiveSyncTips {
+			printSyncBucket(b)
+		}
+
+		fmt.Printf("\nWorkers (%d):\n", len(st.Workers))
+		for _, w := range st.Workers {
+			var target abi.ChainEpoch
+			if w.State.Target != nil {
+				target = w.State.Target.Height()
+			}
+			fmt.Printf("\tworker %d: %s, height %d/%d\n", w.ID, chain.SyncStageString(w.State.Stage), w.State.Height, target)
+			if w.LastError != "" {
+				fmt.Printf("\t\tlast error (%s ago): %s\n", time.Since(w.LastErrorTime).Truncate(time.Second), w.LastError)
+			}
+		}
+
+		return nil
+	},
+}
+
+func printSyncBucket(b api.SyncBucket) {
+	fmt.Printf("\t%d tipsets, seen %d times, heaviest %s (%d)\n", len(b.Tips), b.Count, b.Heaviest, b.Height)
+}
+
+var syncDebugPauseCmd = &cli.Command{
+	Name:  "pause",
+	Usage: "Stop starting new syncs, running syncs will finish",
+	Action: func(cctx *cli.Context) error {
+		napi, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		return napi.SyncPause(ctx)
+	},
+}
+
+var syncDebugResumeCmd = &cli.Command{
+	Name:  "resume",
+	Usage: "Resume syncing after it was paused",
+	Action: func(cctx *cli.Context) error {
+		napi, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		return napi.SyncResume(ctx)
+	},
+}
+
+var syncDebugTargetCmd = &cli.Command{
+	Name:      "force-target",
+	Usage:     "Sync to the head of the given peer next",
+	ArgsUsage: "[peerId]",
+	Action: func(cctx *cli.Context) error {
+		napi, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		if !cctx.Args().Present() {
+			return fmt.Errorf("must specify peer ID")
+		}
+
+		pid, err := peer.IDB58Decode(cctx.Args().First())
+		if err != nil {
+			return fmt.Errorf("failed to parse peer ID: %s", err)
+		}
+
+		tsk, err := napi.SyncForceTarget(ctx, pid)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Sync target set to %s\n", tsk)
+		return nil
+	},
+}
+
+var syncDebugWorkersCmd = &cli.Command{
+	Name:      "set-workers",
+	Usage:     "Change the number of sync workers",
+	ArgsUsage: "[count]",
+	Action: func(cctx *cli.Context) error {
+		napi, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		if !cctx.Args().Present() {
+			return fmt.Errorf("must specify worker count")
+		}
+
+		n, err := strconv.Atoi(cctx.Args().First())
+		if err != nil {
+			return fmt.Errorf("failed to parse worker count: %s", err)
+		}
+
+		return napi.SyncSetWorkers(ctx, n)
+	},
+}
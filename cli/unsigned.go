@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+
+	"golang.org/x/xerrors"
+	"gopkg.in/urfave/cli.v2"
+
+	lapi "github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// unsignedFlag is accepted by commands which can emit a message for signing
+// on another machine instead of sending it. The printed message can be
+// signed with `lotus-shed sign-message`, and sent with `lotus mpool push`.
+var unsignedFlag = &cli.BoolFlag{
+	Name:  "unsigned",
+	Usage: "don't send the message, print it hex encoded, with the nonce filled in, for offline signing",
+}
+
+// printUnsigned fills in the next nonce of the sender, and prints the
+// serialized message
+func printUnsigned(ctx context.Context, api lapi.FullNode, msg *types.Message) error {
+	nonce, err := api.MpoolGetNonce(ctx, msg.From)
+	if err != nil {
+		return xerrors.Errorf("getting nonce: %w", err)
+	}
+	msg.Nonce = nonce
+
+	return printMessage(ctx, api, msg)
+}
+
+// printMessage prints the serialized message with the nonce set by the caller
+func printMessage(ctx context.Context, api lapi.FullNode, msg *types.Message) error {
+	act, err := api.StateGetActor(ctx, msg.From, types.EmptyTSK)
+	if err != nil {
+		return xerrors.Errorf("getting sender actor: %w", err)
+	}
+
+	if err := checkMessageFunds(act, msg); err != nil {
+		return err
+	}
+
+	b, err := msg.Serialize()
+	if err != nil {
+		return xerrors.Errorf("serializing message: %w", err)
+	}
+
+	fmt.Println(hex.EncodeToString(b))
+	return nil
+}
+
+func checkMessageFunds(act *types.Actor, msg *types.Message) error {
+	if act.Balance.LessThan(msg.RequiredFunds()) {
+		return xerrors.Errorf("not enough funds in %s: %s < %s", msg.From, types.FIL(act.Balance), types.FIL(msg.RequiredFunds()))
+	}
+	return nil
+}
@@ -5,14 +5,19 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"strings"
+	"text/tabwriter"
 
 	"github.com/filecoin-project/go-address"
 	types "github.com/filecoin-project/lotus/chain/types"
 	"github.com/filecoin-project/lotus/chain/wallet"
+	"github.com/filecoin-project/lotus/lib/sigs"
+	"github.com/filecoin-project/specs-actors/actors/abi"
 	"github.com/filecoin-project/specs-actors/actors/crypto"
+	"golang.org/x/crypto/ssh/terminal"
 	"golang.org/x/xerrors"
 
 	"gopkg.in/urfave/cli.v2"
@@ -23,14 +28,24 @@ var walletCmd = &cli.Command{
 	Usage: "Manage wallet",
 	Subcommands: []*cli.Command{
 		walletNew,
+		walletRestore,
+		walletDerive,
+		walletUnlock,
 		walletList,
 		walletBalance,
+		walletDelete,
+		walletLabel,
+		walletTag,
+		walletWatch,
+		walletHistory,
 		walletExport,
 		walletImport,
 		walletGetDefault,
 		walletSetDefault,
 		walletSign,
 		walletVerify,
+		walletSignData,
+		walletVerifyData,
 	},
 }
 
@@ -38,6 +53,12 @@ var walletNew = &cli.Command{
 	Name:      "new",
 	Usage:     "Generate a new key of the given type",
 	ArgsUsage: "[bls|secp256k1 (default secp256k1)]",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "hd",
+			Usage: "derive the key from the HD wallet seed, creating the seed if there is none",
+		},
+	},
 	Action: func(cctx *cli.Context) error {
 		api, closer, err := GetFullNodeAPI(cctx)
 		if err != nil {
@@ -51,6 +72,28 @@ var walletNew = &cli.Command{
 			t = "secp256k1"
 		}
 
+		if cctx.Bool("hd") {
+			pass, err := readSecret(bufio.NewReader(os.Stdin), "Wallet passphrase: ")
+			if err != nil {
+				return err
+			}
+
+			res, err := api.WalletHDNew(ctx, wallet.ActSigType(t), pass)
+			if err != nil {
+				return err
+			}
+
+			if res.Mnemonic != "" {
+				fmt.Fprintln(os.Stderr, "Created a new HD wallet seed. Write down the mnemonic below, it's the only way to restore the wallet:")
+				fmt.Fprintln(os.Stderr)
+				fmt.Fprintln(os.Stderr, res.Mnemonic)
+				fmt.Fprintln(os.Stderr)
+			}
+
+			fmt.Println(res.Address.String())
+			return nil
+		}
+
 		nk, err := api.WalletNew(ctx, wallet.ActSigType(t))
 		if err != nil {
 			return err
@@ -62,9 +105,148 @@ var walletNew = &cli.Command{
 	},
 }
 
+var walletRestore = &cli.Command{
+	Name:  "restore",
+	Usage: "Restore the HD wallet from a mnemonic read from stdin",
+	Flags: []cli.Flag{
+		&cli.IntFlag{
+			Name:  "secp",
+			Usage: "number of secp256k1 addresses to derive",
+			Value: 1,
+		},
+		&cli.IntFlag{
+			Name:  "bls",
+			Usage: "number of BLS addresses to derive",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		if cctx.Int("secp") < 0 || cctx.Int("bls") < 0 {
+			return xerrors.Errorf("address counts can't be negative")
+		}
+
+		reader := bufio.NewReader(os.Stdin)
+
+		mnemonic, err := readSecret(reader, "Mnemonic: ")
+		if err != nil {
+			return err
+		}
+
+		pass, err := readSecret(reader, "Wallet passphrase: ")
+		if err != nil {
+			return err
+		}
+
+		addrs, err := api.WalletHDRestore(ctx, strings.Join(strings.Fields(mnemonic), " "), pass, cctx.Int("secp"), cctx.Int("bls"))
+		if err != nil {
+			return err
+		}
+
+		for _, addr := range addrs {
+			fmt.Println(addr.String())
+		}
+		return nil
+	},
+}
+
+var walletDerive = &cli.Command{
+	Name:      "derive",
+	Usage:     "Derive a key of the given type from the HD wallet seed",
+	ArgsUsage: "[bls|secp256k1 (default secp256k1)]",
+	Flags: []cli.Flag{
+		&cli.Int64Flag{
+			Name:  "index",
+			Usage: "derivation index, defaults to the next unused index",
+			Value: -1,
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		t := cctx.Args().First()
+		if t == "" {
+			t = "secp256k1"
+		}
+
+		pass, err := readSecret(bufio.NewReader(os.Stdin), "Wallet passphrase: ")
+		if err != nil {
+			return err
+		}
+
+		addr, err := api.WalletHDDerive(ctx, wallet.ActSigType(t), cctx.Int64("index"), pass)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(addr.String())
+		return nil
+	},
+}
+
+var walletUnlock = &cli.Command{
+	Name:  "unlock",
+	Usage: "Unlock the HD wallet, so that derived addresses can sign",
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		pass, err := readSecret(bufio.NewReader(os.Stdin), "Wallet passphrase: ")
+		if err != nil {
+			return err
+		}
+
+		return api.WalletHDUnlock(ctx, pass)
+	},
+}
+
+// readSecret prompts for a secret without echoing it when stdin is a
+// terminal, and reads a line from r otherwise
+func readSecret(r *bufio.Reader, prompt string) (string, error) {
+	if terminal.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Fprint(os.Stderr, prompt)
+		b, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", xerrors.Errorf("reading input: %w", err)
+		}
+		return string(b), nil
+	}
+
+	line, err := r.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", xerrors.Errorf("reading input: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
 var walletList = &cli.Command{
 	Name:  "list",
 	Usage: "List wallet address",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "addr-only",
+			Usage: "only print addresses",
+		},
+		&cli.StringFlag{
+			Name:  "tag",
+			Usage: "only list addresses with the given tag",
+		},
+	},
 	Action: func(cctx *cli.Context) error {
 		api, closer, err := GetFullNodeAPI(cctx)
 		if err != nil {
@@ -78,10 +260,171 @@ var walletList = &cli.Command{
 			return err
 		}
 
-		for _, addr := range addrs {
-			fmt.Println(addr.String())
+		if tag := cctx.String("tag"); tag != "" {
+			filtered := addrs[:0]
+			for _, wa := range addrs {
+				for _, t := range wa.Tags {
+					if t == tag {
+						filtered = append(filtered, wa)
+						break
+					}
+				}
+			}
+			addrs = filtered
 		}
-		return nil
+
+		if cctx.Bool("addr-only") {
+			for _, wa := range addrs {
+				fmt.Println(wa.Address.String())
+			}
+			return nil
+		}
+
+		def, err := api.WalletDefaultAddress(ctx)
+		if err != nil {
+			def = address.Undef
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "Address\tLabel\tBalance\tNonce\tType\tTags\n")
+		for _, wa := range addrs {
+			balance, err := api.WalletBalance(ctx, wa.Address)
+			if err != nil {
+				return xerrors.Errorf("getting balance of %s: %w", wa.Address, err)
+			}
+
+			// the address may not be on chain yet
+			nonce := "-"
+			if n, err := api.MpoolGetNonce(ctx, wa.Address); err == nil {
+				nonce = fmt.Sprint(n)
+			}
+
+			typ := wa.KeyType
+			switch {
+			case wa.WatchOnly:
+				typ = "watch-only"
+			case wa.HD:
+				typ = "hd-" + typ
+			}
+
+			addr := wa.Address.String()
+			if wa.Address == def {
+				addr += " (default)"
+			}
+
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", addr, wa.Label, types.FIL(balance), nonce, typ, strings.Join(wa.Tags, ","))
+		}
+		return w.Flush()
+	},
+}
+
+var walletDelete = &cli.Command{
+	Name:      "delete",
+	Usage:     "Remove an address from the wallet, keeping a backup of its key",
+	ArgsUsage: "[address]",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "undo",
+			Usage: "restore the key of a deleted address",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		if !cctx.Args().Present() {
+			return fmt.Errorf("must pass address to delete")
+		}
+
+		addr, err := address.NewFromString(cctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		if cctx.Bool("undo") {
+			return api.WalletUndelete(ctx, addr)
+		}
+
+		return api.WalletDelete(ctx, addr)
+	},
+}
+
+var walletLabel = &cli.Command{
+	Name:      "label",
+	Usage:     "Set the label of an address, or clear it if no label is given",
+	ArgsUsage: "[address] [label]",
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		if !cctx.Args().Present() {
+			return fmt.Errorf("must pass address")
+		}
+
+		addr, err := address.NewFromString(cctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		return api.WalletSetLabel(ctx, addr, strings.Join(cctx.Args().Tail(), " "))
+	},
+}
+
+var walletTag = &cli.Command{
+	Name:      "tag",
+	Usage:     "Set the tags of an address, or clear them if no tags are given",
+	ArgsUsage: "[address] [tags...]",
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		if !cctx.Args().Present() {
+			return fmt.Errorf("must pass address")
+		}
+
+		addr, err := address.NewFromString(cctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		return api.WalletSetTags(ctx, addr, cctx.Args().Tail())
+	},
+}
+
+var walletWatch = &cli.Command{
+	Name:      "watch",
+	Usage:     "Add an address the wallet doesn't hold the key for",
+	ArgsUsage: "[address] [label]",
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		if !cctx.Args().Present() {
+			return fmt.Errorf("must pass address to watch")
+		}
+
+		addr, err := address.NewFromString(cctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		return api.WalletAddWatch(ctx, addr, strings.Join(cctx.Args().Tail(), " "))
 	},
 }
 
@@ -117,6 +460,70 @@ var walletBalance = &cli.Command{
 	},
 }
 
+var walletHistory = &cli.Command{
+	Name:      "history",
+	Usage:     "Show messages and value transfers of a wallet address",
+	ArgsUsage: "[address]",
+	Flags: []cli.Flag{
+		&cli.Int64Flag{
+			Name:  "from-height",
+			Usage: "only show entries at and below this height",
+			Value: -1,
+		},
+		&cli.IntFlag{
+			Name:  "limit",
+			Usage: "maximum number of entries to show",
+			Value: 50,
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		var addr address.Address
+		if cctx.Args().First() != "" {
+			addr, err = address.NewFromString(cctx.Args().First())
+		} else {
+			addr, err = api.WalletDefaultAddress(ctx)
+		}
+		if err != nil {
+			return err
+		}
+
+		entries, err := api.WalletHistory(ctx, addr, abi.ChainEpoch(cctx.Int64("from-height")), cctx.Int("limit"))
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "Height\tMessage\tCounterparty\tValue\tFee\tExit\n")
+		for _, e := range entries {
+			value := types.FIL(e.Value).String()
+			if e.Outgoing {
+				value = "-" + value
+			}
+
+			// the fee is paid by the sender of the top level message
+			fee := "-"
+			if e.Outgoing && !e.Internal {
+				fee = types.FIL(e.Fee).String()
+			}
+
+			msg := e.Message.String()
+			if e.Internal {
+				msg += " (internal)"
+			}
+
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%d\n", e.Height, msg, e.Counterparty, value, fee, e.ExitCode)
+		}
+		return w.Flush()
+	},
+}
+
 var walletGetDefault = &cli.Command{
 	Name:  "default",
 	Usage: "Get default wallet address",
@@ -288,7 +695,7 @@ var walletImport = &cli.Command{
 
 var walletSign = &cli.Command{
 	Name:      "sign",
-	Usage:     "sign a message",
+	Usage:     "sign raw bytes (use sign-data for off-chain proofs)",
 	ArgsUsage: "<signing address> <hexMessage>",
 	Action: func(cctx *cli.Context) error {
 		api, closer, err := GetFullNodeAPI(cctx)
@@ -375,3 +782,113 @@ var walletVerify = &cli.Command{
 		}
 	},
 }
+
+var walletSignData = &cli.Command{
+	Name:      "sign-data",
+	Usage:     "sign data for off-chain use, like a proof of address ownership",
+	ArgsUsage: "<signing address> <purpose> <data>",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "hex",
+			Usage: "data is hex encoded",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		if cctx.NArg() != 3 {
+			return fmt.Errorf("must specify signing address, purpose and data to sign")
+		}
+
+		addr, err := address.NewFromString(cctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		data := []byte(cctx.Args().Get(2))
+		if cctx.Bool("hex") {
+			data, err = hex.DecodeString(cctx.Args().Get(2))
+			if err != nil {
+				return err
+			}
+		}
+
+		sd, err := api.WalletSignData(ctx, addr, cctx.Args().Get(1), data)
+		if err != nil {
+			return err
+		}
+
+		b, err := json.Marshal(sd)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(b))
+		return nil
+	},
+}
+
+var walletVerifyData = &cli.Command{
+	Name:      "verify-data",
+	Usage:     "verify data signed with sign-data, read as JSON from the argument or stdin",
+	ArgsUsage: "[signed data]",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "purpose",
+			Usage: "expected purpose of the signature",
+		},
+		&cli.StringFlag{
+			Name:  "network",
+			Usage: "expected network name, when set the node isn't contacted",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.String("purpose") == "" {
+			return xerrors.Errorf("must specify --purpose")
+		}
+
+		var input []byte
+		if cctx.Args().Present() {
+			input = []byte(cctx.Args().First())
+		} else {
+			var err error
+			input, err = ioutil.ReadAll(os.Stdin)
+			if err != nil {
+				return err
+			}
+		}
+
+		var sd sigs.SignedData
+		if err := json.Unmarshal(input, &sd); err != nil {
+			return xerrors.Errorf("decoding signed data: %w", err)
+		}
+
+		network := cctx.String("network")
+		if network == "" {
+			api, closer, err := GetFullNodeAPI(cctx)
+			if err != nil {
+				return err
+			}
+			defer closer()
+
+			nn, err := api.StateNetworkName(ReqContext(cctx))
+			if err != nil {
+				return err
+			}
+			network = string(nn)
+		}
+
+		if err := sd.Verify(cctx.String("purpose"), network); err != nil {
+			fmt.Println("invalid")
+			return NewCliError(err.Error())
+		}
+
+		fmt.Println("valid")
+		return nil
+	},
+}
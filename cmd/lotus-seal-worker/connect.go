@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/xerrors"
+	"gopkg.in/urfave/cli.v2"
+
+	"github.com/filecoin-project/sector-storage/stores"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+
+	"github.com/filecoin-project/lotus/api"
+	lcli "github.com/filecoin-project/lotus/cli"
+)
+
+var drainCmd = &cli.Command{
+	Name:  "drain",
+	Usage: "Finish the tasks in progress on a worker, without taking new ones",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "address",
+			Usage: "locally reachable address of the worker, as passed to run",
+		},
+		&cli.BoolFlag{
+			Name:  "undo",
+			Usage: "let the worker take new tasks again",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.String("address") == "" {
+			return xerrors.Errorf("--address flag is required")
+		}
+
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return xerrors.Errorf("getting miner api: %w", err)
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		if err := nodeApi.WorkerDrain(ctx, workerURL(cctx.String("address")), !cctx.Bool("undo")); err != nil {
+			return err
+		}
+
+		if cctx.Bool("undo") {
+			fmt.Println("Worker takes new tasks again")
+		} else {
+			fmt.Println("Worker is draining, check the tasks in progress with lotus-storage-miner sealing jobs")
+		}
+		return nil
+	},
+}
+
+func workerURL(address string) string {
+	return "ws://" + address + "/rpc/v0"
+}
+
+// heartbeat keeps the worker connected to the miner. When the miner doesn't
+// know the worker anymore, e.g. after it was restarted, the storage paths of
+// the worker are attached again, and the worker connects again.
+func heartbeat(ctx context.Context, nodeApi api.StorageMiner, localStore *stores.Local, url string, storageURLs []string) {
+	t := time.NewTicker(api.WorkerHeartbeatInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return
+		}
+
+		ok, err := nodeApi.WorkerHeartbeat(ctx, url)
+		if err != nil {
+			log.Warnf("Sending heartbeat to miner failed: %s", err)
+			continue
+		}
+		if ok {
+			continue
+		}
+
+		log.Warn("Miner lost the connection to the worker, connecting again")
+
+		if err := attachPaths(ctx, nodeApi, localStore, storageURLs); err != nil {
+			log.Errorf("Attaching storage paths failed: %+v", err)
+			continue
+		}
+
+		if err := nodeApi.WorkerConnect(ctx, url); err != nil {
+			log.Errorf("Registering worker failed: %+v", err)
+			continue
+		}
+
+		log.Info("Connected to miner again")
+	}
+}
+
+// attachPaths attaches the local storage paths to the index of the miner,
+// and declares the sector files in them
+func attachPaths(ctx context.Context, nodeApi api.StorageMiner, localStore *stores.Local, storageURLs []string) error {
+	paths, err := localStore.Local(ctx)
+	if err != nil {
+		return xerrors.Errorf("getting local paths: %w", err)
+	}
+
+	for _, p := range paths {
+		st, err := localStore.FsStat(ctx, p.ID)
+		if err != nil {
+			return xerrors.Errorf("getting stat of %s: %w", p.LocalPath, err)
+		}
+
+		err = nodeApi.StorageAttach(ctx, stores.StorageInfo{
+			ID:       p.ID,
+			URLs:     storageURLs,
+			Weight:   p.Weight,
+			CanSeal:  p.CanSeal,
+			CanStore: p.CanStore,
+		}, st)
+		if err != nil {
+			return xerrors.Errorf("attaching %s: %w", p.LocalPath, err)
+		}
+
+		for _, ft := range []stores.SectorFileType{stores.FTUnsealed, stores.FTSealed, stores.FTCache} {
+			ents, err := ioutil.ReadDir(filepath.Join(p.LocalPath, ft.String()))
+			if err != nil {
+				if os.IsNotExist(err) {
+					continue
+				}
+				return xerrors.Errorf("listing %s files in %s: %w", ft, p.LocalPath, err)
+			}
+
+			for _, ent := range ents {
+				sid, err := parseSectorName(ent.Name())
+				if err != nil {
+					log.Warnf("Skipping %s file %s: %s", ft, ent.Name(), err)
+					continue
+				}
+
+				if err := nodeApi.StorageDeclareSector(ctx, p.ID, sid, ft); err != nil {
+					return xerrors.Errorf("declaring %s file of sector %d: %w", ft, sid.Number, err)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func parseSectorName(name string) (abi.SectorID, error) {
+	var miner, num uint64
+	if _, err := fmt.Sscanf(name, "s-t0%d-%d", &miner, &num); err != nil {
+		return abi.SectorID{}, xerrors.Errorf("parsing sector name: %w", err)
+	}
+
+	return abi.SectorID{
+		Miner:  abi.ActorID(miner),
+		Number: abi.SectorNumber(num),
+	}, nil
+}
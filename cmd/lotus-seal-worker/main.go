@@ -42,6 +42,7 @@ func main() {
 
 	local := []*cli.Command{
 		runCmd,
+		drainCmd,
 	}
 
 	app := &cli.App{
@@ -236,7 +237,9 @@ var runCmd = &cli.Command{
 
 		log.Info("Opening local storage; connecting to master")
 
-		localStore, err := stores.NewLocal(ctx, lr, nodeApi, []string{"http://" + cctx.String("address") + "/remote"})
+		storageURLs := []string{"http://" + cctx.String("address") + "/remote"}
+
+		localStore, err := stores.NewLocal(ctx, lr, nodeApi, storageURLs)
 		if err != nil {
 			return err
 		}
@@ -303,11 +306,14 @@ var runCmd = &cli.Command{
 		log.Info("Waiting for tasks")
 
 		go func() {
-			if err := nodeApi.WorkerConnect(ctx, "ws://"+cctx.String("address")+"/rpc/v0"); err != nil {
+			url := workerURL(cctx.String("address"))
+			if err := nodeApi.WorkerConnect(ctx, url); err != nil {
 				log.Errorf("Registering worker failed: %+v", err)
 				cancel()
 				return
 			}
+
+			heartbeat(ctx, nodeApi, localStore, url, storageURLs)
 		}()
 
 		return srv.Serve(nl)
@@ -23,6 +23,7 @@ func main() {
 		bigIntParseCmd,
 		staterootStatsCmd,
 		importCarCmd,
+		signMessageCmd,
 	}
 
 	app := &cli.App{
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"golang.org/x/xerrors"
+	"gopkg.in/urfave/cli.v2"
+
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/wallet"
+)
+
+var signMessageCmd = &cli.Command{
+	Name:        "sign-message",
+	Description: "sign a hex encoded unsigned message (as printed with --unsigned) using a keyinfo file, without a running node",
+	ArgsUsage:   "[message (or - for stdin)]",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "key",
+			Usage: "path to a hex encoded keyinfo file, as created by 'lotus wallet export'",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if !cctx.IsSet("key") {
+			return xerrors.Errorf("must specify --key")
+		}
+
+		kidata, err := ioutil.ReadFile(cctx.String("key"))
+		if err != nil {
+			return xerrors.Errorf("reading key file: %w", err)
+		}
+
+		kib, err := hex.DecodeString(strings.TrimSpace(string(kidata)))
+		if err != nil {
+			return xerrors.Errorf("decoding key file: %w", err)
+		}
+
+		var ki types.KeyInfo
+		if err := json.Unmarshal(kib, &ki); err != nil {
+			return xerrors.Errorf("parsing keyinfo: %w", err)
+		}
+
+		key, err := wallet.NewKey(ki)
+		if err != nil {
+			return err
+		}
+
+		var input []byte
+		if !cctx.Args().Present() || cctx.Args().First() == "-" {
+			input, err = ioutil.ReadAll(os.Stdin)
+			if err != nil {
+				return err
+			}
+		} else {
+			input = []byte(cctx.Args().First())
+		}
+
+		mb, err := hex.DecodeString(strings.TrimSpace(string(input)))
+		if err != nil {
+			return xerrors.Errorf("decoding message: %w", err)
+		}
+
+		msg, err := types.DecodeMessage(mb)
+		if err != nil {
+			return xerrors.Errorf("decoding message: %w", err)
+		}
+
+		if msg.From != key.Address {
+			return xerrors.Errorf("message is from %s, but key is for %s", msg.From, key.Address)
+		}
+
+		// show what is being signed, the signed message goes to stdout
+		pretty, err := json.MarshalIndent(msg, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Signing message %s:\n%s\n", msg.Cid(), string(pretty))
+
+		sig, err := wallet.KeyWallet(key).Sign(context.TODO(), key.Address, msg.Cid().Bytes())
+		if err != nil {
+			return xerrors.Errorf("signing message: %w", err)
+		}
+
+		smsg := &types.SignedMessage{
+			Message:   *msg,
+			Signature: *sig,
+		}
+
+		b, err := smsg.Serialize()
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(hex.EncodeToString(b))
+		return nil
+	},
+}
@@ -25,8 +25,11 @@ func main() {
 		dealsCmd,
 		infoCmd,
 		initCmd,
+		marketCmd,
+		provingCmd,
 		rewardsCmd,
 		runCmd,
+		sealingCmd,
 		sectorsCmd,
 		storageCmd,
 		setPriceCmd,
@@ -4,9 +4,13 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"github.com/docker/go-units"
+	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/lotus/chain/types"
 	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/filecoin-project/specs-actors/actors/abi"
 	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
 	"gopkg.in/urfave/cli.v2"
 )
 
@@ -42,6 +46,8 @@ var dealsCmd = &cli.Command{
 	Subcommands: []*cli.Command{
 		dealsImportDataCmd,
 		dealsListCmd,
+		dealsGetPolicyCmd,
+		dealsSetPolicyCmd,
 	},
 }
 
@@ -100,3 +106,218 @@ var dealsListCmd = &cli.Command{
 		return nil
 	},
 }
+
+var dealsGetPolicyCmd = &cli.Command{
+	Name:  "get-policy",
+	Usage: "Print the policy deciding which deals are accepted",
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.DaemonContext(cctx)
+
+		policy, err := api.DealsGetPolicy(ctx)
+		if err != nil {
+			return err
+		}
+
+		data, err := json.MarshalIndent(policy, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(data))
+		return nil
+	},
+}
+
+var dealsSetPolicyCmd = &cli.Command{
+	Name:  "set-policy",
+	Usage: "Change the policy deciding which deals are accepted, until the miner is restarted",
+	Flags: []cli.Flag{
+		&cli.Int64Flag{
+			Name:  "min-duration",
+			Usage: "shortest deal duration accepted, in epochs, 0 for no limit",
+		},
+		&cli.Int64Flag{
+			Name:  "max-duration",
+			Usage: "longest deal duration accepted, in epochs, 0 for no limit",
+		},
+		&cli.StringFlag{
+			Name:  "min-piece-size",
+			Usage: "smallest padded piece size accepted, 0 for no limit",
+		},
+		&cli.StringFlag{
+			Name:  "max-piece-size",
+			Usage: "largest padded piece size accepted, 0 for no limit",
+		},
+		&cli.StringSliceFlag{
+			Name:  "allow-client",
+			Usage: "accept deals only from these clients, pass an empty address to accept all clients",
+		},
+		&cli.StringSliceFlag{
+			Name:  "block-client",
+			Usage: "reject deals from these clients, pass an empty address to block no clients",
+		},
+		&cli.Uint64Flag{
+			Name:  "max-concurrent-deals",
+			Usage: "limit of deals in progress, 0 for no limit",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.DaemonContext(cctx)
+
+		// flags which aren't set keep their current value
+		policy, err := api.DealsGetPolicy(ctx)
+		if err != nil {
+			return err
+		}
+
+		parseSize := func(name string) (abi.PaddedPieceSize, error) {
+			size, err := units.RAMInBytes(cctx.String(name))
+			if err != nil {
+				return 0, xerrors.Errorf("parsing %s: %w", name, err)
+			}
+			return abi.PaddedPieceSize(size), nil
+		}
+
+		parseClients := func(name string) ([]address.Address, error) {
+			var out []address.Address
+			for _, s := range cctx.StringSlice(name) {
+				if s == "" {
+					continue
+				}
+				addr, err := address.NewFromString(s)
+				if err != nil {
+					return nil, xerrors.Errorf("parsing %s: %w", name, err)
+				}
+				out = append(out, addr)
+			}
+			return out, nil
+		}
+
+		if cctx.IsSet("min-duration") {
+			policy.MinDuration = abi.ChainEpoch(cctx.Int64("min-duration"))
+		}
+		if cctx.IsSet("max-duration") {
+			policy.MaxDuration = abi.ChainEpoch(cctx.Int64("max-duration"))
+		}
+		if cctx.IsSet("min-piece-size") {
+			if policy.MinPieceSize, err = parseSize("min-piece-size"); err != nil {
+				return err
+			}
+		}
+		if cctx.IsSet("max-piece-size") {
+			if policy.MaxPieceSize, err = parseSize("max-piece-size"); err != nil {
+				return err
+			}
+		}
+		if cctx.IsSet("allow-client") {
+			if policy.AllowedClients, err = parseClients("allow-client"); err != nil {
+				return err
+			}
+		}
+		if cctx.IsSet("block-client") {
+			if policy.BlockedClients, err = parseClients("block-client"); err != nil {
+				return err
+			}
+		}
+		if cctx.IsSet("max-concurrent-deals") {
+			policy.MaxConcurrentDeals = cctx.Uint64("max-concurrent-deals")
+		}
+		return api.DealsSetPolicy(ctx, policy)
+	},
+}
+
+var marketCmd = &cli.Command{
+	Name:  "market",
+	Usage: "Manage the storage market escrow of the miner",
+	Subcommands: []*cli.Command{
+		marketBalanceCmd,
+		marketWithdrawCmd,
+	},
+}
+
+var marketBalanceCmd = &cli.Command{
+	Name:  "balance",
+	Usage: "Show market escrow balance, with the collateral reserved for deals",
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		api, acloser, err := lcli.GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer acloser()
+
+		ctx := lcli.ReqContext(cctx)
+
+		maddr, err := nodeApi.ActorAddress(ctx)
+		if err != nil {
+			return err
+		}
+
+		return lcli.PrintMarketBalance(ctx, api, maddr)
+	},
+}
+
+var marketWithdrawCmd = &cli.Command{
+	Name:      "withdraw",
+	Usage:     "Withdraw funds which aren't locked or reserved from market escrow to the owner",
+	ArgsUsage: "[amount (FIL)]",
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 1 {
+			return xerrors.Errorf("must pass amount to withdraw")
+		}
+
+		amt, err := types.ParseFIL(cctx.Args().First())
+		if err != nil {
+			return xerrors.Errorf("parsing amount: %w", err)
+		}
+
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		api, acloser, err := lcli.GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer acloser()
+
+		ctx := lcli.ReqContext(cctx)
+
+		maddr, err := nodeApi.ActorAddress(ctx)
+		if err != nil {
+			return err
+		}
+
+		worker, err := api.StateMinerWorker(ctx, maddr, types.EmptyTSK)
+		if err != nil {
+			return xerrors.Errorf("getting miner worker: %w", err)
+		}
+
+		mcid, err := api.MarketWithdraw(ctx, worker, maddr, types.BigInt(amt))
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Withdrawing %s in message %s\n", amt, mcid)
+		return nil
+	},
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"golang.org/x/xerrors"
+	"gopkg.in/urfave/cli.v2"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/build"
+	lcli "github.com/filecoin-project/lotus/cli"
+)
+
+var provingCmd = &cli.Command{
+	Name:  "proving",
+	Usage: "View proving state and check sectors",
+	Subcommands: []*cli.Command{
+		provingInfoCmd,
+		provingHistoryCmd,
+		provingDryRunCmd,
+		provingCheckCmd,
+	},
+}
+
+var provingInfoCmd = &cli.Command{
+	Name:  "info",
+	Usage: "View the current proving period and the last PoSt",
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		st, err := nodeApi.ProvingStatus(ctx)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Chain head:           %d\n", st.Head)
+		fmt.Printf("Proving period start: %d\n", st.ProvingPeriodStart)
+		if st.ChallengeEpoch > st.Head {
+			fmt.Printf("Next PoSt:            %d (in %s)\n", st.ChallengeEpoch, time.Duration(st.ChallengeEpoch-st.Head)*time.Duration(build.BlockDelay)*time.Second)
+		} else {
+			fmt.Printf("Next PoSt:            %d\n", st.ChallengeEpoch)
+		}
+		fmt.Printf("Proving set:          %d sectors, %d faulty\n", st.ProvingSetSize, st.Faults)
+
+		if st.Running != nil {
+			fmt.Println("\nRunning:")
+			printPoStRecord(st.Running)
+		}
+		if st.Last != nil {
+			fmt.Println("\nLast PoSt:")
+			printPoStRecord(st.Last)
+		}
+
+		return nil
+	},
+}
+
+var provingHistoryCmd = &cli.Command{
+	Name:  "history",
+	Usage: "List PoSt attempts",
+	Flags: []cli.Flag{
+		&cli.IntFlag{
+			Name:  "limit",
+			Usage: "number of attempts to list, 0 for all",
+			Value: 20,
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		recs, err := nodeApi.ProvingHistory(ctx, cctx.Int("limit"))
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "Period\tHeight\tStarted\tSectors\tFaults\tDuration\tStatus\tMessage\tError")
+		for _, rec := range recs {
+			msg := "-"
+			if rec.Message != nil {
+				msg = rec.Message.String()
+			}
+
+			fmt.Fprintf(tw, "%d\t%d\t%s\t%d\t%d\t%s\t%s\t%s\t%s\n",
+				rec.ProvingPeriodStart,
+				rec.Height,
+				rec.Start.Format(time.Stamp),
+				rec.Sectors,
+				len(rec.Faults),
+				rec.Duration.Truncate(time.Millisecond),
+				postStatusStr(&rec),
+				msg,
+				rec.Err)
+		}
+
+		return tw.Flush()
+	},
+}
+
+var provingDryRunCmd = &cli.Command{
+	Name:  "dry-run",
+	Usage: "Compute a PoSt against the current head, without submitting it",
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		rec, err := nodeApi.ProvingDryRun(ctx)
+		if err != nil {
+			return err
+		}
+
+		printPoStRecord(rec)
+
+		if rec.Status != api.PoStOk {
+			return xerrors.New("dry run failed")
+		}
+		return nil
+	},
+}
+
+var provingCheckCmd = &cli.Command{
+	Name:      "check",
+	Usage:     "Check that the files of sectors are present and readable",
+	ArgsUsage: "[sector numbers, all proven sectors if none given]",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "sample",
+			Usage: "make random reads from sealed files, in addition to checking their size",
+		},
+		&cli.BoolFlag{
+			Name:  "faulty",
+			Usage: "only list sectors which aren't ok",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		sectors, err := parseSectorNums(cctx.Args().Slice())
+		if err != nil {
+			return err
+		}
+
+		health, err := nodeApi.SectorsCheckHealth(ctx, sectors, cctx.Bool("sample"))
+		if err != nil {
+			return err
+		}
+
+		counts := map[api.SectorHealthStatus]int{}
+
+		tw := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "Sector\tStatus\tError")
+		for _, h := range health {
+			counts[h.Status]++
+			if cctx.Bool("faulty") && h.Status == api.SectorHealthOk {
+				continue
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\n", h.Sector, h.Status, h.Err)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+
+		fmt.Printf("\n%d ok, %d faulty, %d unchecked\n", counts[api.SectorHealthOk], counts[api.SectorHealthFaulty], counts[api.SectorHealthUnchecked])
+		return nil
+	},
+}
+
+func printPoStRecord(rec *api.PoStRecord) {
+	fmt.Printf("\tPeriod start:    %d\n", rec.ProvingPeriodStart)
+	fmt.Printf("\tHeight:          %d\n", rec.Height)
+	fmt.Printf("\tStarted:         %s\n", rec.Start.Format(time.Stamp))
+	fmt.Printf("\tSectors:         %d (%d faulty)\n", rec.Sectors, len(rec.Faults))
+	fmt.Printf("\tDuration:        %s\n", rec.Duration.Truncate(time.Millisecond))
+	fmt.Printf("\tStatus:          %s\n", postStatusStr(rec))
+	if rec.Message != nil {
+		fmt.Printf("\tMessage:         %s\n", rec.Message)
+	}
+	if rec.Err != "" {
+		fmt.Printf("\tError:           %s\n", rec.Err)
+	}
+}
+
+func postStatusStr(rec *api.PoStRecord) string {
+	if rec.Status == api.PoStFailed && rec.ExitCode != 0 {
+		return fmt.Sprintf("%s (exit %d)", rec.Status, rec.ExitCode)
+	}
+	return string(rec.Status)
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"golang.org/x/xerrors"
+	"gopkg.in/urfave/cli.v2"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/filecoin-project/lotus/node/config"
+)
+
+var sealingCmd = &cli.Command{
+	Name:  "sealing",
+	Usage: "interact with sealing pipeline",
+	Subcommands: []*cli.Command{
+		sealingJobsCmd,
+		sealingPledgeCmd,
+	},
+}
+
+var sealingJobsCmd = &cli.Command{
+	Name:  "jobs",
+	Usage: "list sealing jobs running on remote workers",
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		jobs, err := nodeApi.WorkerJobs(ctx)
+		if err != nil {
+			return err
+		}
+
+		type line struct {
+			api.WorkerJob
+			worker string
+		}
+
+		var lines []line
+		for worker, wjobs := range jobs {
+			for _, job := range wjobs {
+				lines = append(lines, line{WorkerJob: job, worker: worker})
+			}
+		}
+
+		sort.Slice(lines, func(i, j int) bool {
+			return lines[i].ID < lines[j].ID
+		})
+
+		tw := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tSector\tWorker\tTask\tTime")
+		for _, l := range lines {
+			fmt.Fprintf(tw, "%d\t%d\t%s\t%s\t%s\n", l.ID, l.Sector.Number, l.worker, l.Task, time.Since(l.Start).Truncate(time.Second))
+		}
+
+		return tw.Flush()
+	},
+}
+
+var sealingPledgeCmd = &cli.Command{
+	Name:  "pledge",
+	Usage: "manage the pledge scheduler",
+	Subcommands: []*cli.Command{
+		sealingPledgeStatusCmd,
+		sealingPledgeSetCmd,
+	},
+}
+
+var sealingPledgeStatusCmd = &cli.Command{
+	Name:  "status",
+	Usage: "print the config and status of the pledge scheduler",
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		cfg, err := nodeApi.PledgeGetConfig(ctx)
+		if err != nil {
+			return err
+		}
+
+		st, err := nodeApi.PledgeStatus(ctx)
+		if err != nil {
+			return err
+		}
+
+		limit := func(n uint64) string {
+			if n == 0 {
+				return "no limit"
+			}
+			return fmt.Sprintf("limit %d", n)
+		}
+
+		fmt.Printf("Enabled: %t\n", cfg.Enabled)
+		fmt.Printf("Sealing: %d (target %d, %d pledged sectors not started)\n", st.Sealing, cfg.TargetSealing, st.Pending)
+		fmt.Printf("\tPreCommit1: %d (%s)\n", st.PreCommit1, limit(cfg.MaxPreCommit1))
+		fmt.Printf("\tPreCommit2: %d (%s)\n", st.PreCommit2, limit(cfg.MaxPreCommit2))
+		fmt.Printf("\tCommit: %d (%s)\n", st.Commit, limit(cfg.MaxCommit))
+		fmt.Printf("Min available storage: %d%%\n", cfg.MinAvailablePercent)
+		fmt.Printf("Min worker balance: %s\n", cfg.MinWorkerBalance)
+		fmt.Printf("Check interval: %s\n", time.Duration(cfg.CheckInterval))
+
+		if st.Waiting != "" {
+			fmt.Printf("Waiting: %s\n", st.Waiting)
+		}
+		if !st.LastPledge.IsZero() {
+			fmt.Printf("Last pledge: %s ago\n", time.Since(st.LastPledge).Truncate(time.Second))
+		}
+		if st.LastErr != "" {
+			fmt.Printf("Last error: %s\n", st.LastErr)
+		}
+
+		return nil
+	},
+}
+
+var sealingPledgeSetCmd = &cli.Command{
+	Name:  "set",
+	Usage: "change the config of the pledge scheduler until the miner is restarted",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "enable",
+			Usage: "pledge sectors",
+		},
+		&cli.Uint64Flag{
+			Name:  "target",
+			Usage: "number of sectors kept sealing",
+		},
+		&cli.Uint64Flag{
+			Name:  "max-precommit1",
+			Usage: "limit of sectors in PreCommit1, 0 for no limit",
+		},
+		&cli.Uint64Flag{
+			Name:  "max-precommit2",
+			Usage: "limit of sectors in PreCommit2, 0 for no limit",
+		},
+		&cli.Uint64Flag{
+			Name:  "max-commit",
+			Usage: "limit of sectors committing, 0 for no limit",
+		},
+		&cli.Uint64Flag{
+			Name:  "min-available",
+			Usage: "percentage of storage capacity which has to be available",
+		},
+		&cli.StringFlag{
+			Name:  "min-balance",
+			Usage: "worker balance needed to pledge, in FIL",
+		},
+		&cli.DurationFlag{
+			Name:  "interval",
+			Usage: "time between checks",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		// flags which aren't set keep their current value
+		cfg, err := nodeApi.PledgeGetConfig(ctx)
+		if err != nil {
+			return err
+		}
+
+		if cctx.IsSet("enable") {
+			cfg.Enabled = cctx.Bool("enable")
+		}
+		if cctx.IsSet("target") {
+			cfg.TargetSealing = cctx.Uint64("target")
+		}
+		if cctx.IsSet("max-precommit1") {
+			cfg.MaxPreCommit1 = cctx.Uint64("max-precommit1")
+		}
+		if cctx.IsSet("max-precommit2") {
+			cfg.MaxPreCommit2 = cctx.Uint64("max-precommit2")
+		}
+		if cctx.IsSet("max-commit") {
+			cfg.MaxCommit = cctx.Uint64("max-commit")
+		}
+		if cctx.IsSet("min-available") {
+			cfg.MinAvailablePercent = cctx.Uint64("min-available")
+		}
+		if cctx.IsSet("min-balance") {
+			bal, err := types.ParseFIL(cctx.String("min-balance"))
+			if err != nil {
+				return xerrors.Errorf("parsing min balance: %w", err)
+			}
+			cfg.MinWorkerBalance = bal
+		}
+		if cctx.IsSet("interval") {
+			cfg.CheckInterval = config.Duration(cctx.Duration("interval"))
+		}
+
+		return nodeApi.PledgeSetConfig(ctx, cfg)
+	},
+}
@@ -11,8 +11,10 @@ import (
 	"golang.org/x/xerrors"
 	"gopkg.in/urfave/cli.v2"
 
+	"github.com/filecoin-project/sector-storage/stores"
 	"github.com/filecoin-project/specs-actors/actors/abi"
 
+	"github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/chain/types"
 	lcli "github.com/filecoin-project/lotus/cli"
 	sealing "github.com/filecoin-project/storage-fsm"
@@ -27,6 +29,13 @@ var sectorsCmd = &cli.Command{
 		sectorsRefsCmd,
 		sectorsUpdateCmd,
 		sectorsPledgeCmd,
+		sectorsRemoveCmd,
+		sectorsExtendCmd,
+		sectorsTerminateCmd,
+		sectorsMoveCmd,
+		sectorsMovesCmd,
+		sectorsPackingCmd,
+		sectorsSealNowCmd,
 	},
 }
 
@@ -45,6 +54,58 @@ var sectorsPledgeCmd = &cli.Command{
 	},
 }
 
+var sectorsPackingCmd = &cli.Command{
+	Name:  "packing",
+	Usage: "List deals waiting to be packed into sectors",
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		st, err := nodeApi.SectorsPackingStatus(ctx)
+		if err != nil {
+			return err
+		}
+
+		if len(st.Pieces) == 0 {
+			fmt.Println("No deals waiting")
+			return nil
+		}
+
+		fmt.Printf("Filled: %s of %s (%d%%)\n",
+			types.SizeStr(types.NewInt(uint64(st.Filled))),
+			types.SizeStr(types.NewInt(uint64(st.SectorSize))),
+			uint64(st.Filled)*100/uint64(st.SectorSize))
+		fmt.Printf("Sealing in %s, or at epoch %d\n", time.Until(st.Deadline).Truncate(time.Second), st.SealBy)
+
+		tw := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "Deal\tSize\tStart Epoch\tWaiting")
+		for _, p := range st.Pieces {
+			fmt.Fprintf(tw, "%d\t%s\t%d\t%s\n", p.DealID, types.SizeStr(types.NewInt(uint64(p.Size))), p.StartEpoch, time.Since(p.Added).Truncate(time.Second))
+		}
+
+		return tw.Flush()
+	},
+}
+
+var sectorsSealNowCmd = &cli.Command{
+	Name:  "seal-now",
+	Usage: "Hand all deals waiting for packing to sealing",
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		return nodeApi.SectorsSealNow(ctx)
+	},
+}
+
 var sectorsStatusCmd = &cli.Command{
 	Name:  "status",
 	Usage: "Get the seal status of a sector by its ID",
@@ -240,6 +301,246 @@ var sectorsUpdateCmd = &cli.Command{
 	},
 }
 
+var sectorsRemoveCmd = &cli.Command{
+	Name:      "remove",
+	Usage:     "Remove the files of a sector which isn't committed on chain, and stop sealing it",
+	ArgsUsage: "<sector number>",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "really-do-it",
+			Usage: "pass this flag if you know what you are doing",
+		},
+		&cli.BoolFlag{
+			Name:  "force",
+			Usage: "remove sectors which aren't in a failed state, or have deals",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if !cctx.Bool("really-do-it") {
+			return xerrors.Errorf("sector files are removed permanently, pass --really-do-it to confirm")
+		}
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+		if cctx.Args().Len() != 1 {
+			return xerrors.Errorf("must pass sector number")
+		}
+
+		id, err := strconv.ParseUint(cctx.Args().First(), 10, 64)
+		if err != nil {
+			return xerrors.Errorf("could not parse sector number: %w", err)
+		}
+
+		return nodeApi.SectorsRemove(ctx, abi.SectorNumber(id), cctx.Bool("force"))
+	},
+}
+
+var sectorsMoveCmd = &cli.Command{
+	Name:      "move",
+	Usage:     "Move the files of a proving sector to another local storage path",
+	ArgsUsage: "<sector number> <storage id>",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "wait",
+			Usage: "print progress until the move ends",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+		if cctx.Args().Len() != 2 {
+			return xerrors.Errorf("must pass sector number and storage id")
+		}
+
+		id, err := strconv.ParseUint(cctx.Args().First(), 10, 64)
+		if err != nil {
+			return xerrors.Errorf("could not parse sector number: %w", err)
+		}
+
+		if err := nodeApi.SectorsMove(ctx, abi.SectorNumber(id), stores.ID(cctx.Args().Get(1))); err != nil {
+			return err
+		}
+
+		if !cctx.Bool("wait") {
+			fmt.Println("Move started, check progress with lotus-storage-miner sectors moves")
+			return nil
+		}
+
+		for {
+			moves, err := nodeApi.SectorsMoves(ctx)
+			if err != nil {
+				return err
+			}
+
+			for _, mv := range moves {
+				if mv.Sector.Number != abi.SectorNumber(id) {
+					continue
+				}
+
+				fmt.Printf("\r%s", moveStatus(mv))
+
+				switch mv.Stage {
+				case api.SectorMoveDone:
+					fmt.Println()
+					return nil
+				case api.SectorMoveFailed:
+					fmt.Println()
+					return xerrors.Errorf("move failed: %s", mv.Err)
+				}
+			}
+
+			select {
+			case <-time.After(time.Second):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	},
+}
+
+var sectorsMovesCmd = &cli.Command{
+	Name:  "moves",
+	Usage: "List sector moves",
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		moves, err := nodeApi.SectorsMoves(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, mv := range moves {
+			fmt.Printf("%d: %s -> %s, %s\n", mv.Sector.Number, mv.From, mv.To, moveStatus(mv))
+			if mv.Err != "" {
+				fmt.Printf("\t%s\n", mv.Err)
+			}
+		}
+
+		return nil
+	},
+}
+
+func moveStatus(mv api.SectorMove) string {
+	if mv.Stage != api.SectorMoveCopying {
+		return string(mv.Stage)
+	}
+
+	var pct uint64
+	if mv.Total > 0 {
+		pct = mv.Copied * 100 / mv.Total
+	}
+
+	return fmt.Sprintf("%s %s/%s (%d%%)", mv.Stage,
+		types.SizeStr(types.NewInt(mv.Copied)),
+		types.SizeStr(types.NewInt(mv.Total)),
+		pct)
+}
+
+var sectorsExtendCmd = &cli.Command{
+	Name:      "extend",
+	Usage:     "Extend the expiration of committed sectors",
+	ArgsUsage: "<sector numbers...>",
+	Flags: []cli.Flag{
+		&cli.Int64Flag{
+			Name:  "new-expiration",
+			Usage: "epoch at which the sectors expire",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if !cctx.IsSet("new-expiration") {
+			return xerrors.Errorf("must specify --new-expiration")
+		}
+
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		sectors, err := parseSectorNums(cctx.Args().Slice())
+		if err != nil {
+			return err
+		}
+		if len(sectors) == 0 {
+			return xerrors.Errorf("must pass sector numbers")
+		}
+
+		msgs, err := nodeApi.SectorsExtend(ctx, sectors, abi.ChainEpoch(cctx.Int64("new-expiration")))
+		for i, mcid := range msgs {
+			fmt.Printf("sector %d: %s\n", sectors[i], mcid)
+		}
+		return err
+	},
+}
+
+var sectorsTerminateCmd = &cli.Command{
+	Name:      "terminate",
+	Usage:     "Terminate committed sectors early",
+	ArgsUsage: "<sector numbers...>",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "really-do-it",
+			Usage: "pass this flag if you know what you are doing",
+		},
+		&cli.BoolFlag{
+			Name:  "force",
+			Usage: "terminate sectors with active deals",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if !cctx.Bool("really-do-it") {
+			return xerrors.Errorf("terminated sectors lose their pledge, pass --really-do-it to confirm")
+		}
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		sectors, err := parseSectorNums(cctx.Args().Slice())
+		if err != nil {
+			return err
+		}
+		if len(sectors) == 0 {
+			return xerrors.Errorf("must pass sector numbers")
+		}
+
+		mcid, err := nodeApi.SectorsTerminate(ctx, sectors, cctx.Bool("force"))
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Terminated %d sectors in message %s\n", len(sectors), mcid)
+		return nil
+	},
+}
+
+func parseSectorNums(args []string) ([]abi.SectorNumber, error) {
+	out := make([]abi.SectorNumber, 0, len(args))
+	for _, a := range args {
+		n, err := strconv.ParseUint(a, 10, 64)
+		if err != nil {
+			return nil, xerrors.Errorf("parsing sector number %q: %w", a, err)
+		}
+		out = append(out, abi.SectorNumber(n))
+	}
+	return out, nil
+}
+
 func yesno(b bool) string {
 	if b {
 		return "YES"
@@ -18,6 +18,7 @@ import (
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/specs-actors/actors/abi"
 
+	"github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/chain/types"
 	lcli "github.com/filecoin-project/lotus/cli"
 	"github.com/filecoin-project/sector-storage/stores"
@@ -32,6 +33,8 @@ var storageCmd = &cli.Command{
 		storageAttachCmd,
 		storageListCmd,
 		storageFindCmd,
+		storageDetachCmd,
+		storageSetConfigCmd,
 	},
 }
 
@@ -345,3 +348,89 @@ var storageFindCmd = &cli.Command{
 		return nil
 	},
 }
+
+var storageDetachCmd = &cli.Command{
+	Name:      "detach",
+	Usage:     "detach local storage path without sector files",
+	ArgsUsage: "<storage id>",
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		if !cctx.Args().Present() {
+			return xerrors.Errorf("must specify storage id to detach")
+		}
+
+		if err := nodeApi.StorageDetach(ctx, stores.ID(cctx.Args().First())); err != nil {
+			return err
+		}
+
+		fmt.Println("Detached, restart the miner to stop using the path")
+		return nil
+	},
+}
+
+var storageSetConfigCmd = &cli.Command{
+	Name:      "set-config",
+	Usage:     "change the weight and use of a local storage path",
+	ArgsUsage: "<storage id>",
+	Flags: []cli.Flag{
+		&cli.Uint64Flag{
+			Name:  "weight",
+			Usage: "path weight",
+		},
+		&cli.BoolFlag{
+			Name:  "seal",
+			Usage: "use path for sealing",
+		},
+		&cli.BoolFlag{
+			Name:  "store",
+			Usage: "use path for long-term storage",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		if !cctx.Args().Present() {
+			return xerrors.Errorf("must specify storage id")
+		}
+		id := stores.ID(cctx.Args().First())
+
+		si, err := nodeApi.StorageInfo(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		// flags which aren't set keep their current value
+		cfg := api.StoragePathConfig{
+			Weight:   si.Weight,
+			CanSeal:  si.CanSeal,
+			CanStore: si.CanStore,
+		}
+		if cctx.IsSet("weight") {
+			cfg.Weight = cctx.Uint64("weight")
+		}
+		if cctx.IsSet("seal") {
+			cfg.CanSeal = cctx.Bool("seal")
+		}
+		if cctx.IsSet("store") {
+			cfg.CanStore = cctx.Bool("store")
+		}
+
+		if err := nodeApi.StorageSetConfig(ctx, id, cfg); err != nil {
+			return err
+		}
+
+		fmt.Println("Config updated, restart the miner to use it")
+		return nil
+	},
+}
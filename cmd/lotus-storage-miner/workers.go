@@ -6,6 +6,7 @@ import (
 	"github.com/filecoin-project/sector-storage"
 	"gopkg.in/urfave/cli.v2"
 	"sort"
+	"time"
 
 	lcli "github.com/filecoin-project/lotus/cli"
 )
@@ -15,6 +16,7 @@ var workersCmd = &cli.Command{
 	Usage: "interact with workers",
 	Subcommands: []*cli.Command{
 		workersListCmd,
+		workersRemoteCmd,
 	},
 }
 
@@ -84,3 +86,35 @@ var workersListCmd = &cli.Command{
 		return nil
 	},
 }
+
+var workersRemoteCmd = &cli.Command{
+	Name:  "remote",
+	Usage: "list connection state of remote workers",
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		states, err := nodeApi.WorkerStates(ctx)
+		if err != nil {
+			return err
+		}
+
+		urls := make([]string, 0, len(states))
+		for url := range states {
+			urls = append(urls, url)
+		}
+		sort.Strings(urls)
+
+		for _, url := range urls {
+			st := states[url]
+			fmt.Printf("%s: %s, last heartbeat %s ago\n", url, st.State, time.Since(st.LastHeartbeat).Truncate(time.Second))
+		}
+
+		return nil
+	},
+}
@@ -35,6 +35,7 @@ func main() {
 	err = gen.WriteMapEncodersToFile("./paychmgr/cbor_gen.go", "paychmgr",
 		paychmgr.VoucherInfo{},
 		paychmgr.ChannelInfo{},
+		paychmgr.PendingChannel{},
 	)
 	if err != nil {
 		fmt.Println(err)
@@ -55,6 +56,7 @@ func main() {
 
 	err = gen.WriteTupleEncodersToFile("./node/hello/cbor_gen.go", "hello",
 		hello.HelloMessage{},
+		hello.HelloMessageV2{},
 		hello.LatencyMessage{},
 	)
 	if err != nil {
@@ -0,0 +1,213 @@
+// Package hdkey derives Filecoin private keys from a BIP-39 seed.
+//
+// secp256k1 keys are derived with BIP-32 on the BIP-44 path
+// m/44'/461'/0'/0/<index>, BLS keys are derived with EIP-2333 on the path
+// m/12381/461/0/<index>.
+package hdkey
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"io"
+	"math/big"
+
+	"github.com/filecoin-project/go-crypto"
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/xerrors"
+)
+
+const (
+	// FilecoinCoinType is the SLIP-44 coin type of Filecoin
+	FilecoinCoinType = 461
+
+	// Hardened is added to an index to request hardened BIP-32 derivation
+	Hardened = uint32(1 << 31)
+)
+
+var (
+	// order of the secp256k1 curve
+	secpN, _ = new(big.Int).SetString("fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141", 16)
+
+	// order of the BLS12-381 curve subgroup
+	blsR, _ = new(big.Int).SetString("73eda753299d7d483339d80809a1d80553bda402fffe5bfeffffffff00000001", 16)
+)
+
+// SecpPath returns the BIP-44 path of the secp256k1 key with the given index
+func SecpPath(index uint32) []uint32 {
+	return []uint32{44 + Hardened, FilecoinCoinType + Hardened, Hardened, 0, index}
+}
+
+// BLSPath returns the EIP-2334 style path of the BLS key with the given index
+func BLSPath(index uint32) []uint32 {
+	return []uint32{12381, FilecoinCoinType, 0, index}
+}
+
+// DeriveSecp256k1 returns the secp256k1 private key at path, derived with
+// BIP-32 from seed
+func DeriveSecp256k1(seed []byte, path []uint32) ([]byte, error) {
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	_, _ = mac.Write(seed)
+	I := mac.Sum(nil)
+
+	key, chainCode := I[:32], I[32:]
+	k := new(big.Int).SetBytes(key)
+	if k.Sign() == 0 || k.Cmp(secpN) >= 0 {
+		return nil, xerrors.Errorf("invalid master key, use a different seed")
+	}
+
+	for _, index := range path {
+		var data []byte
+		if index >= Hardened {
+			data = append([]byte{0}, key...)
+		} else {
+			data = compressedPubkey(key)
+		}
+		data = append(data, ser32(index)...)
+
+		mac := hmac.New(sha512.New, chainCode)
+		_, _ = mac.Write(data)
+		I := mac.Sum(nil)
+
+		il := new(big.Int).SetBytes(I[:32])
+		if il.Cmp(secpN) >= 0 {
+			return nil, xerrors.Errorf("invalid key at index %d, use the next index", index)
+		}
+
+		child := new(big.Int).Add(il, new(big.Int).SetBytes(key))
+		child.Mod(child, secpN)
+		if child.Sign() == 0 {
+			return nil, xerrors.Errorf("invalid key at index %d, use the next index", index)
+		}
+
+		key = ser256(child)
+		chainCode = I[32:]
+	}
+
+	return key, nil
+}
+
+func compressedPubkey(priv []byte) []byte {
+	// uncompressed: 0x04 || X || Y
+	pub := crypto.PublicKey(priv)
+
+	out := make([]byte, 33)
+	out[0] = 0x02 + pub[64]&1
+	copy(out[1:], pub[1:33])
+	return out
+}
+
+// DeriveBLS returns the BLS private key at path, derived with EIP-2333 from
+// seed. The key is encoded little-endian, as expected by filecoin-ffi.
+func DeriveBLS(seed []byte, path []uint32) ([]byte, error) {
+	sk, err := deriveBLSScalar(seed, path)
+	if err != nil {
+		return nil, err
+	}
+
+	be := ser256(sk)
+	le := make([]byte, len(be))
+	for i := range be {
+		le[i] = be[len(be)-1-i]
+	}
+	return le, nil
+}
+
+func deriveBLSScalar(seed []byte, path []uint32) (*big.Int, error) {
+	if len(seed) < 32 {
+		return nil, xerrors.Errorf("seed must be at least 32 bytes long, was %d", len(seed))
+	}
+
+	sk, err := hkdfModR(seed)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, index := range path {
+		lamportPK, err := parentSKToLamportPK(sk, index)
+		if err != nil {
+			return nil, err
+		}
+
+		sk, err = hkdfModR(lamportPK)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return sk, nil
+}
+
+func hkdfModR(ikm []byte) (*big.Int, error) {
+	const L = 48
+
+	salt := []byte("BLS-SIG-KEYGEN-SALT-")
+	sk := new(big.Int)
+	for sk.Sign() == 0 {
+		h := sha256.Sum256(salt)
+		salt = h[:]
+
+		prk := hkdf.Extract(sha256.New, append(append([]byte{}, ikm...), 0), salt)
+
+		okm := make([]byte, L)
+		if _, err := io.ReadFull(hkdf.Expand(sha256.New, prk, []byte{0, L}), okm); err != nil {
+			return nil, err
+		}
+
+		sk.SetBytes(okm)
+		sk.Mod(sk, blsR)
+	}
+
+	return sk, nil
+}
+
+func parentSKToLamportPK(parent *big.Int, index uint32) ([]byte, error) {
+	salt := ser32(index)
+	ikm := ser256(parent)
+
+	notIkm := make([]byte, len(ikm))
+	for i := range ikm {
+		notIkm[i] = ^ikm[i]
+	}
+
+	lamport := make([]byte, 0, 2*255*32)
+	for _, k := range [][]byte{ikm, notIkm} {
+		chunks, err := ikmToLamportSK(k, salt)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := 0; i < 255; i++ {
+			h := sha256.Sum256(chunks[i*32 : (i+1)*32])
+			lamport = append(lamport, h[:]...)
+		}
+	}
+
+	pk := sha256.Sum256(lamport)
+	return pk[:], nil
+}
+
+func ikmToLamportSK(ikm []byte, salt []byte) ([]byte, error) {
+	prk := hkdf.Extract(sha256.New, ikm, salt)
+
+	okm := make([]byte, 255*32)
+	if _, err := io.ReadFull(hkdf.Expand(sha256.New, prk, nil), okm); err != nil {
+		return nil, err
+	}
+
+	return okm, nil
+}
+
+func ser32(i uint32) []byte {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], i)
+	return b[:]
+}
+
+func ser256(i *big.Int) []byte {
+	b := i.Bytes()
+	out := make([]byte, 32)
+	copy(out[32-len(b):], b)
+	return out
+}
@@ -0,0 +1,80 @@
+package hdkey
+
+import (
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+func TestDeriveSecp256k1(t *testing.T) {
+	// BIP-32 test vector 1
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+
+	cases := []struct {
+		path []uint32
+		key  string
+	}{
+		{nil, "e8f32e723decf4051aefac8e2c93c9c5b214313817cdb01a1494b917c8436b35"},
+		{[]uint32{Hardened}, "edb2e14f9ee77d26dd93b4ecede8d16ed408ce149b6cd80b0715a2d911a0afea"},
+		{[]uint32{Hardened, 1}, "3c6cb8d0f6a264c91ea8b5030fadaa8e538b020f0a387421a12de9319dc93368"},
+	}
+
+	for _, c := range cases {
+		k, err := DeriveSecp256k1(seed, c.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if hex.EncodeToString(k) != c.key {
+			t.Errorf("path %v: expected %s, got %x", c.path, c.key, k)
+		}
+	}
+}
+
+func TestDeriveBLS(t *testing.T) {
+	// EIP-2333 test case 0
+	seed, _ := hex.DecodeString("c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04")
+
+	cases := []struct {
+		path []uint32
+		key  string
+	}{
+		{nil, "6083874454709270928345386274498605044986640685124978867557563392430687146096"},
+		{[]uint32{0}, "20397789859736650942317412262472558107875392172444076792671091975210932703118"},
+	}
+
+	for _, c := range cases {
+		sk, err := deriveBLSScalar(seed, c.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expect, _ := new(big.Int).SetString(c.key, 10)
+		if sk.Cmp(expect) != 0 {
+			t.Errorf("path %v: expected %s, got %s", c.path, expect, sk)
+		}
+	}
+}
+
+func TestDeriveBLSLittleEndian(t *testing.T) {
+	seed := make([]byte, 32)
+
+	sk, err := deriveBLSScalar(seed, BLSPath(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	le, err := DeriveBLS(seed, BLSPath(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	be := make([]byte, len(le))
+	for i := range le {
+		be[len(le)-1-i] = le[i]
+	}
+
+	if new(big.Int).SetBytes(be).Cmp(sk) != 0 {
+		t.Fatal("key isn't encoded little-endian")
+	}
+}
@@ -25,6 +25,75 @@ const (
 	MinFilPeers = 12
 )
 
+// NodeType is the kind of node a peer advertised itself as in hello
+type NodeType uint64
+
+const (
+	NodeUnknown NodeType = iota
+	NodeFull
+	NodeMiner
+	NodeLight
+)
+
+func (t NodeType) String() string {
+	switch t {
+	case NodeFull:
+		return "full"
+	case NodeMiner:
+		return "miner"
+	case NodeLight:
+		return "light"
+	default:
+		return "unknown"
+	}
+}
+
+// Capabilities is a set of flags describing the protocols a peer supports
+type Capabilities uint64
+
+const (
+	// CapBlockSync means the peer serves blocksync requests for headers
+	CapBlockSync Capabilities = 1 << iota
+	// CapBlockSyncMessages means the peer serves blocksync requests for messages
+	CapBlockSyncMessages
+	// CapGraphsync means the peer serves chain data over graphsync
+	CapGraphsync
+)
+
+// LegacyCapabilities are assumed for peers which only speak hello 1.0.0
+const LegacyCapabilities = CapBlockSync | CapBlockSyncMessages | CapGraphsync
+
+func (c Capabilities) Has(o Capabilities) bool {
+	return c&o == o
+}
+
+func (c Capabilities) Strings() []string {
+	var out []string
+	if c.Has(CapBlockSync) {
+		out = append(out, "blocksync")
+	}
+	if c.Has(CapBlockSyncMessages) {
+		out = append(out, "blocksync-messages")
+	}
+	if c.Has(CapGraphsync) {
+		out = append(out, "graphsync")
+	}
+	return out
+}
+
+// PeerInfo is what we know about a filecoin peer from the hello protocol
+type PeerInfo struct {
+	Latency time.Duration
+
+	// HelloVersion is the hello protocol version the peer spoke, 1 for
+	// peers which don't advertise capabilities
+	HelloVersion  int
+	NodeType      NodeType
+	Capabilities  Capabilities
+	ClientVersion string
+	APIVersion    uint64
+}
+
 type MaybePeerMgr struct {
 	fx.In
 
@@ -39,7 +108,7 @@ type PeerMgr struct {
 	//peerLeads map[peer.ID]time.Time // TODO: unused
 
 	peersLk sync.Mutex
-	peers   map[peer.ID]time.Duration
+	peers   map[peer.ID]*PeerInfo
 
 	maxFilPeers int
 	minFilPeers int
@@ -58,7 +127,7 @@ func NewPeerMgr(h host.Host, dht *dht.IpfsDHT, bootstrap dtypes.BootstrapPeers)
 		dht:           dht,
 		bootstrappers: bootstrap,
 
-		peers:     make(map[peer.ID]time.Duration),
+		peers:     make(map[peer.ID]*PeerInfo),
 		expanding: make(chan struct{}, 1),
 
 		maxFilPeers: MaxFilPeers,
@@ -77,27 +146,52 @@ func NewPeerMgr(h host.Host, dht *dht.IpfsDHT, bootstrap dtypes.BootstrapPeers)
 }
 
 func (pmgr *PeerMgr) AddFilecoinPeer(p peer.ID) {
+	pmgr.AddFilecoinPeerInfo(p, PeerInfo{
+		HelloVersion: 1,
+		Capabilities: LegacyCapabilities,
+	})
+}
+
+func (pmgr *PeerMgr) AddFilecoinPeerInfo(p peer.ID, info PeerInfo) {
 	pmgr.peersLk.Lock()
 	defer pmgr.peersLk.Unlock()
-	pmgr.peers[p] = time.Duration(0)
+	if pi, ok := pmgr.peers[p]; ok {
+		// keep latency measured by an earlier hello
+		info.Latency = pi.Latency
+	}
+	pmgr.peers[p] = &info
 }
 
 func (pmgr *PeerMgr) GetPeerLatency(p peer.ID) (time.Duration, bool) {
 	pmgr.peersLk.Lock()
 	defer pmgr.peersLk.Unlock()
-	dur, ok := pmgr.peers[p]
-	return dur, ok
+	pi, ok := pmgr.peers[p]
+	if !ok {
+		return 0, false
+	}
+	return pi.Latency, true
 }
 
 func (pmgr *PeerMgr) SetPeerLatency(p peer.ID, latency time.Duration) {
 	pmgr.peersLk.Lock()
 	defer pmgr.peersLk.Unlock()
-	if _, ok := pmgr.peers[p]; ok {
-		pmgr.peers[p] = latency
+	if pi, ok := pmgr.peers[p]; ok {
+		pi.Latency = latency
 	}
 
 }
 
+// GetPeerInfo returns what the peer told us about itself in hello
+func (pmgr *PeerMgr) GetPeerInfo(p peer.ID) (PeerInfo, bool) {
+	pmgr.peersLk.Lock()
+	defer pmgr.peersLk.Unlock()
+	pi, ok := pmgr.peers[p]
+	if !ok {
+		return PeerInfo{}, false
+	}
+	return *pi, true
+}
+
 func (pmgr *PeerMgr) Disconnect(p peer.ID) {
 	if pmgr.h.Network().Connectedness(p) == net.NotConnected {
 		pmgr.peersLk.Lock()
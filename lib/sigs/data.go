@@ -0,0 +1,76 @@
+package sigs
+
+import (
+	"encoding/binary"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/crypto"
+	"golang.org/x/xerrors"
+)
+
+// dataPrefix starts the signing bytes of signed data. Messages are signed over
+// their CID, which never starts with this byte, so a data signature can't be
+// used as a message signature, or the other way around.
+const dataPrefix = "\x19Filecoin Signed Data:\n"
+
+// DataEnvelope wraps off-chain data signed with a wallet key, like a proof of
+// address ownership. The purpose and network are signed along with the
+// payload, so that a signature made for one application or network can't be
+// reused in another.
+type DataEnvelope struct {
+	Purpose string
+	Network string
+	Payload []byte
+}
+
+// SigningBytes returns the bytes signed for the envelope
+func (d *DataEnvelope) SigningBytes() ([]byte, error) {
+	if d.Purpose == "" {
+		return nil, xerrors.Errorf("signed data must have a purpose")
+	}
+	if d.Network == "" {
+		return nil, xerrors.Errorf("signed data must have a network name")
+	}
+
+	out := []byte(dataPrefix)
+	for _, field := range [][]byte{[]byte(d.Purpose), []byte(d.Network), d.Payload} {
+		var lb [binary.MaxVarintLen64]byte
+		n := binary.PutUvarint(lb[:], uint64(len(field)))
+		out = append(out, lb[:n]...)
+		out = append(out, field...)
+	}
+
+	return out, nil
+}
+
+// SignedData is a data envelope along with its signature
+type SignedData struct {
+	DataEnvelope
+
+	Address   address.Address
+	Signature crypto.Signature
+}
+
+// VerifyData verifies the signature of a data envelope. It doesn't need a
+// node, but addr has to be a key address.
+func VerifyData(sig *crypto.Signature, addr address.Address, d *DataEnvelope) error {
+	b, err := d.SigningBytes()
+	if err != nil {
+		return err
+	}
+
+	return Verify(sig, addr, b)
+}
+
+// Verify verifies the signature of the signed data, and checks that it was
+// made for the expected purpose and network
+func (sd *SignedData) Verify(purpose string, network string) error {
+	if sd.Purpose != purpose {
+		return xerrors.Errorf("data was signed for %q, expected %q", sd.Purpose, purpose)
+	}
+	if sd.Network != network {
+		return xerrors.Errorf("data was signed on network %q, expected %q", sd.Network, network)
+	}
+
+	return VerifyData(&sd.Signature, sd.Address, &sd.DataEnvelope)
+}
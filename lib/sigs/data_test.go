@@ -0,0 +1,160 @@
+package sigs_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/crypto"
+
+	"github.com/filecoin-project/lotus/lib/sigs"
+	_ "github.com/filecoin-project/lotus/lib/sigs/bls"
+	_ "github.com/filecoin-project/lotus/lib/sigs/secp"
+)
+
+func TestDataEnvelopeSigningBytes(t *testing.T) {
+	long := bytes.Repeat([]byte{0xaa}, 200)
+
+	cases := []struct {
+		name   string
+		env    sigs.DataEnvelope
+		expect []byte
+	}{
+		{
+			name: "short fields",
+			env:  sigs.DataEnvelope{Purpose: "test", Network: "testnet", Payload: []byte("hello")},
+			expect: []byte("\x19Filecoin Signed Data:\n" +
+				"\x04test" +
+				"\x07testnet" +
+				"\x05hello"),
+		},
+		{
+			name: "empty payload",
+			env:  sigs.DataEnvelope{Purpose: "p", Network: "n"},
+			expect: []byte("\x19Filecoin Signed Data:\n" +
+				"\x01p" +
+				"\x01n" +
+				"\x00"),
+		},
+		{
+			// lengths over 127 take two varint bytes
+			name: "long payload",
+			env:  sigs.DataEnvelope{Purpose: "p", Network: "n", Payload: long},
+			expect: append([]byte("\x19Filecoin Signed Data:\n"+
+				"\x01p"+
+				"\x01n"+
+				"\xc8\x01"), long...),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			b, err := c.env.SigningBytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(b, c.expect) {
+				t.Errorf("expected %x, got %x", c.expect, b)
+			}
+		})
+	}
+
+	// fields are length prefixed, so moving bytes between them changes the
+	// signed bytes
+	a, err := (&sigs.DataEnvelope{Purpose: "ab", Network: "c", Payload: []byte("d")}).SigningBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := (&sigs.DataEnvelope{Purpose: "a", Network: "bc", Payload: []byte("d")}).SigningBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(a, b) {
+		t.Error("different envelopes have the same signing bytes")
+	}
+
+	for _, env := range []sigs.DataEnvelope{
+		{Network: "testnet", Payload: []byte("x")},
+		{Purpose: "test", Payload: []byte("x")},
+	} {
+		if _, err := env.SigningBytes(); err == nil {
+			t.Errorf("expected an error for %+v", env)
+		}
+	}
+}
+
+func TestSignedDataRoundTrip(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		typ     crypto.SigType
+		address func([]byte) (address.Address, error)
+	}{
+		{name: "secp", typ: crypto.SigTypeSecp256k1, address: address.NewSecp256k1Address},
+		{name: "bls", typ: crypto.SigTypeBLS, address: address.NewBLSAddress},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			pk, err := sigs.Generate(c.typ)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pub, err := sigs.ToPublic(c.typ, pk)
+			if err != nil {
+				t.Fatal(err)
+			}
+			addr, err := c.address(pub)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			env := sigs.DataEnvelope{Purpose: "login", Network: "testnet", Payload: []byte("nonce 1")}
+			b, err := env.SigningBytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			sig, err := sigs.Sign(c.typ, pk, b)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sd := &sigs.SignedData{DataEnvelope: env, Address: addr, Signature: *sig}
+			if err := sd.Verify("login", "testnet"); err != nil {
+				t.Fatal(err)
+			}
+
+			// checked before the signature
+			if err := sd.Verify("payment", "testnet"); err == nil || !strings.Contains(err.Error(), "signed for") {
+				t.Errorf("expected a purpose mismatch, got %v", err)
+			}
+			if err := sd.Verify("login", "mainnet"); err == nil || !strings.Contains(err.Error(), "network") {
+				t.Errorf("expected a network mismatch, got %v", err)
+			}
+
+			// the signature doesn't carry over to another domain
+			for _, other := range []sigs.DataEnvelope{
+				{Purpose: "payment", Network: "testnet", Payload: env.Payload},
+				{Purpose: "login", Network: "mainnet", Payload: env.Payload},
+				{Purpose: "login", Network: "testnet", Payload: []byte("nonce 2")},
+			} {
+				other := other
+				if err := sigs.VerifyData(sig, addr, &other); err == nil {
+					t.Errorf("signature verified for %+v", other)
+				}
+
+				forged := &sigs.SignedData{DataEnvelope: other, Address: addr, Signature: *sig}
+				if err := forged.Verify(other.Purpose, other.Network); err == nil {
+					t.Errorf("forged signed data verified for %+v", other)
+				}
+			}
+
+			// nor does a signature over the raw payload verify as signed data
+			raw, err := sigs.Sign(c.typ, pk, env.Payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := sigs.VerifyData(raw, addr, &env); err == nil {
+				t.Error("raw payload signature verified as signed data")
+			}
+		})
+	}
+}
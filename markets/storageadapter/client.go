@@ -34,10 +34,11 @@ type ClientNodeAdapter struct {
 	full.ChainAPI
 	full.MpoolAPI
 
-	sm *stmgr.StateManager
-	cs *store.ChainStore
-	fm *market.FundMgr
-	ev *events.Events
+	sm    *stmgr.StateManager
+	cs    *store.ChainStore
+	fm    *market.FundMgr
+	funds *dealFunds
+	ev    *events.Events
 }
 
 type clientApi struct {
@@ -51,10 +52,11 @@ func NewClientNodeAdapter(state full.StateAPI, chain full.ChainAPI, mpool full.M
 		ChainAPI: chain,
 		MpoolAPI: mpool,
 
-		sm: sm,
-		cs: cs,
-		fm: fm,
-		ev: events.NewEvents(context.TODO(), &clientApi{chain, state}),
+		sm:    sm,
+		cs:    cs,
+		fm:    fm,
+		funds: newDealFunds(),
+		ev:    events.NewEvents(context.TODO(), &clientApi{chain, state}),
 	}
 }
 
@@ -148,8 +150,16 @@ func (n *ClientNodeAdapter) AddFunds(ctx context.Context, addr address.Address,
 	return nil
 }
 
+// EnsureFunds reserves the funds of a deal proposal signed with SignProposal.
+// The reservation is released once the deal is published, or by
+// ReleaseDealFunds when the deal fails.
 func (n *ClientNodeAdapter) EnsureFunds(ctx context.Context, addr, wallet address.Address, amount abi.TokenAmount, ts shared.TipSetToken) error {
-	return n.fm.EnsureAvailable(ctx, addr, wallet, amount)
+	proposal, ok := n.funds.pop(addr, amount)
+	if !ok {
+		return xerrors.Errorf("no signed deal proposal needs %s from %s", types.FIL(amount), addr)
+	}
+
+	return n.fm.EnsureAvailable(ctx, proposal, addr, wallet, amount)
 }
 
 func (n *ClientNodeAdapter) GetBalance(ctx context.Context, addr address.Address, encodedTs shared.TipSetToken) (storagemarket.Balance, error) {
@@ -230,9 +240,37 @@ func (c *ClientNodeAdapter) ValidatePublishedDeal(ctx context.Context, deal stor
 		return 0, err
 	}
 
+	// the deal funds are now locked on chain
+	if err := c.fm.Release(deal.ProposalCid); err != nil {
+		log.Errorf("releasing reserved funds for deal %d: %s", res.IDs[dealIdx], err)
+	}
+
 	return res.IDs[dealIdx], nil
 }
 
+// ReleaseDealFunds drops the fund reservations of client deals which were
+// published or failed. Failed deals aren't reported to the node adapter, so
+// this is called periodically with the deals of the client.
+func ReleaseDealFunds(fm *market.FundMgr, deals []storagemarket.ClientDeal) {
+	for _, deal := range deals {
+		switch deal.State {
+		case storagemarket.StorageDealProposalNotFound,
+			storagemarket.StorageDealProposalRejected,
+			storagemarket.StorageDealSealing,
+			storagemarket.StorageDealActive,
+			storagemarket.StorageDealFailing,
+			storagemarket.StorageDealNotFound,
+			storagemarket.StorageDealError:
+		default:
+			continue
+		}
+
+		if err := fm.Release(deal.ProposalCid); err != nil {
+			log.Errorf("releasing reserved funds for deal %s: %s", deal.ProposalCid, err)
+		}
+	}
+}
+
 func (c *ClientNodeAdapter) OnDealSectorCommitted(ctx context.Context, provider address.Address, dealId abi.DealID, cb storagemarket.DealSectorCommittedCallback) error {
 	checkFunc := func(ts *types.TipSet) (done bool, more bool, err error) {
 		sd, err := stmgr.GetStorageDeal(ctx, c.StateManager, dealId, ts)
@@ -346,10 +384,19 @@ func (n *ClientNodeAdapter) SignProposal(ctx context.Context, signer address.Add
 		return nil, err
 	}
 
-	return &samarket.ClientDealProposal{
+	out := &samarket.ClientDealProposal{
 		Proposal:        proposal,
 		ClientSignature: *sig,
-	}, nil
+	}
+
+	// the funds of the deal are reserved under its proposal CID
+	nd, err := cborutil.AsIpld(out)
+	if err != nil {
+		return nil, xerrors.Errorf("getting proposal node: %w", err)
+	}
+	n.funds.push(proposal.Client, proposal.ClientBalanceRequirement(), nd.Cid())
+
+	return out, nil
 }
 
 func (n *ClientNodeAdapter) GetDefaultWalletAddress(ctx context.Context) (address.Address, error) {
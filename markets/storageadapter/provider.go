@@ -28,6 +28,7 @@ import (
 	"github.com/filecoin-project/lotus/lib/sigs"
 	"github.com/filecoin-project/lotus/markets/utils"
 	"github.com/filecoin-project/lotus/node/modules/dtypes"
+	"github.com/filecoin-project/lotus/storage"
 	"github.com/filecoin-project/lotus/storage/sectorblocks"
 	sealing "github.com/filecoin-project/storage-fsm"
 )
@@ -40,15 +41,21 @@ type ProviderNodeAdapter struct {
 	// this goes away with the data transfer module
 	dag dtypes.StagingDAG
 
-	secb *sectorblocks.SectorBlocks
-	ev   *events.Events
+	secb   *sectorblocks.SectorBlocks
+	packer *sectorblocks.Packer
+	funds  *dealFunds
+	ev     *events.Events
+	policy storage.MessagePolicy
 }
 
-func NewProviderNodeAdapter(dag dtypes.StagingDAG, secb *sectorblocks.SectorBlocks, full api.FullNode) storagemarket.StorageProviderNode {
+func NewProviderNodeAdapter(dag dtypes.StagingDAG, secb *sectorblocks.SectorBlocks, packer *sectorblocks.Packer, full api.FullNode, policy storage.MessagePolicy) storagemarket.StorageProviderNode {
 	return &ProviderNodeAdapter{
 		FullNode: full,
 		dag:      dag,
+		policy:   policy,
 		secb:     secb,
+		packer:   packer,
+		funds:    newDealFunds(),
 		ev:       events.NewEvents(context.TODO(), full),
 	}
 }
@@ -56,6 +63,10 @@ func NewProviderNodeAdapter(dag dtypes.StagingDAG, secb *sectorblocks.SectorBloc
 func (n *ProviderNodeAdapter) PublishDeals(ctx context.Context, deal storagemarket.MinerDeal) (abi.DealID, cid.Cid, error) {
 	log.Info("publishing deal")
 
+	// the collateral is locked on chain once the deal is published, and not
+	// needed anymore if publishing fails
+	defer n.releaseFunds(ctx, deal)
+
 	worker, err := n.StateMinerWorker(ctx, deal.Proposal.Provider, types.EmptyTSK)
 	if err != nil {
 		return 0, cid.Undef, err
@@ -69,16 +80,18 @@ func (n *ProviderNodeAdapter) PublishDeals(ctx context.Context, deal storagemark
 		return 0, cid.Undef, xerrors.Errorf("serializing PublishStorageDeals params failed: ", err)
 	}
 
-	// TODO: We may want this to happen after fetching data
-	smsg, err := n.MpoolPushMessage(ctx, &types.Message{
+	msg := &types.Message{
 		To:       builtin.StorageMarketActorAddr,
-		From:     worker,
 		Value:    types.NewInt(0),
 		GasPrice: types.NewInt(0),
 		GasLimit: 1000000,
 		Method:   builtin.MethodsMarket.PublishStorageDeals,
 		Params:   params,
-	})
+	}
+	n.policy.Apply(storage.MsgPublishDeals, worker, msg)
+
+	// TODO: We may want this to happen after fetching data
+	smsg, err := n.MpoolPushMessage(ctx, msg)
 	if err != nil {
 		return 0, cid.Undef, err
 	}
@@ -102,7 +115,8 @@ func (n *ProviderNodeAdapter) PublishDeals(ctx context.Context, deal storagemark
 }
 
 func (n *ProviderNodeAdapter) OnDealComplete(ctx context.Context, deal storagemarket.MinerDeal, pieceSize abi.UnpaddedPieceSize, pieceData io.Reader) error {
-	_, err := n.secb.AddPiece(ctx, pieceSize, pieceData, sealing.DealInfo{
+	// the deal waits to be packed with other deals after this returns
+	err := n.packer.AddPiece(ctx, pieceSize, pieceData, sealing.DealInfo{
 		DealID: deal.DealID,
 		DealSchedule: sealing.DealSchedule{
 			StartEpoch: deal.ClientDealProposal.Proposal.StartEpoch,
@@ -161,8 +175,31 @@ func (n *ProviderNodeAdapter) SignBytes(ctx context.Context, signer address.Addr
 	return localSignature, nil
 }
 
+// EnsureFunds reserves the collateral of a deal about to be published. The
+// reservation is released by PublishDeals, which is called right after.
 func (n *ProviderNodeAdapter) EnsureFunds(ctx context.Context, addr, wallet address.Address, amt abi.TokenAmount, encodedTs shared.TipSetToken) error {
-	return n.MarketEnsureAvailable(ctx, addr, wallet, amt)
+	key, err := newReservationKey()
+	if err != nil {
+		return err
+	}
+
+	if err := n.MarketEnsureAvailable(ctx, key, addr, wallet, amt); err != nil {
+		return err
+	}
+
+	n.funds.push(addr, amt, key)
+	return nil
+}
+
+func (n *ProviderNodeAdapter) releaseFunds(ctx context.Context, deal storagemarket.MinerDeal) {
+	key, ok := n.funds.pop(deal.Proposal.Provider, deal.Proposal.ProviderCollateral)
+	if !ok {
+		return
+	}
+
+	if err := n.MarketReleaseFunds(ctx, key); err != nil {
+		log.Errorf("releasing reserved funds for deal %s: %s", deal.ProposalCid, err)
+	}
 }
 
 // Adds funds with the StorageMinerActor for a storage participant.  Used by both providers and clients.
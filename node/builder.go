@@ -40,6 +40,7 @@ import (
 	"github.com/filecoin-project/lotus/chain/types"
 	"github.com/filecoin-project/lotus/chain/vm"
 	"github.com/filecoin-project/lotus/chain/wallet"
+	"github.com/filecoin-project/lotus/chain/wallet/history"
 	"github.com/filecoin-project/lotus/lib/peermgr"
 	_ "github.com/filecoin-project/lotus/lib/sigs/bls"
 	_ "github.com/filecoin-project/lotus/lib/sigs/secp"
@@ -72,15 +73,17 @@ type special struct{ id int }
 
 //nolint:golint
 var (
-	DefaultTransportsKey = special{0} // Libp2p option
-	DiscoveryHandlerKey  = special{2} // Private type
-	AddrsFactoryKey      = special{3} // Libp2p option
-	SmuxTransportKey     = special{4} // Libp2p option
-	RelayKey             = special{5} // Libp2p option
-	SecurityKey          = special{6} // Libp2p option
-	BaseRoutingKey       = special{7} // fx groups + multiret
-	NatPortMapKey        = special{8} // Libp2p option
-	ConnectionManagerKey = special{9} // Libp2p option
+	DefaultTransportsKey = special{0}  // Libp2p option
+	DiscoveryHandlerKey  = special{2}  // Private type
+	AddrsFactoryKey      = special{3}  // Libp2p option
+	SmuxTransportKey     = special{4}  // Libp2p option
+	RelayKey             = special{5}  // Libp2p option
+	SecurityKey          = special{6}  // Libp2p option
+	BaseRoutingKey       = special{7}  // fx groups + multiret
+	NatPortMapKey        = special{8}  // Libp2p option
+	ConnectionManagerKey = special{9}  // Libp2p option
+	ConnGaterKey         = special{10} // Libp2p option
+	BandwidthReporterKey = special{11} // Libp2p option + metrics.Reporter
 )
 
 type invoke int
@@ -92,6 +95,7 @@ const (
 	PstoreAddSelfKeysKey = invoke(iota)
 	StartListeningKey
 	BootstrapKey
+	RunConnGaterKey
 
 	// filecoin
 	SetGenesisKey
@@ -106,6 +110,7 @@ const (
 
 	RunDealClientKey
 	RegisterClientValidatorKey
+	RunPaychManagerKey
 
 	// storage miner
 	GetParamsKey
@@ -174,8 +179,14 @@ func libp2p() Option {
 		Override(NatPortMapKey, lp2p.NatPortMap),
 
 		Override(ConnectionManagerKey, lp2p.ConnectionManager(50, 200, 20*time.Second, nil)),
+		Override(BandwidthReporterKey, lp2p.BandwidthCounter),
 
-		Override(new(*pubsub.PubSub), lp2p.GossipSub()),
+		Override(new(*lp2p.ConnGater), lp2p.NewConnGater),
+		Override(ConnGaterKey, lp2p.ConnectionGater),
+		Override(RunConnGaterKey, lp2p.RunConnGater),
+
+		Override(new(*dtypes.ScoreKeeper), lp2p.ScoreKeeper),
+		Override(new(*pubsub.PubSub), lp2p.GossipSub(nil)),
 
 		Override(PstoreAddSelfKeysKey, lp2p.PstoreAddSelfKeys),
 		Override(StartListeningKey, lp2p.StartListening(config.DefaultFullNode().Libp2p.ListenAddresses)),
@@ -214,6 +225,7 @@ func Online() Option {
 			Override(new(*store.ChainStore), modules.ChainStore),
 			Override(new(*stmgr.StateManager), stmgr.NewStateManager),
 			Override(new(*wallet.Wallet), wallet.NewWallet),
+			Override(new(*history.Index), history.NewIndex),
 
 			Override(new(dtypes.ChainGCLocker), blockstore.NewGCLocker),
 			Override(new(dtypes.ChainGCBlockstore), modules.ChainGCBlockstore),
@@ -258,6 +270,7 @@ func Online() Option {
 
 			Override(new(*paychmgr.Store), paychmgr.NewStore),
 			Override(new(*paychmgr.Manager), paychmgr.NewManager),
+			Override(RunPaychManagerKey, modules.RunPaychManager),
 			Override(new(*market.FundMgr), market.NewFundMgr),
 		),
 
@@ -280,8 +293,14 @@ func Online() Option {
 			Override(new(storage2.Prover), From(new(sectorstorage.SectorManager))),
 
 			Override(new(*sectorblocks.SectorBlocks), sectorblocks.NewSectorBlocks),
+			Override(new(*sectorblocks.Packer), modules.Packer),
 			Override(new(sealing.TicketFn), modules.SealTicketGen),
+			Override(new(storage.MessagePolicy), modules.MessagePolicy),
+			Override(new(*storage.SectorFiles), modules.SectorFiles),
+			Override(new(*storage.SectorChecker), modules.SectorChecker),
+			Override(new(*storage.FPoStScheduler), modules.FPoStScheduler),
 			Override(new(*storage.Miner), modules.StorageMiner),
+			Override(new(*storage.PledgeScheduler), modules.PledgeScheduler),
 			Override(new(dtypes.NetworkName), modules.StorageNetworkName),
 
 			Override(new(dtypes.StagingBlockstore), modules.StagingBlockstore),
@@ -292,6 +311,7 @@ func Online() Option {
 			Override(new(dtypes.ProviderDataTransfer), modules.NewProviderDAGServiceDataTransfer),
 			Override(new(*requestvalidation.ProviderRequestValidator), modules.NewProviderRequestValidator),
 			Override(new(dtypes.ProviderPieceStore), modules.NewProviderPieceStore),
+			Override(new(*storageadapter.DealFilter), modules.DealFilter),
 			Override(new(storagemarket.StorageProvider), modules.StorageProvider),
 			Override(new(storagemarket.StorageProviderNode), storageadapter.NewProviderNodeAdapter),
 			Override(RegisterProviderValidatorKey, modules.RegisterProviderValidator),
@@ -351,6 +371,7 @@ func ConfigCommon(cfg *config.Common) Option {
 				cfg.Libp2p.ConnMgrHigh,
 				time.Duration(cfg.Libp2p.ConnMgrGrace),
 				cfg.Libp2p.ProtectedPeers)),
+			Override(new(*pubsub.PubSub), lp2p.GossipSub(cfg.Libp2p.ProtectedPeers)),
 
 			ApplyIf(func(s *Settings) bool { return len(cfg.Libp2p.BootstrapPeers) > 0 },
 				Override(new(dtypes.BootstrapPeers), modules.ConfigBootstrap(cfg.Libp2p.BootstrapPeers)),
@@ -367,11 +388,12 @@ func ConfigFullNode(c interface{}) Option {
 
 	return Options(
 		ConfigCommon(&cfg.Common),
+		Override(new(config.WalletHistory), cfg.WalletHistory),
 		If(cfg.Metrics.HeadNotifs,
 			Override(HeadMetricsKey, metrics.SendHeadNotifs(cfg.Metrics.Nickname)),
 		),
 		If(cfg.Metrics.PubsubTracing,
-			Override(new(*pubsub.PubSub), lp2p.GossipSub(lp2p.PubsubTracer())),
+			Override(new(*pubsub.PubSub), lp2p.GossipSub(cfg.Libp2p.ProtectedPeers, lp2p.PubsubTracer())),
 		),
 	)
 }
@@ -386,6 +408,10 @@ func ConfigStorageMiner(c interface{}) Option {
 		ConfigCommon(&cfg.Common),
 
 		Override(new(sectorstorage.SealerConfig), cfg.Storage),
+		Override(new(config.MinerFeeConfig), cfg.Fees),
+		Override(new(config.PledgeConfig), cfg.Pledge),
+		Override(new(config.DealPolicy), cfg.Deals),
+		Override(new(config.PackingConfig), cfg.Packing),
 	)
 }
 
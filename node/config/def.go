@@ -4,7 +4,15 @@ import (
 	"encoding"
 	"time"
 
+	"github.com/BurntSushi/toml"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
 	sectorstorage "github.com/filecoin-project/sector-storage"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/types"
 )
 
 // Common is common config between full node and miner
@@ -16,7 +24,8 @@ type Common struct {
 // FullNode is a full node config
 type FullNode struct {
 	Common
-	Metrics Metrics
+	Metrics       Metrics
+	WalletHistory WalletHistory
 }
 
 // // Common
@@ -26,6 +35,93 @@ type StorageMiner struct {
 	Common
 
 	Storage sectorstorage.SealerConfig
+	Fees    MinerFeeConfig
+	Pledge  PledgeConfig
+	Deals   DealPolicy
+	Packing PackingConfig
+}
+
+// PackingConfig configures how long deals wait to be packed into sectors
+// with other deals
+type PackingConfig struct {
+	// MaxWait is the longest time a deal waits for other deals. 0 hands deals
+	// to sealing immediately.
+	MaxWait Duration
+	// FillPercent releases waiting deals once their pieces fill this much of
+	// a sector, from 1 to 100
+	FillPercent uint64
+	// SealDuration releases waiting deals when a deal starts within this many
+	// epochs, so that its sector is sealed in time
+	SealDuration abi.ChainEpoch
+}
+
+// DealPolicy decides which deal proposals are accepted, in addition to the
+// price of the ask. Zero values mean no limit.
+type DealPolicy struct {
+	// MinDuration and MaxDuration bound deal durations, in epochs
+	MinDuration abi.ChainEpoch
+	MaxDuration abi.ChainEpoch
+
+	// MinPieceSize and MaxPieceSize bound padded piece sizes, in bytes
+	MinPieceSize abi.PaddedPieceSize
+	MaxPieceSize abi.PaddedPieceSize
+
+	// AllowedClients accepts only deals from these clients if not empty
+	AllowedClients ClientAddresses
+	BlockedClients ClientAddresses
+
+	// MaxConcurrentDeals limits deals in progress, which aren't active yet
+	MaxConcurrentDeals uint64
+
+	// FilterCommand is run by the shell with the JSON deal proposal on
+	// stdin. Deals are rejected when it exits with a non-zero code, with its
+	// standard output as the reason. It can only be set in the config.
+	FilterCommand string
+}
+
+// PledgeConfig configures the pledge scheduler, which pledges sectors with
+// random data to keep TargetSealing sectors sealing at the same time
+type PledgeConfig struct {
+	Enabled bool
+
+	// TargetSealing is the number of sectors kept sealing at the same time
+	TargetSealing uint64
+
+	// Sectors aren't pledged while the number of sectors in a sealing stage
+	// is at its limit. 0 means no limit.
+	MaxPreCommit1 uint64
+	MaxPreCommit2 uint64
+	MaxCommit     uint64
+
+	// MinAvailablePercent pauses pledging when no sealing or long-term
+	// storage path has this much of its capacity available
+	MinAvailablePercent uint64
+	// MinWorkerBalance pauses pledging when the worker balance is lower
+	MinWorkerBalance types.FIL
+
+	CheckInterval Duration
+}
+
+// MinerFeeConfig sets the gas of each kind of message sent by the miner. The
+// messages are all sent from the worker address, which is the only sender
+// accepted by the miner actor and by PublishStorageDeals.
+type MinerFeeConfig struct {
+	PreCommit     MessageFee
+	Commit        MessageFee
+	WindowPoSt    MessageFee
+	DeclareFaults MessageFee
+	PublishDeals  MessageFee
+	// ExtendSectors and TerminateSectors are sent by the sectors extend and
+	// terminate commands
+	ExtendSectors    MessageFee
+	TerminateSectors MessageFee
+}
+
+// MessageFee is the gas limit of a message, and the most which can be paid
+// for it. The gas price is set to MaxFee / GasLimit.
+type MessageFee struct {
+	GasLimit int64
+	MaxFee   types.FIL
 }
 
 // API contains configs for API endpoint
@@ -54,6 +150,14 @@ type Metrics struct {
 	PubsubTracing bool
 }
 
+// WalletHistory configures the indexing of the history of wallet addresses
+type WalletHistory struct {
+	// InternalTransfers also indexes value transfers to wallet addresses made
+	// by actors. It computes the execution trace of every tipset, while
+	// otherwise only tipsets with messages from or to the wallet are traced.
+	InternalTransfers bool
+}
+
 func defCommon() Common {
 	return Common{
 		API: API{
@@ -90,6 +194,29 @@ func DefaultStorageMiner() *StorageMiner {
 			AllowPreCommit2: true,
 			AllowCommit:     true,
 		},
+
+		Fees: MinerFeeConfig{
+			PreCommit:     MessageFee{GasLimit: 10000000, MaxFee: types.FIL(types.NewInt(10000000))},
+			Commit:        MessageFee{GasLimit: 10000000, MaxFee: types.FIL(types.NewInt(10000000))},
+			WindowPoSt:    MessageFee{GasLimit: 10000000, MaxFee: types.FIL(types.NewInt(10000000))},
+			DeclareFaults: MessageFee{GasLimit: 10000000, MaxFee: types.FIL(types.NewInt(10000000))},
+			PublishDeals:  MessageFee{GasLimit: 1000000, MaxFee: types.FIL(types.NewInt(0))},
+
+			ExtendSectors:    MessageFee{GasLimit: 10000000, MaxFee: types.FIL(types.NewInt(10000000))},
+			TerminateSectors: MessageFee{GasLimit: 10000000, MaxFee: types.FIL(types.NewInt(10000000))},
+		},
+
+		Pledge: PledgeConfig{
+			TargetSealing:       1,
+			MinAvailablePercent: 10,
+			MinWorkerBalance:    types.FIL(types.NewInt(0)),
+			CheckInterval:       Duration(time.Minute),
+		},
+
+		Packing: PackingConfig{
+			FillPercent:  90,
+			SealDuration: abi.ChainEpoch(24 * time.Hour / (build.BlockDelay * time.Second)),
+		},
 	}
 	cfg.Common.API.ListenAddress = "/ip4/127.0.0.1/tcp/2345/http"
 	cfg.Common.API.RemoteListenAddress = "127.0.0.1:2345"
@@ -117,3 +244,33 @@ func (dur Duration) MarshalText() ([]byte, error) {
 	d := time.Duration(dur)
 	return []byte(d.String()), nil
 }
+
+var _ toml.Unmarshaler = (*ClientAddresses)(nil)
+
+// ClientAddresses is a list of addresses, written as strings in TOML
+type ClientAddresses []address.Address
+
+// UnmarshalTOML parses the address strings of the list
+func (ca *ClientAddresses) UnmarshalTOML(v interface{}) error {
+	list, ok := v.([]interface{})
+	if !ok {
+		return xerrors.Errorf("expected a list of addresses, got %T", v)
+	}
+
+	out := make(ClientAddresses, len(list))
+	for i, e := range list {
+		s, ok := e.(string)
+		if !ok {
+			return xerrors.Errorf("expected an address string, got %T", e)
+		}
+
+		addr, err := address.NewFromString(s)
+		if err != nil {
+			return xerrors.Errorf("parsing client address %q: %w", s, err)
+		}
+		out[i] = addr
+	}
+
+	*ca = out
+	return nil
+}
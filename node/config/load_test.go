@@ -2,12 +2,17 @@ package config
 
 import (
 	"bytes"
+	"encoding/json"
 	"io/ioutil"
 	"os"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/lotus/chain/types"
 )
 
 func TestDecodeNothing(t *testing.T) {
@@ -61,3 +66,67 @@ func TestParitalConfig(t *testing.T) {
 			"config from reader should contain changes")
 	}
 }
+
+func TestMinerFeesConfig(t *testing.T) {
+	assert := assert.New(t)
+	cfgString := `
+		[Fees.WindowPoSt]
+		GasLimit = 20000000
+		MaxFee = "0.0000001"
+		`
+
+	cfg, err := FromReader(bytes.NewReader([]byte(cfgString)), DefaultStorageMiner())
+	assert.NoError(err, "error should be nil")
+
+	mcfg := cfg.(*StorageMiner)
+	assert.Equal(int64(20000000), mcfg.Fees.WindowPoSt.GasLimit)
+	assert.Equal("100000000000", mcfg.Fees.WindowPoSt.MaxFee.Int.String())
+	assert.Equal(DefaultStorageMiner().Fees.PreCommit.MaxFee.String(), mcfg.Fees.PreCommit.MaxFee.String(),
+		"other fees should keep their defaults")
+}
+
+func TestPledgeConfigJSON(t *testing.T) {
+	assert := assert.New(t)
+
+	// the pledge config is sent as JSON by the miner API
+	cfg := DefaultStorageMiner().Pledge
+	cfg.Enabled = true
+	cfg.MinWorkerBalance = types.FIL(types.NewInt(1500))
+	cfg.CheckInterval = Duration(90 * time.Second)
+
+	b, err := json.Marshal(cfg)
+	assert.NoError(err)
+
+	var out PledgeConfig
+	assert.NoError(json.Unmarshal(b, &out))
+	assert.Equal(cfg, out)
+}
+
+func TestDealPolicyConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	client, err := address.NewIDAddress(1000)
+	assert.NoError(err)
+
+	cfgString := `
+		[Deals]
+		MaxDuration = 1000
+		MaxPieceSize = 2048
+		BlockedClients = ["t01000"]
+		`
+	expected := DefaultStorageMiner()
+	expected.Deals.MaxDuration = 1000
+	expected.Deals.MaxPieceSize = 2048
+	expected.Deals.BlockedClients = ClientAddresses{client}
+
+	cfg, err := FromReader(bytes.NewReader([]byte(cfgString)), DefaultStorageMiner())
+	assert.NoError(err)
+	assert.Equal(expected, cfg)
+
+	_, err = FromReader(bytes.NewReader([]byte("[Deals]\nAllowedClients = [\"t0x\"]")), DefaultStorageMiner())
+	assert.Error(err, "invalid client addresses should be rejected")
+
+	// the default config doesn't list clients, it can be written as TOML
+	_, err = ConfigComment(DefaultStorageMiner())
+	assert.NoError(err)
+}
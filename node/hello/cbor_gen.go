@@ -152,6 +152,226 @@ func (t *HelloMessage) UnmarshalCBOR(r io.Reader) error {
 	return nil
 }
 
+func (t *HelloMessageV2) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write([]byte{136}); err != nil {
+		return err
+	}
+
+	// t.HeaviestTipSet ([]cid.Cid) (slice)
+	if len(t.HeaviestTipSet) > cbg.MaxLength {
+		return xerrors.Errorf("Slice value in field t.HeaviestTipSet was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajArray, uint64(len(t.HeaviestTipSet)))); err != nil {
+		return err
+	}
+	for _, v := range t.HeaviestTipSet {
+		if err := cbg.WriteCid(w, v); err != nil {
+			return xerrors.Errorf("failed writing cid field t.HeaviestTipSet: %w", err)
+		}
+	}
+
+	// t.HeaviestTipSetHeight (abi.ChainEpoch) (int64)
+	if t.HeaviestTipSetHeight >= 0 {
+		if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.HeaviestTipSetHeight))); err != nil {
+			return err
+		}
+	} else {
+		if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajNegativeInt, uint64(-t.HeaviestTipSetHeight)-1)); err != nil {
+			return err
+		}
+	}
+
+	// t.HeaviestTipSetWeight (big.Int) (struct)
+	if err := t.HeaviestTipSetWeight.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.GenesisHash (cid.Cid) (struct)
+
+	if err := cbg.WriteCid(w, t.GenesisHash); err != nil {
+		return xerrors.Errorf("failed to write cid field t.GenesisHash: %w", err)
+	}
+
+	// t.NodeType (uint64) (uint64)
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.NodeType))); err != nil {
+		return err
+	}
+
+	// t.Capabilities (uint64) (uint64)
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.Capabilities))); err != nil {
+		return err
+	}
+
+	// t.ClientVersion (string) (string)
+	if len(t.ClientVersion) > cbg.MaxLength {
+		return xerrors.Errorf("Value in field t.ClientVersion was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len(t.ClientVersion)))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(t.ClientVersion)); err != nil {
+		return err
+	}
+
+	// t.APIVersion (uint64) (uint64)
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.APIVersion))); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (t *HelloMessageV2) UnmarshalCBOR(r io.Reader) error {
+	br := cbg.GetPeeker(r)
+
+	maj, extra, err := cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 8 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.HeaviestTipSet ([]cid.Cid) (slice)
+
+	maj, extra, err = cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+
+	if extra > cbg.MaxLength {
+		return fmt.Errorf("t.HeaviestTipSet: array too large (%d)", extra)
+	}
+
+	if maj != cbg.MajArray {
+		return fmt.Errorf("expected cbor array")
+	}
+	if extra > 0 {
+		t.HeaviestTipSet = make([]cid.Cid, extra)
+	}
+	for i := 0; i < int(extra); i++ {
+
+		c, err := cbg.ReadCid(br)
+		if err != nil {
+			return xerrors.Errorf("reading cid field t.HeaviestTipSet failed: %w", err)
+		}
+		t.HeaviestTipSet[i] = c
+	}
+
+	// t.HeaviestTipSetHeight (abi.ChainEpoch) (int64)
+	{
+		maj, extra, err := cbg.CborReadHeader(br)
+		var extraI int64
+		if err != nil {
+			return err
+		}
+		switch maj {
+		case cbg.MajUnsignedInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 positive overflow")
+			}
+		case cbg.MajNegativeInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 negative oveflow")
+			}
+			extraI = -1 - extraI
+		default:
+			return fmt.Errorf("wrong type for int64 field: %d", maj)
+		}
+
+		t.HeaviestTipSetHeight = abi.ChainEpoch(extraI)
+	}
+	// t.HeaviestTipSetWeight (big.Int) (struct)
+
+	{
+
+		if err := t.HeaviestTipSetWeight.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.HeaviestTipSetWeight: %w", err)
+		}
+
+	}
+	// t.GenesisHash (cid.Cid) (struct)
+
+	{
+
+		c, err := cbg.ReadCid(br)
+		if err != nil {
+			return xerrors.Errorf("failed to read cid field t.GenesisHash: %w", err)
+		}
+
+		t.GenesisHash = c
+
+	}
+	// t.NodeType (uint64) (uint64)
+
+	{
+
+		maj, extra, err = cbg.CborReadHeader(br)
+		if err != nil {
+			return err
+		}
+		if maj != cbg.MajUnsignedInt {
+			return fmt.Errorf("wrong type for uint64 field")
+		}
+		t.NodeType = uint64(extra)
+
+	}
+	// t.Capabilities (uint64) (uint64)
+
+	{
+
+		maj, extra, err = cbg.CborReadHeader(br)
+		if err != nil {
+			return err
+		}
+		if maj != cbg.MajUnsignedInt {
+			return fmt.Errorf("wrong type for uint64 field")
+		}
+		t.Capabilities = uint64(extra)
+
+	}
+	// t.ClientVersion (string) (string)
+
+	{
+		sval, err := cbg.ReadString(br)
+		if err != nil {
+			return err
+		}
+
+		t.ClientVersion = string(sval)
+	}
+	// t.APIVersion (uint64) (uint64)
+
+	{
+
+		maj, extra, err = cbg.CborReadHeader(br)
+		if err != nil {
+			return err
+		}
+		if maj != cbg.MajUnsignedInt {
+			return fmt.Errorf("wrong type for uint64 field")
+		}
+		t.APIVersion = uint64(extra)
+
+	}
+	return nil
+}
+
 func (t *LatencyMessage) MarshalCBOR(w io.Writer) error {
 	if t == nil {
 		_, err := w.Write(cbg.CborNull)
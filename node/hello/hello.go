@@ -15,6 +15,7 @@ import (
 	protocol "github.com/libp2p/go-libp2p-core/protocol"
 
 	cborutil "github.com/filecoin-project/go-cbor-util"
+	"github.com/filecoin-project/lotus/build"
 	"github.com/filecoin-project/lotus/chain"
 	"github.com/filecoin-project/lotus/chain/store"
 	"github.com/filecoin-project/lotus/chain/types"
@@ -23,6 +24,13 @@ import (
 
 const ProtocolID = "/fil/hello/1.0.0"
 
+// ProtocolIDv2 extends hello with node type, capability and version
+// information. Peers which don't support it fall back to ProtocolID.
+const ProtocolIDv2 = "/fil/hello/2.0.0"
+
+// localCapabilities are the chain protocols served by this node
+const localCapabilities = peermgr.CapBlockSync | peermgr.CapBlockSyncMessages | peermgr.CapGraphsync
+
 var log = logging.Logger("hello")
 
 type HelloMessage struct {
@@ -31,6 +39,19 @@ type HelloMessage struct {
 	HeaviestTipSetWeight big.Int
 	GenesisHash          cid.Cid
 }
+
+type HelloMessageV2 struct {
+	HeaviestTipSet       []cid.Cid
+	HeaviestTipSetHeight abi.ChainEpoch
+	HeaviestTipSetWeight big.Int
+	GenesisHash          cid.Cid
+
+	NodeType      uint64
+	Capabilities  uint64
+	ClientVersion string
+	APIVersion    uint64
+}
+
 type LatencyMessage struct {
 	TArrial int64
 	TSent   int64
@@ -61,8 +82,8 @@ func NewHelloService(h host.Host, cs *store.ChainStore, syncer *chain.Syncer, pm
 
 func (hs *Service) HandleStream(s inet.Stream) {
 
-	var hmsg HelloMessage
-	if err := cborutil.ReadCborRPC(s, &hmsg); err != nil {
+	hmsg, err := readHello(s)
+	if err != nil {
 		log.Infow("failed to read hello message, diconnecting", "error", err)
 		s.Conn().Close()
 		return
@@ -102,27 +123,76 @@ func (hs *Service) HandleStream(s inet.Stream) {
 		time.Sleep(time.Millisecond * 300)
 	}
 
-	ts, err := hs.syncer.FetchTipSet(context.Background(), s.Conn().RemotePeer(), types.NewTipSetKey(hmsg.HeaviestTipSet...))
-	if err != nil {
-		log.Errorf("failed to fetch tipset from peer during hello: %+v", err)
-		return
-	}
+	info := peerInfoFromHello(s.Protocol(), &hmsg)
+
+	// light nodes don't keep the chain, and peers without blocksync can't
+	// serve us the tipset, don't use them as sync targets
+	if info.NodeType != peermgr.NodeLight && info.Capabilities.Has(peermgr.CapBlockSync) {
+		ts, err := hs.syncer.FetchTipSet(context.Background(), s.Conn().RemotePeer(), types.NewTipSetKey(hmsg.HeaviestTipSet...))
+		if err != nil {
+			log.Errorf("failed to fetch tipset from peer during hello: %+v", err)
+			return
+		}
 
-	if ts.TipSet().Height() > 0 {
-		hs.h.ConnManager().TagPeer(s.Conn().RemotePeer(), "fcpeer", 10)
+		if ts.TipSet().Height() > 0 {
+			hs.h.ConnManager().TagPeer(s.Conn().RemotePeer(), "fcpeer", 10)
 
-		// don't bother informing about genesis
-		log.Infof("Got new tipset through Hello: %s from %s", ts.Cids(), s.Conn().RemotePeer())
-		hs.syncer.InformNewHead(s.Conn().RemotePeer(), ts)
+			// don't bother informing about genesis
+			log.Infof("Got new tipset through Hello: %s from %s", ts.Cids(), s.Conn().RemotePeer())
+			hs.syncer.InformNewHead(s.Conn().RemotePeer(), ts)
+		}
 	}
 	if hs.pmgr != nil {
-		hs.pmgr.AddFilecoinPeer(s.Conn().RemotePeer())
+		hs.pmgr.AddFilecoinPeerInfo(s.Conn().RemotePeer(), info)
 	}
 
 }
 
+// readHello reads the hello message in the version of the negotiated protocol
+func readHello(s inet.Stream) (HelloMessageV2, error) {
+	if s.Protocol() == ProtocolIDv2 {
+		var hmsg HelloMessageV2
+		err := cborutil.ReadCborRPC(s, &hmsg)
+		return hmsg, err
+	}
+
+	var v1 HelloMessage
+	if err := cborutil.ReadCborRPC(s, &v1); err != nil {
+		return HelloMessageV2{}, err
+	}
+	return upgradeHello(&v1), nil
+}
+
+// upgradeHello converts a legacy hello message, assuming the capabilities
+// all nodes had before hello 2.0.0
+func upgradeHello(v1 *HelloMessage) HelloMessageV2 {
+	return HelloMessageV2{
+		HeaviestTipSet:       v1.HeaviestTipSet,
+		HeaviestTipSetHeight: v1.HeaviestTipSetHeight,
+		HeaviestTipSetWeight: v1.HeaviestTipSetWeight,
+		GenesisHash:          v1.GenesisHash,
+
+		NodeType:     uint64(peermgr.NodeUnknown),
+		Capabilities: uint64(peermgr.LegacyCapabilities),
+	}
+}
+
+func peerInfoFromHello(proto protocol.ID, hmsg *HelloMessageV2) peermgr.PeerInfo {
+	info := peermgr.PeerInfo{
+		HelloVersion:  1,
+		NodeType:      peermgr.NodeType(hmsg.NodeType),
+		Capabilities:  peermgr.Capabilities(hmsg.Capabilities),
+		ClientVersion: hmsg.ClientVersion,
+		APIVersion:    hmsg.APIVersion,
+	}
+	if proto == ProtocolIDv2 {
+		info.HelloVersion = 2
+	}
+	return info
+}
+
 func (hs *Service) SayHello(ctx context.Context, pid peer.ID) error {
-	s, err := hs.h.NewStream(ctx, pid, ProtocolID)
+	s, err := hs.h.NewStream(ctx, pid, ProtocolIDv2, ProtocolID)
 	if err != nil {
 		return err
 	}
@@ -138,13 +208,8 @@ func (hs *Service) SayHello(ctx context.Context, pid peer.ID) error {
 		return err
 	}
 
-	hmsg := &HelloMessage{
-		HeaviestTipSet:       hts.Cids(),
-		HeaviestTipSetHeight: hts.Height(),
-		HeaviestTipSetWeight: weight,
-		GenesisHash:          gen.Cid(),
-	}
-	log.Debug("Sending hello message: ", hts.Cids(), hts.Height(), gen.Cid())
+	hmsg := newHello(s.Protocol(), hts, weight, gen.Cid())
+	log.Debug("Sending hello message: ", s.Protocol(), hts.Cids(), hts.Height(), gen.Cid())
 
 	t0 := time.Now()
 	if err := cborutil.WriteCborRPC(s, hmsg); err != nil {
@@ -181,3 +246,27 @@ func (hs *Service) SayHello(ctx context.Context, pid peer.ID) error {
 
 	return nil
 }
+
+// newHello builds the hello message in the version of the negotiated protocol
+func newHello(proto protocol.ID, hts *types.TipSet, weight big.Int, gen cid.Cid) interface{} {
+	if proto == ProtocolIDv2 {
+		return &HelloMessageV2{
+			HeaviestTipSet:       hts.Cids(),
+			HeaviestTipSetHeight: hts.Height(),
+			HeaviestTipSetWeight: weight,
+			GenesisHash:          gen,
+
+			NodeType:      uint64(peermgr.NodeFull),
+			Capabilities:  uint64(localCapabilities),
+			ClientVersion: build.UserVersion,
+			APIVersion:    uint64(build.APIVersion),
+		}
+	}
+
+	return &HelloMessage{
+		HeaviestTipSet:       hts.Cids(),
+		HeaviestTipSetHeight: hts.Height(),
+		HeaviestTipSetWeight: weight,
+		GenesisHash:          gen,
+	}
+}
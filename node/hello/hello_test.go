@@ -0,0 +1,204 @@
+package hello
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	cborutil "github.com/filecoin-project/go-cbor-util"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/libp2p/go-libp2p-core/host"
+	inet "github.com/libp2p/go-libp2p-core/network"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/types/mock"
+	"github.com/filecoin-project/lotus/lib/peermgr"
+)
+
+type received struct {
+	hmsg  HelloMessageV2
+	proto string
+}
+
+func connectedHosts(t *testing.T, ctx context.Context) (host.Host, host.Host) {
+	mn := mocknet.New(ctx)
+
+	a, err := mn.GenPeer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := mn.GenPeer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mn.LinkAll(); err != nil {
+		t.Fatal(err)
+	}
+	if err := mn.ConnectAllButSelf(); err != nil {
+		t.Fatal(err)
+	}
+
+	return a, b
+}
+
+// handleHello serves both hello versions on h, like a node running this code
+func handleHello(t *testing.T, h host.Host) chan received {
+	out := make(chan received, 1)
+	handler := func(s inet.Stream) {
+		defer s.Close()
+
+		hmsg, err := readHello(s)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		out <- received{hmsg: hmsg, proto: string(s.Protocol())}
+	}
+
+	h.SetStreamHandler(ProtocolIDv2, handler)
+	h.SetStreamHandler(ProtocolID, handler)
+	return out
+}
+
+func waitHello(t *testing.T, ch chan received) received {
+	t.Helper()
+
+	select {
+	case r := <-ch:
+		return r
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the hello message")
+		return received{}
+	}
+}
+
+func testTipSet() *types.TipSet {
+	return mock.TipSet(mock.MkBlock(nil, 1, 0))
+}
+
+func TestHelloV2(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	a, b := connectedHosts(t, ctx)
+	res := handleHello(t, b)
+
+	ts := testTipSet()
+	gen := ts.Cids()[0]
+
+	s, err := a.NewStream(ctx, b.ID(), ProtocolIDv2, ProtocolID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Protocol() != ProtocolIDv2 {
+		t.Fatalf("expected %s to be negotiated, got %s", ProtocolIDv2, s.Protocol())
+	}
+	if err := cborutil.WriteCborRPC(s, newHello(s.Protocol(), ts, big.NewInt(42), gen)); err != nil {
+		t.Fatal(err)
+	}
+
+	r := waitHello(t, res)
+	if r.proto != ProtocolIDv2 {
+		t.Errorf("expected the message to arrive over %s, got %s", ProtocolIDv2, r.proto)
+	}
+	if r.hmsg.GenesisHash != gen || !r.hmsg.HeaviestTipSetWeight.Equals(big.NewInt(42)) {
+		t.Errorf("unexpected chain info in %+v", r.hmsg)
+	}
+
+	info := peerInfoFromHello(ProtocolIDv2, &r.hmsg)
+	if info.HelloVersion != 2 || info.NodeType != peermgr.NodeFull {
+		t.Errorf("expected a v2 full node, got %+v", info)
+	}
+	if info.Capabilities != localCapabilities {
+		t.Errorf("expected capabilities %v, got %v", localCapabilities.Strings(), info.Capabilities.Strings())
+	}
+	if info.ClientVersion != build.UserVersion || info.APIVersion != uint64(build.APIVersion) {
+		t.Errorf("expected version %s (api %d), got %s (api %d)", build.UserVersion, build.APIVersion, info.ClientVersion, info.APIVersion)
+	}
+}
+
+func TestHelloFallbackToV1(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	a, b := connectedHosts(t, ctx)
+
+	// b only speaks hello 1.0.0
+	res := make(chan HelloMessage, 1)
+	b.SetStreamHandler(ProtocolID, func(s inet.Stream) {
+		defer s.Close()
+
+		var hmsg HelloMessage
+		if err := cborutil.ReadCborRPC(s, &hmsg); err != nil {
+			t.Error(err)
+			return
+		}
+		res <- hmsg
+	})
+
+	ts := testTipSet()
+	gen := ts.Cids()[0]
+
+	s, err := a.NewStream(ctx, b.ID(), ProtocolIDv2, ProtocolID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Protocol() != ProtocolID {
+		t.Fatalf("expected to fall back to %s, got %s", ProtocolID, s.Protocol())
+	}
+	if err := cborutil.WriteCborRPC(s, newHello(s.Protocol(), ts, big.NewInt(42), gen)); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case hmsg := <-res:
+		if hmsg.GenesisHash != gen || hmsg.HeaviestTipSetHeight != ts.Height() {
+			t.Errorf("unexpected chain info in %+v", hmsg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the hello message")
+	}
+}
+
+func TestHelloFromV1Peer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	a, b := connectedHosts(t, ctx)
+	res := handleHello(t, a)
+
+	ts := testTipSet()
+	gen := ts.Cids()[0]
+
+	// b only speaks hello 1.0.0
+	s, err := b.NewStream(ctx, a.ID(), ProtocolID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cborutil.WriteCborRPC(s, &HelloMessage{
+		HeaviestTipSet:       ts.Cids(),
+		HeaviestTipSetHeight: ts.Height(),
+		HeaviestTipSetWeight: big.NewInt(42),
+		GenesisHash:          gen,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := waitHello(t, res)
+	if r.hmsg.GenesisHash != gen || !r.hmsg.HeaviestTipSetWeight.Equals(big.NewInt(42)) {
+		t.Errorf("unexpected chain info in %+v", r.hmsg)
+	}
+
+	// peers from before hello 2.0.0 are assumed to serve everything
+	info := peerInfoFromHello(ProtocolID, &r.hmsg)
+	if info.HelloVersion != 1 || info.NodeType != peermgr.NodeUnknown {
+		t.Errorf("expected a v1 peer of unknown type, got %+v", info)
+	}
+	if info.Capabilities != peermgr.LegacyCapabilities {
+		t.Errorf("expected capabilities %v, got %v", peermgr.LegacyCapabilities.Strings(), info.Capabilities.Strings())
+	}
+}
@@ -2,6 +2,8 @@ package common
 
 import (
 	"context"
+	"net"
+	"sort"
 
 	"github.com/filecoin-project/lotus/node/modules/lp2p"
 
@@ -9,8 +11,10 @@ import (
 
 	"github.com/gbrlsnchs/jwt/v3"
 	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/metrics"
 	"github.com/libp2p/go-libp2p-core/network"
 	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/protocol"
 	swarm "github.com/libp2p/go-libp2p-swarm"
 	ma "github.com/multiformats/go-multiaddr"
 	"go.uber.org/fx"
@@ -18,6 +22,7 @@ import (
 
 	"github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/lib/peermgr"
 	"github.com/filecoin-project/lotus/node/modules/dtypes"
 )
 
@@ -27,6 +32,10 @@ type CommonAPI struct {
 	APISecret *dtypes.APIAlg
 	Host      host.Host
 	Router    lp2p.BaseIpfsRouting
+	Sk        *dtypes.ScoreKeeper
+	ConnGater *lp2p.ConnGater
+	Reporter  metrics.Reporter
+	PeerMgr   *peermgr.PeerMgr `optional:"true"`
 }
 
 type jwtPayload struct {
@@ -54,6 +63,22 @@ func (a *CommonAPI) NetConnectedness(ctx context.Context, pid peer.ID) (network.
 	return a.Host.Network().Connectedness(pid), nil
 }
 
+func (a *CommonAPI) NetPubsubScores(context.Context) ([]api.PubsubScore, error) {
+	scores := a.Sk.Get()
+	out := make([]api.PubsubScore, len(scores))
+	i := 0
+	for k, v := range scores {
+		out[i] = api.PubsubScore{ID: k, Score: v}
+		i++
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Score > out[j].Score
+	})
+
+	return out, nil
+}
+
 func (a *CommonAPI) NetPeers(context.Context) ([]peer.AddrInfo, error) {
 	conns := a.Host.Network().Conns()
 	out := make([]peer.AddrInfo, len(conns))
@@ -70,7 +95,46 @@ func (a *CommonAPI) NetPeers(context.Context) ([]peer.AddrInfo, error) {
 	return out, nil
 }
 
+func (a *CommonAPI) NetPeerInfo(_ context.Context, p peer.ID) (*api.ExtendedPeerInfo, error) {
+	info := &api.ExtendedPeerInfo{ID: p}
+
+	agent, err := a.Host.Peerstore().Get(p, "AgentVersion")
+	if err == nil {
+		info.Agent = agent.(string)
+	}
+
+	for _, addr := range a.Host.Peerstore().Addrs(p) {
+		info.Addrs = append(info.Addrs, addr.String())
+	}
+	sort.Strings(info.Addrs)
+
+	protocols, err := a.Host.Peerstore().GetProtocols(p)
+	if err == nil {
+		sort.Strings(protocols)
+		info.Protocols = protocols
+	}
+
+	if a.PeerMgr != nil {
+		if pi, ok := a.PeerMgr.GetPeerInfo(p); ok {
+			info.Hello = &api.HelloPeerInfo{
+				HelloVersion:  pi.HelloVersion,
+				NodeType:      pi.NodeType.String(),
+				Capabilities:  pi.Capabilities.Strings(),
+				ClientVersion: pi.ClientVersion,
+				APIVersion:    build.Version(pi.APIVersion),
+				Latency:       pi.Latency,
+			}
+		}
+	}
+
+	return info, nil
+}
+
 func (a *CommonAPI) NetConnect(ctx context.Context, p peer.AddrInfo) error {
+	if a.ConnGater.PeerBlocked(p.ID) {
+		return xerrors.Errorf("peer %s is blocked", p.ID)
+	}
+
 	if swrm, ok := a.Host.Network().(*swarm.Swarm); ok {
 		swrm.Backoff().Clear(p.ID)
 	}
@@ -93,6 +157,97 @@ func (a *CommonAPI) NetFindPeer(ctx context.Context, p peer.ID) (peer.AddrInfo,
 	return a.Router.FindPeer(ctx, p)
 }
 
+func (a *CommonAPI) NetBlockAdd(ctx context.Context, acl api.NetBlockList) error {
+	for _, p := range acl.Peers {
+		if err := a.ConnGater.BlockPeer(p); err != nil {
+			return xerrors.Errorf("error blocking peer %s: %w", p, err)
+		}
+	}
+
+	for _, addr := range acl.IPAddrs {
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			return xerrors.Errorf("error parsing IP address %s", addr)
+		}
+
+		if err := a.ConnGater.BlockAddr(ip); err != nil {
+			return xerrors.Errorf("error blocking IP address %s: %w", addr, err)
+		}
+	}
+
+	for _, subnet := range acl.IPSubnets {
+		_, cidr, err := net.ParseCIDR(subnet)
+		if err != nil {
+			return xerrors.Errorf("error parsing subnet %s: %w", subnet, err)
+		}
+
+		if err := a.ConnGater.BlockSubnet(cidr); err != nil {
+			return xerrors.Errorf("error blocking subnet %s: %w", subnet, err)
+		}
+	}
+
+	return nil
+}
+
+func (a *CommonAPI) NetBlockRemove(ctx context.Context, acl api.NetBlockList) error {
+	for _, p := range acl.Peers {
+		if err := a.ConnGater.UnblockPeer(p); err != nil {
+			return xerrors.Errorf("error unblocking peer %s: %w", p, err)
+		}
+	}
+
+	for _, addr := range acl.IPAddrs {
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			return xerrors.Errorf("error parsing IP address %s", addr)
+		}
+
+		if err := a.ConnGater.UnblockAddr(ip); err != nil {
+			return xerrors.Errorf("error unblocking IP address %s: %w", addr, err)
+		}
+	}
+
+	for _, subnet := range acl.IPSubnets {
+		_, cidr, err := net.ParseCIDR(subnet)
+		if err != nil {
+			return xerrors.Errorf("error parsing subnet %s: %w", subnet, err)
+		}
+
+		if err := a.ConnGater.UnblockSubnet(cidr); err != nil {
+			return xerrors.Errorf("error unblocking subnet %s: %w", subnet, err)
+		}
+	}
+
+	return nil
+}
+
+func (a *CommonAPI) NetBlockList(ctx context.Context) (result api.NetBlockList, err error) {
+	result.Peers = a.ConnGater.ListBlockedPeers()
+	for _, ip := range a.ConnGater.ListBlockedAddrs() {
+		result.IPAddrs = append(result.IPAddrs, ip.String())
+	}
+	for _, subnet := range a.ConnGater.ListBlockedSubnets() {
+		result.IPSubnets = append(result.IPSubnets, subnet.String())
+	}
+	return
+}
+
+func (a *CommonAPI) NetBandwidthStats(ctx context.Context) (metrics.Stats, error) {
+	return a.Reporter.GetBandwidthTotals(), nil
+}
+
+func (a *CommonAPI) NetBandwidthStatsByPeer(ctx context.Context) (map[string]metrics.Stats, error) {
+	out := make(map[string]metrics.Stats)
+	for p, s := range a.Reporter.GetBandwidthByPeer() {
+		out[p.String()] = s
+	}
+	return out, nil
+}
+
+func (a *CommonAPI) NetBandwidthStatsByProtocol(ctx context.Context) (map[protocol.ID]metrics.Stats, error) {
+	return a.Reporter.GetBandwidthByProtocol(), nil
+}
+
 func (a *CommonAPI) ID(context.Context) (peer.ID, error) {
 	return a.Host.ID(), nil
 }
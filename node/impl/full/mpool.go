@@ -114,3 +114,54 @@ func (a *MpoolAPI) MpoolGetNonce(ctx context.Context, addr address.Address) (uin
 func (a *MpoolAPI) MpoolSub(ctx context.Context) (<-chan api.MpoolUpdate, error) {
 	return a.Mpool.Updates(ctx)
 }
+
+func (a *MpoolAPI) MpoolReplace(ctx context.Context, from address.Address, nonce uint64, auto bool, gasPrice types.BigInt) (cid.Cid, error) {
+	old, ok := a.Mpool.GetPending(from, nonce)
+	if !ok {
+		return cid.Undef, xerrors.Errorf("no pending message from %s with nonce %d", from, nonce)
+	}
+
+	msg := old.Message
+	return a.replace(ctx, old, &msg, auto, gasPrice)
+}
+
+func (a *MpoolAPI) MpoolCancel(ctx context.Context, from address.Address, nonce uint64) (cid.Cid, error) {
+	old, ok := a.Mpool.GetPending(from, nonce)
+	if !ok {
+		return cid.Undef, xerrors.Errorf("no pending message from %s with nonce %d", from, nonce)
+	}
+
+	msg := &types.Message{
+		To:       from,
+		From:     from,
+		Nonce:    nonce,
+		Value:    types.NewInt(0),
+		GasLimit: 10000,
+	}
+	return a.replace(ctx, old, msg, true, types.EmptyInt)
+}
+
+func (a *MpoolAPI) replace(ctx context.Context, old *types.SignedMessage, msg *types.Message, auto bool, gasPrice types.BigInt) (cid.Cid, error) {
+	minPrice := messagepool.ComputeMinRBF(old.Message.GasPrice)
+
+	if auto {
+		msg.GasPrice = minPrice
+	} else {
+		if gasPrice.Int == nil {
+			return cid.Undef, xerrors.Errorf("must specify gas price, or use auto")
+		}
+		if gasPrice.LessThan(minPrice) {
+			return cid.Undef, xerrors.Errorf("gas price %s is too low to replace message %s, needs to be at least %s", gasPrice, old.Cid(), minPrice)
+		}
+		msg.GasPrice = gasPrice
+	}
+
+	smsg, err := a.WalletSignMessage(ctx, msg.From, msg)
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("signing replacement message: %w", err)
+	}
+
+	log.Infow("replacing message", "from", msg.From, "nonce", msg.Nonce, "old", old.Cid(), "new", smsg.Cid(), "gasprice", msg.GasPrice)
+
+	return a.Mpool.Push(smsg)
+}
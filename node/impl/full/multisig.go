@@ -0,0 +1,186 @@
+package full
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"sort"
+
+	cid "github.com/ipfs/go-cid"
+	"github.com/ipfs/go-hamt-ipld"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	cbg "github.com/whyrusleeping/cbor-gen"
+	"go.uber.org/fx"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	samsig "github.com/filecoin-project/specs-actors/actors/builtin/multisig"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/actors"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/vm"
+)
+
+type MsigAPI struct {
+	fx.In
+
+	StateAPI StateAPI
+	MpoolAPI MpoolAPI
+}
+
+func (a *MsigAPI) MsigGetPending(ctx context.Context, msig address.Address, tsk types.TipSetKey) ([]*api.MsigTransaction, error) {
+	ts, err := a.StateAPI.Chain.GetTipSetFromKey(tsk)
+	if err != nil {
+		return nil, xerrors.Errorf("loading tipset %s: %w", tsk, err)
+	}
+
+	var st samsig.State
+	act, err := a.StateAPI.StateManager.LoadActorState(ctx, msig, &st, ts)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load multisig actor state: %w", err)
+	}
+
+	if act.Code != builtin.MultisigActorCodeID {
+		return nil, xerrors.Errorf("given actor was not a multisig")
+	}
+
+	cst := cbor.NewCborStore(a.StateAPI.StateManager.ChainStore().Blockstore())
+	nd, err := hamt.LoadNode(ctx, cst, st.PendingTxns, hamt.UseTreeBitWidth(5))
+	if err != nil {
+		return nil, xerrors.Errorf("loading pending transactions: %w", err)
+	}
+
+	var out []*api.MsigTransaction
+	err = nd.ForEach(ctx, func(k string, val interface{}) error {
+		d := val.(*cbg.Deferred)
+		var tx samsig.Transaction
+		if err := tx.UnmarshalCBOR(bytes.NewReader(d.Raw)); err != nil {
+			return err
+		}
+
+		txid, _ := binary.Varint([]byte(k))
+
+		mtx := &api.MsigTransaction{
+			ID:       txid,
+			To:       tx.To,
+			Value:    types.BigInt(tx.Value),
+			Method:   tx.Method,
+			Params:   tx.Params,
+			Approved: tx.Approved,
+		}
+
+		// decoding is best-effort, the target may not exist yet, or not be
+		// a builtin actor
+		toAct, err := a.StateAPI.StateManager.GetActor(tx.To, ts)
+		if err == nil {
+			name, params, err := vm.DecodeMethodParams(toAct.Code, tx.Method, tx.Params)
+			if err != nil {
+				log.Warnf("decoding multisig %s transaction %d: %s", msig, txid, err)
+			}
+			mtx.MethodName = name
+			mtx.DecodedParams = params
+		} else if tx.Method == builtin.MethodSend {
+			mtx.MethodName = "Send"
+		}
+
+		out = append(out, mtx)
+		return nil
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("failed to iterate transactions hamt: %w", err)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].ID < out[j].ID
+	})
+
+	return out, nil
+}
+
+func (a *MsigAPI) MsigCreate(ctx context.Context, required int64, signers []address.Address, value types.BigInt, src address.Address) (cid.Cid, error) {
+	msg, err := actors.MsigCreateMessage(src, signers, required, value)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	return a.push(ctx, msg)
+}
+
+func (a *MsigAPI) MsigPropose(ctx context.Context, msig address.Address, to address.Address, value types.BigInt, src address.Address, method uint64, params []byte) (cid.Cid, error) {
+	msg, err := actors.MsigProposeMessage(msig, src, to, value, abi.MethodNum(method), params)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	return a.push(ctx, msg)
+}
+
+func (a *MsigAPI) MsigApprove(ctx context.Context, msig address.Address, txID int64, src address.Address) (cid.Cid, error) {
+	msg, err := actors.MsigApproveMessage(msig, src, txID)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	return a.push(ctx, msg)
+}
+
+func (a *MsigAPI) MsigCancel(ctx context.Context, msig address.Address, txID int64, src address.Address) (cid.Cid, error) {
+	msg, err := actors.MsigCancelMessage(msig, src, txID)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	return a.push(ctx, msg)
+}
+
+func (a *MsigAPI) MsigAddSigner(ctx context.Context, msig address.Address, src address.Address, signer address.Address, increase bool) (cid.Cid, error) {
+	msg, err := actors.MsigAddSignerMessage(msig, src, signer, increase)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	return a.push(ctx, msg)
+}
+
+func (a *MsigAPI) MsigRemoveSigner(ctx context.Context, msig address.Address, src address.Address, signer address.Address, decrease bool) (cid.Cid, error) {
+	msg, err := actors.MsigRemoveSignerMessage(msig, src, signer, decrease)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	return a.push(ctx, msg)
+}
+
+func (a *MsigAPI) MsigSwapSigner(ctx context.Context, msig address.Address, src address.Address, oldSigner address.Address, newSigner address.Address) (cid.Cid, error) {
+	msg, err := actors.MsigSwapSignerMessage(msig, src, oldSigner, newSigner)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	return a.push(ctx, msg)
+}
+
+func (a *MsigAPI) MsigChangeThreshold(ctx context.Context, msig address.Address, src address.Address, threshold int64) (cid.Cid, error) {
+	msg, err := actors.MsigChangeThresholdMessage(msig, src, threshold)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	return a.push(ctx, msg)
+}
+
+func (a *MsigAPI) push(ctx context.Context, msg *types.Message) (cid.Cid, error) {
+	if msg.From == address.Undef {
+		return cid.Undef, xerrors.Errorf("must provide source address")
+	}
+
+	smsg, err := a.MpoolAPI.MpoolPushMessage(ctx, msg)
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("failed to push message: %w", err)
+	}
+
+	return smsg.Cid(), nil
+}
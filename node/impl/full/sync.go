@@ -2,8 +2,10 @@ package full
 
 import (
 	"context"
+	"sort"
 
 	cid "github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p-core/peer"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	"go.uber.org/fx"
 	"golang.org/x/xerrors"
@@ -100,3 +102,126 @@ func (a *SyncAPI) SyncCheckBad(ctx context.Context, bcid cid.Cid) (string, error
 
 	return reason, nil
 }
+
+func (a *SyncAPI) SyncDebugState(ctx context.Context) (*api.SyncDebugState, error) {
+	st, err := a.Syncer.DebugState(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &api.SyncDebugState{
+		BootstrapState: bootstrapStateString(st.BootstrapState),
+		Paused:         st.Paused,
+		SyncQueue:      syncBuckets(st.SyncQueue),
+		ActiveSyncTips: syncBuckets(st.ActiveSyncTips),
+	}
+
+	for p, ts := range st.PeerHeads {
+		out.PeerHeads = append(out.PeerHeads, api.SyncPeerHead{
+			Peer:   p,
+			Head:   ts.Key(),
+			Height: ts.Height(),
+		})
+	}
+	sort.Slice(out.PeerHeads, func(i, j int) bool {
+		return out.PeerHeads[i].Height > out.PeerHeads[j].Height
+	})
+
+	for _, ts := range st.ActiveSyncs {
+		out.ActiveSyncs = append(out.ActiveSyncs, ts.Key())
+	}
+
+	if st.NextTarget != nil {
+		nt := syncBucket(*st.NextTarget)
+		out.NextTarget = &nt
+	}
+
+	for i := range st.Workers {
+		w := &st.Workers[i]
+		ws := api.SyncWorkerState{
+			ID: w.ID,
+			State: api.ActiveSync{
+				Base:    w.State.Base,
+				Target:  w.State.Target,
+				Stage:   w.State.Stage,
+				Height:  w.State.Height,
+				Start:   w.State.Start,
+				End:     w.State.End,
+				Message: w.State.Message,
+			},
+			LastErrorTime: w.LastErrTime,
+		}
+		if w.LastErr != nil {
+			ws.LastError = w.LastErr.Error()
+		}
+		out.Workers = append(out.Workers, ws)
+	}
+
+	return out, nil
+}
+
+func bootstrapStateString(bss int) string {
+	switch bss {
+	case chain.BSStateInit:
+		return "init"
+	case chain.BSStateSelected:
+		return "selected"
+	case chain.BSStateScheduled:
+		return "scheduled"
+	case chain.BSStateComplete:
+		return "complete"
+	default:
+		return "unknown"
+	}
+}
+
+func syncBucket(b chain.SyncBucketState) api.SyncBucket {
+	out := api.SyncBucket{Count: b.Count}
+
+	var heaviest *types.TipSet
+	for _, ts := range b.Tips {
+		out.Tips = append(out.Tips, ts.Key())
+		if heaviest == nil || ts.ParentWeight().GreaterThan(heaviest.ParentWeight()) {
+			heaviest = ts
+		}
+	}
+
+	if heaviest != nil {
+		out.Heaviest = heaviest.Key()
+		out.Height = heaviest.Height()
+	}
+
+	return out
+}
+
+func syncBuckets(in []chain.SyncBucketState) []api.SyncBucket {
+	out := make([]api.SyncBucket, len(in))
+	for i, b := range in {
+		out[i] = syncBucket(b)
+	}
+	return out
+}
+
+func (a *SyncAPI) SyncPause(ctx context.Context) error {
+	log.Warn("Pausing chain sync")
+	return a.Syncer.Pause(ctx)
+}
+
+func (a *SyncAPI) SyncResume(ctx context.Context) error {
+	log.Warn("Resuming chain sync")
+	return a.Syncer.Resume(ctx)
+}
+
+func (a *SyncAPI) SyncForceTarget(ctx context.Context, p peer.ID) (types.TipSetKey, error) {
+	ts, err := a.Syncer.ForceTarget(ctx, p)
+	if err != nil {
+		return types.EmptyTSK, xerrors.Errorf("forcing sync target: %w", err)
+	}
+
+	return ts.Key(), nil
+}
+
+func (a *SyncAPI) SyncSetWorkers(ctx context.Context, count int) error {
+	log.Warnf("Setting sync worker count to %d", count)
+	return a.Syncer.SetWorkerCount(count)
+}
@@ -3,14 +3,17 @@ package full
 import (
 	"context"
 
+	"github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/lib/sigs"
 
 	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
 	"github.com/filecoin-project/specs-actors/actors/crypto"
 
 	"github.com/filecoin-project/lotus/chain/stmgr"
 	"github.com/filecoin-project/lotus/chain/types"
 	"github.com/filecoin-project/lotus/chain/wallet"
+	"github.com/filecoin-project/lotus/chain/wallet/history"
 
 	"go.uber.org/fx"
 	"golang.org/x/xerrors"
@@ -21,6 +24,7 @@ type WalletAPI struct {
 
 	StateManager *stmgr.StateManager
 	Wallet       *wallet.Wallet
+	History      *history.Index
 }
 
 func (a *WalletAPI) WalletNew(ctx context.Context, typ crypto.SigType) (address.Address, error) {
@@ -31,8 +35,25 @@ func (a *WalletAPI) WalletHas(ctx context.Context, addr address.Address) (bool,
 	return a.Wallet.HasKey(addr)
 }
 
-func (a *WalletAPI) WalletList(ctx context.Context) ([]address.Address, error) {
-	return a.Wallet.ListAddrs()
+func (a *WalletAPI) WalletList(ctx context.Context) ([]api.WalletAddress, error) {
+	infos, err := a.Wallet.ListAll()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]api.WalletAddress, len(infos))
+	for i, info := range infos {
+		out[i] = api.WalletAddress{
+			Address:   info.Address,
+			KeyType:   info.KeyType,
+			HD:        info.HD,
+			WatchOnly: info.WatchOnly,
+			Label:     info.Label,
+			Tags:      info.Tags,
+		}
+	}
+
+	return out, nil
 }
 
 func (a *WalletAPI) WalletBalance(ctx context.Context, addr address.Address) (types.BigInt, error) {
@@ -76,3 +97,99 @@ func (a *WalletAPI) WalletExport(ctx context.Context, addr address.Address) (*ty
 func (a *WalletAPI) WalletImport(ctx context.Context, ki *types.KeyInfo) (address.Address, error) {
 	return a.Wallet.Import(ki)
 }
+
+func (a *WalletAPI) WalletSignData(ctx context.Context, addr address.Address, purpose string, payload []byte) (*sigs.SignedData, error) {
+	nn, err := stmgr.GetNetworkName(ctx, a.StateManager, a.StateManager.ChainStore().GetHeaviestTipSet().ParentState())
+	if err != nil {
+		return nil, xerrors.Errorf("getting network name: %w", err)
+	}
+
+	return a.Wallet.SignData(ctx, addr, &sigs.DataEnvelope{
+		Purpose: purpose,
+		Network: string(nn),
+		Payload: payload,
+	})
+}
+
+func (a *WalletAPI) WalletDelete(ctx context.Context, addr address.Address) error {
+	return a.Wallet.Delete(addr)
+}
+
+func (a *WalletAPI) WalletUndelete(ctx context.Context, addr address.Address) error {
+	return a.Wallet.Undelete(addr)
+}
+
+func (a *WalletAPI) WalletSetLabel(ctx context.Context, addr address.Address, label string) error {
+	return a.Wallet.SetLabel(addr, label)
+}
+
+func (a *WalletAPI) WalletSetTags(ctx context.Context, addr address.Address, tags []string) error {
+	return a.Wallet.SetTags(addr, tags)
+}
+
+func (a *WalletAPI) WalletAddWatch(ctx context.Context, addr address.Address, label string) error {
+	return a.Wallet.AddWatch(addr, label)
+}
+
+func (a *WalletAPI) WalletHistory(ctx context.Context, addr address.Address, fromHeight abi.ChainEpoch, limit int) ([]api.WalletHistoryEntry, error) {
+	entries, err := a.History.History(addr, fromHeight, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	// messages may refer to the address by its ID
+	id, err := a.StateManager.LookupID(ctx, addr, nil)
+	if err != nil {
+		id = addr
+	}
+
+	out := make([]api.WalletHistoryEntry, len(entries))
+	for i, e := range entries {
+		outgoing := e.From == addr || e.From == id
+		counterparty := e.From
+		if outgoing {
+			counterparty = e.To
+		}
+
+		out[i] = api.WalletHistoryEntry{
+			Height:       e.Height,
+			TipSet:       e.TipSet,
+			Message:      e.Message,
+			Internal:     e.Internal,
+			From:         e.From,
+			To:           e.To,
+			Counterparty: counterparty,
+			Outgoing:     outgoing,
+			Method:       e.Method,
+			Value:        e.Value,
+			Fee:          e.Fee,
+			ExitCode:     e.ExitCode,
+		}
+	}
+
+	return out, nil
+}
+
+func (a *WalletAPI) WalletHDNew(ctx context.Context, typ crypto.SigType, passphrase string) (*api.HDNewResult, error) {
+	mnemonic, addr, err := a.Wallet.HDNew(typ, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	return &api.HDNewResult{
+		Mnemonic: mnemonic,
+		Address:  addr,
+	}, nil
+}
+
+func (a *WalletAPI) WalletHDRestore(ctx context.Context, mnemonic string, passphrase string, secpCount, blsCount int) ([]address.Address, error) {
+	return a.Wallet.HDRestore(mnemonic, passphrase, secpCount, blsCount)
+}
+
+func (a *WalletAPI) WalletHDDerive(ctx context.Context, typ crypto.SigType, index int64, passphrase string) (address.Address, error) {
+	return a.Wallet.HDDerive(typ, index, passphrase)
+}
+
+func (a *WalletAPI) WalletHDUnlock(ctx context.Context, passphrase string) error {
+	return a.Wallet.HDUnlock(passphrase)
+}
@@ -3,6 +3,7 @@ package market
 import (
 	"context"
 
+	"github.com/ipfs/go-cid"
 	"go.uber.org/fx"
 
 	"github.com/filecoin-project/go-address"
@@ -16,6 +17,18 @@ type MarketAPI struct {
 	FMgr *market.FundMgr
 }
 
-func (a *MarketAPI) MarketEnsureAvailable(ctx context.Context, addr, wallet address.Address, amt types.BigInt) error {
-	return a.FMgr.EnsureAvailable(ctx, addr, wallet, amt)
+func (a *MarketAPI) MarketEnsureAvailable(ctx context.Context, deal cid.Cid, addr, wallet address.Address, amt types.BigInt) error {
+	return a.FMgr.EnsureAvailable(ctx, deal, addr, wallet, amt)
+}
+
+func (a *MarketAPI) MarketReleaseFunds(ctx context.Context, deal cid.Cid) error {
+	return a.FMgr.Release(deal)
+}
+
+func (a *MarketAPI) MarketGetReserved(ctx context.Context, addr address.Address) (types.BigInt, error) {
+	return a.FMgr.Reserved(addr), nil
+}
+
+func (a *MarketAPI) MarketWithdraw(ctx context.Context, wallet, addr address.Address, amt types.BigInt) (cid.Cid, error) {
+	return a.FMgr.Withdraw(ctx, addr, wallet, amt)
 }
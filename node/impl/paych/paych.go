@@ -2,9 +2,9 @@ package paych
 
 import (
 	"context"
-	"fmt"
+	"sort"
 
-	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/abi"
 	"github.com/filecoin-project/specs-actors/actors/builtin/paych"
 	"github.com/ipfs/go-cid"
 	"go.uber.org/fx"
@@ -47,7 +47,6 @@ func (a *PaychAPI) PaychAllocateLane(ctx context.Context, ch address.Address) (u
 func (a *PaychAPI) PaychNewPayment(ctx context.Context, from, to address.Address, vouchers []api.VoucherSpec) (*api.PaymentInfo, error) {
 	amount := vouchers[len(vouchers)-1].Amount
 
-	// TODO: Fix free fund tracking in PaychGet
 	// TODO: validate voucher spec before locking funds
 	ch, err := a.PaychGet(ctx, from, to, amount)
 	if err != nil {
@@ -99,46 +98,90 @@ func (a *PaychAPI) PaychStatus(ctx context.Context, pch address.Address) (*api.P
 	if err != nil {
 		return nil, err
 	}
-	return &api.PaychStatus{
+
+	status := &api.PaychStatus{
 		ControlAddr: ci.Control,
 		Direction:   api.PCHDir(ci.Direction),
-	}, nil
-}
 
-func (a *PaychAPI) PaychClose(ctx context.Context, addr address.Address) (cid.Cid, error) {
-	panic("TODO Settle logic")
+		Target:     ci.Target,
+		State:      paychmgr.ChanStateNames[ci.State],
+		SettlingAt: abi.ChainEpoch(ci.SettlingAt),
 
-	ci, err := a.PaychMgr.GetChannelInfo(addr)
-	if err != nil {
-		return cid.Undef, err
+		Balance: types.NewInt(0),
+		ToSend:  types.NewInt(0),
+
+		AvailableFunds: types.NewInt(0),
+		PendingFunds:   types.NewInt(0),
 	}
 
-	nonce, err := a.MpoolGetNonce(ctx, ci.Control)
-	if err != nil {
-		return cid.Undef, err
+	if ci.PendingAmount.Int != nil {
+		status.PendingFunds = ci.PendingAmount
 	}
 
-	msg := &types.Message{
-		To:     addr,
-		From:   ci.Control,
-		Value:  types.NewInt(0),
-		Method: builtin.MethodsPaych.Settle,
-		Nonce:  nonce,
+	lanes := map[uint64]*api.PaychLaneStatus{}
+	lane := func(id uint64) *api.PaychLaneStatus {
+		if _, ok := lanes[id]; !ok {
+			lanes[id] = &api.PaychLaneStatus{
+				Lane:     id,
+				Redeemed: types.NewInt(0),
+				Best:     types.NewInt(0),
+			}
+		}
+		return lanes[id]
+	}
+
+	if ci.State != paychmgr.ChanCollected {
+		act, st, err := a.PaychMgr.ChannelState(ctx, pch)
+		if err != nil {
+			return nil, xerrors.Errorf("loading channel state: %w", err)
+		}
 
-		GasLimit: 10000,
-		GasPrice: types.NewInt(0),
+		status.Balance = act.Balance
+		status.ToSend = st.ToSend
+
+		for _, ls := range st.LaneStates {
+			l := lane(ls.ID)
+			l.Redeemed = ls.Redeemed
+			l.Nonce = ls.Nonce
+		}
+
+		if ci.Direction == paychmgr.DirOutbound {
+			status.AvailableFunds, err = a.PaychMgr.AvailableFunds(ctx, pch)
+			if err != nil {
+				return nil, xerrors.Errorf("getting available funds: %w", err)
+			}
+		}
 	}
 
-	smsg, err := a.WalletSignMessage(ctx, ci.Control, msg)
-	if err != nil {
-		return cid.Undef, err
+	for _, v := range ci.Vouchers {
+		l := lane(v.Voucher.Lane)
+		l.Vouchers++
+		if v.Voucher.Amount.GreaterThan(l.Best) {
+			l.Best = v.Voucher.Amount
+			l.BestNonce = v.Voucher.Nonce
+		}
 	}
 
-	if _, err := a.MpoolPush(ctx, smsg); err != nil {
-		return cid.Undef, err
+	for _, l := range lanes {
+		status.Lanes = append(status.Lanes, *l)
 	}
+	sort.Slice(status.Lanes, func(i, j int) bool {
+		return status.Lanes[i].Lane < status.Lanes[j].Lane
+	})
 
-	return smsg.Cid(), nil
+	return status, nil
+}
+
+func (a *PaychAPI) PaychSettle(ctx context.Context, addr address.Address) (cid.Cid, error) {
+	return a.PaychMgr.Settle(ctx, addr)
+}
+
+func (a *PaychAPI) PaychClose(ctx context.Context, addr address.Address) (cid.Cid, error) {
+	return a.PaychSettle(ctx, addr)
+}
+
+func (a *PaychAPI) PaychCollect(ctx context.Context, addr address.Address) (cid.Cid, error) {
+	return a.PaychMgr.Collect(ctx, addr)
 }
 
 func (a *PaychAPI) PaychVoucherCheckValid(ctx context.Context, ch address.Address, sv *paych.SignedVoucher) error {
@@ -222,27 +265,11 @@ func (a *PaychAPI) PaychVoucherSubmit(ctx context.Context, ch address.Address, s
 		return cid.Undef, err
 	}
 
-	if sv.Extra != nil || len(sv.SecretPreimage) > 0 {
-		return cid.Undef, fmt.Errorf("cant handle more advanced payment channel stuff yet")
-	}
-
-	enc, err := actors.SerializeParams(&paych.UpdateChannelStateParams{
-		Sv: *sv,
-	})
+	msg, err := actors.PaychUpdateMessage(ch, ci.Control, sv)
 	if err != nil {
 		return cid.Undef, err
 	}
-
-	msg := &types.Message{
-		From:     ci.Control,
-		To:       ch,
-		Value:    types.NewInt(0),
-		Nonce:    nonce,
-		Method:   builtin.MethodsPaych.UpdateChannelState,
-		Params:   enc,
-		GasLimit: 100000,
-		GasPrice: types.NewInt(0),
-	}
+	msg.Nonce = nonce
 
 	smsg, err := a.WalletSignMessage(ctx, ci.Control, msg)
 	if err != nil {
@@ -3,6 +3,8 @@ package impl
 import (
 	"context"
 	"net/http"
+	"sync"
+	"time"
 
 	"golang.org/x/xerrors"
 
@@ -13,11 +15,40 @@ import (
 	"github.com/filecoin-project/lotus/api/client"
 	"github.com/filecoin-project/lotus/lib/jsonrpc"
 	"github.com/filecoin-project/sector-storage"
+	"github.com/filecoin-project/sector-storage/sealtasks"
+	"github.com/filecoin-project/sector-storage/stores"
 )
 
+// workers which haven't sent a heartbeat for this long are declared dead
+const workerDeadTimeout = 3 * api.WorkerHeartbeatInterval
+
+var ErrWorkerDead = xerrors.New("worker is dead")
+var ErrWorkerDraining = xerrors.New("worker is draining")
+
+// remoteWorker is a worker connected over RPC. The sector manager keeps the
+// worker once it's added. Dead or draining workers report no task types, so
+// the sector manager doesn't pick them for new tasks. Tasks which fail because
+// the worker died are retried by the sealing state machine, and scheduled on
+// the workers which are available then. When a dead worker reconnects, its
+// connection is replaced.
 type remoteWorker struct {
-	api.WorkerApi
-	closer jsonrpc.ClientCloser
+	url  string
+	jobs *workerJobs
+
+	closing   chan struct{}
+	closeOnce sync.Once
+
+	lk            sync.Mutex
+	wapi          api.WorkerApi
+	closer        jsonrpc.ClientCloser
+	lastHeartbeat time.Time
+	draining      bool
+	dead          bool
+
+	// calls is canceled when the worker is declared dead, aborting calls in
+	// progress
+	calls       context.Context
+	cancelCalls context.CancelFunc
 }
 
 func (r *remoteWorker) NewSector(ctx context.Context, sector abi.SectorID) error {
@@ -28,10 +59,213 @@ func (r *remoteWorker) AddPiece(ctx context.Context, sector abi.SectorID, pieceS
 	return abi.PieceInfo{}, xerrors.New("unsupported")
 }
 
-func connectRemoteWorker(ctx context.Context, fa api.Common, url string) (*remoteWorker, error) {
+func (r *remoteWorker) TaskTypes(ctx context.Context) (map[sealtasks.TaskType]struct{}, error) {
+	if !r.available() {
+		return map[sealtasks.TaskType]struct{}{}, nil
+	}
+
+	return r.conn().TaskTypes(ctx)
+}
+
+func (r *remoteWorker) Paths(ctx context.Context) ([]stores.StoragePath, error) {
+	return r.conn().Paths(ctx)
+}
+
+func (r *remoteWorker) Info(ctx context.Context) (sectorstorage.WorkerInfo, error) {
+	return r.conn().Info(ctx)
+}
+
+func (r *remoteWorker) SealPreCommit1(ctx context.Context, sector abi.SectorID, ticket abi.SealRandomness, pieces []abi.PieceInfo) (storage2.PreCommit1Out, error) {
+	ctx, wapi, finish, err := r.startJob(ctx, sector, sealtasks.TTPreCommit1)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := wapi.SealPreCommit1(ctx, sector, ticket, pieces)
+	return out, finish(err)
+}
+
+func (r *remoteWorker) SealPreCommit2(ctx context.Context, sector abi.SectorID, pc1o storage2.PreCommit1Out) (storage2.SectorCids, error) {
+	ctx, wapi, finish, err := r.startJob(ctx, sector, sealtasks.TTPreCommit2)
+	if err != nil {
+		return storage2.SectorCids{}, err
+	}
+
+	out, err := wapi.SealPreCommit2(ctx, sector, pc1o)
+	return out, finish(err)
+}
+
+func (r *remoteWorker) SealCommit1(ctx context.Context, sector abi.SectorID, ticket abi.SealRandomness, seed abi.InteractiveSealRandomness, pieces []abi.PieceInfo, cids storage2.SectorCids) (storage2.Commit1Out, error) {
+	ctx, wapi, finish, err := r.startJob(ctx, sector, sealtasks.TTCommit1)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := wapi.SealCommit1(ctx, sector, ticket, seed, pieces, cids)
+	return out, finish(err)
+}
+
+func (r *remoteWorker) SealCommit2(ctx context.Context, sector abi.SectorID, c1o storage2.Commit1Out) (storage2.Proof, error) {
+	ctx, wapi, finish, err := r.startJob(ctx, sector, sealtasks.TTCommit2)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := wapi.SealCommit2(ctx, sector, c1o)
+	return out, finish(err)
+}
+
+func (r *remoteWorker) FinalizeSector(ctx context.Context, sector abi.SectorID) error {
+	ctx, wapi, finish, err := r.startJob(ctx, sector, sealtasks.TTFinalize)
+	if err != nil {
+		return err
+	}
+
+	return finish(wapi.FinalizeSector(ctx, sector))
+}
+
+func (r *remoteWorker) conn() api.WorkerApi {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	return r.wapi
+}
+
+// available returns whether the worker accepts new jobs
+func (r *remoteWorker) available() bool {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	return !r.dead && !r.draining
+}
+
+// startJob checks that the worker accepts new jobs, and returns a context
+// canceled when the worker is declared dead, along with a function to call
+// with the result of the job once it's done. The function returns
+// ErrWorkerDead if the worker died while running the job.
+func (r *remoteWorker) startJob(ctx context.Context, sector abi.SectorID, task sealtasks.TaskType) (context.Context, api.WorkerApi, func(error) error, error) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	switch {
+	case r.dead:
+		return nil, nil, nil, xerrors.Errorf("%s: %w", r.url, ErrWorkerDead)
+	case r.draining:
+		return nil, nil, nil, xerrors.Errorf("%s: %w", r.url, ErrWorkerDraining)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	calls := r.calls
+	go func() {
+		select {
+		case <-calls.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	jobDone := r.jobs.start(r.url, sector, task)
+
+	return ctx, r.wapi, func(err error) error {
+		cancel()
+		jobDone()
+
+		if err != nil && calls.Err() != nil {
+			return xerrors.Errorf("%s: %w (%s)", r.url, ErrWorkerDead, err)
+		}
+		return err
+	}, nil
+}
+
+// reconnect replaces the connection to the worker, and brings it back to life
+func (r *remoteWorker) reconnect(wapi api.WorkerApi, closer jsonrpc.ClientCloser) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	r.closer()
+
+	r.wapi = wapi
+	r.closer = closer
+	r.draining = false
+	r.revive()
+}
+
+func (r *remoteWorker) heartbeat() {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	r.revive()
+}
+
+// Must be called with r.lk held.
+func (r *remoteWorker) revive() {
+	r.lastHeartbeat = time.Now()
+
+	if r.dead {
+		log.Infof("Worker %s is back", r.url)
+		r.dead = false
+		r.calls, r.cancelCalls = context.WithCancel(context.Background())
+	}
+}
+
+func (r *remoteWorker) setDraining(draining bool) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	r.draining = draining
+}
+
+func (r *remoteWorker) state() api.WorkerState {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	st := api.WorkerState{
+		State:         api.WorkerOk,
+		LastHeartbeat: r.lastHeartbeat,
+	}
+
+	switch {
+	case r.dead:
+		st.State = api.WorkerDead
+	case r.draining:
+		st.State = api.WorkerDraining
+	}
+
+	return st
+}
+
+// watchdog declares the worker dead when it stops sending heartbeats, until
+// the worker is closed
+func (r *remoteWorker) watchdog() {
+	t := time.NewTicker(api.WorkerHeartbeatInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+		case <-r.closing:
+			return
+		}
+
+		r.checkHeartbeat()
+	}
+}
+
+func (r *remoteWorker) checkHeartbeat() {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	if !r.dead && time.Since(r.lastHeartbeat) > workerDeadTimeout {
+		log.Warnf("Worker %s missed heartbeats since %s, declaring it dead", r.url, r.lastHeartbeat)
+		r.dead = true
+		r.cancelCalls()
+	}
+}
+
+func dialRemoteWorker(ctx context.Context, fa api.Common, url string) (api.WorkerApi, jsonrpc.ClientCloser, error) {
 	token, err := fa.AuthNew(ctx, []api.Permission{"admin"})
 	if err != nil {
-		return nil, xerrors.Errorf("creating auth token for remote connection: %w", err)
+		return nil, nil, xerrors.Errorf("creating auth token for remote connection: %w", err)
 	}
 
 	headers := http.Header{}
@@ -39,15 +273,84 @@ func connectRemoteWorker(ctx context.Context, fa api.Common, url string) (*remot
 
 	wapi, closer, err := client.NewWorkerRPC(url, headers)
 	if err != nil {
-		return nil, xerrors.Errorf("creating jsonrpc client: %w", err)
+		return nil, nil, xerrors.Errorf("creating jsonrpc client: %w", err)
 	}
 
-	return &remoteWorker{wapi, closer}, nil
+	return wapi, closer, nil
+}
+
+func connectRemoteWorker(ctx context.Context, fa api.Common, url string, jobs *workerJobs) (*remoteWorker, error) {
+	wapi, closer, err := dialRemoteWorker(ctx, fa, url)
+	if err != nil {
+		return nil, err
+	}
+
+	calls, cancelCalls := context.WithCancel(context.Background())
+
+	w := &remoteWorker{
+		url:  url,
+		jobs: jobs,
+
+		closing: make(chan struct{}),
+
+		wapi:          wapi,
+		closer:        closer,
+		lastHeartbeat: time.Now(),
+
+		calls:       calls,
+		cancelCalls: cancelCalls,
+	}
+	go w.watchdog()
+
+	return w, nil
 }
 
 func (r *remoteWorker) Close() error {
+	r.closeOnce.Do(func() {
+		close(r.closing)
+	})
+
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
 	r.closer()
 	return nil
 }
 
 var _ sectorstorage.Worker = &remoteWorker{}
+
+// remoteWorkers are the connected remote workers, by URL. The zero value is
+// ready to use.
+type remoteWorkers struct {
+	lk      sync.Mutex
+	workers map[string]*remoteWorker
+}
+
+func (rw *remoteWorkers) get(url string) *remoteWorker {
+	rw.lk.Lock()
+	defer rw.lk.Unlock()
+
+	return rw.workers[url]
+}
+
+func (rw *remoteWorkers) add(w *remoteWorker) {
+	rw.lk.Lock()
+	defer rw.lk.Unlock()
+
+	if rw.workers == nil {
+		rw.workers = map[string]*remoteWorker{}
+	}
+	rw.workers[w.url] = w
+}
+
+func (rw *remoteWorkers) states() map[string]api.WorkerState {
+	rw.lk.Lock()
+	defer rw.lk.Unlock()
+
+	out := make(map[string]api.WorkerState, len(rw.workers))
+	for url, w := range rw.workers {
+		out[url] = w.state()
+	}
+
+	return out
+}
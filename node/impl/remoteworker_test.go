@@ -0,0 +1,133 @@
+package impl
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/sector-storage/sealtasks"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+func testRemoteWorker() *remoteWorker {
+	calls, cancelCalls := context.WithCancel(context.Background())
+
+	return &remoteWorker{
+		url:  "http://worker",
+		jobs: &workerJobs{},
+
+		closing: make(chan struct{}),
+
+		closer:        func() {},
+		lastHeartbeat: time.Now(),
+
+		calls:       calls,
+		cancelCalls: cancelCalls,
+	}
+}
+
+func TestRemoteWorkerWatchdogStops(t *testing.T) {
+	w := testRemoteWorker()
+
+	stopped := make(chan struct{})
+	go func() {
+		w.watchdog()
+		close(stopped)
+	}()
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// closing twice is fine
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("watchdog didn't stop")
+	}
+}
+
+func TestRemoteWorkerDraining(t *testing.T) {
+	w := testRemoteWorker()
+
+	w.setDraining(true)
+	if w.available() {
+		t.Fatal("draining worker is available")
+	}
+
+	// the sector manager doesn't pick workers without task types
+	tt, err := w.TaskTypes(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tt) != 0 {
+		t.Errorf("expected no task types, got %v", tt)
+	}
+
+	_, _, _, err = w.startJob(context.TODO(), abi.SectorID{Number: 1}, sealtasks.TTPreCommit1)
+	if !xerrors.Is(err, ErrWorkerDraining) {
+		t.Fatalf("expected the job to be refused, got %v", err)
+	}
+
+	w.setDraining(false)
+	if !w.available() {
+		t.Fatal("worker isn't available after draining")
+	}
+}
+
+func TestRemoteWorkerDiesDuringJob(t *testing.T) {
+	w := testRemoteWorker()
+
+	ctx, _, finish, err := w.startJob(context.TODO(), abi.SectorID{Number: 1}, sealtasks.TTPreCommit1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jobs := w.jobs.list(); len(jobs[w.url]) != 1 {
+		t.Fatalf("expected a running job, got %v", jobs)
+	}
+
+	w.lk.Lock()
+	w.lastHeartbeat = time.Now().Add(-2 * workerDeadTimeout)
+	w.lk.Unlock()
+	w.checkHeartbeat()
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("job wasn't aborted")
+	}
+
+	err = finish(ctx.Err())
+	if !xerrors.Is(err, ErrWorkerDead) {
+		t.Fatalf("expected the job to fail with ErrWorkerDead, got %v", err)
+	}
+	if jobs := w.jobs.list(); len(jobs[w.url]) != 0 {
+		t.Errorf("expected no running jobs, got %v", jobs)
+	}
+	if w.available() {
+		t.Fatal("dead worker is available")
+	}
+	if tt, err := w.TaskTypes(context.TODO()); err != nil || len(tt) != 0 {
+		t.Errorf("expected no task types, got %v (%v)", tt, err)
+	}
+
+	w.heartbeat()
+	if !w.available() {
+		t.Error("worker isn't available after a heartbeat")
+	}
+
+	// errors of jobs started after the worker is back are returned as they are
+	_, _, finish, err = w.startJob(context.TODO(), abi.SectorID{Number: 2}, sealtasks.TTPreCommit1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobErr := xerrors.New("sealing failed")
+	if err := finish(jobErr); err != jobErr {
+		t.Errorf("expected the job error, got %v", err)
+	}
+}
@@ -3,8 +3,10 @@ package impl
 import (
 	"context"
 	"encoding/json"
+	"io/ioutil"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
 
 	"github.com/ipfs/go-cid"
@@ -20,25 +22,40 @@ import (
 	"github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/api/apistruct"
 	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/markets/storageadapter"
 	"github.com/filecoin-project/lotus/miner"
+	"github.com/filecoin-project/lotus/node/config"
 	"github.com/filecoin-project/lotus/node/impl/common"
 	"github.com/filecoin-project/lotus/storage"
 	"github.com/filecoin-project/lotus/storage/sectorblocks"
 	sealing "github.com/filecoin-project/storage-fsm"
 )
 
+// name of the metadata file in local storage paths
+const metaFile = "sectorstore.json"
+
 type StorageMinerAPI struct {
 	common.CommonAPI
 
 	ProofsConfig *ffiwrapper.Config
 	SectorBlocks *sectorblocks.SectorBlocks
+	Packer       *sectorblocks.Packer
 
 	StorageProvider storagemarket.StorageProvider
+	DealFilter      *storageadapter.DealFilter
 	Miner           *storage.Miner
+	SectorChecker   *storage.SectorChecker
+	SectorFiles     *storage.SectorFiles
+	FPoSt           *storage.FPoStScheduler
+	Pledge          *storage.PledgeScheduler
 	BlockMiner      *miner.Miner
 	Full            api.FullNode
 	StorageMgr      *sectorstorage.Manager `optional:"true"`
+	LocalStorage    stores.LocalStorage
 	*stores.Index
+
+	jobs    workerJobs
+	workers remoteWorkers
 }
 
 func (sm *StorageMinerAPI) ServeRemote(w http.ResponseWriter, r *http.Request) {
@@ -55,6 +72,10 @@ func (sm *StorageMinerAPI) WorkerStats(context.Context) (map[uint64]sectorstorag
 	return sm.StorageMgr.WorkerStats(), nil
 }
 
+func (sm *StorageMinerAPI) WorkerJobs(context.Context) (map[string][]api.WorkerJob, error) {
+	return sm.jobs.list(), nil
+}
+
 func (sm *StorageMinerAPI) ActorAddress(context.Context) (address.Address, error) {
 	return sm.Miner.Address(), nil
 }
@@ -67,6 +88,18 @@ func (sm *StorageMinerAPI) PledgeSector(ctx context.Context) error {
 	return sm.Miner.PledgeSector()
 }
 
+func (sm *StorageMinerAPI) PledgeGetConfig(context.Context) (config.PledgeConfig, error) {
+	return sm.Pledge.Config(), nil
+}
+
+func (sm *StorageMinerAPI) PledgeSetConfig(ctx context.Context, cfg config.PledgeConfig) error {
+	return sm.Pledge.SetConfig(cfg)
+}
+
+func (sm *StorageMinerAPI) PledgeStatus(context.Context) (api.PledgeStatus, error) {
+	return sm.Pledge.Status(), nil
+}
+
 func (sm *StorageMinerAPI) SectorsStatus(ctx context.Context, sid abi.SectorNumber) (api.SectorInfo, error) {
 	info, err := sm.Miner.GetSectorInfo(sid)
 	if err != nil {
@@ -155,15 +188,102 @@ func (sm *StorageMinerAPI) SectorsUpdate(ctx context.Context, id abi.SectorNumbe
 	return sm.Miner.ForceSectorState(ctx, id, state)
 }
 
+func (sm *StorageMinerAPI) SectorsPackingStatus(ctx context.Context) (api.PackingStatus, error) {
+	return sm.Packer.Status(ctx)
+}
+
+func (sm *StorageMinerAPI) SectorsSealNow(context.Context) error {
+	sm.Packer.SealNow()
+	return nil
+}
+
+func (sm *StorageMinerAPI) SectorsCheckHealth(ctx context.Context, sectors []abi.SectorNumber, sample bool) ([]api.SectorHealth, error) {
+	return sm.SectorChecker.Check(ctx, sectors, sample)
+}
+
+func (sm *StorageMinerAPI) SectorsRemove(ctx context.Context, sector abi.SectorNumber, force bool) error {
+	return sm.Miner.RemoveSector(ctx, sector, force)
+}
+
+func (sm *StorageMinerAPI) SectorsExtend(ctx context.Context, sectors []abi.SectorNumber, newExpiration abi.ChainEpoch) ([]cid.Cid, error) {
+	return sm.Miner.ExtendSectors(ctx, sectors, newExpiration)
+}
+
+func (sm *StorageMinerAPI) SectorsTerminate(ctx context.Context, sectors []abi.SectorNumber, force bool) (cid.Cid, error) {
+	return sm.Miner.TerminateSectors(ctx, sectors, force)
+}
+
+func (sm *StorageMinerAPI) SectorsMove(ctx context.Context, sector abi.SectorNumber, dest stores.ID) error {
+	return sm.Miner.MoveSector(ctx, sector, dest)
+}
+
+func (sm *StorageMinerAPI) SectorsMoves(context.Context) ([]api.SectorMove, error) {
+	return sm.SectorFiles.Moves(), nil
+}
+
+func (sm *StorageMinerAPI) ProvingStatus(ctx context.Context) (*api.ProvingStatus, error) {
+	return sm.FPoSt.Status(ctx)
+}
+
+func (sm *StorageMinerAPI) ProvingHistory(ctx context.Context, limit int) ([]api.PoStRecord, error) {
+	return sm.FPoSt.History(limit)
+}
+
+func (sm *StorageMinerAPI) ProvingDryRun(ctx context.Context) (*api.PoStRecord, error) {
+	return sm.FPoSt.DryRun(ctx)
+}
+
 func (sm *StorageMinerAPI) WorkerConnect(ctx context.Context, url string) error {
-	w, err := connectRemoteWorker(ctx, sm, url)
+	if w := sm.workers.get(url); w != nil {
+		// the sector manager already has the worker, only replace the
+		// connection
+		wapi, closer, err := dialRemoteWorker(ctx, sm, url)
+		if err != nil {
+			return xerrors.Errorf("connecting remote storage failed: %w", err)
+		}
+		w.reconnect(wapi, closer)
+
+		log.Infof("Reconnected to a remote worker at %s", url)
+		return nil
+	}
+
+	w, err := connectRemoteWorker(ctx, sm, url, &sm.jobs)
 	if err != nil {
 		return xerrors.Errorf("connecting remote storage failed: %w", err)
 	}
 
 	log.Infof("Connected to a remote worker at %s", url)
 
-	return sm.StorageMgr.AddWorker(ctx, w)
+	if err := sm.StorageMgr.AddWorker(ctx, w); err != nil {
+		return err
+	}
+
+	sm.workers.add(w)
+	return nil
+}
+
+func (sm *StorageMinerAPI) WorkerHeartbeat(ctx context.Context, url string) (bool, error) {
+	w := sm.workers.get(url)
+	if w == nil {
+		return false, nil
+	}
+
+	w.heartbeat()
+	return true, nil
+}
+
+func (sm *StorageMinerAPI) WorkerDrain(ctx context.Context, url string, drain bool) error {
+	w := sm.workers.get(url)
+	if w == nil {
+		return xerrors.Errorf("no worker connected at %s", url)
+	}
+
+	w.setDraining(drain)
+	return nil
+}
+
+func (sm *StorageMinerAPI) WorkerStates(context.Context) (map[string]api.WorkerState, error) {
+	return sm.workers.states(), nil
 }
 
 func (sm *StorageMinerAPI) MarketImportDealData(ctx context.Context, propCid cid.Cid, path string) error {
@@ -192,6 +312,14 @@ func (sm *StorageMinerAPI) DealsList(ctx context.Context) ([]storagemarket.Stora
 	return sm.StorageProvider.ListDeals(ctx)
 }
 
+func (sm *StorageMinerAPI) DealsGetPolicy(context.Context) (config.DealPolicy, error) {
+	return sm.DealFilter.Policy(), nil
+}
+
+func (sm *StorageMinerAPI) DealsSetPolicy(ctx context.Context, policy config.DealPolicy) error {
+	return sm.DealFilter.SetPolicy(policy)
+}
+
 func (sm *StorageMinerAPI) DealsImportData(ctx context.Context, deal cid.Cid, fname string) error {
 	fi, err := os.Open(fname)
 	if err != nil {
@@ -210,4 +338,107 @@ func (sm *StorageMinerAPI) StorageAddLocal(ctx context.Context, path string) err
 	return sm.StorageMgr.AddLocalStorage(ctx, path)
 }
 
+func (sm *StorageMinerAPI) StorageDetach(ctx context.Context, id stores.ID) error {
+	p, err := sm.localPath(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	decls, err := sm.StorageList(ctx)
+	if err != nil {
+		return xerrors.Errorf("listing storage: %w", err)
+	}
+	if n := len(decls[id]); n > 0 {
+		return xerrors.Errorf("storage %s has %d sector files, they have to be moved first", id, n)
+	}
+	for _, mv := range sm.SectorFiles.Moves() {
+		if mv.Stage != api.SectorMoveDone && mv.Stage != api.SectorMoveFailed && (mv.From == id || mv.To == id) {
+			return xerrors.Errorf("sector %d is being moved from %s to %s", mv.Sector.Number, mv.From, mv.To)
+		}
+	}
+
+	var found bool
+	err = sm.LocalStorage.SetStorage(func(sc *stores.StorageConfig) {
+		out := sc.StoragePaths[:0]
+		for _, lp := range sc.StoragePaths {
+			if lp.Path == p {
+				found = true
+				continue
+			}
+			out = append(out, lp)
+		}
+		sc.StoragePaths = out
+	})
+	if err != nil {
+		return xerrors.Errorf("updating storage config: %w", err)
+	}
+
+	if !found {
+		return xerrors.Errorf("path %s isn't in the storage config", p)
+	}
+
+	log.Infof("Detached storage %s at %s", id, p)
+	return nil
+}
+
+func (sm *StorageMinerAPI) StorageSetConfig(ctx context.Context, id stores.ID, cfg api.StoragePathConfig) error {
+	if !(cfg.CanSeal || cfg.CanStore) {
+		return xerrors.Errorf("storage has to be used for sealing or long-term storage")
+	}
+
+	p, err := sm.localPath(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	mf := filepath.Join(p, metaFile)
+
+	b, err := ioutil.ReadFile(mf)
+	if err != nil {
+		return xerrors.Errorf("reading storage metadata: %w", err)
+	}
+
+	var meta stores.LocalStorageMeta
+	if err := json.Unmarshal(b, &meta); err != nil {
+		return xerrors.Errorf("decoding storage metadata (%s): %w", mf, err)
+	}
+
+	if meta.ID != id {
+		return xerrors.Errorf("storage metadata in %s is for storage %s", mf, meta.ID)
+	}
+
+	meta.Weight = cfg.Weight
+	meta.CanSeal = cfg.CanSeal
+	meta.CanStore = cfg.CanStore
+
+	b, err = json.MarshalIndent(&meta, "", "  ")
+	if err != nil {
+		return xerrors.Errorf("marshaling storage metadata: %w", err)
+	}
+
+	if err := ioutil.WriteFile(mf+".tmp", b, 0644); err != nil {
+		return xerrors.Errorf("persisting storage metadata (%s): %w", mf, err)
+	}
+
+	return os.Rename(mf+".tmp", mf)
+}
+
+func (sm *StorageMinerAPI) localPath(ctx context.Context, id stores.ID) (string, error) {
+	if sm.StorageMgr == nil {
+		return "", xerrors.Errorf("no storage manager")
+	}
+
+	local, err := sm.StorageMgr.StorageLocal(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	p, ok := local[id]
+	if !ok {
+		return "", xerrors.Errorf("storage %s isn't attached to this machine", id)
+	}
+
+	return p, nil
+}
+
 var _ api.StorageMiner = &StorageMinerAPI{}
@@ -0,0 +1,73 @@
+package impl
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/sector-storage/sealtasks"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+
+	"github.com/filecoin-project/lotus/api"
+)
+
+// workerJobs tracks the jobs running on remote workers. The zero value is
+// ready to use.
+type workerJobs struct {
+	lk      sync.Mutex
+	next    uint64
+	running map[uint64]workerJob
+}
+
+type workerJob struct {
+	worker string
+	api.WorkerJob
+}
+
+// start records a job, and returns a function to call once the job is done
+func (wj *workerJobs) start(worker string, sector abi.SectorID, task sealtasks.TaskType) func() {
+	wj.lk.Lock()
+	defer wj.lk.Unlock()
+
+	if wj.running == nil {
+		wj.running = map[uint64]workerJob{}
+	}
+
+	id := wj.next
+	wj.next++
+
+	wj.running[id] = workerJob{
+		worker: worker,
+		WorkerJob: api.WorkerJob{
+			ID:     id,
+			Sector: sector,
+			Task:   task,
+			Start:  time.Now(),
+		},
+	}
+
+	return func() {
+		wj.lk.Lock()
+		delete(wj.running, id)
+		wj.lk.Unlock()
+	}
+}
+
+// list returns the running jobs by worker, oldest first
+func (wj *workerJobs) list() map[string][]api.WorkerJob {
+	wj.lk.Lock()
+	defer wj.lk.Unlock()
+
+	out := map[string][]api.WorkerJob{}
+	for _, job := range wj.running {
+		out[job.worker] = append(out[job.worker], job.WorkerJob)
+	}
+
+	for _, jobs := range out {
+		sort.Slice(jobs, func(i, j int) bool {
+			return jobs[i].ID < jobs[j].ID
+		})
+	}
+
+	return out
+}
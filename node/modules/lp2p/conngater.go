@@ -0,0 +1,379 @@
+package lp2p
+
+import (
+	"encoding/json"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p-core/connmgr"
+	"github.com/libp2p/go-libp2p-core/control"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/node/modules/dtypes"
+)
+
+const (
+	gaterPeerPrefix   = "/peer"
+	gaterAddrPrefix   = "/addr"
+	gaterSubnetPrefix = "/subnet"
+
+	// peers blocked by the node itself, e.g. for sending bad blocks
+	gaterAutoPeerPrefix = "/autopeer"
+)
+
+// ConnGater is a libp2p connection gater with a persistent block list of
+// peers, IP addresses and subnets, managed by the node operator.
+//
+// Peers can also be blocked for a limited time with BlockPeerFor, which is
+// used by the node to ban misbehaving peers. These temporary blocks are kept
+// separately from the operator's block list, and expire on their own.
+type ConnGater struct {
+	lk sync.RWMutex
+
+	ds      datastore.Datastore
+	filters *ma.Filters
+	host    host.Host
+
+	peers   map[peer.ID]struct{}
+	addrs   map[string]net.IP
+	subnets map[string]*net.IPNet
+
+	autoPeers map[peer.ID]time.Time
+
+	now func() time.Time
+}
+
+type autoBlock struct {
+	Peer  peer.ID
+	Until time.Time
+}
+
+var _ connmgr.ConnectionGater = (*ConnGater)(nil)
+
+func NewConnGater(ds dtypes.MetadataDS) (*ConnGater, error) {
+	return newConnGater(ds, time.Now)
+}
+
+func newConnGater(ds datastore.Batching, now func() time.Time) (*ConnGater, error) {
+	cg := &ConnGater{
+		ds:      namespace.Wrap(ds, datastore.NewKey("/libp2p/gater")),
+		filters: ma.NewFilters(),
+
+		peers:   map[peer.ID]struct{}{},
+		addrs:   map[string]net.IP{},
+		subnets: map[string]*net.IPNet{},
+
+		autoPeers: map[peer.ID]time.Time{},
+
+		now: now,
+	}
+
+	if err := cg.load(); err != nil {
+		return nil, xerrors.Errorf("loading connection gater state: %w", err)
+	}
+
+	return cg, nil
+}
+
+// ConnectionGater installs the gater into the libp2p host
+func ConnectionGater(cg *ConnGater) (opts Libp2pOpts, err error) {
+	opts.Opts = append(opts.Opts, libp2p.ConnectionGater(cg))
+	return opts, nil
+}
+
+// RunConnGater gives the gater access to the host network, so that existing
+// connections can be closed when a peer or an address is blocked
+func RunConnGater(h host.Host, cg *ConnGater) {
+	cg.lk.Lock()
+	cg.host = h
+	cg.lk.Unlock()
+}
+
+func (cg *ConnGater) load() error {
+	res, err := cg.ds.Query(query.Query{})
+	if err != nil {
+		return err
+	}
+
+	entries, err := res.Rest()
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		k := datastore.NewKey(e.Key)
+		v := string(e.Value)
+
+		switch "/" + k.List()[0] {
+		case gaterPeerPrefix:
+			p, err := peer.IDB58Decode(v)
+			if err != nil {
+				return xerrors.Errorf("parsing blocked peer %q: %w", v, err)
+			}
+			cg.peers[p] = struct{}{}
+		case gaterAddrPrefix:
+			ip := net.ParseIP(v)
+			if ip == nil {
+				return xerrors.Errorf("parsing blocked address %q", v)
+			}
+			cg.addrs[ip.String()] = ip
+			cg.filters.AddFilter(ipNet(ip), ma.ActionDeny)
+		case gaterSubnetPrefix:
+			_, ipnet, err := net.ParseCIDR(v)
+			if err != nil {
+				return xerrors.Errorf("parsing blocked subnet %q: %w", v, err)
+			}
+			cg.subnets[ipnet.String()] = ipnet
+			cg.filters.AddFilter(*ipnet, ma.ActionDeny)
+		case gaterAutoPeerPrefix:
+			var ab autoBlock
+			if err := json.Unmarshal(e.Value, &ab); err != nil {
+				return xerrors.Errorf("parsing temporarily blocked peer %s: %w", e.Key, err)
+			}
+			if !ab.Until.After(cg.now()) {
+				if err := cg.ds.Delete(k); err != nil {
+					return xerrors.Errorf("removing expired peer block: %w", err)
+				}
+				continue
+			}
+			cg.autoPeers[ab.Peer] = ab.Until
+		default:
+			log.Warnf("unknown connection gater entry: %s", e.Key)
+		}
+	}
+
+	return nil
+}
+
+func gaterKey(prefix string, v string) datastore.Key {
+	// subnets contain a '/', which would otherwise be treated as a key separator
+	return datastore.NewKey(prefix).ChildString(strings.ReplaceAll(v, "/", "_"))
+}
+
+func ipNet(ip net.IP) net.IPNet {
+	if ip4 := ip.To4(); ip4 != nil {
+		return net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}
+	}
+	return net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}
+}
+
+func (cg *ConnGater) BlockPeer(p peer.ID) error {
+	cg.lk.Lock()
+	if err := cg.ds.Put(gaterKey(gaterPeerPrefix, p.Pretty()), []byte(p.Pretty())); err != nil {
+		cg.lk.Unlock()
+		return xerrors.Errorf("persisting blocked peer: %w", err)
+	}
+
+	cg.peers[p] = struct{}{}
+	cg.lk.Unlock()
+
+	cg.closeBlocked()
+	return nil
+}
+
+// BlockPeerFor blocks a peer for the given duration. The block doesn't show
+// up in the block list, and is lifted by UnblockPeer or when it expires.
+func (cg *ConnGater) BlockPeerFor(p peer.ID, d time.Duration) error {
+	cg.lk.Lock()
+	until := cg.now().Add(d)
+	if cur, ok := cg.autoPeers[p]; ok && cur.After(until) {
+		cg.lk.Unlock()
+		return nil
+	}
+
+	b, err := json.Marshal(&autoBlock{Peer: p, Until: until})
+	if err != nil {
+		cg.lk.Unlock()
+		return err
+	}
+	if err := cg.ds.Put(gaterKey(gaterAutoPeerPrefix, p.Pretty()), b); err != nil {
+		cg.lk.Unlock()
+		return xerrors.Errorf("persisting temporarily blocked peer: %w", err)
+	}
+
+	cg.autoPeers[p] = until
+	cg.lk.Unlock()
+
+	cg.closeBlocked()
+	return nil
+}
+
+// UnblockPeer removes the peer from the block list, and lifts its temporary
+// block, if any
+func (cg *ConnGater) UnblockPeer(p peer.ID) error {
+	cg.lk.Lock()
+	defer cg.lk.Unlock()
+
+	if err := cg.ds.Delete(gaterKey(gaterPeerPrefix, p.Pretty())); err != nil {
+		return xerrors.Errorf("removing blocked peer: %w", err)
+	}
+	if err := cg.ds.Delete(gaterKey(gaterAutoPeerPrefix, p.Pretty())); err != nil {
+		return xerrors.Errorf("removing temporarily blocked peer: %w", err)
+	}
+
+	delete(cg.peers, p)
+	delete(cg.autoPeers, p)
+	return nil
+}
+
+func (cg *ConnGater) BlockAddr(ip net.IP) error {
+	cg.lk.Lock()
+	if err := cg.ds.Put(gaterKey(gaterAddrPrefix, ip.String()), []byte(ip.String())); err != nil {
+		cg.lk.Unlock()
+		return xerrors.Errorf("persisting blocked address: %w", err)
+	}
+
+	cg.addrs[ip.String()] = ip
+	cg.filters.AddFilter(ipNet(ip), ma.ActionDeny)
+	cg.lk.Unlock()
+
+	cg.closeBlocked()
+	return nil
+}
+
+func (cg *ConnGater) UnblockAddr(ip net.IP) error {
+	cg.lk.Lock()
+	defer cg.lk.Unlock()
+
+	if err := cg.ds.Delete(gaterKey(gaterAddrPrefix, ip.String())); err != nil {
+		return xerrors.Errorf("removing blocked address: %w", err)
+	}
+
+	delete(cg.addrs, ip.String())
+	cg.filters.RemoveLiteral(ipNet(ip))
+	return nil
+}
+
+func (cg *ConnGater) BlockSubnet(ipnet *net.IPNet) error {
+	cg.lk.Lock()
+	if err := cg.ds.Put(gaterKey(gaterSubnetPrefix, ipnet.String()), []byte(ipnet.String())); err != nil {
+		cg.lk.Unlock()
+		return xerrors.Errorf("persisting blocked subnet: %w", err)
+	}
+
+	cg.subnets[ipnet.String()] = ipnet
+	cg.filters.AddFilter(*ipnet, ma.ActionDeny)
+	cg.lk.Unlock()
+
+	cg.closeBlocked()
+	return nil
+}
+
+func (cg *ConnGater) UnblockSubnet(ipnet *net.IPNet) error {
+	cg.lk.Lock()
+	defer cg.lk.Unlock()
+
+	if err := cg.ds.Delete(gaterKey(gaterSubnetPrefix, ipnet.String())); err != nil {
+		return xerrors.Errorf("removing blocked subnet: %w", err)
+	}
+
+	delete(cg.subnets, ipnet.String())
+	cg.filters.RemoveLiteral(*ipnet)
+	return nil
+}
+
+// PeerBlocked returns true if the peer is in the block list, or blocked
+// temporarily
+func (cg *ConnGater) PeerBlocked(p peer.ID) bool {
+	cg.lk.RLock()
+	defer cg.lk.RUnlock()
+
+	if _, ok := cg.peers[p]; ok {
+		return true
+	}
+
+	until, ok := cg.autoPeers[p]
+	return ok && until.After(cg.now())
+}
+
+func (cg *ConnGater) AddrBlocked(a ma.Multiaddr) bool {
+	cg.lk.RLock()
+	defer cg.lk.RUnlock()
+
+	return cg.filters.AddrBlocked(a)
+}
+
+func (cg *ConnGater) ListBlockedPeers() []peer.ID {
+	cg.lk.RLock()
+	defer cg.lk.RUnlock()
+
+	out := make([]peer.ID, 0, len(cg.peers))
+	for p := range cg.peers {
+		out = append(out, p)
+	}
+	return out
+}
+
+func (cg *ConnGater) ListBlockedAddrs() []net.IP {
+	cg.lk.RLock()
+	defer cg.lk.RUnlock()
+
+	out := make([]net.IP, 0, len(cg.addrs))
+	for _, ip := range cg.addrs {
+		out = append(out, ip)
+	}
+	return out
+}
+
+func (cg *ConnGater) ListBlockedSubnets() []*net.IPNet {
+	cg.lk.RLock()
+	defer cg.lk.RUnlock()
+
+	out := make([]*net.IPNet, 0, len(cg.subnets))
+	for _, ipnet := range cg.subnets {
+		out = append(out, ipnet)
+	}
+	return out
+}
+
+func (cg *ConnGater) InterceptPeerDial(p peer.ID) bool {
+	return !cg.PeerBlocked(p)
+}
+
+func (cg *ConnGater) InterceptAddrDial(p peer.ID, a ma.Multiaddr) bool {
+	return !cg.PeerBlocked(p) && !cg.AddrBlocked(a)
+}
+
+func (cg *ConnGater) InterceptAccept(cma network.ConnMultiaddrs) bool {
+	return !cg.AddrBlocked(cma.RemoteMultiaddr())
+}
+
+func (cg *ConnGater) InterceptSecured(_ network.Direction, p peer.ID, cma network.ConnMultiaddrs) bool {
+	return !cg.PeerBlocked(p) && !cg.AddrBlocked(cma.RemoteMultiaddr())
+}
+
+func (cg *ConnGater) InterceptUpgraded(network.Conn) (bool, control.DisconnectReason) {
+	return true, 0
+}
+
+func (cg *ConnGater) connBlocked(c network.Conn) bool {
+	return cg.PeerBlocked(c.RemotePeer()) || cg.AddrBlocked(c.RemoteMultiaddr())
+}
+
+func (cg *ConnGater) closeBlocked() {
+	cg.lk.RLock()
+	h := cg.host
+	cg.lk.RUnlock()
+
+	if h == nil {
+		return
+	}
+
+	for _, c := range h.Network().Conns() {
+		if cg.connBlocked(c) {
+			if err := c.Close(); err != nil {
+				log.Warnf("closing connection to blocked peer %s: %s", c.RemotePeer(), err)
+			}
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package lp2p
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	tpeer "github.com/libp2p/go-libp2p-core/test"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+type testConnAddrs struct {
+	remote ma.Multiaddr
+}
+
+func (c testConnAddrs) LocalMultiaddr() ma.Multiaddr {
+	return ma.StringCast("/ip4/127.0.0.1/tcp/1347")
+}
+
+func (c testConnAddrs) RemoteMultiaddr() ma.Multiaddr {
+	return c.remote
+}
+
+var _ network.ConnMultiaddrs = testConnAddrs{}
+
+func newTestGater(t *testing.T, ds datastore.Batching, now time.Time) *ConnGater {
+	cg, err := newConnGater(ds, func() time.Time { return now })
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cg
+}
+
+func TestConnGaterPersist(t *testing.T) {
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	now := time.Now()
+
+	p := tpeer.RandPeerIDFatal(t)
+	auto := tpeer.RandPeerIDFatal(t)
+	ip := net.ParseIP("1.2.3.4")
+	_, subnet, err := net.ParseCIDR("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cg := newTestGater(t, ds, now)
+	if err := cg.BlockPeer(p); err != nil {
+		t.Fatal(err)
+	}
+	if err := cg.BlockAddr(ip); err != nil {
+		t.Fatal(err)
+	}
+	if err := cg.BlockSubnet(subnet); err != nil {
+		t.Fatal(err)
+	}
+	if err := cg.BlockPeerFor(auto, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	// everything is loaded back from the datastore
+	cg = newTestGater(t, ds, now)
+	if peers := cg.ListBlockedPeers(); len(peers) != 1 || peers[0] != p {
+		t.Errorf("expected only %s in the block list, got %v", p, peers)
+	}
+	if addrs := cg.ListBlockedAddrs(); len(addrs) != 1 || !addrs[0].Equal(ip) {
+		t.Errorf("expected only %s in the blocked addresses, got %v", ip, addrs)
+	}
+	if subnets := cg.ListBlockedSubnets(); len(subnets) != 1 || subnets[0].String() != subnet.String() {
+		t.Errorf("expected only %s in the blocked subnets, got %v", subnet, subnets)
+	}
+	for _, bp := range []peer.ID{p, auto} {
+		if !cg.PeerBlocked(bp) {
+			t.Errorf("expected %s to be blocked", bp)
+		}
+	}
+	for _, a := range []string{"/ip4/1.2.3.4/tcp/1", "/ip4/10.1.2.3/tcp/1"} {
+		if !cg.AddrBlocked(ma.StringCast(a)) {
+			t.Errorf("expected %s to be blocked", a)
+		}
+	}
+
+	if err := cg.UnblockPeer(p); err != nil {
+		t.Fatal(err)
+	}
+	if err := cg.UnblockPeer(auto); err != nil {
+		t.Fatal(err)
+	}
+	if err := cg.UnblockAddr(ip); err != nil {
+		t.Fatal(err)
+	}
+	if err := cg.UnblockSubnet(subnet); err != nil {
+		t.Fatal(err)
+	}
+
+	cg = newTestGater(t, ds, now)
+	if len(cg.ListBlockedPeers())+len(cg.ListBlockedAddrs())+len(cg.ListBlockedSubnets()) != 0 {
+		t.Error("expected the block lists to be empty")
+	}
+	for _, bp := range []peer.ID{p, auto} {
+		if cg.PeerBlocked(bp) {
+			t.Errorf("expected %s to be unblocked", bp)
+		}
+	}
+	for _, a := range []string{"/ip4/1.2.3.4/tcp/1", "/ip4/10.1.2.3/tcp/1"} {
+		if cg.AddrBlocked(ma.StringCast(a)) {
+			t.Errorf("expected %s to be unblocked", a)
+		}
+	}
+}
+
+func TestConnGaterIntercept(t *testing.T) {
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	cg := newTestGater(t, ds, time.Now())
+
+	p := tpeer.RandPeerIDFatal(t)
+	other := tpeer.RandPeerIDFatal(t)
+	blockedAddr := ma.StringCast("/ip4/1.2.3.4/tcp/1")
+	addr := ma.StringCast("/ip4/1.2.3.5/tcp/1")
+
+	if err := cg.BlockPeer(p); err != nil {
+		t.Fatal(err)
+	}
+	if err := cg.BlockAddr(net.ParseIP("1.2.3.4")); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(what string, allow, expect bool) {
+		t.Helper()
+		if allow != expect {
+			t.Errorf("%s: expected allow=%t, got %t", what, expect, allow)
+		}
+	}
+
+	check("dial blocked peer", cg.InterceptPeerDial(p), false)
+	check("dial peer", cg.InterceptPeerDial(other), true)
+	check("dial blocked peer address", cg.InterceptAddrDial(p, addr), false)
+	check("dial blocked address", cg.InterceptAddrDial(other, blockedAddr), false)
+	check("dial address", cg.InterceptAddrDial(other, addr), true)
+	check("accept blocked address", cg.InterceptAccept(testConnAddrs{blockedAddr}), false)
+	check("accept address", cg.InterceptAccept(testConnAddrs{addr}), true)
+	check("secured blocked peer", cg.InterceptSecured(network.DirInbound, p, testConnAddrs{addr}), false)
+	check("secured blocked address", cg.InterceptSecured(network.DirOutbound, other, testConnAddrs{blockedAddr}), false)
+	check("secured", cg.InterceptSecured(network.DirInbound, other, testConnAddrs{addr}), true)
+
+	if err := cg.UnblockPeer(p); err != nil {
+		t.Fatal(err)
+	}
+	check("dial unblocked peer", cg.InterceptPeerDial(p), true)
+	check("secured unblocked peer", cg.InterceptSecured(network.DirInbound, p, testConnAddrs{addr}), true)
+}
+
+func TestConnGaterBlockPeerFor(t *testing.T) {
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	now := time.Now()
+	cg := newTestGater(t, ds, now)
+
+	p := tpeer.RandPeerIDFatal(t)
+	if err := cg.BlockPeerFor(p, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if !cg.PeerBlocked(p) || cg.InterceptPeerDial(p) {
+		t.Fatal("expected the peer to be blocked")
+	}
+	if len(cg.ListBlockedPeers()) != 0 {
+		t.Error("expected temporary blocks to be kept out of the block list")
+	}
+
+	// a shorter block doesn't cut an existing one short
+	if err := cg.BlockPeerFor(p, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	cg.now = func() time.Time { return now.Add(30 * time.Minute) }
+	if !cg.PeerBlocked(p) {
+		t.Error("expected the peer to still be blocked")
+	}
+
+	cg.now = func() time.Time { return now.Add(2 * time.Hour) }
+	if cg.PeerBlocked(p) || !cg.InterceptPeerDial(p) {
+		t.Error("expected the block to expire")
+	}
+
+	// expired blocks are dropped when loading
+	cg = newTestGater(t, ds, now.Add(2*time.Hour))
+	if _, ok := cg.autoPeers[p]; ok {
+		t.Error("expected the expired block not to be loaded")
+	}
+	has, err := ds.Has(datastore.NewKey("/libp2p/gater").Child(gaterKey(gaterAutoPeerPrefix, p.Pretty())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if has {
+		t.Error("expected the expired block to be removed from the datastore")
+	}
+}
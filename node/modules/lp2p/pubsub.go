@@ -2,13 +2,17 @@ package lp2p
 
 import (
 	"context"
+	"time"
 
 	host "github.com/libp2p/go-libp2p-core/host"
 	peer "github.com/libp2p/go-libp2p-core/peer"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	ma "github.com/multiformats/go-multiaddr"
 	"go.uber.org/fx"
+	"golang.org/x/xerrors"
 
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/node/modules/dtypes"
 	"github.com/filecoin-project/lotus/node/modules/helpers"
 )
 
@@ -30,9 +34,146 @@ func PubsubTracer() PubsubOpt {
 	}
 }
 
-func GossipSub(pubsubOptions ...PubsubOpt) interface{} {
-	return func(mctx helpers.MetricsCtx, lc fx.Lifecycle, host host.Host) (service *pubsub.PubSub, err error) {
-		return pubsub.NewGossipSub(helpers.LifecycleCtx(mctx, lc), host, paresOpts(host, pubsubOptions)...)
+func ScoreKeeper() *dtypes.ScoreKeeper {
+	return new(dtypes.ScoreKeeper)
+}
+
+// GossipSub constructs the gossipsub router with peer scoring enabled on the
+// filecoin topics. Protected peers and the builtin bootstrappers get a
+// positive application-specific score so that they are never pruned from
+// the mesh for being quiet.
+func GossipSub(protected []string, pubsubOptions ...PubsubOpt) interface{} {
+	return func(mctx helpers.MetricsCtx, lc fx.Lifecycle, host host.Host, nn dtypes.NetworkName, sk *dtypes.ScoreKeeper) (service *pubsub.PubSub, err error) {
+		whitelist, err := scoreWhitelist(protected)
+		if err != nil {
+			return nil, err
+		}
+
+		options := []pubsub.Option{
+			pubsub.WithPeerScore(peerScoreParams(nn, whitelist), peerScoreThresholds()),
+			pubsub.WithPeerScoreInspect(sk.Update, 10*time.Second),
+		}
+		options = append(options, paresOpts(host, pubsubOptions)...)
+
+		return pubsub.NewGossipSub(helpers.LifecycleCtx(mctx, lc), host, options...)
+	}
+}
+
+func scoreWhitelist(protected []string) (map[peer.ID]struct{}, error) {
+	out := map[peer.ID]struct{}{}
+
+	for _, p := range protected {
+		pid, err := peer.IDFromString(p)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to parse peer ID in protected peers array: %w", err)
+		}
+
+		out[pid] = struct{}{}
+	}
+
+	infos, err := build.BuiltinBootstrap()
+	if err != nil {
+		return nil, xerrors.Errorf("failed to get bootstrap peers: %w", err)
+	}
+
+	for _, inf := range infos {
+		out[inf.ID] = struct{}{}
+	}
+
+	return out, nil
+}
+
+func peerScoreParams(nn dtypes.NetworkName, whitelist map[peer.ID]struct{}) *pubsub.PeerScoreParams {
+	return &pubsub.PeerScoreParams{
+		AppSpecificScore: func(p peer.ID) float64 {
+			if _, ok := whitelist[p]; ok {
+				return 2500
+			}
+			return 0
+		},
+		AppSpecificWeight: 1,
+
+		// don't let a single IP flood the mesh with sybils
+		IPColocationFactorThreshold: 5,
+		IPColocationFactorWeight:    -100,
+
+		BehaviourPenaltyWeight: -10,
+		BehaviourPenaltyDecay:  pubsub.ScoreParameterDecay(time.Hour),
+
+		DecayInterval: pubsub.DefaultDecayInterval,
+		DecayToZero:   pubsub.DefaultDecayToZero,
+
+		// remember scores of disconnected peers, so reconnecting doesn't
+		// reset penalties
+		RetainScore: 6 * time.Hour,
+
+		Topics: map[string]*pubsub.TopicScoreParams{
+			build.BlocksTopic(nn): {
+				// max positive contribution is 50, a single invalid block is -100
+				TopicWeight: 0.1,
+
+				TimeInMeshWeight:  0.00027, // ~1/3600
+				TimeInMeshQuantum: time.Second,
+				TimeInMeshCap:     1,
+
+				// rewards peers which deliver new blocks to us first
+				FirstMessageDeliveriesWeight: 5,
+				FirstMessageDeliveriesDecay:  pubsub.ScoreParameterDecay(10 * time.Hour),
+				FirstMessageDeliveriesCap:    100, // ~100 blocks in an hour
+
+				// mesh peers are expected to deliver at least some of the
+				// blocks in each epoch
+				MeshMessageDeliveriesWeight:     -1,
+				MeshMessageDeliveriesDecay:      pubsub.ScoreParameterDecay(time.Hour),
+				MeshMessageDeliveriesCap:        100,
+				MeshMessageDeliveriesThreshold:  5,
+				MeshMessageDeliveriesWindow:     10 * time.Millisecond,
+				MeshMessageDeliveriesActivation: 10 * time.Minute,
+
+				MeshFailurePenaltyWeight: -1,
+				MeshFailurePenaltyDecay:  pubsub.ScoreParameterDecay(time.Hour),
+
+				// fed by rejections from the block validator
+				InvalidMessageDeliveriesWeight: -1000,
+				InvalidMessageDeliveriesDecay:  pubsub.ScoreParameterDecay(time.Hour),
+			},
+			build.MessagesTopic(nn): {
+				// max positive contribution is 5, a single invalid message is -100
+				TopicWeight: 0.1,
+
+				TimeInMeshWeight:  0.0002778, // ~1/3600
+				TimeInMeshQuantum: time.Second,
+				TimeInMeshCap:     1,
+
+				FirstMessageDeliveriesWeight: 0.5,
+				FirstMessageDeliveriesDecay:  pubsub.ScoreParameterDecay(10 * time.Minute),
+				FirstMessageDeliveriesCap:    100, // ~100 messages in 10 minutes
+
+				// message traffic is too bursty to penalize quiet mesh peers
+				MeshMessageDeliveriesWeight:     0,
+				MeshMessageDeliveriesDecay:      pubsub.ScoreParameterDecay(time.Hour),
+				MeshMessageDeliveriesCap:        100,
+				MeshMessageDeliveriesThreshold:  1,
+				MeshMessageDeliveriesWindow:     10 * time.Millisecond,
+				MeshMessageDeliveriesActivation: 10 * time.Minute,
+
+				MeshFailurePenaltyWeight: 0,
+				MeshFailurePenaltyDecay:  pubsub.ScoreParameterDecay(time.Hour),
+
+				// fed by rejections from the message validator
+				InvalidMessageDeliveriesWeight: -1000,
+				InvalidMessageDeliveriesDecay:  pubsub.ScoreParameterDecay(time.Hour),
+			},
+		},
+	}
+}
+
+func peerScoreThresholds() *pubsub.PeerScoreThresholds {
+	return &pubsub.PeerScoreThresholds{
+		GossipThreshold:   -500,
+		PublishThreshold:  -1000,
+		GraylistThreshold: -2500,
+		AcceptPXThreshold: 1000,
 	}
 }
 
@@ -0,0 +1,20 @@
+package modules
+
+import (
+	"context"
+
+	"go.uber.org/fx"
+
+	"github.com/filecoin-project/lotus/node/modules/helpers"
+	"github.com/filecoin-project/lotus/paychmgr"
+)
+
+func RunPaychManager(mctx helpers.MetricsCtx, lc fx.Lifecycle, pm *paychmgr.Manager) {
+	ctx := helpers.LifecycleCtx(mctx, lc)
+
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			return pm.Start(ctx)
+		},
+	})
+}
@@ -19,16 +19,20 @@ import (
 	"github.com/filecoin-project/lotus/chain"
 	"github.com/filecoin-project/lotus/chain/beacon"
 	"github.com/filecoin-project/lotus/chain/blocksync"
+	"github.com/filecoin-project/lotus/chain/market"
 	"github.com/filecoin-project/lotus/chain/messagepool"
 	"github.com/filecoin-project/lotus/chain/sub"
 	"github.com/filecoin-project/lotus/lib/peermgr"
+	"github.com/filecoin-project/lotus/markets/storageadapter"
 	"github.com/filecoin-project/lotus/node/hello"
 	"github.com/filecoin-project/lotus/node/modules/dtypes"
 	"github.com/filecoin-project/lotus/node/modules/helpers"
+	"github.com/filecoin-project/lotus/node/modules/lp2p"
 )
 
 func RunHello(mctx helpers.MetricsCtx, lc fx.Lifecycle, h host.Host, svc *hello.Service) error {
 	h.SetStreamHandler(hello.ProtocolID, svc.HandleStream)
+	h.SetStreamHandler(hello.ProtocolIDv2, svc.HandleStream)
 
 	sub, err := h.EventBus().Subscribe(new(event.EvtPeerIdentificationCompleted), eventbus.BufSize(1024))
 	if err != nil {
@@ -57,7 +61,10 @@ func RunBlockSync(h host.Host, svc *blocksync.BlockSyncService) {
 	h.SetStreamHandler(blocksync.BlockSyncProtocolID, svc.HandleStream)
 }
 
-func HandleIncomingBlocks(mctx helpers.MetricsCtx, lc fx.Lifecycle, ps *pubsub.PubSub, s *chain.Syncer, h host.Host, nn dtypes.NetworkName) {
+// badBlockPeerBlockTime is how long peers sending invalid blocks are blocked
+const badBlockPeerBlockTime = 24 * time.Hour
+
+func HandleIncomingBlocks(mctx helpers.MetricsCtx, lc fx.Lifecycle, ps *pubsub.PubSub, s *chain.Syncer, h host.Host, nn dtypes.NetworkName, cg *lp2p.ConnGater) {
 	ctx := helpers.LifecycleCtx(mctx, lc)
 
 	blocksub, err := ps.Subscribe(build.BlocksTopic(nn))
@@ -68,6 +75,11 @@ func HandleIncomingBlocks(mctx helpers.MetricsCtx, lc fx.Lifecycle, ps *pubsub.P
 	v := sub.NewBlockValidator(func(p peer.ID) {
 		ps.BlacklistPeer(p)
 		h.ConnManager().TagPeer(p, "badblock", -1000)
+
+		// keep the peer disconnected for a while, also across restarts
+		if err := cg.BlockPeerFor(p, badBlockPeerBlockTime); err != nil {
+			log.Errorf("failed to block peer %s: %s", p, err)
+		}
 	})
 
 	if err := ps.RegisterTopicValidator(build.BlocksTopic(nn), v.Validate); err != nil {
@@ -94,12 +106,17 @@ func HandleIncomingMessages(mctx helpers.MetricsCtx, lc fx.Lifecycle, ps *pubsub
 	go sub.HandleIncomingMessages(ctx, mpool, msgsub)
 }
 
-func RunDealClient(mctx helpers.MetricsCtx, lc fx.Lifecycle, c storagemarket.StorageClient) {
+// dealFundsReleaseInterval is how often fund reservations of finished client
+// deals are released
+const dealFundsReleaseInterval = time.Minute
+
+func RunDealClient(mctx helpers.MetricsCtx, lc fx.Lifecycle, c storagemarket.StorageClient, fm *market.FundMgr) {
 	ctx := helpers.LifecycleCtx(mctx, lc)
 
 	lc.Append(fx.Hook{
 		OnStart: func(context.Context) error {
 			c.Run(ctx)
+			go releaseDealFunds(ctx, c, fm)
 			return nil
 		},
 		OnStop: func(context.Context) error {
@@ -109,6 +126,26 @@ func RunDealClient(mctx helpers.MetricsCtx, lc fx.Lifecycle, c storagemarket.Sto
 	})
 }
 
+func releaseDealFunds(ctx context.Context, c storagemarket.StorageClient, fm *market.FundMgr) {
+	t := time.NewTicker(dealFundsReleaseInterval)
+	defer t.Stop()
+
+	for {
+		deals, err := c.ListLocalDeals(ctx)
+		if err != nil {
+			log.Errorf("listing client deals: %s", err)
+		} else {
+			storageadapter.ReleaseDealFunds(fm, deals)
+		}
+
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
 func NewLocalDiscovery(ds dtypes.MetadataDS) *discovery.Local {
 	return discovery.NewLocal(ds)
 }
@@ -3,6 +3,7 @@ package modules
 import (
 	"context"
 	"net/http"
+	"path/filepath"
 	"reflect"
 
 	"github.com/ipfs/go-bitswap"
@@ -46,11 +47,14 @@ import (
 	"github.com/filecoin-project/lotus/chain/gen"
 	"github.com/filecoin-project/lotus/chain/types"
 	"github.com/filecoin-project/lotus/markets/retrievaladapter"
+	"github.com/filecoin-project/lotus/markets/storageadapter"
 	"github.com/filecoin-project/lotus/miner"
+	"github.com/filecoin-project/lotus/node/config"
 	"github.com/filecoin-project/lotus/node/modules/dtypes"
 	"github.com/filecoin-project/lotus/node/modules/helpers"
 	"github.com/filecoin-project/lotus/node/repo"
 	"github.com/filecoin-project/lotus/storage"
+	"github.com/filecoin-project/lotus/storage/sectorblocks"
 	sealing "github.com/filecoin-project/storage-fsm"
 )
 
@@ -123,7 +127,55 @@ func SectorIDCounter(ds dtypes.MetadataDS) sealing.SectorIDCounter {
 	return &sidsc{sc}
 }
 
-func StorageMiner(mctx helpers.MetricsCtx, lc fx.Lifecycle, api lapi.FullNode, h host.Host, ds dtypes.MetadataDS, sealer sectorstorage.SectorManager, sc sealing.SectorIDCounter, verif ffiwrapper.Verifier, tktFn sealing.TicketFn) (*storage.Miner, error) {
+func SectorFiles(lc fx.Lifecycle, si stores.SectorIndex, sealer sectorstorage.SectorManager, ds dtypes.MetadataDS) *storage.SectorFiles {
+	var files *storage.SectorFiles
+
+	// only the sector storage manager keeps sectors in local storage
+	if mgr, ok := sealer.(*sectorstorage.Manager); ok {
+		files = storage.NewSectorFiles(si, mgr, ds)
+	} else {
+		files = storage.NewSectorFiles(si, nil, ds)
+	}
+
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			return files.ResumeMoves()
+		},
+	})
+
+	return files
+}
+
+func SectorChecker(api lapi.FullNode, files *storage.SectorFiles, maddr dtypes.MinerAddress) *storage.SectorChecker {
+	return storage.NewSectorChecker(api, files, address.Address(maddr))
+}
+
+func MessagePolicy(fees config.MinerFeeConfig) storage.MessagePolicy {
+	settings := func(fee config.MessageFee) storage.MsgSettings {
+		ms := storage.MsgSettings{
+			GasLimit: fee.GasLimit,
+		}
+
+		if fee.GasLimit > 0 && fee.MaxFee.Int != nil {
+			ms.GasPrice = types.BigDiv(types.BigInt(fee.MaxFee), types.NewInt(uint64(fee.GasLimit)))
+		}
+
+		return ms
+	}
+
+	return storage.MessagePolicy{
+		storage.MsgPreCommit:     settings(fees.PreCommit),
+		storage.MsgCommit:        settings(fees.Commit),
+		storage.MsgWindowPoSt:    settings(fees.WindowPoSt),
+		storage.MsgDeclareFaults: settings(fees.DeclareFaults),
+		storage.MsgPublishDeals:  settings(fees.PublishDeals),
+
+		storage.MsgExtendSectors:    settings(fees.ExtendSectors),
+		storage.MsgTerminateSectors: settings(fees.TerminateSectors),
+	}
+}
+
+func FPoStScheduler(mctx helpers.MetricsCtx, lc fx.Lifecycle, api lapi.FullNode, ds dtypes.MetadataDS, sealer sectorstorage.SectorManager, checker *storage.SectorChecker, policy storage.MessagePolicy) (*storage.FPoStScheduler, error) {
 	maddr, err := minerAddrFromDS(ds)
 	if err != nil {
 		return nil, err
@@ -141,9 +193,23 @@ func StorageMiner(mctx helpers.MetricsCtx, lc fx.Lifecycle, api lapi.FullNode, h
 		return nil, xerrors.Errorf("bad sector size: %w", err)
 	}
 
-	fps := storage.NewFPoStScheduler(api, sealer, maddr, worker, ppt)
+	return storage.NewFPoStScheduler(api, sealer, checker, ds, maddr, worker, policy, ppt), nil
+}
+
+func StorageMiner(mctx helpers.MetricsCtx, lc fx.Lifecycle, api lapi.FullNode, h host.Host, ds dtypes.MetadataDS, sealer sectorstorage.SectorManager, fps *storage.FPoStScheduler, files *storage.SectorFiles, policy storage.MessagePolicy, sc sealing.SectorIDCounter, verif ffiwrapper.Verifier, tktFn sealing.TicketFn) (*storage.Miner, error) {
+	maddr, err := minerAddrFromDS(ds)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := helpers.LifecycleCtx(mctx, lc)
 
-	sm, err := storage.NewMiner(api, maddr, worker, h, ds, sealer, sc, verif, tktFn)
+	worker, err := api.StateMinerWorker(ctx, maddr, types.EmptyTSK)
+	if err != nil {
+		return nil, err
+	}
+
+	sm, err := storage.NewMiner(api, maddr, worker, policy, files, h, ds, sealer, sc, verif, tktFn)
 	if err != nil {
 		return nil, err
 	}
@@ -159,6 +225,50 @@ func StorageMiner(mctx helpers.MetricsCtx, lc fx.Lifecycle, api lapi.FullNode, h
 	return sm, nil
 }
 
+func PledgeScheduler(mctx helpers.MetricsCtx, lc fx.Lifecycle, m *storage.Miner, index *stores.Index, sealer sectorstorage.SectorManager, cfg config.PledgeConfig) (*storage.PledgeScheduler, error) {
+	var fs interface {
+		FsStat(ctx context.Context, id stores.ID) (stores.FsStat, error)
+	}
+	// only the sector storage manager reports storage stats
+	if mgr, ok := sealer.(*sectorstorage.Manager); ok {
+		fs = mgr
+	}
+
+	ps, err := storage.NewPledgeScheduler(m, index, fs, cfg)
+	if err != nil {
+		return nil, xerrors.Errorf("pledge config: %w", err)
+	}
+
+	ctx := helpers.LifecycleCtx(mctx, lc)
+
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go ps.Run(ctx)
+			return nil
+		},
+	})
+
+	return ps, nil
+}
+
+func Packer(mctx helpers.MetricsCtx, lc fx.Lifecycle, secb *sectorblocks.SectorBlocks, api lapi.FullNode, ds dtypes.MetadataDS, lr repo.LockedRepo, sealer sectorstorage.SectorManager, cfg config.PackingConfig) (*sectorblocks.Packer, error) {
+	p, err := sectorblocks.NewPacker(secb, api, ds, filepath.Join(lr.Path(), "deal-staging"), sealer.SectorSize(), cfg)
+	if err != nil {
+		return nil, xerrors.Errorf("creating deal packer: %w", err)
+	}
+
+	ctx := helpers.LifecycleCtx(mctx, lc)
+
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go p.Run(ctx)
+			return nil
+		},
+	})
+
+	return p, nil
+}
+
 func HandleRetrieval(host host.Host, lc fx.Lifecycle, m retrievalmarket.RetrievalProvider) {
 	lc.Append(fx.Hook{
 		OnStart: func(context.Context) error {
@@ -304,12 +414,11 @@ func NewProviderRequestValidator(deals dtypes.ProviderDealStore) *requestvalidat
 	return requestvalidation.NewProviderRequestValidator(deals)
 }
 
-func StorageProvider(ctx helpers.MetricsCtx, fapi lapi.FullNode, h host.Host, ds dtypes.MetadataDS, ibs dtypes.StagingBlockstore, r repo.LockedRepo, pieceStore dtypes.ProviderPieceStore, dataTransfer dtypes.ProviderDataTransfer, spn storagemarket.StorageProviderNode) (storagemarket.StorageProvider, error) {
+func StorageProvider(ctx helpers.MetricsCtx, fapi lapi.FullNode, h host.Host, ds dtypes.MetadataDS, ibs dtypes.StagingBlockstore, r repo.LockedRepo, pieceStore dtypes.ProviderPieceStore, dataTransfer dtypes.ProviderDataTransfer, spn storagemarket.StorageProviderNode, filter *storageadapter.DealFilter) (storagemarket.StorageProvider, error) {
 	store, err := piecefilestore.NewLocalFileStore(piecefilestore.OsPath(r.Path()))
 	if err != nil {
 		return nil, err
 	}
-	net := smnet.NewFromLibp2pHost(h)
 	addr, err := ds.Get(datastore.NewKey("miner-address"))
 	if err != nil {
 		return nil, err
@@ -320,6 +429,15 @@ func StorageProvider(ctx helpers.MetricsCtx, fapi lapi.FullNode, h host.Host, ds
 		return nil, err
 	}
 
+	// rejections are signed by the worker, like the other deal responses
+	net := storageadapter.NewFilterNetwork(smnet.NewFromLibp2pHost(h), filter, func(ctx context.Context, b []byte) (*crypto.Signature, error) {
+		worker, err := fapi.StateMinerWorker(ctx, minerAddress, types.EmptyTSK)
+		if err != nil {
+			return nil, err
+		}
+		return spn.SignBytes(ctx, worker, b)
+	})
+
 	ssize, err := fapi.StateMinerSectorSize(ctx, minerAddress, types.EmptyTSK)
 	if err != nil {
 		return nil, err
@@ -330,7 +448,17 @@ func StorageProvider(ctx helpers.MetricsCtx, fapi lapi.FullNode, h host.Host, ds
 		return nil, err
 	}
 
-	return storageimpl.NewProvider(net, ds, ibs, store, pieceStore, dataTransfer, spn, minerAddress, rt)
+	p, err := storageimpl.NewProvider(net, ds, ibs, store, pieceStore, dataTransfer, spn, minerAddress, rt)
+	if err != nil {
+		return nil, err
+	}
+
+	filter.SetProvider(p)
+	return p, nil
+}
+
+func DealFilter(cfg config.DealPolicy, fapi lapi.FullNode) (*storageadapter.DealFilter, error) {
+	return storageadapter.NewDealFilter(cfg, fapi)
 }
 
 // RetrievalProvider creates a new retrieval provider attached to the provider blockstore
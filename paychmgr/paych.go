@@ -5,19 +5,24 @@ import (
 	"context"
 	"fmt"
 	"math"
+	"sync"
 
 	cborutil "github.com/filecoin-project/go-cbor-util"
+	"github.com/filecoin-project/specs-actors/actors/abi"
 	"github.com/filecoin-project/specs-actors/actors/builtin"
 	"github.com/filecoin-project/specs-actors/actors/builtin/account"
 	"github.com/filecoin-project/specs-actors/actors/builtin/paych"
 	"golang.org/x/xerrors"
 
+	"github.com/ipfs/go-cid"
 	logging "github.com/ipfs/go-log/v2"
 	"go.uber.org/fx"
 
 	"github.com/filecoin-project/go-address"
 
+	"github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/chain/actors"
+	"github.com/filecoin-project/lotus/chain/events"
 	"github.com/filecoin-project/lotus/chain/stmgr"
 	"github.com/filecoin-project/lotus/chain/types"
 	"github.com/filecoin-project/lotus/lib/sigs"
@@ -32,25 +37,56 @@ type ManagerApi struct {
 	full.MpoolAPI
 	full.WalletAPI
 	full.StateAPI
+	full.ChainAPI
+}
+
+// managerAPI is the node API used by the Manager
+type managerAPI interface {
+	MpoolPushMessage(context.Context, *types.Message) (*types.SignedMessage, error)
+	StateWaitMsg(context.Context, cid.Cid) (*api.MsgLookup, error)
+	ChainHead(context.Context) (*types.TipSet, error)
+}
+
+// stateManagerAPI is the chain state access used by the Manager
+type stateManagerAPI interface {
+	LoadActorState(ctx context.Context, a address.Address, out interface{}, ts *types.TipSet) (*types.Actor, error)
+	Call(ctx context.Context, msg *types.Message, ts *types.TipSet) (*api.InvocResult, error)
+}
+
+// settleEvents are the chain events used to follow channels through
+// settlement
+type settleEvents interface {
+	Called(check events.CheckFunc, hnd events.CalledHandler, rev events.RevertHandler, confidence int, timeout abi.ChainEpoch, mf events.MatchFunc) error
+	CalledMsg(ctx context.Context, hnd events.CalledHandler, rev events.RevertHandler, confidence int, timeout abi.ChainEpoch, msg types.ChainMsg) error
+	ChainAt(hnd events.HeightHandler, rev events.RevertHandler, confidence int, h abi.ChainEpoch) error
 }
 
 type Manager struct {
 	store *Store
-	sm    *stmgr.StateManager
+	sm    stateManagerAPI
+	api   managerAPI
+
+	newEvents func(context.Context) settleEvents
+
+	// set up in Start, used to follow channels through settlement
+	ctx context.Context
+	ev  settleEvents
 
-	mpool  full.MpoolAPI
-	wallet full.WalletAPI
-	state  full.StateAPI
+	fundLk  sync.Mutex
+	funders map[fundKey]*channelFunder
 }
 
 func NewManager(sm *stmgr.StateManager, pchstore *Store, api ManagerApi) *Manager {
 	return &Manager{
 		store: pchstore,
 		sm:    sm,
+		api:   &api,
 
-		mpool:  api.MpoolAPI,
-		wallet: api.WalletAPI,
-		state:  api.StateAPI,
+		newEvents: func(ctx context.Context) settleEvents {
+			return events.NewEvents(ctx, &eventsApi{api.ChainAPI, api.StateAPI})
+		},
+
+		funders: map[fundKey]*channelFunder{},
 	}
 }
 
@@ -76,7 +112,7 @@ func (pm *Manager) TrackInboundChannel(ctx context.Context, ch address.Address)
 		return err
 	}
 	from := account.Address
-	_, err = pm.sm.LoadActorState(ctx, st.From, &account, nil)
+	_, err = pm.sm.LoadActorState(ctx, st.To, &account, nil)
 	if err != nil {
 		return err
 	}
@@ -87,14 +123,21 @@ func (pm *Manager) TrackInboundChannel(ctx context.Context, ch address.Address)
 		return err
 	}
 
-	return pm.store.TrackChannel(&ChannelInfo{
+	err = pm.store.TrackChannel(&ChannelInfo{
 		Channel: ch,
 		Control: to,
 		Target:  from,
 
 		Direction: DirInbound,
 		NextLane:  maxLane + 1,
+
+		PendingAmount: types.NewInt(0),
 	})
+	if err != nil {
+		return err
+	}
+
+	return pm.watchChannel(ch)
 }
 
 func (pm *Manager) loadOutboundChannelInfo(ctx context.Context, ch address.Address) (*ChannelInfo, error) {
@@ -114,7 +157,7 @@ func (pm *Manager) loadOutboundChannelInfo(ctx context.Context, ch address.Addre
 		return nil, err
 	}
 	from := account.Address
-	_, err = pm.sm.LoadActorState(ctx, st.From, &account, nil)
+	_, err = pm.sm.LoadActorState(ctx, st.To, &account, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -127,6 +170,8 @@ func (pm *Manager) loadOutboundChannelInfo(ctx context.Context, ch address.Addre
 
 		Direction: DirOutbound,
 		NextLane:  maxLane + 1,
+
+		PendingAmount: types.NewInt(0),
 	}, nil
 }
 
@@ -136,7 +181,11 @@ func (pm *Manager) TrackOutboundChannel(ctx context.Context, ch address.Address)
 		return err
 	}
 
-	return pm.store.TrackChannel(ci)
+	if err := pm.store.TrackChannel(ci); err != nil {
+		return err
+	}
+
+	return pm.watchChannel(ch)
 }
 
 func (pm *Manager) ListChannels() ([]address.Address, error) {
@@ -342,12 +391,14 @@ func (pm *Manager) ListVouchers(ctx context.Context, ch address.Address) ([]*Vou
 	return pm.store.VouchersForPaych(ch)
 }
 
+// OutboundChanTo returns the open outbound channel from -> to. Channels which
+// are settling or were collected can't take new vouchers, so they are skipped.
 func (pm *Manager) OutboundChanTo(from, to address.Address) (address.Address, error) {
 	pm.store.lk.Lock()
 	defer pm.store.lk.Unlock()
 
 	return pm.store.findChan(func(ci *ChannelInfo) bool {
-		if ci.Direction != DirOutbound {
+		if ci.Direction != DirOutbound || ci.State != ChanOpen {
 			return false
 		}
 		return ci.Control == from && ci.Target == to
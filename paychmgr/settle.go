@@ -0,0 +1,386 @@
+package paychmgr
+
+import (
+	"context"
+
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/builtin/paych"
+
+	"github.com/filecoin-project/lotus/chain/actors"
+	"github.com/filecoin-project/lotus/chain/events"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/node/impl/full"
+)
+
+// settleConfidence is the number of epochs settlement related messages need
+// to be buried under before the manager acts on them
+const settleConfidence = 5
+
+type eventsApi struct {
+	full.ChainAPI
+	full.StateAPI
+}
+
+// Start sets up chain event tracking for all tracked channels which weren't
+// collected yet, and resumes waiting for funding messages sent before the node
+// stopped. Channels move through the following states:
+//
+//	open -(Settle)-> settling -(settlement period over, Collect)-> collected
+//
+// When a channel starts settling, the best spendable vouchers of inbound
+// channels are submitted, and when the settlement period is over the funds
+// are collected.
+func (pm *Manager) Start(ctx context.Context) error {
+	pm.ctx = ctx
+	pm.ev = pm.newEvents(ctx)
+
+	chans, err := pm.store.ListChannels()
+	if err != nil {
+		return xerrors.Errorf("listing channels: %w", err)
+	}
+
+	for _, ch := range chans {
+		ci, err := pm.store.getChannelInfo(ch)
+		if err != nil {
+			return xerrors.Errorf("getting channel info for %s: %w", ch, err)
+		}
+		if ci.Direction == DirOutbound && ci.AddFundsMsg != nil {
+			pm.funder(ci.Control, ci.Target).start()
+		}
+
+		if ci.State == ChanCollected {
+			continue
+		}
+
+		if err := pm.ReconcileLanes(ctx, ch); err != nil {
+			log.Warnf("reconciling lanes of %s: %s", ch, err)
+		}
+
+		if err := pm.watchChannel(ch); err != nil {
+			return xerrors.Errorf("watching channel %s: %w", ch, err)
+		}
+	}
+
+	pending, err := pm.store.listPendingCreates()
+	if err != nil {
+		return xerrors.Errorf("listing pending channels: %w", err)
+	}
+
+	for _, pc := range pending {
+		pm.funder(pc.From, pc.To).start()
+	}
+
+	return nil
+}
+
+// watchChannel waits for the channel to start settling. The check callback
+// runs with the events lock held, so the event callbacks hand off the work to
+// a goroutine instead of touching the store and events directly.
+func (pm *Manager) watchChannel(ch address.Address) error {
+	if pm.ev == nil {
+		// not started yet, Start will pick up the channel
+		return nil
+	}
+
+	check := func(ts *types.TipSet) (done bool, more bool, err error) {
+		var st paych.State
+		_, err = pm.sm.LoadActorState(pm.ctx, ch, &st, ts)
+		switch {
+		case xerrors.Is(err, types.ErrActorNotFound):
+			// the channel actor is gone, which means it was collected
+			go pm.onCollected(ch)
+			return true, false, nil
+		case err != nil:
+			return false, false, xerrors.Errorf("loading state of payment channel %s: %w", ch, err)
+		}
+
+		if st.SettlingAt != 0 {
+			go pm.onSettling(ch, st.SettlingAt)
+			return true, false, nil
+		}
+
+		return false, true, nil
+	}
+
+	called := func(msg *types.Message, rec *types.MessageReceipt, ts *types.TipSet, curH abi.ChainEpoch) (more bool, err error) {
+		if rec.ExitCode != 0 {
+			// failed settle attempt, keep waiting
+			return true, nil
+		}
+
+		var st paych.State
+		if _, err := pm.sm.LoadActorState(pm.ctx, ch, &st, nil); err != nil {
+			return false, xerrors.Errorf("loading payment channel state: %w", err)
+		}
+
+		go pm.onSettling(ch, st.SettlingAt)
+		return false, nil
+	}
+
+	revert := func(ctx context.Context, ts *types.TipSet) error {
+		log.Warnf("settle message for payment channel %s reverted", ch)
+		return nil
+	}
+
+	match := func(msg *types.Message) (bool, error) {
+		return msg.To == ch && msg.Method == builtin.MethodsPaych.Settle, nil
+	}
+
+	return pm.ev.Called(check, called, revert, settleConfidence, events.NoTimeout, match)
+}
+
+func (pm *Manager) onSettling(ch address.Address, settlingAt abi.ChainEpoch) {
+	var ci ChannelInfo
+	err := pm.store.updateChannel(ch, func(info *ChannelInfo) error {
+		if info.State == ChanOpen {
+			info.State = ChanSettling
+		}
+		info.SettlingAt = uint64(settlingAt)
+		ci = *info
+		return nil
+	})
+	if err != nil {
+		log.Errorf("recording settlement of payment channel %s: %s", ch, err)
+		return
+	}
+
+	if ci.State == ChanCollected {
+		return
+	}
+
+	log.Infof("payment channel %s is settling, settlement ends at %d", ch, settlingAt)
+
+	if ci.Direction == DirInbound {
+		if err := pm.submitBestVouchers(pm.ctx, ch); err != nil {
+			log.Errorf("submitting vouchers for settling payment channel %s: %s", ch, err)
+		}
+	}
+
+	collect := func(ctx context.Context, ts *types.TipSet, curH abi.ChainEpoch) error {
+		go pm.autoCollect(ch)
+		return nil
+	}
+
+	revert := func(ctx context.Context, ts *types.TipSet) error {
+		return nil
+	}
+
+	if err := pm.ev.ChainAt(collect, revert, settleConfidence, settlingAt); err != nil {
+		log.Errorf("scheduling collect of payment channel %s: %s", ch, err)
+	}
+}
+
+func (pm *Manager) autoCollect(ch address.Address) {
+	if err := pm.ReconcileLanes(pm.ctx, ch); err != nil {
+		log.Warnf("reconciling lanes of %s: %s", ch, err)
+	}
+
+	ci, err := pm.store.getChannelInfo(ch)
+	if err != nil {
+		log.Errorf("collecting payment channel %s: %s", ch, err)
+		return
+	}
+
+	if ci.State == ChanCollected {
+		return
+	}
+
+	_, _, err = pm.loadPaychState(pm.ctx, ch)
+	switch {
+	case xerrors.Is(err, types.ErrActorNotFound):
+		// collected by the other party
+		pm.onCollected(ch)
+		return
+	case err != nil:
+		log.Errorf("collecting payment channel %s: loading state: %s", ch, err)
+		return
+	}
+
+	if _, err := pm.Collect(pm.ctx, ch); err != nil {
+		log.Errorf("collecting payment channel %s: %s", ch, err)
+	}
+}
+
+func (pm *Manager) onCollected(ch address.Address) {
+	err := pm.store.updateChannel(ch, func(info *ChannelInfo) error {
+		info.State = ChanCollected
+		return nil
+	})
+	if err != nil {
+		log.Errorf("recording collection of payment channel %s: %s", ch, err)
+		return
+	}
+
+	log.Infof("payment channel %s collected", ch)
+}
+
+// Settle sends a message starting the settlement period of the channel
+func (pm *Manager) Settle(ctx context.Context, ch address.Address) (cid.Cid, error) {
+	ci, err := pm.store.getChannelInfo(ch)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	if ci.State != ChanOpen {
+		return cid.Undef, xerrors.Errorf("payment channel %s is already %s", ch, ChanStateNames[ci.State])
+	}
+
+	smsg, err := pm.api.MpoolPushMessage(ctx, actors.PaychSettleMessage(ch, ci.Control))
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("pushing settle message: %w", err)
+	}
+
+	return smsg.Cid(), nil
+}
+
+// Collect sends a message paying out the channel funds. The settlement
+// period has to be over.
+func (pm *Manager) Collect(ctx context.Context, ch address.Address) (cid.Cid, error) {
+	ci, err := pm.store.getChannelInfo(ch)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	if ci.State == ChanCollected {
+		return cid.Undef, xerrors.Errorf("payment channel %s was already collected", ch)
+	}
+
+	_, st, err := pm.loadPaychState(ctx, ch)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	if st.SettlingAt == 0 {
+		return cid.Undef, xerrors.Errorf("payment channel %s is not settling", ch)
+	}
+
+	head, err := pm.api.ChainHead(ctx)
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("getting chain head: %w", err)
+	}
+	if head.Height() < st.SettlingAt {
+		return cid.Undef, xerrors.Errorf("settlement period of payment channel %s ends at %d (current epoch %d)", ch, st.SettlingAt, head.Height())
+	}
+
+	smsg, err := pm.api.MpoolPushMessage(ctx, actors.PaychCollectMessage(ch, ci.Control))
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("pushing collect message: %w", err)
+	}
+
+	if pm.ev != nil {
+		hnd := func(msg *types.Message, rec *types.MessageReceipt, ts *types.TipSet, curH abi.ChainEpoch) (more bool, err error) {
+			if rec.ExitCode != 0 {
+				log.Errorf("collect message for payment channel %s failed (exit code %d)", ch, rec.ExitCode)
+				return false, nil
+			}
+
+			go pm.onCollected(ch)
+			return false, nil
+		}
+
+		revert := func(ctx context.Context, ts *types.TipSet) error {
+			log.Warnf("collect message for payment channel %s reverted", ch)
+			return nil
+		}
+
+		if err := pm.ev.CalledMsg(ctx, hnd, revert, settleConfidence, events.NoTimeout, smsg); err != nil {
+			return smsg.Cid(), xerrors.Errorf("waiting for collect message: %w", err)
+		}
+	}
+
+	return smsg.Cid(), nil
+}
+
+// submitBestVouchers submits, for each lane, the highest value voucher which
+// is spendable and wasn't redeemed on chain yet
+func (pm *Manager) submitBestVouchers(ctx context.Context, ch address.Address) error {
+	ci, err := pm.store.getChannelInfo(ch)
+	if err != nil {
+		return err
+	}
+
+	_, st, err := pm.loadPaychState(ctx, ch)
+	if err != nil {
+		return err
+	}
+
+	best := map[uint64]*paych.SignedVoucher{}
+	for _, v := range ci.Vouchers {
+		sv := v.Voucher
+
+		if ls := findLane(st.LaneStates, sv.Lane); ls != nil && sv.Nonce <= ls.Nonce {
+			continue
+		}
+
+		if cur, ok := best[sv.Lane]; ok && !sv.Amount.GreaterThan(cur.Amount) {
+			continue
+		}
+
+		spendable, err := pm.CheckVoucherSpendable(ctx, ch, sv, nil, v.Proof)
+		if err != nil {
+			log.Warnf("checking voucher (ch %s, lane %d, nonce %d): %s", ch, sv.Lane, sv.Nonce, err)
+			continue
+		}
+		if !spendable {
+			continue
+		}
+
+		best[sv.Lane] = sv
+	}
+
+	for lane, sv := range best {
+		msg, err := actors.PaychUpdateMessage(ch, ci.Control, sv)
+		if err != nil {
+			log.Warnf("can't submit voucher (ch %s, lane %d, nonce %d): %s", ch, lane, sv.Nonce, err)
+			continue
+		}
+
+		smsg, err := pm.api.MpoolPushMessage(ctx, msg)
+		if err != nil {
+			return xerrors.Errorf("submitting voucher for lane %d: %w", lane, err)
+		}
+
+		log.Infof("submitted voucher (ch %s, lane %d, nonce %d, amount %s) in %s", ch, lane, sv.Nonce, types.FIL(sv.Amount), smsg.Cid())
+	}
+
+	return nil
+}
+
+// ReconcileLanes drops stored vouchers which were superseded by vouchers
+// redeemed on chain, and makes sure newly allocated lanes don't collide with
+// lanes already used on chain
+func (pm *Manager) ReconcileLanes(ctx context.Context, ch address.Address) error {
+	_, st, err := pm.loadPaychState(ctx, ch)
+	switch {
+	case xerrors.Is(err, types.ErrActorNotFound):
+		// nothing to reconcile against once the channel is collected
+		return nil
+	case err != nil:
+		return xerrors.Errorf("loading payment channel state: %w", err)
+	}
+
+	return pm.store.updateChannel(ch, func(ci *ChannelInfo) error {
+		vouchers := ci.Vouchers[:0]
+		for _, v := range ci.Vouchers {
+			ls := findLane(st.LaneStates, v.Voucher.Lane)
+			if ls != nil && v.Voucher.Nonce < ls.Nonce {
+				continue
+			}
+			vouchers = append(vouchers, v)
+		}
+		ci.Vouchers = vouchers
+
+		for _, ls := range st.LaneStates {
+			if ci.NextLane <= ls.ID {
+				ci.NextLane = ls.ID + 1
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,508 @@
+package paychmgr
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/builtin/paych"
+	"github.com/filecoin-project/specs-actors/actors/crypto"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/events"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/types/mock"
+)
+
+// fakeChain implements the node API and the state manager used by the
+// Manager. Pushed messages land when the test sets their receipt.
+type fakeChain struct {
+	lk       sync.Mutex
+	height   abi.ChainEpoch
+	actors   map[address.Address]*fakeActor
+	pushed   []*types.Message
+	receipts map[cid.Cid]*types.MessageReceipt
+	landed   chan struct{}
+	waiting  int
+}
+
+type fakeActor struct {
+	act   types.Actor
+	state interface{}
+}
+
+func newFakeChain() *fakeChain {
+	return &fakeChain{
+		actors:   map[address.Address]*fakeActor{},
+		receipts: map[cid.Cid]*types.MessageReceipt{},
+		landed:   make(chan struct{}),
+	}
+}
+
+func (fc *fakeChain) MpoolPushMessage(ctx context.Context, msg *types.Message) (*types.SignedMessage, error) {
+	fc.lk.Lock()
+	defer fc.lk.Unlock()
+
+	m := *msg
+	m.Nonce = uint64(len(fc.pushed))
+	fc.pushed = append(fc.pushed, &m)
+
+	// BLS signed messages share the CID of the unsigned message
+	return &types.SignedMessage{Message: m, Signature: crypto.Signature{Type: crypto.SigTypeBLS}}, nil
+}
+
+func (fc *fakeChain) StateWaitMsg(ctx context.Context, c cid.Cid) (*api.MsgLookup, error) {
+	for {
+		fc.lk.Lock()
+		rec, ok := fc.receipts[c]
+		landed := fc.landed
+		if !ok {
+			fc.waiting++
+		}
+		fc.lk.Unlock()
+
+		if ok {
+			return &api.MsgLookup{Receipt: *rec}, nil
+		}
+
+		select {
+		case <-landed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		fc.lk.Lock()
+		fc.waiting--
+		fc.lk.Unlock()
+	}
+}
+
+func (fc *fakeChain) ChainHead(context.Context) (*types.TipSet, error) {
+	fc.lk.Lock()
+	defer fc.lk.Unlock()
+
+	return fc.tipset(), nil
+}
+
+// Must be called with fc.lk held.
+func (fc *fakeChain) tipset() *types.TipSet {
+	blk := mock.MkBlock(nil, 1, 1)
+	blk.Height = fc.height
+	return mock.TipSet(blk)
+}
+
+func (fc *fakeChain) LoadActorState(ctx context.Context, a address.Address, out interface{}, ts *types.TipSet) (*types.Actor, error) {
+	fc.lk.Lock()
+	defer fc.lk.Unlock()
+
+	fa, ok := fc.actors[a]
+	if !ok {
+		return nil, xerrors.Errorf("loading actor %s: %w", a, types.ErrActorNotFound)
+	}
+
+	reflect.ValueOf(out).Elem().Set(reflect.ValueOf(fa.state).Elem())
+	act := fa.act
+	return &act, nil
+}
+
+func (fc *fakeChain) Call(ctx context.Context, msg *types.Message, ts *types.TipSet) (*api.InvocResult, error) {
+	return &api.InvocResult{MsgRct: &types.MessageReceipt{ExitCode: 0}}, nil
+}
+
+func (fc *fakeChain) setActor(a address.Address, balance types.BigInt, st interface{}) {
+	fc.lk.Lock()
+	defer fc.lk.Unlock()
+
+	fc.actors[a] = &fakeActor{act: types.Actor{Balance: balance}, state: st}
+}
+
+func (fc *fakeChain) removeActor(a address.Address) {
+	fc.lk.Lock()
+	defer fc.lk.Unlock()
+
+	delete(fc.actors, a)
+}
+
+func (fc *fakeChain) setHeight(h abi.ChainEpoch) {
+	fc.lk.Lock()
+	defer fc.lk.Unlock()
+
+	fc.height = h
+}
+
+// land sets the receipt of a pushed message, waking up its waiters
+func (fc *fakeChain) land(c cid.Cid, rec *types.MessageReceipt) {
+	fc.lk.Lock()
+	defer fc.lk.Unlock()
+
+	fc.receipts[c] = rec
+	close(fc.landed)
+	fc.landed = make(chan struct{})
+}
+
+func (fc *fakeChain) messages() []*types.Message {
+	fc.lk.Lock()
+	defer fc.lk.Unlock()
+
+	return append([]*types.Message(nil), fc.pushed...)
+}
+
+func (fc *fakeChain) waiters() int {
+	fc.lk.Lock()
+	defer fc.lk.Unlock()
+
+	return fc.waiting
+}
+
+// fakeEvents records the handlers registered by the Manager, tests call them
+type fakeEvents struct {
+	lk        sync.Mutex
+	called    []calledReg
+	calledMsg map[cid.Cid]events.CalledHandler
+	chainAt   map[abi.ChainEpoch]events.HeightHandler
+}
+
+type calledReg struct {
+	check events.CheckFunc
+	hnd   events.CalledHandler
+	match events.MatchFunc
+}
+
+func newFakeEvents() *fakeEvents {
+	return &fakeEvents{
+		calledMsg: map[cid.Cid]events.CalledHandler{},
+		chainAt:   map[abi.ChainEpoch]events.HeightHandler{},
+	}
+}
+
+func (fe *fakeEvents) Called(check events.CheckFunc, hnd events.CalledHandler, rev events.RevertHandler, confidence int, timeout abi.ChainEpoch, mf events.MatchFunc) error {
+	fe.lk.Lock()
+	defer fe.lk.Unlock()
+
+	fe.called = append(fe.called, calledReg{check: check, hnd: hnd, match: mf})
+	return nil
+}
+
+func (fe *fakeEvents) CalledMsg(ctx context.Context, hnd events.CalledHandler, rev events.RevertHandler, confidence int, timeout abi.ChainEpoch, msg types.ChainMsg) error {
+	fe.lk.Lock()
+	defer fe.lk.Unlock()
+
+	fe.calledMsg[msg.VMMessage().Cid()] = hnd
+	return nil
+}
+
+func (fe *fakeEvents) ChainAt(hnd events.HeightHandler, rev events.RevertHandler, confidence int, h abi.ChainEpoch) error {
+	fe.lk.Lock()
+	defer fe.lk.Unlock()
+
+	fe.chainAt[h] = hnd
+	return nil
+}
+
+func newTestManager(fc *fakeChain, fe *fakeEvents, ds datastore.Batching) *Manager {
+	return &Manager{
+		store: NewStore(ds),
+		sm:    fc,
+		api:   fc,
+
+		newEvents: func(context.Context) settleEvents {
+			return fe
+		},
+
+		funders: map[fundKey]*channelFunder{},
+	}
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	for start := time.Now(); !cond(); time.Sleep(5 * time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+}
+
+func testAddr(t *testing.T, id uint64) address.Address {
+	a, err := address.NewIDAddress(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func testVoucher(lane, nonce uint64, amt int64) *VoucherInfo {
+	return &VoucherInfo{Voucher: &paych.SignedVoucher{
+		Lane:   lane,
+		Nonce:  nonce,
+		Amount: types.NewInt(uint64(amt)),
+	}}
+}
+
+func TestSettleLifecycle(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fc := newFakeChain()
+	fe := newFakeEvents()
+	pm := newTestManager(fc, fe, dssync.MutexWrap(datastore.NewMapDatastore()))
+
+	ch, from, to := testAddr(t, 100), testAddr(t, 101), testAddr(t, 102)
+	st := &paych.State{
+		From:   from,
+		To:     to,
+		ToSend: types.NewInt(0),
+		// lane 1 was redeemed up to nonce 2 already
+		LaneStates: []*paych.LaneState{{ID: 1, Redeemed: types.NewInt(4), Nonce: 2}},
+	}
+	fc.setActor(ch, types.NewInt(100), st)
+
+	err := pm.store.TrackChannel(&ChannelInfo{
+		Channel:   ch,
+		Control:   to,
+		Target:    from,
+		Direction: DirInbound,
+		Vouchers: []*VoucherInfo{
+			testVoucher(0, 1, 5),
+			testVoucher(0, 2, 10),
+			testVoucher(1, 1, 3),
+			testVoucher(1, 2, 4),
+		},
+		PendingAmount: types.NewInt(0),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pm.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// the voucher superseded on chain was dropped when starting
+	vouchers, err := pm.ListVouchers(ctx, ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vouchers) != 3 {
+		t.Fatalf("expected 3 vouchers after reconciling lanes, got %d", len(vouchers))
+	}
+
+	if _, err := pm.Settle(ctx, ch); err != nil {
+		t.Fatal(err)
+	}
+	msgs := fc.messages()
+	if len(msgs) != 1 || msgs[0].Method != builtin.MethodsPaych.Settle || msgs[0].From != to {
+		t.Fatalf("expected a settle message from %s, got %+v", to, msgs)
+	}
+
+	// the settle message lands
+	fe.lk.Lock()
+	if len(fe.called) != 1 {
+		fe.lk.Unlock()
+		t.Fatalf("expected the channel to be watched, got %d watches", len(fe.called))
+	}
+	watch := fe.called[0]
+	fe.lk.Unlock()
+
+	if ok, err := watch.match(msgs[0]); err != nil || !ok {
+		t.Fatalf("expected the settle message to match (%t, %v)", ok, err)
+	}
+
+	settled := *st
+	settled.SettlingAt = 200
+	fc.setActor(ch, types.NewInt(100), &settled)
+	if _, err := watch.hnd(msgs[0], &types.MessageReceipt{ExitCode: 0}, nil, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, "collect to be scheduled", func() bool {
+		fe.lk.Lock()
+		defer fe.lk.Unlock()
+		return fe.chainAt[200] != nil
+	})
+
+	ci, err := pm.GetChannelInfo(ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ci.State != ChanSettling || ci.SettlingAt != 200 {
+		t.Fatalf("expected the channel to be settling until 200, got state %d, settling at %d", ci.State, ci.SettlingAt)
+	}
+
+	// only the best voucher of lane 0 is submitted, lane 1 was redeemed
+	msgs = fc.messages()
+	if len(msgs) != 2 || msgs[1].Method != builtin.MethodsPaych.UpdateChannelState {
+		t.Fatalf("expected a voucher to be submitted, got %+v", msgs)
+	}
+	var params paych.UpdateChannelStateParams
+	if err := params.UnmarshalCBOR(bytes.NewReader(msgs[1].Params)); err != nil {
+		t.Fatal(err)
+	}
+	if params.Sv.Lane != 0 || params.Sv.Nonce != 2 {
+		t.Fatalf("expected the voucher with nonce 2 on lane 0, got lane %d nonce %d", params.Sv.Lane, params.Sv.Nonce)
+	}
+
+	// the settlement period ends
+	fc.setHeight(200)
+	fe.lk.Lock()
+	collect := fe.chainAt[200]
+	fe.lk.Unlock()
+	if err := collect(ctx, nil, 200); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, "the collect message", func() bool {
+		return len(fc.messages()) == 3
+	})
+	msgs = fc.messages()
+	if msgs[2].Method != builtin.MethodsPaych.Collect || msgs[2].From != to {
+		t.Fatalf("expected a collect message from %s, got %+v", to, msgs[2])
+	}
+
+	waitFor(t, "the collect message to be watched", func() bool {
+		fe.lk.Lock()
+		defer fe.lk.Unlock()
+		return len(fe.calledMsg) == 1
+	})
+	fe.lk.Lock()
+	collected := fe.calledMsg[msgs[2].Cid()]
+	fe.lk.Unlock()
+	if collected == nil {
+		t.Fatal("expected the collect message to be watched")
+	}
+
+	fc.removeActor(ch)
+	if _, err := collected(msgs[2], &types.MessageReceipt{ExitCode: 0}, nil, 205); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, "the channel to be collected", func() bool {
+		ci, err := pm.GetChannelInfo(ch)
+		return err == nil && ci.State == ChanCollected
+	})
+
+	if _, err := pm.Settle(ctx, ch); err == nil {
+		t.Error("expected settling a collected channel to fail")
+	}
+}
+
+func TestWatchChannelCollected(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fc := newFakeChain()
+	fe := newFakeEvents()
+	pm := newTestManager(fc, fe, dssync.MutexWrap(datastore.NewMapDatastore()))
+
+	// the channel was collected by the other party while the node was down
+	ch := testAddr(t, 100)
+	err := pm.store.TrackChannel(&ChannelInfo{
+		Channel:       ch,
+		Control:       testAddr(t, 101),
+		Target:        testAddr(t, 102),
+		Direction:     DirOutbound,
+		PendingAmount: types.NewInt(0),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pm.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	fe.lk.Lock()
+	check := fe.called[0].check
+	fe.lk.Unlock()
+
+	fc.lk.Lock()
+	ts := fc.tipset()
+	fc.lk.Unlock()
+
+	done, more, err := check(ts)
+	if err != nil || !done || more {
+		t.Fatalf("expected the watch to be done (done %t, more %t, err %v)", done, more, err)
+	}
+
+	waitFor(t, "the channel to be collected", func() bool {
+		ci, err := pm.GetChannelInfo(ch)
+		return err == nil && ci.State == ChanCollected
+	})
+}
+
+func TestReconcileLanes(t *testing.T) {
+	ctx := context.Background()
+
+	fc := newFakeChain()
+	pm := newTestManager(fc, newFakeEvents(), dssync.MutexWrap(datastore.NewMapDatastore()))
+
+	ch := testAddr(t, 100)
+	fc.setActor(ch, types.NewInt(100), &paych.State{
+		From:   testAddr(t, 101),
+		To:     testAddr(t, 102),
+		ToSend: types.NewInt(0),
+		LaneStates: []*paych.LaneState{
+			{ID: 0, Redeemed: types.NewInt(5), Nonce: 2},
+			{ID: 5, Redeemed: types.NewInt(1), Nonce: 1},
+		},
+	})
+
+	err := pm.store.TrackChannel(&ChannelInfo{
+		Channel:   ch,
+		Control:   testAddr(t, 101),
+		Target:    testAddr(t, 102),
+		Direction: DirOutbound,
+		NextLane:  2,
+		Vouchers: []*VoucherInfo{
+			testVoucher(0, 1, 3),
+			testVoucher(0, 2, 5),
+			testVoucher(0, 3, 7),
+			testVoucher(1, 1, 1),
+		},
+		PendingAmount: types.NewInt(0),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pm.ReconcileLanes(ctx, ch); err != nil {
+		t.Fatal(err)
+	}
+
+	ci, err := pm.GetChannelInfo(ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type lv struct{ lane, nonce uint64 }
+	var got []lv
+	for _, v := range ci.Vouchers {
+		got = append(got, lv{v.Voucher.Lane, v.Voucher.Nonce})
+	}
+	expect := []lv{{0, 2}, {0, 3}, {1, 1}}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("expected vouchers %v, got %v", expect, got)
+	}
+
+	// lanes used on chain aren't allocated again
+	if ci.NextLane != 6 {
+		t.Errorf("expected next lane 6, got %d", ci.NextLane)
+	}
+
+	// a collected channel has nothing to reconcile against
+	fc.removeActor(ch)
+	if err := pm.ReconcileLanes(ctx, ch); err != nil {
+		t.Errorf("expected reconciling a collected channel to do nothing, got %s", err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"sync"
 
 	"github.com/filecoin-project/specs-actors/actors/builtin"
 	init_ "github.com/filecoin-project/specs-actors/actors/builtin/init"
@@ -17,8 +18,176 @@ import (
 	"github.com/filecoin-project/lotus/chain/types"
 )
 
-func (pm *Manager) createPaych(ctx context.Context, from, to address.Address, amt types.BigInt) (address.Address, cid.Cid, error) {
-	params, aerr := actors.SerializeParams(&paych.ConstructorParams{From: from, To: to})
+// fundKey identifies the outbound channel between a pair of addresses
+type fundKey struct {
+	from, to address.Address
+}
+
+// channelFunder serializes the funding of the outbound channel between a pair
+// of addresses. Requests arriving while a message is in flight are queued, and
+// then served together with a single message. Channels between other
+// addresses are funded independently.
+type channelFunder struct {
+	pm       *Manager
+	from, to address.Address
+
+	lk      sync.Mutex
+	queue   []*fundRequest
+	running bool
+}
+
+type fundRequest struct {
+	amt  types.BigInt
+	done chan fundResult
+}
+
+type fundResult struct {
+	ch   address.Address
+	mcid cid.Cid
+	err  error
+}
+
+func (pm *Manager) funder(from, to address.Address) *channelFunder {
+	pm.fundLk.Lock()
+	defer pm.fundLk.Unlock()
+
+	k := fundKey{from: from, to: to}
+	f, ok := pm.funders[k]
+	if !ok {
+		f = &channelFunder{pm: pm, from: from, to: to}
+		pm.funders[k] = f
+	}
+
+	return f
+}
+
+// GetPaych returns the outbound channel from -> to, creating it if needed,
+// and makes sure at least ensureFree of its funds aren't committed to
+// vouchers. The returned cid is the create message, when the channel was
+// created for this request.
+//
+// If ctx is cancelled, any message sent for the request still goes through.
+func (pm *Manager) GetPaych(ctx context.Context, from, to address.Address, ensureFree types.BigInt) (address.Address, cid.Cid, error) {
+	req := &fundRequest{
+		amt:  ensureFree,
+		done: make(chan fundResult, 1),
+	}
+	pm.funder(from, to).start(req)
+
+	select {
+	case res := <-req.done:
+		return res.ch, res.mcid, res.err
+	case <-ctx.Done():
+		return address.Undef, cid.Undef, ctx.Err()
+	}
+}
+
+// start queues the requests, and starts processing the queue if it isn't
+// being processed already. Called without requests, it only finishes
+// messages which were in flight when the node stopped.
+func (f *channelFunder) start(reqs ...*fundRequest) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+
+	f.queue = append(f.queue, reqs...)
+	if f.running {
+		return
+	}
+
+	f.running = true
+	go f.run()
+}
+
+func (f *channelFunder) run() {
+	ctx := f.pm.ctx
+	if ctx == nil {
+		ctx = context.TODO()
+	}
+
+	first := true
+	for {
+		f.lk.Lock()
+		reqs := f.queue
+		f.queue = nil
+		if len(reqs) == 0 && !first {
+			f.running = false
+			f.lk.Unlock()
+			return
+		}
+		f.lk.Unlock()
+		first = false
+
+		ch, mcid, err := f.fund(ctx, reqs)
+		if err != nil {
+			log.Errorf("funding payment channel %s -> %s: %s", f.from, f.to, err)
+		}
+
+		for _, r := range reqs {
+			r.done <- fundResult{ch: ch, mcid: mcid, err: err}
+		}
+	}
+}
+
+// fund serves all requests with at most one message
+func (f *channelFunder) fund(ctx context.Context, reqs []*fundRequest) (address.Address, cid.Cid, error) {
+	total := types.NewInt(0)
+	for _, r := range reqs {
+		total = types.BigAdd(total, r.amt)
+	}
+
+	ch, err := f.findChannel(ctx)
+	if err != nil {
+		return address.Undef, cid.Undef, err
+	}
+
+	if ch == address.Undef {
+		if len(reqs) == 0 {
+			return address.Undef, cid.Undef, nil
+		}
+		return f.createChannel(ctx, total)
+	}
+
+	if err := f.waitAddFunds(ctx, ch); err != nil {
+		return ch, cid.Undef, err
+	}
+
+	avail, err := f.pm.AvailableFunds(ctx, ch)
+	if err != nil {
+		return ch, cid.Undef, xerrors.Errorf("getting available funds: %w", err)
+	}
+
+	need := types.BigSub(total, avail)
+	if need.GreaterThan(types.NewInt(0)) {
+		if err := f.addFunds(ctx, ch, need); err != nil {
+			return ch, cid.Undef, err
+		}
+	}
+
+	return ch, cid.Undef, nil
+}
+
+// findChannel returns the open channel from -> to, waiting for it to be
+// created if there is a create message in flight. If the channel is settling
+// or was collected, no channel is returned, so that a new one gets created.
+func (f *channelFunder) findChannel(ctx context.Context) (address.Address, error) {
+	pc, err := f.pm.store.getPendingCreate(f.from, f.to)
+	if err != nil {
+		return address.Undef, xerrors.Errorf("getting pending channel: %w", err)
+	}
+	if pc != nil {
+		return f.waitCreate(ctx, pc)
+	}
+
+	ch, err := f.pm.OutboundChanTo(f.from, f.to)
+	if err != nil {
+		return address.Undef, xerrors.Errorf("findChan: %w", err)
+	}
+
+	return ch, nil
+}
+
+func (f *channelFunder) createChannel(ctx context.Context, amt types.BigInt) (address.Address, cid.Cid, error) {
+	params, aerr := actors.SerializeParams(&paych.ConstructorParams{From: f.from, To: f.to})
 	if aerr != nil {
 		return address.Undef, cid.Undef, aerr
 	}
@@ -33,7 +202,7 @@ func (pm *Manager) createPaych(ctx context.Context, from, to address.Address, am
 
 	msg := &types.Message{
 		To:       builtin.InitActorAddr,
-		From:     from,
+		From:     f.from,
 		Value:    amt,
 		Method:   builtin.MethodsInit.Exec,
 		Params:   enc,
@@ -41,63 +210,131 @@ func (pm *Manager) createPaych(ctx context.Context, from, to address.Address, am
 		GasPrice: types.NewInt(0),
 	}
 
-	smsg, err := pm.mpool.MpoolPushMessage(ctx, msg)
+	smsg, err := f.pm.api.MpoolPushMessage(ctx, msg)
 	if err != nil {
 		return address.Undef, cid.Undef, xerrors.Errorf("initializing paych actor: %w", err)
 	}
 
-	mcid := smsg.Cid()
+	pc := &PendingChannel{
+		From:   f.from,
+		To:     f.to,
+		Amount: amt,
+		Msg:    smsg.Cid(),
+	}
+	if err := f.pm.store.putPendingCreate(pc); err != nil {
+		return address.Undef, cid.Undef, xerrors.Errorf("recording pending channel: %w", err)
+	}
+
+	ch, err := f.waitCreate(ctx, pc)
+	return ch, smsg.Cid(), err
+}
 
-	// TODO: wait outside the store lock!
-	//  (tricky because we need to setup channel tracking before we know it's address)
-	mwait, err := pm.state.StateWaitMsg(ctx, mcid)
+// waitCreate waits for the create message, and starts tracking the channel.
+// If waiting fails, the pending record is kept, so that the next request, or
+// the next start of the node, picks it up again.
+func (f *channelFunder) waitCreate(ctx context.Context, pc *PendingChannel) (address.Address, error) {
+	mwait, err := f.pm.api.StateWaitMsg(ctx, pc.Msg)
 	if err != nil {
-		return address.Undef, cid.Undef, xerrors.Errorf("wait msg: %w", err)
+		return address.Undef, xerrors.Errorf("wait msg: %w", err)
 	}
 
 	if mwait.Receipt.ExitCode != 0 {
-		return address.Undef, cid.Undef, fmt.Errorf("payment channel creation failed (exit code %d)", mwait.Receipt.ExitCode)
+		if err := f.pm.store.removePendingCreate(pc.From, pc.To); err != nil {
+			return address.Undef, xerrors.Errorf("removing pending channel: %w", err)
+		}
+		return address.Undef, fmt.Errorf("payment channel creation failed (exit code %d)", mwait.Receipt.ExitCode)
 	}
 
 	var decodedReturn init_.ExecReturn
 	err = decodedReturn.UnmarshalCBOR(bytes.NewReader(mwait.Receipt.Return))
 	if err != nil {
-		return address.Undef, cid.Undef, err
+		return address.Undef, err
 	}
 	paychaddr := decodedReturn.RobustAddress
 
-	ci, err := pm.loadOutboundChannelInfo(ctx, paychaddr)
-	if err != nil {
-		return address.Undef, cid.Undef, xerrors.Errorf("loading channel info: %w", err)
+	// the channel may already be tracked if the node stopped right before
+	// the pending record was removed
+	_, err = f.pm.store.getChannelInfo(paychaddr)
+	switch err {
+	case nil:
+	case ErrChannelNotTracked:
+		ci, err := f.pm.loadOutboundChannelInfo(ctx, paychaddr)
+		if err != nil {
+			return address.Undef, xerrors.Errorf("loading channel info: %w", err)
+		}
+
+		if err := f.pm.store.TrackChannel(ci); err != nil {
+			return address.Undef, xerrors.Errorf("tracking channel: %w", err)
+		}
+
+		if err := f.pm.watchChannel(paychaddr); err != nil {
+			return address.Undef, xerrors.Errorf("watching channel: %w", err)
+		}
+	default:
+		return address.Undef, err
 	}
 
-	if err := pm.store.trackChannel(ci); err != nil {
-		return address.Undef, cid.Undef, xerrors.Errorf("tracking channel: %w", err)
+	if err := f.pm.store.removePendingCreate(pc.From, pc.To); err != nil {
+		return address.Undef, xerrors.Errorf("removing pending channel: %w", err)
 	}
 
-	return paychaddr, mcid, nil
+	return paychaddr, nil
 }
 
-func (pm *Manager) addFunds(ctx context.Context, ch address.Address, from address.Address, amt types.BigInt) error {
+func (f *channelFunder) addFunds(ctx context.Context, ch address.Address, amt types.BigInt) error {
 	msg := &types.Message{
 		To:       ch,
-		From:     from,
+		From:     f.from,
 		Value:    amt,
 		Method:   0,
 		GasLimit: 1000000,
 		GasPrice: types.NewInt(0),
 	}
 
-	smsg, err := pm.mpool.MpoolPushMessage(ctx, msg)
+	smsg, err := f.pm.api.MpoolPushMessage(ctx, msg)
 	if err != nil {
 		return err
 	}
 
-	mwait, err := pm.state.StateWaitMsg(ctx, smsg.Cid()) // TODO: wait outside the store lock!
+	mcid := smsg.Cid()
+	err = f.pm.store.updateChannel(ch, func(ci *ChannelInfo) error {
+		ci.PendingAmount = amt
+		ci.AddFundsMsg = &mcid
+		return nil
+	})
+	if err != nil {
+		return xerrors.Errorf("recording pending funds: %w", err)
+	}
+
+	return f.waitAddFunds(ctx, ch)
+}
+
+// waitAddFunds waits for the add funds message recorded for the channel, if
+// there is one
+func (f *channelFunder) waitAddFunds(ctx context.Context, ch address.Address) error {
+	ci, err := f.pm.store.getChannelInfo(ch)
 	if err != nil {
 		return err
 	}
 
+	if ci.AddFundsMsg == nil {
+		return nil
+	}
+
+	mwait, err := f.pm.api.StateWaitMsg(ctx, *ci.AddFundsMsg)
+	if err != nil {
+		return xerrors.Errorf("wait msg: %w", err)
+	}
+
+	err = f.pm.store.updateChannel(ch, func(ci *ChannelInfo) error {
+		ci.PendingAmount = types.NewInt(0)
+		ci.AddFundsMsg = nil
+		return nil
+	})
+	if err != nil {
+		return xerrors.Errorf("clearing pending funds: %w", err)
+	}
+
 	if mwait.Receipt.ExitCode != 0 {
 		return fmt.Errorf("voucher channel creation failed: adding funds (exit code %d)", mwait.Receipt.ExitCode)
 	}
@@ -105,23 +342,35 @@ func (pm *Manager) addFunds(ctx context.Context, ch address.Address, from addres
 	return nil
 }
 
-func (pm *Manager) GetPaych(ctx context.Context, from, to address.Address, ensureFree types.BigInt) (address.Address, cid.Cid, error) {
-	pm.store.lk.Lock()
-	defer pm.store.lk.Unlock()
+// AvailableFunds returns the part of the channel balance which isn't
+// committed to vouchers yet. Funds in flight aren't included.
+func (pm *Manager) AvailableFunds(ctx context.Context, ch address.Address) (types.BigInt, error) {
+	act, st, err := pm.loadPaychState(ctx, ch)
+	if err != nil {
+		return types.BigInt{}, err
+	}
 
-	ch, err := pm.store.findChan(func(ci *ChannelInfo) bool {
-		if ci.Direction != DirOutbound {
-			return false
-		}
-		return ci.Control == from && ci.Target == to
-	})
+	ci, err := pm.store.getChannelInfo(ch)
 	if err != nil {
-		return address.Undef, cid.Undef, xerrors.Errorf("findChan: %w", err)
+		return types.BigInt{}, err
+	}
+
+	// vouchers on a lane replace each other, so only the largest one counts
+	spent := map[uint64]types.BigInt{}
+	for _, ls := range st.LaneStates {
+		spent[ls.ID] = ls.Redeemed
 	}
-	if ch != address.Undef {
-		// TODO: Track available funds
-		return ch, cid.Undef, pm.addFunds(ctx, ch, from, ensureFree)
+	for _, v := range ci.Vouchers {
+		cur, ok := spent[v.Voucher.Lane]
+		if !ok || v.Voucher.Amount.GreaterThan(cur) {
+			spent[v.Voucher.Lane] = v.Voucher.Amount
+		}
+	}
+
+	avail := act.Balance
+	for _, amt := range spent {
+		avail = types.BigSub(avail, amt)
 	}
 
-	return pm.createPaych(ctx, from, to, ensureFree)
+	return avail, nil
 }
@@ -0,0 +1,308 @@
+package paychmgr
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/builtin/account"
+	init_ "github.com/filecoin-project/specs-actors/actors/builtin/init"
+	"github.com/filecoin-project/specs-actors/actors/builtin/paych"
+
+	"github.com/filecoin-project/lotus/chain/actors"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+type getPaychResult struct {
+	ch   address.Address
+	mcid cid.Cid
+	err  error
+}
+
+func getPaychAsync(ctx context.Context, pm *Manager, from, to address.Address, amt uint64) chan getPaychResult {
+	out := make(chan getPaychResult, 1)
+	go func() {
+		ch, mcid, err := pm.GetPaych(ctx, from, to, types.NewInt(amt))
+		out <- getPaychResult{ch: ch, mcid: mcid, err: err}
+	}()
+	return out
+}
+
+func waitResult(t *testing.T, res chan getPaychResult) getPaychResult {
+	t.Helper()
+
+	select {
+	case r := <-res:
+		if r.err != nil {
+			t.Fatal(r.err)
+		}
+		return r
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for GetPaych")
+		return getPaychResult{}
+	}
+}
+
+func queued(pm *Manager, from, to address.Address) int {
+	f := pm.funder(from, to)
+	f.lk.Lock()
+	defer f.lk.Unlock()
+
+	return len(f.queue)
+}
+
+// setupAccounts adds the account actors of the channel parties to the chain
+func setupAccounts(fc *fakeChain, addrs ...address.Address) {
+	for _, a := range addrs {
+		fc.setActor(a, types.NewInt(1000), &account.State{Address: a})
+	}
+}
+
+func execReturn(t *testing.T, ch address.Address) *types.MessageReceipt {
+	ret, err := actors.SerializeParams(&init_.ExecReturn{IDAddress: ch, RobustAddress: ch})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &types.MessageReceipt{ExitCode: 0, Return: ret}
+}
+
+func newChannelState(from, to address.Address) *paych.State {
+	return &paych.State{From: from, To: to, ToSend: types.NewInt(0)}
+}
+
+func TestGetPaychCoalesce(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fc := newFakeChain()
+	pm := newTestManager(fc, newFakeEvents(), dssync.MutexWrap(datastore.NewMapDatastore()))
+	if err := pm.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	ch, from, to := testAddr(t, 100), testAddr(t, 101), testAddr(t, 102)
+	setupAccounts(fc, from, to)
+
+	first := getPaychAsync(ctx, pm, from, to, 10)
+	waitFor(t, "the create message", func() bool {
+		return len(fc.messages()) == 1 && fc.waiters() == 1
+	})
+
+	create := fc.messages()[0]
+	if create.To != builtin.InitActorAddr || !create.Value.Equals(types.NewInt(10)) {
+		t.Fatalf("expected a create message with 10 attoFIL, got %+v", create)
+	}
+
+	// requests arriving while the channel is created are queued
+	var rest []chan getPaychResult
+	for i := 0; i < 3; i++ {
+		rest = append(rest, getPaychAsync(ctx, pm, from, to, 5))
+	}
+	waitFor(t, "requests to be queued", func() bool {
+		return queued(pm, from, to) == 3
+	})
+
+	// channels between other addresses don't wait for this one
+	other := testAddr(t, 103)
+	setupAccounts(fc, other)
+	getPaychAsync(ctx, pm, from, other, 1)
+	waitFor(t, "the other create message", func() bool {
+		return len(fc.messages()) == 2
+	})
+
+	fc.setActor(ch, types.NewInt(10), newChannelState(from, to))
+	fc.land(create.Cid(), execReturn(t, ch))
+
+	res := waitResult(t, first)
+	if res.ch != ch || !res.mcid.Equals(create.Cid()) {
+		t.Fatalf("expected channel %s created by %s, got %s created by %s", ch, create.Cid(), res.ch, res.mcid)
+	}
+
+	// the queued requests are served with a single message
+	waitFor(t, "the add funds message", func() bool {
+		return len(fc.messages()) == 3 && fc.waiters() == 2
+	})
+	add := fc.messages()[2]
+	if add.To != ch || !add.Value.Equals(types.NewInt(5)) {
+		t.Fatalf("expected 5 attoFIL to be added to %s, got %+v", ch, add)
+	}
+
+	// the store isn't locked while waiting for messages
+	locked := make(chan error, 1)
+	go func() {
+		_, err := pm.ListChannels()
+		if err == nil {
+			_, err = pm.AllocateLane(ch)
+		}
+		locked <- err
+	}()
+	select {
+	case err := <-locked:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("store locked while waiting for the add funds message")
+	}
+
+	ci, err := pm.GetChannelInfo(ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ci.AddFundsMsg == nil || !ci.AddFundsMsg.Equals(add.Cid()) || !ci.PendingAmount.Equals(types.NewInt(5)) {
+		t.Fatalf("expected the add funds message to be recorded, got %v (%s)", ci.AddFundsMsg, ci.PendingAmount)
+	}
+
+	fc.setActor(ch, types.NewInt(15), newChannelState(from, to))
+	fc.land(add.Cid(), &types.MessageReceipt{ExitCode: 0})
+
+	for _, r := range rest {
+		res := waitResult(t, r)
+		if res.ch != ch || res.mcid != cid.Undef {
+			t.Fatalf("expected channel %s without a create message, got %s (%s)", ch, res.ch, res.mcid)
+		}
+	}
+
+	if len(fc.messages()) != 3 {
+		t.Fatalf("expected no more messages, got %d", len(fc.messages()))
+	}
+
+	ci, err = pm.GetChannelInfo(ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ci.AddFundsMsg != nil || !ci.PendingAmount.IsZero() {
+		t.Errorf("expected the pending funds to be cleared, got %v (%s)", ci.AddFundsMsg, ci.PendingAmount)
+	}
+}
+
+func TestGetPaychRecoverCreate(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fc := newFakeChain()
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+
+	ch, from, to := testAddr(t, 100), testAddr(t, 101), testAddr(t, 102)
+	setupAccounts(fc, from, to)
+
+	// the node stopped while the create message was in flight
+	create := &types.Message{To: builtin.InitActorAddr, From: from, Value: types.NewInt(10)}
+	err := NewStore(ds).putPendingCreate(&PendingChannel{
+		From:   from,
+		To:     to,
+		Amount: types.NewInt(10),
+		Msg:    create.Cid(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pm := newTestManager(fc, newFakeEvents(), ds)
+	if err := pm.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, "the create message to be waited for", func() bool {
+		return fc.waiters() == 1
+	})
+
+	// requests wait for the channel instead of creating another one
+	res := getPaychAsync(ctx, pm, from, to, 10)
+	waitFor(t, "the request to be queued", func() bool {
+		return queued(pm, from, to) == 1
+	})
+
+	fc.setActor(ch, types.NewInt(10), newChannelState(from, to))
+	fc.land(create.Cid(), execReturn(t, ch))
+
+	if r := waitResult(t, res); r.ch != ch {
+		t.Fatalf("expected channel %s, got %s", ch, r.ch)
+	}
+
+	if n := len(fc.messages()); n != 0 {
+		t.Errorf("expected no messages to be sent, got %d", n)
+	}
+
+	pc, err := pm.store.getPendingCreate(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pc != nil {
+		t.Error("expected the pending channel to be removed")
+	}
+
+	ci, err := pm.GetChannelInfo(ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ci.Direction != DirOutbound || ci.Control != from || ci.Target != to {
+		t.Errorf("expected outbound channel %s -> %s, got %+v", from, to, ci)
+	}
+}
+
+func TestGetPaychRecoverAddFunds(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fc := newFakeChain()
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+
+	ch, from, to := testAddr(t, 100), testAddr(t, 101), testAddr(t, 102)
+	fc.setActor(ch, types.NewInt(10), newChannelState(from, to))
+
+	// the node stopped while adding funds
+	add := &types.Message{To: ch, From: from, Value: types.NewInt(5)}
+	addCid := add.Cid()
+	err := NewStore(ds).TrackChannel(&ChannelInfo{
+		Channel:       ch,
+		Control:       from,
+		Target:        to,
+		Direction:     DirOutbound,
+		PendingAmount: types.NewInt(5),
+		AddFundsMsg:   &addCid,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pm := newTestManager(fc, newFakeEvents(), ds)
+	if err := pm.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, "the add funds message to be waited for", func() bool {
+		return fc.waiters() == 1
+	})
+
+	// the request is served by the funds in flight
+	res := getPaychAsync(ctx, pm, from, to, 15)
+	waitFor(t, "the request to be queued", func() bool {
+		return queued(pm, from, to) == 1
+	})
+
+	fc.setActor(ch, types.NewInt(15), newChannelState(from, to))
+	fc.land(addCid, &types.MessageReceipt{ExitCode: 0})
+
+	if r := waitResult(t, res); r.ch != ch {
+		t.Fatalf("expected channel %s, got %s", ch, r.ch)
+	}
+
+	if n := len(fc.messages()); n != 0 {
+		t.Errorf("expected no messages to be sent, got %d", n)
+	}
+
+	ci, err := pm.GetChannelInfo(ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ci.AddFundsMsg != nil || !ci.PendingAmount.IsZero() {
+		t.Errorf("expected the pending funds to be cleared, got %v (%s)", ci.AddFundsMsg, ci.PendingAmount)
+	}
+}
@@ -78,3 +78,8 @@ func (pm *Manager) laneState(ctx context.Context, ch address.Address, lane uint6
 
 	return *ls, nil
 }
+
+// ChannelState loads the on-chain state of the channel at the chain head
+func (pm *Manager) ChannelState(ctx context.Context, ch address.Address) (*types.Actor, *paych.State, error) {
+	return pm.loadPaychState(ctx, ch)
+}
@@ -8,6 +8,7 @@ import (
 	"sync"
 
 	"github.com/filecoin-project/specs-actors/actors/builtin/paych"
+	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-datastore"
 	"github.com/ipfs/go-datastore/namespace"
 	dsq "github.com/ipfs/go-datastore/query"
@@ -16,6 +17,7 @@ import (
 	"github.com/filecoin-project/go-address"
 	cborrpc "github.com/filecoin-project/go-cbor-util"
 
+	"github.com/filecoin-project/lotus/chain/types"
 	"github.com/filecoin-project/lotus/node/modules/dtypes"
 )
 
@@ -25,12 +27,15 @@ type Store struct {
 	lk sync.Mutex // TODO: this can be split per paych
 
 	ds datastore.Batching
+
+	// channels being created, by from/to pair
+	pending datastore.Batching
 }
 
 func NewStore(ds dtypes.MetadataDS) *Store {
-	ds = namespace.Wrap(ds, datastore.NewKey("/paych/"))
 	return &Store{
-		ds: ds,
+		ds:      namespace.Wrap(ds, datastore.NewKey("/paych/")),
+		pending: namespace.Wrap(ds, datastore.NewKey("/pending-paych/")),
 	}
 }
 
@@ -39,6 +44,19 @@ const (
 	DirOutbound = 2
 )
 
+// Channel lifecycle states, as recorded in ChannelInfo.State
+const (
+	ChanOpen      = 0
+	ChanSettling  = 1 // Settle landed on chain, waiting for the settlement period to end
+	ChanCollected = 2 // funds were paid out, the actor is gone
+)
+
+var ChanStateNames = map[uint64]string{
+	ChanOpen:      "open",
+	ChanSettling:  "settling",
+	ChanCollected: "collected",
+}
+
 type VoucherInfo struct {
 	Voucher *paych.SignedVoucher
 	Proof   []byte
@@ -52,6 +70,21 @@ type ChannelInfo struct {
 	Direction uint64
 	Vouchers  []*VoucherInfo
 	NextLane  uint64
+
+	State      uint64
+	SettlingAt uint64 // epoch at which the settlement period ends, if settling
+
+	// funds sent to the channel, which didn't land on chain yet
+	PendingAmount types.BigInt
+	AddFundsMsg   *cid.Cid
+}
+
+// PendingChannel records a channel create message in flight
+type PendingChannel struct {
+	From   address.Address
+	To     address.Address
+	Amount types.BigInt
+	Msg    cid.Cid
 }
 
 func dskeyForChannel(addr address.Address) datastore.Key {
@@ -177,6 +210,88 @@ func (ps *Store) findChan(filter func(*ChannelInfo) bool) (address.Address, erro
 	return address.Undef, nil
 }
 
+// updateChannel applies cb to the stored channel info, and persists the result
+func (ps *Store) updateChannel(ch address.Address, cb func(*ChannelInfo) error) error {
+	ps.lk.Lock()
+	defer ps.lk.Unlock()
+
+	ci, err := ps.getChannelInfo(ch)
+	if err != nil {
+		return err
+	}
+
+	if err := cb(ci); err != nil {
+		return err
+	}
+
+	return ps.putChannelInfo(ci)
+}
+
+func dskeyForPending(from, to address.Address) datastore.Key {
+	return datastore.NewKey(from.String() + "-" + to.String())
+}
+
+func (ps *Store) putPendingCreate(pc *PendingChannel) error {
+	b, err := cborrpc.Dump(pc)
+	if err != nil {
+		return err
+	}
+
+	return ps.pending.Put(dskeyForPending(pc.From, pc.To), b)
+}
+
+// getPendingCreate returns nil if there is no create message in flight for
+// the pair
+func (ps *Store) getPendingCreate(from, to address.Address) (*PendingChannel, error) {
+	b, err := ps.pending.Get(dskeyForPending(from, to))
+	if err == datastore.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var pc PendingChannel
+	if err := pc.UnmarshalCBOR(bytes.NewReader(b)); err != nil {
+		return nil, err
+	}
+
+	return &pc, nil
+}
+
+func (ps *Store) removePendingCreate(from, to address.Address) error {
+	return ps.pending.Delete(dskeyForPending(from, to))
+}
+
+func (ps *Store) listPendingCreates() ([]*PendingChannel, error) {
+	res, err := ps.pending.Query(dsq.Query{})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	var out []*PendingChannel
+	for {
+		res, ok := res.NextSync()
+		if !ok {
+			break
+		}
+
+		if res.Error != nil {
+			return nil, res.Error
+		}
+
+		var pc PendingChannel
+		if err := pc.UnmarshalCBOR(bytes.NewReader(res.Value)); err != nil {
+			return nil, xerrors.Errorf("failed reading pending paych (%q) from datastore: %w", res.Key, err)
+		}
+
+		out = append(out, &pc)
+	}
+
+	return out, nil
+}
+
 func (ps *Store) AllocateLane(ch address.Address) (uint64, error) {
 	ps.lk.Lock()
 	defer ps.lk.Unlock()
@@ -28,10 +28,11 @@ var _ sealing.SealingAPI = new(SealingAPIAdapter)
 
 type SealingAPIAdapter struct {
 	delegate storageMinerApi
+	policy   MessagePolicy
 }
 
-func NewSealingAPIAdapter(api storageMinerApi) SealingAPIAdapter {
-	return SealingAPIAdapter{delegate: api}
+func NewSealingAPIAdapter(api storageMinerApi, policy MessagePolicy) SealingAPIAdapter {
+	return SealingAPIAdapter{delegate: api, policy: policy}
 }
 
 func (s SealingAPIAdapter) StateMinerSectorSize(ctx context.Context, maddr address.Address, tok sealing.TipSetToken) (abi.SectorSize, error) {
@@ -154,6 +155,10 @@ func (s SealingAPIAdapter) SendMsg(ctx context.Context, from, to address.Address
 		Params:   params,
 	}
 
+	if kind, ok := minerMsgKind(method); ok {
+		s.policy.Apply(kind, from, &msg)
+	}
+
 	smsg, err := s.delegate.MpoolPushMessage(ctx, &msg)
 	if err != nil {
 		return cid.Undef, err
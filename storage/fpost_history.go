@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
+	dsq "github.com/ipfs/go-datastore/query"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/api"
+)
+
+// maxPoStRecords is the number of PoSt attempts kept in the history, older
+// attempts are dropped
+const maxPoStRecords = 1000
+
+// postHistory persists fallback PoSt attempts, keyed by proving period start
+// and start time, so that several attempts for the same proving period are
+// kept
+//
+//	/<proving period start>/<start unix nanos>    JSON api.PoStRecord
+type postHistory struct {
+	ds  datastore.Batching
+	max int
+}
+
+func newPoStHistory(ds datastore.Batching) *postHistory {
+	return &postHistory{
+		ds:  namespace.Wrap(ds, datastore.NewKey("/post-history")),
+		max: maxPoStRecords,
+	}
+}
+
+func (h *postHistory) put(rec *api.PoStRecord) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	k := datastore.NewKey(fmt.Sprintf("/%016x/%016x", uint64(rec.ProvingPeriodStart), uint64(rec.Start.UnixNano())))
+	if err := h.ds.Put(k, b); err != nil {
+		return err
+	}
+
+	return h.prune()
+}
+
+// prune drops the oldest attempts beyond max
+func (h *postHistory) prune() error {
+	keys, err := h.keys()
+	if err != nil {
+		return err
+	}
+
+	if len(keys) <= h.max {
+		return nil
+	}
+
+	for _, k := range keys[h.max:] {
+		if err := h.ds.Delete(datastore.NewKey(k)); err != nil {
+			return xerrors.Errorf("dropping PoSt record %s: %w", k, err)
+		}
+	}
+
+	return nil
+}
+
+// list returns recorded attempts, newest first. limit 0 returns all attempts.
+func (h *postHistory) list(limit int) ([]api.PoStRecord, error) {
+	keys, err := h.keys()
+	if err != nil {
+		return nil, err
+	}
+
+	if limit > 0 && len(keys) > limit {
+		keys = keys[:limit]
+	}
+
+	out := make([]api.PoStRecord, len(keys))
+	for i, k := range keys {
+		b, err := h.ds.Get(datastore.NewKey(k))
+		if err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(b, &out[i]); err != nil {
+			return nil, xerrors.Errorf("decoding PoSt record %s: %w", k, err)
+		}
+	}
+
+	return out, nil
+}
+
+// keys returns the keys of recorded attempts, newest first
+func (h *postHistory) keys() ([]string, error) {
+	res, err := h.ds.Query(dsq.Query{KeysOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	var keys []string
+	for r := range res.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		keys = append(keys, r.Key)
+	}
+
+	// keys are zero padded, so they sort by proving period, then start time
+	sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+	return keys, nil
+}
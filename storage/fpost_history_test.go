@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+
+	"github.com/filecoin-project/lotus/api"
+)
+
+func TestPoStHistory(t *testing.T) {
+	h := newPoStHistory(dssync.MutexWrap(datastore.NewMapDatastore()))
+	h.max = 4
+
+	start := time.Unix(1000, 0)
+	put := func(period abi.ChainEpoch, attempt time.Duration, status api.PoStStatus) {
+		rec := &api.PoStRecord{ProvingPeriodStart: period, Start: start.Add(attempt), Status: status}
+		if err := h.put(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// period 100 sorts after 20, and the retry in period 100 after the first
+	// attempt
+	put(20, 0, api.PoStOk)
+	put(100, time.Second, api.PoStFailed)
+	put(100, time.Minute, api.PoStOk)
+	put(300, 0, api.PoStComputing)
+
+	recs, err := h.list(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []struct {
+		period abi.ChainEpoch
+		status api.PoStStatus
+	}{
+		{300, api.PoStComputing},
+		{100, api.PoStOk},
+		{100, api.PoStFailed},
+		{20, api.PoStOk},
+	}
+	if len(recs) != len(expect) {
+		t.Fatalf("expected %d records, got %d", len(expect), len(recs))
+	}
+	for i, e := range expect {
+		if recs[i].ProvingPeriodStart != e.period || recs[i].Status != e.status {
+			t.Errorf("record %d: expected period %d %s, got %d %s", i, e.period, e.status, recs[i].ProvingPeriodStart, recs[i].Status)
+		}
+	}
+
+	recs, err = h.list(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 1 || recs[0].ProvingPeriodStart != 300 {
+		t.Fatalf("expected the newest record, got %+v", recs)
+	}
+
+	// updating a record keeps its key
+	put(300, 0, api.PoStSubmitted)
+
+	// the oldest records are dropped beyond the max
+	put(400, 0, api.PoStOk)
+	put(500, 0, api.PoStOk)
+
+	recs, err = h.list(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 4 {
+		t.Fatalf("expected 4 records, got %d", len(recs))
+	}
+	for i, period := range []abi.ChainEpoch{500, 400, 300, 100} {
+		if recs[i].ProvingPeriodStart != period {
+			t.Errorf("record %d: expected period %d, got %d", i, period, recs[i].ProvingPeriodStart)
+		}
+	}
+	if recs[2].Status != api.PoStSubmitted {
+		t.Errorf("expected the updated record, got %s", recs[2].Status)
+	}
+	if recs[3].Status != api.PoStOk {
+		t.Errorf("expected the newer attempt of period 100 to be kept, got %s", recs[3].Status)
+	}
+}
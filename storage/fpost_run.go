@@ -3,6 +3,7 @@ package storage
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"github.com/filecoin-project/go-address"
 	"time"
 
@@ -14,16 +15,45 @@ import (
 	"go.opencensus.io/trace"
 	"golang.org/x/xerrors"
 
+	"github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/chain/actors"
 	"github.com/filecoin-project/lotus/chain/types"
 )
 
-func (s *FPoStScheduler) failPost(eps abi.ChainEpoch) {
+// faultDuration is the number of epochs newly detected faults are declared
+// for. Sectors which are still faulty after that are declared again.
+const faultDuration = miner.ProvingPeriod
+
+func (s *FPoStScheduler) failPost(eps abi.ChainEpoch, rec *api.PoStRecord, err error) {
 	s.failLk.Lock()
 	if eps > s.failed {
 		s.failed = eps
 	}
 	s.failLk.Unlock()
+
+	rec.Status = api.PoStFailed
+	rec.Err = err.Error()
+	s.recordPost(rec)
+}
+
+// recordPost persists the PoSt record, and tracks it as running until it
+// either fails or lands on chain
+func (s *FPoStScheduler) recordPost(rec *api.PoStRecord) {
+	s.statusLk.Lock()
+	switch rec.Status {
+	case api.PoStComputing, api.PoStSubmitted:
+		running := *rec
+		s.running = &running
+	default:
+		if s.running != nil && s.running.Start.Equal(rec.Start) {
+			s.running = nil
+		}
+	}
+	s.statusLk.Unlock()
+
+	if err := s.history.put(rec); err != nil {
+		log.Errorf("recording PoSt for epoch %d: %+v", rec.ProvingPeriodStart, err)
+	}
 }
 
 func (s *FPoStScheduler) doPost(ctx context.Context, eps abi.ChainEpoch, ts *types.TipSet) {
@@ -38,16 +68,24 @@ func (s *FPoStScheduler) doPost(ctx context.Context, eps abi.ChainEpoch, ts *typ
 		ctx, span := trace.StartSpan(ctx, "FPoStScheduler.doPost")
 		defer span.End()
 
-		proof, err := s.runPost(ctx, eps, ts)
+		rec := &api.PoStRecord{
+			ProvingPeriodStart: eps,
+			Height:             ts.Height(),
+			Start:              time.Now(),
+			Status:             api.PoStComputing,
+		}
+		s.recordPost(rec)
+
+		proof, err := s.runPost(ctx, eps, ts, rec)
 		if err != nil {
 			log.Errorf("runPost failed: %+v", err)
-			s.failPost(eps)
+			s.failPost(eps, rec, err)
 			return
 		}
 
-		if err := s.submitPost(ctx, proof); err != nil {
+		if err := s.submitPost(ctx, proof, rec); err != nil {
 			log.Errorf("submitPost failed: %+v", err)
-			s.failPost(eps)
+			s.failPost(eps, rec, err)
 			return
 		}
 
@@ -63,14 +101,12 @@ func (s *FPoStScheduler) declareFaults(ctx context.Context, fc uint64, params *m
 	}
 
 	msg := &types.Message{
-		To:       s.actor,
-		From:     s.worker,
-		Method:   builtin.MethodsMiner.DeclareTemporaryFaults,
-		Params:   enc,
-		Value:    types.NewInt(0),
-		GasLimit: 10000000, // i dont know help
-		GasPrice: types.NewInt(1),
+		To:     s.actor,
+		Method: builtin.MethodsMiner.DeclareTemporaryFaults,
+		Params: enc,
+		Value:  types.NewInt(0),
 	}
+	s.policy.Apply(MsgDeclareFaults, s.worker, msg)
 
 	sm, err := s.api.MpoolPushMessage(ctx, msg)
 	if err != nil {
@@ -90,12 +126,13 @@ func (s *FPoStScheduler) declareFaults(ctx context.Context, fc uint64, params *m
 	return nil
 }
 
-func (s *FPoStScheduler) checkFaults(ctx context.Context, ssi []abi.SectorNumber) ([]abi.SectorNumber, error) {
-	//faults := s.sb.Scrub(ssi)
-	log.Warnf("Stub checkFaults")
-	var faults []struct {
-		SectorNum abi.SectorNumber
-		Err       error
+// checkFaults checks the files of the sectors, and declares the faulty ones
+// which aren't declared on chain yet, unless declare is false. It returns all
+// faulty sectors, so that they are skipped when generating the proof.
+func (s *FPoStScheduler) checkFaults(ctx context.Context, ssi []abi.SectorNumber, declare bool) ([]abi.SectorNumber, error) {
+	health, err := s.checker.Check(ctx, ssi, true)
+	if err != nil {
+		return nil, xerrors.Errorf("checking sector health: %w", err)
 	}
 
 	declaredFaults := map[abi.SectorNumber]struct{}{}
@@ -111,39 +148,44 @@ func (s *FPoStScheduler) checkFaults(ctx context.Context, ssi []abi.SectorNumber
 		}
 	}
 
-	var faultIDs []abi.SectorNumber
-	if len(faults) > 0 {
-		params := &miner.DeclareTemporaryFaultsParams{
-			Duration:      900, // TODO: duration is annoying
-			SectorNumbers: abi.NewBitField(),
-		}
-
-		for _, fault := range faults {
-			if _, ok := declaredFaults[(fault.SectorNum)]; ok {
-				continue
-			}
+	params := &miner.DeclareTemporaryFaultsParams{
+		Duration:      faultDuration,
+		SectorNumbers: abi.NewBitField(),
+	}
 
-			log.Warnf("new fault detected: sector %d: %s", fault.SectorNum, fault.Err)
-			declaredFaults[fault.SectorNum] = struct{}{}
+	var newFaults uint64
+	for _, h := range health {
+		if h.Status != api.SectorHealthFaulty {
+			continue
 		}
 
-		faultIDs = make([]abi.SectorNumber, 0, len(declaredFaults))
-		for fault := range declaredFaults {
-			faultIDs = append(faultIDs, fault)
-			params.SectorNumbers.Set(uint64(fault))
+		if _, ok := declaredFaults[h.Sector]; ok {
+			continue
 		}
 
-		if len(faultIDs) > 0 {
-			if err := s.declareFaults(ctx, uint64(len(faultIDs)), params); err != nil {
-				return nil, err
-			}
+		log.Warnf("new fault detected: sector %d: %s", h.Sector, h.Err)
+		declaredFaults[h.Sector] = struct{}{}
+		params.SectorNumbers.Set(uint64(h.Sector))
+		newFaults++
+	}
+
+	if declare && newFaults > 0 {
+		if err := s.declareFaults(ctx, newFaults, params); err != nil {
+			return nil, err
 		}
 	}
 
+	faultIDs := make([]abi.SectorNumber, 0, len(declaredFaults))
+	for fault := range declaredFaults {
+		faultIDs = append(faultIDs, fault)
+	}
+
 	return faultIDs, nil
 }
 
-func (s *FPoStScheduler) runPost(ctx context.Context, eps abi.ChainEpoch, ts *types.TipSet) (*abi.OnChainPoStVerifyInfo, error) {
+// runPost computes the proof, filling in the sectors, faults and duration of
+// rec. Faults aren't declared for dry runs.
+func (s *FPoStScheduler) runPost(ctx context.Context, eps abi.ChainEpoch, ts *types.TipSet, rec *api.PoStRecord) (*abi.OnChainPoStVerifyInfo, error) {
 	ctx, span := trace.StartSpan(ctx, "storage.runPost")
 	defer span.End()
 
@@ -177,11 +219,14 @@ func (s *FPoStScheduler) runPost(ctx context.Context, eps abi.ChainEpoch, ts *ty
 		snums = append(snums, si.SectorNumber)
 	}
 
-	faults, err := s.checkFaults(ctx, snums)
+	faults, err := s.checkFaults(ctx, snums, !rec.DryRun)
 	if err != nil {
 		log.Errorf("Failed to declare faults: %+v", err)
 	}
 
+	rec.Sectors = uint64(len(ssi))
+	rec.Faults = faults
+
 	tsStart := time.Now()
 
 	log.Infow("generating fPoSt",
@@ -208,6 +253,7 @@ func (s *FPoStScheduler) runPost(ctx context.Context, eps abi.ChainEpoch, ts *ty
 	proof := postOut.Proof[:1]
 
 	elapsed := time.Since(tsStart)
+	rec.Duration = elapsed
 	log.Infow("submitting PoSt", "pLen", len(proof), "elapsed", elapsed)
 
 	candidates := make([]abi.PoStCandidate, len(scandidates))
@@ -250,7 +296,7 @@ func (s *FPoStScheduler) sortedSectorInfo(ctx context.Context, ts *types.TipSet)
 	return sbsi, nil
 }
 
-func (s *FPoStScheduler) submitPost(ctx context.Context, proof *abi.OnChainPoStVerifyInfo) error {
+func (s *FPoStScheduler) submitPost(ctx context.Context, proof *abi.OnChainPoStVerifyInfo, rec *api.PoStRecord) error {
 	ctx, span := trace.StartSpan(ctx, "storage.commitPost")
 	defer span.End()
 
@@ -260,13 +306,18 @@ func (s *FPoStScheduler) submitPost(ctx context.Context, proof *abi.OnChainPoStV
 	}
 
 	msg := &types.Message{
-		To:       s.actor,
-		From:     s.worker,
-		Method:   builtin.MethodsMiner.SubmitWindowedPoSt,
-		Params:   enc,
-		Value:    types.NewInt(1000), // currently hard-coded late fee in actor, returned if not late
-		GasLimit: 10000000,           // i dont know help
-		GasPrice: types.NewInt(1),
+		To:     s.actor,
+		Method: builtin.MethodsMiner.SubmitWindowedPoSt,
+		Params: enc,
+		Value:  types.NewInt(1000), // currently hard-coded late fee in actor, returned if not late
+	}
+	s.policy.Apply(MsgWindowPoSt, s.worker, msg)
+
+	// cheaper messages sent before the PoSt would delay it
+	if !msg.GasPrice.Nil() {
+		if err := bumpPending(ctx, s.api, s.worker, msg.GasPrice); err != nil {
+			log.Errorf("raising gas price of pending messages: %+v", err)
+		}
 	}
 
 	// TODO: consider maybe caring about the output
@@ -277,18 +328,29 @@ func (s *FPoStScheduler) submitPost(ctx context.Context, proof *abi.OnChainPoStV
 
 	log.Infof("Submitted fallback post: %s", sm.Cid())
 
+	mcid := sm.Cid()
+	rec.Status = api.PoStSubmitted
+	rec.Message = &mcid
+	s.recordPost(rec)
+
 	go func() {
-		rec, err := s.api.StateWaitMsg(context.TODO(), sm.Cid())
+		ml, err := s.api.StateWaitMsg(context.TODO(), sm.Cid())
 		if err != nil {
 			log.Error(err)
 			return
 		}
 
-		if rec.Receipt.ExitCode == 0 {
+		rec.ExitCode = ml.Receipt.ExitCode
+		if ml.Receipt.ExitCode == 0 {
+			rec.Status = api.PoStOk
+			s.recordPost(rec)
 			return
 		}
 
-		log.Errorf("Submitting fallback post %s failed: exit %d", sm.Cid(), rec.Receipt.ExitCode)
+		log.Errorf("Submitting fallback post %s failed: exit %d", sm.Cid(), ml.Receipt.ExitCode)
+		rec.Status = api.PoStFailed
+		rec.Err = fmt.Sprintf("message exit %d", ml.Receipt.ExitCode)
+		s.recordPost(rec)
 	}()
 
 	return nil
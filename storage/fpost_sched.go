@@ -3,7 +3,9 @@ package storage
 import (
 	"context"
 	"sync"
+	"time"
 
+	"github.com/ipfs/go-datastore"
 	"go.opencensus.io/trace"
 	"golang.org/x/xerrors"
 
@@ -11,6 +13,7 @@ import (
 	"github.com/filecoin-project/specs-actors/actors/abi"
 	"github.com/filecoin-project/specs-storage/storage"
 
+	"github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/build"
 	"github.com/filecoin-project/lotus/chain/store"
 	"github.com/filecoin-project/lotus/chain/types"
@@ -23,10 +26,13 @@ const StartConfidence = 4 // TODO: config
 type FPoStScheduler struct {
 	api       storageMinerApi
 	sb        storage.Prover
+	checker   *SectorChecker
+	history   *postHistory
 	proofType abi.RegisteredProof
 
 	actor  address.Address
 	worker address.Address
+	policy MessagePolicy
 
 	cur *types.TipSet
 
@@ -36,10 +42,14 @@ type FPoStScheduler struct {
 
 	failed abi.ChainEpoch // eps
 	failLk sync.Mutex
+
+	// running is a copy of the record of the PoSt in progress
+	running  *api.PoStRecord
+	statusLk sync.Mutex
 }
 
-func NewFPoStScheduler(api storageMinerApi, sb storage.Prover, actor address.Address, worker address.Address, rt abi.RegisteredProof) *FPoStScheduler {
-	return &FPoStScheduler{api: api, sb: sb, actor: actor, worker: worker, proofType: rt}
+func NewFPoStScheduler(api storageMinerApi, sb storage.Prover, checker *SectorChecker, ds datastore.Batching, actor address.Address, worker address.Address, policy MessagePolicy, rt abi.RegisteredProof) *FPoStScheduler {
+	return &FPoStScheduler{api: api, sb: sb, checker: checker, history: newPoStHistory(ds), actor: actor, worker: worker, policy: policy, proofType: rt}
 }
 
 func (s *FPoStScheduler) Run(ctx context.Context) {
@@ -174,3 +184,100 @@ func (s *FPoStScheduler) shouldFallbackPost(ctx context.Context, ts *types.TipSe
 	}
 	return 0, false, nil
 }
+
+// Status returns the state of the current proving period, and of the last
+// PoSt
+func (s *FPoStScheduler) Status(ctx context.Context) (*api.ProvingStatus, error) {
+	ts, err := s.api.ChainHead(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("getting chain head: %w", err)
+	}
+
+	ps, err := s.api.StateMinerPostState(ctx, s.actor, ts.Key())
+	if err != nil {
+		return nil, xerrors.Errorf("getting PoSt state: %w", err)
+	}
+
+	pset, err := s.api.StateMinerProvingSet(ctx, s.actor, ts.Key())
+	if err != nil {
+		return nil, xerrors.Errorf("getting proving set: %w", err)
+	}
+
+	faults, err := s.api.StateMinerFaults(ctx, s.actor, ts.Key())
+	if err != nil {
+		return nil, xerrors.Errorf("getting faults: %w", err)
+	}
+
+	out := &api.ProvingStatus{
+		Head:               ts.Height(),
+		ProvingPeriodStart: ps.ProvingPeriodStart,
+		ChallengeEpoch:     ps.ProvingPeriodStart + build.FallbackPoStConfidence,
+		ProvingSetSize:     uint64(len(pset)),
+		Faults:             uint64(len(faults)),
+	}
+
+	s.statusLk.Lock()
+	if s.running != nil {
+		running := *s.running
+		out.Running = &running
+	}
+	s.statusLk.Unlock()
+
+	recs, err := s.history.list(2)
+	if err != nil {
+		return nil, xerrors.Errorf("getting PoSt history: %w", err)
+	}
+	for _, rec := range recs {
+		if out.Running != nil && rec.Start.Equal(out.Running.Start) {
+			continue
+		}
+
+		rec := rec
+		out.Last = &rec
+		break
+	}
+
+	return out, nil
+}
+
+// History returns the recorded PoSt attempts, newest first
+func (s *FPoStScheduler) History(limit int) ([]api.PoStRecord, error) {
+	return s.history.list(limit)
+}
+
+// DryRun computes a PoSt against the current head. The proof isn't
+// submitted, and faults aren't declared. Failures to compute the proof are
+// reported in the returned record.
+func (s *FPoStScheduler) DryRun(ctx context.Context) (*api.PoStRecord, error) {
+	ts, err := s.api.ChainHead(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("getting chain head: %w", err)
+	}
+
+	ps, err := s.api.StateMinerPostState(ctx, s.actor, ts.Key())
+	if err != nil {
+		return nil, xerrors.Errorf("getting PoSt state: %w", err)
+	}
+
+	eps := ps.ProvingPeriodStart
+	if eps > ts.Height() {
+		// randomness isn't known for future epochs
+		eps = ts.Height()
+	}
+
+	rec := &api.PoStRecord{
+		ProvingPeriodStart: eps,
+		Height:             ts.Height(),
+		Start:              time.Now(),
+		DryRun:             true,
+	}
+
+	if _, err := s.runPost(ctx, eps, ts, rec); err != nil {
+		rec.Status = api.PoStFailed
+		rec.Err = err.Error()
+		return rec, nil
+	}
+
+	rec.Status = api.PoStOk
+	return rec, nil
+}
@@ -42,6 +42,8 @@ type Miner struct {
 
 	maddr  address.Address
 	worker address.Address
+	policy MessagePolicy
+	files  *SectorFiles
 
 	sealing *sealing.Sealing
 }
@@ -59,9 +61,12 @@ type storageMinerApi interface {
 	StateGetActor(ctx context.Context, actor address.Address, ts types.TipSetKey) (*types.Actor, error)
 	StateGetReceipt(context.Context, cid.Cid, types.TipSetKey) (*types.MessageReceipt, error)
 	StateMarketStorageDeal(context.Context, abi.DealID, types.TipSetKey) (*api.MarketDeal, error)
+	StateMarketDeals(context.Context, types.TipSetKey) (map[string]api.MarketDeal, error)
 	StateMinerFaults(context.Context, address.Address, types.TipSetKey) ([]abi.SectorNumber, error)
 
 	MpoolPushMessage(context.Context, *types.Message) (*types.SignedMessage, error)
+	MpoolPending(context.Context, types.TipSetKey) ([]*types.SignedMessage, error)
+	MpoolReplace(ctx context.Context, from address.Address, nonce uint64, auto bool, gasPrice types.BigInt) (cid.Cid, error)
 
 	ChainHead(context.Context) (*types.TipSet, error)
 	ChainNotify(context.Context) (<-chan []*store.HeadChange, error)
@@ -76,7 +81,7 @@ type storageMinerApi interface {
 	WalletHas(context.Context, address.Address) (bool, error)
 }
 
-func NewMiner(api storageMinerApi, maddr, worker address.Address, h host.Host, ds datastore.Batching, sealer sectorstorage.SectorManager, sc sealing.SectorIDCounter, verif ffiwrapper.Verifier, tktFn sealing.TicketFn) (*Miner, error) {
+func NewMiner(api storageMinerApi, maddr, worker address.Address, policy MessagePolicy, files *SectorFiles, h host.Host, ds datastore.Batching, sealer sectorstorage.SectorManager, sc sealing.SectorIDCounter, verif ffiwrapper.Verifier, tktFn sealing.TicketFn) (*Miner, error) {
 	m := &Miner{
 		api:    api,
 		h:      h,
@@ -88,6 +93,8 @@ func NewMiner(api storageMinerApi, maddr, worker address.Address, h host.Host, d
 
 		maddr:  maddr,
 		worker: worker,
+		policy: policy,
+		files:  files,
 	}
 
 	return m, nil
@@ -99,7 +106,7 @@ func (m *Miner) Run(ctx context.Context) error {
 	}
 
 	evts := events.NewEvents(ctx, m.api)
-	adaptedAPI := NewSealingAPIAdapter(m.api)
+	adaptedAPI := NewSealingAPIAdapter(m.api, m.policy)
 	pcp := sealing.NewBasicPreCommitPolicy(adaptedAPI, 10000000)
 	m.sealing = sealing.New(adaptedAPI, NewEventsAdapter(evts), m.maddr, m.worker, m.ds, m.sealer, m.sc, m.verif, m.tktFn, &pcp)
 
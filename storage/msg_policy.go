@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+
+	"github.com/filecoin-project/lotus/chain/messagepool"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// MsgKind is a kind of message sent by the miner
+type MsgKind string
+
+const (
+	MsgPreCommit     MsgKind = "precommit"
+	MsgCommit        MsgKind = "commit"
+	MsgWindowPoSt    MsgKind = "window-post"
+	MsgDeclareFaults MsgKind = "declare-faults"
+	MsgPublishDeals  MsgKind = "publish-deals"
+)
+
+// MsgSettings are the gas of a kind of message. Zero GasLimit and nil
+// GasPrice keep the gas already set on messages.
+type MsgSettings struct {
+	GasLimit int64
+	GasPrice types.BigInt
+}
+
+// MessagePolicy selects the gas of messages sent by the miner, so that e.g.
+// PoSt submissions can pay more than precommits.
+//
+// All of these messages are sent from the worker address, the miner actor
+// and PublishStorageDeals only accept messages from the worker.
+type MessagePolicy map[MsgKind]MsgSettings
+
+// Apply sets the sender of msg to the worker, and the gas set for the kind of
+// message.
+func (p MessagePolicy) Apply(kind MsgKind, worker address.Address, msg *types.Message) {
+	msg.From = worker
+
+	ms, ok := p[kind]
+	if !ok {
+		return
+	}
+
+	if ms.GasLimit > 0 {
+		msg.GasLimit = ms.GasLimit
+	}
+	if !ms.GasPrice.Nil() {
+		msg.GasPrice = ms.GasPrice
+	}
+}
+
+type bumpApi interface {
+	MpoolPending(context.Context, types.TipSetKey) ([]*types.SignedMessage, error)
+	MpoolReplace(ctx context.Context, from address.Address, nonce uint64, auto bool, gasPrice types.BigInt) (cid.Cid, error)
+}
+
+// bumpPending raises the gas price of pending messages from the worker which
+// pay less than price, e.g. precommits sent before a PoSt submission. Messages
+// are included in nonce order, so a cheap message would otherwise hold back
+// the messages sent after it.
+func bumpPending(ctx context.Context, api bumpApi, worker address.Address, price types.BigInt) error {
+	pending, err := api.MpoolPending(ctx, types.EmptyTSK)
+	if err != nil {
+		return xerrors.Errorf("getting pending messages: %w", err)
+	}
+
+	for _, sm := range pending {
+		if sm.Message.From != worker || !sm.Message.GasPrice.LessThan(price) {
+			continue
+		}
+
+		newPrice := messagepool.ComputeMinRBF(sm.Message.GasPrice)
+		if newPrice.LessThan(price) {
+			newPrice = price
+		}
+
+		c, err := api.MpoolReplace(ctx, worker, sm.Message.Nonce, false, newPrice)
+		if err != nil {
+			return xerrors.Errorf("replacing message %s: %w", sm.Cid(), err)
+		}
+
+		log.Infow("raised gas price of pending message", "nonce", sm.Message.Nonce, "old", sm.Cid(), "new", c, "gasprice", newPrice)
+	}
+
+	return nil
+}
+
+// minerMsgKind returns the kind of messages sent to the miner actor by the
+// sealing state machine
+func minerMsgKind(method abi.MethodNum) (MsgKind, bool) {
+	switch method {
+	case builtin.MethodsMiner.PreCommitSector:
+		return MsgPreCommit, true
+	case builtin.MethodsMiner.ProveCommitSector:
+		return MsgCommit, true
+	default:
+		return "", false
+	}
+}
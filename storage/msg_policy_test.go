@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/lotus/chain/messagepool"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+func TestMessagePolicyApply(t *testing.T) {
+	worker, err := address.NewIDAddress(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	policy := MessagePolicy{
+		MsgWindowPoSt: {GasLimit: 20000, GasPrice: types.NewInt(3)},
+		MsgCommit:     {GasLimit: 30000},
+	}
+
+	cases := []struct {
+		kind  MsgKind
+		limit int64
+		price uint64
+	}{
+		{MsgWindowPoSt, 20000, 3},
+		// unset gas price is kept
+		{MsgCommit, 30000, 1},
+		// kinds without settings keep their gas
+		{MsgPreCommit, 10000, 1},
+	}
+
+	for _, c := range cases {
+		msg := &types.Message{
+			GasLimit: 10000,
+			GasPrice: types.NewInt(1),
+		}
+		policy.Apply(c.kind, worker, msg)
+
+		if msg.From != worker {
+			t.Errorf("%s: expected sender %s, got %s", c.kind, worker, msg.From)
+		}
+		if msg.GasLimit != c.limit {
+			t.Errorf("%s: expected gas limit %d, got %d", c.kind, c.limit, msg.GasLimit)
+		}
+		if !msg.GasPrice.Equals(types.NewInt(c.price)) {
+			t.Errorf("%s: expected gas price %d, got %s", c.kind, c.price, msg.GasPrice)
+		}
+	}
+}
+
+type fakeBumpApi struct {
+	pending  []*types.SignedMessage
+	replaced map[uint64]types.BigInt
+}
+
+func (a *fakeBumpApi) MpoolPending(context.Context, types.TipSetKey) ([]*types.SignedMessage, error) {
+	return a.pending, nil
+}
+
+func (a *fakeBumpApi) MpoolReplace(ctx context.Context, from address.Address, nonce uint64, auto bool, gasPrice types.BigInt) (cid.Cid, error) {
+	if auto {
+		return cid.Undef, xerrors.New("expected an explicit gas price")
+	}
+	a.replaced[nonce] = gasPrice
+	return cid.Undef, nil
+}
+
+func TestBumpPending(t *testing.T) {
+	worker, err := address.NewIDAddress(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := address.NewIDAddress(101)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := func(from address.Address, nonce uint64, price uint64) *types.SignedMessage {
+		return &types.SignedMessage{Message: types.Message{
+			To:       from,
+			From:     from,
+			Nonce:    nonce,
+			Value:    types.NewInt(0),
+			GasPrice: types.NewInt(price),
+		}}
+	}
+
+	api := &fakeBumpApi{
+		pending: []*types.SignedMessage{
+			msg(worker, 1, 1),
+			msg(worker, 2, 90),
+			msg(worker, 3, 100),
+			msg(worker, 4, 200),
+			msg(other, 5, 1),
+		},
+		replaced: map[uint64]types.BigInt{},
+	}
+
+	if err := bumpPending(context.TODO(), api, worker, types.NewInt(100)); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := map[uint64]types.BigInt{
+		// raised to the PoSt price
+		1: types.NewInt(100),
+		// the PoSt price doesn't replace it, the replace-by-fee minimum is used
+		2: messagepool.ComputeMinRBF(types.NewInt(90)),
+	}
+	if len(api.replaced) != len(expect) {
+		t.Fatalf("expected %d messages to be replaced, got %v", len(expect), api.replaced)
+	}
+	for nonce, price := range expect {
+		if p, ok := api.replaced[nonce]; !ok || !p.Equals(price) {
+			t.Errorf("expected message %d to be replaced with gas price %s, got %s", nonce, price, p)
+		}
+	}
+}
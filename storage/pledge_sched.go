@@ -0,0 +1,334 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/sector-storage/stores"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/node/config"
+	sealing "github.com/filecoin-project/storage-fsm"
+)
+
+// pledged sectors which don't show up in the sealing state machine within this
+// time are assumed to have failed
+const pledgeTimeout = time.Hour
+
+// stages of the sealing state machine limited by the pledge config
+var pledgeStages = map[sealing.SectorState]func(*api.PledgeStatus) *uint64{
+	sealing.Packing:    func(st *api.PledgeStatus) *uint64 { return &st.PreCommit1 },
+	sealing.PreCommit1: func(st *api.PledgeStatus) *uint64 { return &st.PreCommit1 },
+	sealing.PreCommit2: func(st *api.PledgeStatus) *uint64 { return &st.PreCommit2 },
+	sealing.Committing: func(st *api.PledgeStatus) *uint64 { return &st.Commit },
+}
+
+type storageList interface {
+	StorageList(ctx context.Context) (map[stores.ID][]stores.Decl, error)
+	StorageInfo(ctx context.Context, id stores.ID) (stores.StorageInfo, error)
+}
+
+type fsStater interface {
+	FsStat(ctx context.Context, id stores.ID) (stores.FsStat, error)
+}
+
+// PledgeScheduler pledges sectors with random data, keeping a target number of
+// sectors sealing. Pledging pauses when a sealing stage is at its limit, when
+// storage is nearly full, or when the worker balance is low. fs is nil when the
+// sector manager doesn't use local storage, storage isn't checked then.
+type PledgeScheduler struct {
+	miner *Miner
+	index storageList
+	fs    fsStater
+
+	lk     sync.Mutex
+	cfg    config.PledgeConfig
+	status api.PledgeStatus
+
+	// sectors known to the sealing state machine, and start times of pledged
+	// sectors which it doesn't know yet, oldest first
+	seen    map[abi.SectorNumber]struct{}
+	pending []time.Time
+
+	wake chan struct{}
+}
+
+func NewPledgeScheduler(miner *Miner, index storageList, fs fsStater, cfg config.PledgeConfig) (*PledgeScheduler, error) {
+	if err := checkPledgeConfig(cfg); err != nil {
+		return nil, err
+	}
+
+	return &PledgeScheduler{
+		miner: miner,
+		index: index,
+		fs:    fs,
+
+		cfg: cfg,
+
+		wake: make(chan struct{}, 1),
+	}, nil
+}
+
+func checkPledgeConfig(cfg config.PledgeConfig) error {
+	if cfg.CheckInterval <= 0 {
+		return xerrors.Errorf("check interval must be positive")
+	}
+	return nil
+}
+
+func (s *PledgeScheduler) Run(ctx context.Context) {
+	for {
+		if err := s.tick(ctx); err != nil {
+			log.Errorf("pledge scheduler: %+v", err)
+
+			s.lk.Lock()
+			s.status.LastErr = err.Error()
+			s.lk.Unlock()
+		}
+
+		s.lk.Lock()
+		interval := time.Duration(s.cfg.CheckInterval)
+		s.lk.Unlock()
+
+		select {
+		case <-time.After(interval):
+		case <-s.wake:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *PledgeScheduler) Config() config.PledgeConfig {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	return s.cfg
+}
+
+// SetConfig replaces the config until the miner is restarted
+func (s *PledgeScheduler) SetConfig(cfg config.PledgeConfig) error {
+	if err := checkPledgeConfig(cfg); err != nil {
+		return err
+	}
+
+	s.lk.Lock()
+	s.cfg = cfg
+	s.lk.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+func (s *PledgeScheduler) Status() api.PledgeStatus {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	st := s.status
+	st.Enabled = s.cfg.Enabled
+	return st
+}
+
+func (s *PledgeScheduler) tick(ctx context.Context) error {
+	st, err := s.count()
+	if err != nil {
+		return err
+	}
+
+	cfg := s.Config()
+
+	n, waiting, err := s.toPledge(ctx, cfg, st)
+	if err != nil {
+		return err
+	}
+
+	for i := uint64(0); i < n; i++ {
+		if err := s.miner.PledgeSector(); err != nil {
+			return xerrors.Errorf("pledging sector: %w", err)
+		}
+
+		log.Infow("pledged sector", "sealing", st.Sealing+i+1, "target", cfg.TargetSealing)
+
+		s.lk.Lock()
+		s.pending = append(s.pending, time.Now())
+		s.status.LastPledge = time.Now()
+		s.lk.Unlock()
+	}
+
+	s.lk.Lock()
+	s.status.Sealing = st.Sealing + n
+	s.status.Pending = st.Pending + n
+	s.status.PreCommit1 = st.PreCommit1 + n
+	s.status.PreCommit2 = st.PreCommit2
+	s.status.Commit = st.Commit
+	s.status.Waiting = waiting
+	s.status.LastErr = ""
+	s.lk.Unlock()
+
+	return nil
+}
+
+// count counts sealing sectors, and matches sectors new to the sealing state
+// machine with pending pledges
+func (s *PledgeScheduler) count() (api.PledgeStatus, error) {
+	sectors, err := s.miner.ListSectors()
+	if err != nil {
+		return api.PledgeStatus{}, xerrors.Errorf("listing sectors: %w", err)
+	}
+
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	first := s.seen == nil
+	if first {
+		s.seen = map[abi.SectorNumber]struct{}{}
+	}
+
+	var st api.PledgeStatus
+	for _, sector := range sectors {
+		if _, ok := s.seen[sector.SectorNumber]; !ok {
+			s.seen[sector.SectorNumber] = struct{}{}
+
+			if !first && len(s.pending) > 0 && !hasDeals(sector) {
+				s.pending = s.pending[1:]
+			}
+		}
+
+		switch sector.State {
+		case sealing.Proving, sealing.FailedUnrecoverable:
+			continue
+		}
+
+		st.Sealing++
+		if stage, ok := pledgeStages[sector.State]; ok {
+			*stage(&st)++
+		}
+	}
+
+	for len(s.pending) > 0 && time.Since(s.pending[0]) > pledgeTimeout {
+		log.Warnf("pledged sector didn't start sealing within %s", pledgeTimeout)
+		s.pending = s.pending[1:]
+	}
+
+	st.Pending = uint64(len(s.pending))
+	st.Sealing += st.Pending
+	st.PreCommit1 += st.Pending
+
+	return st, nil
+}
+
+// toPledge returns the number of sectors to pledge, and why no more sectors
+// are pledged if the target isn't reached
+func (s *PledgeScheduler) toPledge(ctx context.Context, cfg config.PledgeConfig, st api.PledgeStatus) (uint64, string, error) {
+	if !cfg.Enabled {
+		return 0, "", nil
+	}
+
+	if st.Sealing >= cfg.TargetSealing {
+		return 0, "", nil
+	}
+	n := cfg.TargetSealing - st.Sealing
+
+	switch {
+	case cfg.MaxPreCommit2 > 0 && st.PreCommit2 >= cfg.MaxPreCommit2:
+		return 0, fmt.Sprintf("%d sectors in PreCommit2", st.PreCommit2), nil
+	case cfg.MaxCommit > 0 && st.Commit >= cfg.MaxCommit:
+		return 0, fmt.Sprintf("%d sectors committing", st.Commit), nil
+	}
+
+	var waiting string
+	if cfg.MaxPreCommit1 > 0 {
+		if st.PreCommit1 >= cfg.MaxPreCommit1 {
+			return 0, fmt.Sprintf("%d sectors in PreCommit1", st.PreCommit1), nil
+		}
+		if free := cfg.MaxPreCommit1 - st.PreCommit1; free < n {
+			n = free
+			waiting = fmt.Sprintf("%d sectors in PreCommit1", cfg.MaxPreCommit1)
+		}
+	}
+
+	full, err := s.storageFull(ctx, cfg.MinAvailablePercent)
+	if err != nil {
+		return 0, "", err
+	}
+	if full != "" {
+		return 0, full, nil
+	}
+
+	minBal := types.BigInt(cfg.MinWorkerBalance)
+	if !minBal.Nil() && types.BigCmp(minBal, types.NewInt(0)) > 0 {
+		bal, err := s.miner.api.WalletBalance(ctx, s.miner.worker)
+		if err != nil {
+			return 0, "", xerrors.Errorf("getting worker balance: %w", err)
+		}
+
+		if types.BigCmp(bal, minBal) < 0 {
+			return 0, fmt.Sprintf("worker balance %s below %s", types.FIL(bal), cfg.MinWorkerBalance), nil
+		}
+	}
+
+	return n, waiting, nil
+}
+
+// storageFull checks that some sealing and some long-term storage path has
+// minPercent of its capacity available. Paths which can't be checked are
+// ignored.
+func (s *PledgeScheduler) storageFull(ctx context.Context, minPercent uint64) (string, error) {
+	if s.fs == nil || minPercent == 0 {
+		return "", nil
+	}
+
+	paths, err := s.index.StorageList(ctx)
+	if err != nil {
+		return "", xerrors.Errorf("listing storage: %w", err)
+	}
+
+	var seal, store, sealOk, storeOk bool
+	for id := range paths {
+		info, err := s.index.StorageInfo(ctx, id)
+		if err != nil {
+			return "", xerrors.Errorf("getting storage info: %w", err)
+		}
+
+		st, err := s.fs.FsStat(ctx, id)
+		if err != nil {
+			log.Warnf("getting stat of storage %s: %s", id, err)
+			continue
+		}
+
+		seal = seal || info.CanSeal
+		store = store || info.CanStore
+
+		ok := st.Available*100 >= st.Capacity*minPercent
+		sealOk = sealOk || (info.CanSeal && ok)
+		storeOk = storeOk || (info.CanStore && ok)
+	}
+
+	switch {
+	case seal && !sealOk:
+		return "sealing storage is nearly full", nil
+	case store && !storeOk:
+		return "long-term storage is nearly full", nil
+	}
+
+	return "", nil
+}
+
+func hasDeals(sector sealing.SectorInfo) bool {
+	for _, p := range sector.Pieces {
+		if p.DealInfo != nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,224 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/sector-storage/stores"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/node/config"
+)
+
+type testPath struct {
+	info    stores.StorageInfo
+	stat    stores.FsStat
+	statErr error
+}
+
+type testStorage map[stores.ID]testPath
+
+func (s testStorage) StorageList(ctx context.Context) (map[stores.ID][]stores.Decl, error) {
+	out := map[stores.ID][]stores.Decl{}
+	for id := range s {
+		out[id] = nil
+	}
+	return out, nil
+}
+
+func (s testStorage) StorageInfo(ctx context.Context, id stores.ID) (stores.StorageInfo, error) {
+	return s[id].info, nil
+}
+
+func (s testStorage) FsStat(ctx context.Context, id stores.ID) (stores.FsStat, error) {
+	return s[id].stat, s[id].statErr
+}
+
+func path(seal, store bool, available uint64) testPath {
+	return testPath{
+		info: stores.StorageInfo{CanSeal: seal, CanStore: store},
+		stat: stores.FsStat{Capacity: 100, Available: available},
+	}
+}
+
+type pledgeTestApi struct {
+	storageMinerApi
+
+	balance types.BigInt
+}
+
+func (a *pledgeTestApi) WalletBalance(context.Context, address.Address) (types.BigInt, error) {
+	return a.balance, nil
+}
+
+func TestPledgeStorageFull(t *testing.T) {
+	broken := testPath{
+		info:    stores.StorageInfo{CanSeal: true, CanStore: true},
+		statErr: xerrors.New("stat failed"),
+	}
+
+	cases := []struct {
+		name    string
+		storage testStorage
+		full    string
+	}{
+		{name: "no storage", storage: testStorage{}},
+		{
+			name:    "space available",
+			storage: testStorage{"seal": path(true, false, 50), "store": path(false, true, 10)},
+		},
+		{
+			name:    "sealing full",
+			storage: testStorage{"seal": path(true, false, 5), "store": path(false, true, 50)},
+			full:    "sealing storage is nearly full",
+		},
+		{
+			name:    "long-term storage full",
+			storage: testStorage{"seal": path(true, false, 50), "store": path(false, true, 5)},
+			full:    "long-term storage is nearly full",
+		},
+		{
+			name:    "one of the sealing paths has space",
+			storage: testStorage{"a": path(true, true, 5), "b": path(true, false, 50), "store": path(false, true, 50)},
+		},
+		{
+			name:    "failed stat is ignored",
+			storage: testStorage{"broken": broken, "seal": path(true, false, 50), "store": path(false, true, 50)},
+		},
+		{
+			name:    "failed stat doesn't count as full",
+			storage: testStorage{"broken": broken, "seal": path(true, false, 50)},
+		},
+		{
+			name:    "failed stat doesn't count as space",
+			storage: testStorage{"broken": broken, "seal": path(true, true, 5)},
+			full:    "sealing storage is nearly full",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s, err := NewPledgeScheduler(&Miner{}, c.storage, c.storage, config.PledgeConfig{CheckInterval: config.Duration(time.Minute)})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			full, err := s.storageFull(context.TODO(), 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if full != c.full {
+				t.Errorf("expected %q, got %q", c.full, full)
+			}
+		})
+	}
+}
+
+func TestPledgeToPledge(t *testing.T) {
+	a := &pledgeTestApi{balance: types.NewInt(100)}
+	storage := testStorage{"seal": path(true, true, 50)}
+
+	base := config.PledgeConfig{
+		Enabled:       true,
+		TargetSealing: 5,
+		CheckInterval: config.Duration(time.Minute),
+	}
+
+	cases := []struct {
+		name   string
+		cfg    func(cfg *config.PledgeConfig)
+		status api.PledgeStatus
+
+		n       uint64
+		waiting string
+	}{
+		{name: "disabled", cfg: func(cfg *config.PledgeConfig) { cfg.Enabled = false }},
+		{name: "up to the target", status: api.PledgeStatus{Sealing: 2}, n: 3},
+		{name: "target reached", status: api.PledgeStatus{Sealing: 5}},
+		{
+			name:    "PreCommit1 limits the number",
+			cfg:     func(cfg *config.PledgeConfig) { cfg.MaxPreCommit1 = 3 },
+			status:  api.PledgeStatus{Sealing: 2, PreCommit1: 1},
+			n:       2,
+			waiting: "3 sectors in PreCommit1",
+		},
+		{
+			name:    "PreCommit1 at its limit",
+			cfg:     func(cfg *config.PledgeConfig) { cfg.MaxPreCommit1 = 1 },
+			status:  api.PledgeStatus{Sealing: 1, PreCommit1: 1},
+			waiting: "1 sectors in PreCommit1",
+		},
+		{
+			name:    "PreCommit2 at its limit",
+			cfg:     func(cfg *config.PledgeConfig) { cfg.MaxPreCommit2 = 2 },
+			status:  api.PledgeStatus{Sealing: 2, PreCommit2: 2},
+			waiting: "2 sectors in PreCommit2",
+		},
+		{
+			name:    "Commit at its limit",
+			cfg:     func(cfg *config.PledgeConfig) { cfg.MaxCommit = 1 },
+			status:  api.PledgeStatus{Sealing: 1, Commit: 1},
+			waiting: "1 sectors committing",
+		},
+		{
+			name:    "storage full",
+			cfg:     func(cfg *config.PledgeConfig) { cfg.MinAvailablePercent = 60 },
+			waiting: "nearly full",
+		},
+		{
+			name: "worker balance high enough",
+			cfg:  func(cfg *config.PledgeConfig) { cfg.MinWorkerBalance = types.FIL(types.NewInt(100)) },
+			n:    5,
+		},
+		{
+			name:    "worker balance low",
+			cfg:     func(cfg *config.PledgeConfig) { cfg.MinWorkerBalance = types.FIL(types.NewInt(101)) },
+			waiting: "worker balance",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cfg := base
+			if c.cfg != nil {
+				c.cfg(&cfg)
+			}
+
+			s, err := NewPledgeScheduler(&Miner{api: a}, storage, storage, cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			n, waiting, err := s.toPledge(context.TODO(), cfg, c.status)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != c.n {
+				t.Errorf("expected %d sectors to pledge, got %d", c.n, n)
+			}
+			if !strings.Contains(waiting, c.waiting) || (c.waiting == "") != (waiting == "") {
+				t.Errorf("expected waiting on %q, got %q", c.waiting, waiting)
+			}
+		})
+	}
+}
+
+func TestPledgeCheckInterval(t *testing.T) {
+	if _, err := NewPledgeScheduler(&Miner{}, testStorage{}, nil, config.PledgeConfig{}); err == nil {
+		t.Fatal("expected a zero check interval to be rejected")
+	}
+
+	s, err := NewPledgeScheduler(&Miner{}, testStorage{}, nil, config.PledgeConfig{CheckInterval: config.Duration(time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetConfig(config.PledgeConfig{CheckInterval: config.Duration(-time.Second)}); err == nil {
+		t.Fatal("expected a negative check interval to be rejected")
+	}
+}
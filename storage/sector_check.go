@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"context"
+	"io/ioutil"
+	"math/rand"
+	"os"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/sector-storage/stores"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// number of random reads made from the sealed file of a sector when sampling
+const checkSampleReads = 16
+
+// size of a single sample read, one merkle tree node
+const checkSampleSize = 32
+
+// SectorChecker checks that the files needed to prove sectors are present
+// and readable. Files are only checked in local storage, sectors which only
+// have files in storage of other machines are reported as unchecked, as are
+// all sectors when the sector manager doesn't use local storage.
+type SectorChecker struct {
+	api   storageMinerApi
+	files *SectorFiles
+	maddr address.Address
+}
+
+func NewSectorChecker(api storageMinerApi, files *SectorFiles, maddr address.Address) *SectorChecker {
+	return &SectorChecker{
+		api:   api,
+		files: files,
+		maddr: maddr,
+	}
+}
+
+// Check checks the given sectors, or all sectors in the proving set if none
+// are given. With sample set, a number of random reads are made from sealed
+// files, in addition to checking their size.
+func (c *SectorChecker) Check(ctx context.Context, sectors []abi.SectorNumber, sample bool) ([]api.SectorHealth, error) {
+	if len(sectors) == 0 {
+		pset, err := c.api.StateMinerProvingSet(ctx, c.maddr, types.EmptyTSK)
+		if err != nil {
+			return nil, xerrors.Errorf("getting proving set: %w", err)
+		}
+
+		for _, s := range pset {
+			sectors = append(sectors, s.Info.Info.SectorNumber)
+		}
+	}
+
+	out := make([]api.SectorHealth, len(sectors))
+	if c.files.local == nil {
+		for i, snum := range sectors {
+			out[i] = api.SectorHealth{Sector: snum, Status: api.SectorHealthUnchecked}
+		}
+		return out, nil
+	}
+
+	mid, err := address.IDFromAddress(c.maddr)
+	if err != nil {
+		return nil, err
+	}
+
+	ssize, err := c.api.StateMinerSectorSize(ctx, c.maddr, types.EmptyTSK)
+	if err != nil {
+		return nil, xerrors.Errorf("getting sector size: %w", err)
+	}
+
+	local, err := c.files.local.StorageLocal(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("getting local storage: %w", err)
+	}
+
+	for i, snum := range sectors {
+		sid := abi.SectorID{
+			Miner:  abi.ActorID(mid),
+			Number: snum,
+		}
+
+		out[i] = api.SectorHealth{Sector: snum, Status: api.SectorHealthOk}
+
+		checked, err := c.checkSector(ctx, sid, ssize, local, sample)
+		switch {
+		case err != nil:
+			out[i].Status = api.SectorHealthFaulty
+			out[i].Err = err.Error()
+		case !checked:
+			out[i].Status = api.SectorHealthUnchecked
+		}
+	}
+
+	return out, nil
+}
+
+// checkSector returns false if the sector files couldn't be checked, because
+// they are only stored remotely
+func (c *SectorChecker) checkSector(ctx context.Context, sid abi.SectorID, ssize abi.SectorSize, local map[stores.ID]string, sample bool) (bool, error) {
+	sealedPath, err := c.files.findLocal(ctx, sid, stores.FTSealed, local)
+	if err != nil {
+		return false, err
+	}
+
+	cachePath, err := c.files.findLocal(ctx, sid, stores.FTCache, local)
+	if err != nil {
+		return false, err
+	}
+
+	if sealedPath == "" || cachePath == "" {
+		return false, nil
+	}
+
+	st, err := os.Stat(sealedPath)
+	if err != nil {
+		return true, xerrors.Errorf("sealed file: %w", err)
+	}
+
+	if st.Size() != int64(ssize) {
+		return true, xerrors.Errorf("sealed file %s has size %d, expected %d", sealedPath, st.Size(), ssize)
+	}
+
+	ents, err := ioutil.ReadDir(cachePath)
+	if err != nil {
+		return true, xerrors.Errorf("cache dir: %w", err)
+	}
+
+	if len(ents) == 0 {
+		return true, xerrors.Errorf("cache dir %s is empty", cachePath)
+	}
+
+	if sample {
+		if err := sampleRead(sealedPath, st.Size()); err != nil {
+			return true, xerrors.Errorf("sampling sealed file: %w", err)
+		}
+	}
+
+	return true, nil
+}
+
+func sampleRead(path string, size int64) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close() // nolint
+
+	buf := make([]byte, checkSampleSize)
+	for i := 0; i < checkSampleReads; i++ {
+		off := rand.Int63n(size/checkSampleSize) * checkSampleSize
+		if _, err := f.ReadAt(buf, off); err != nil {
+			return xerrors.Errorf("reading at %d: %w", off, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,281 @@
+package storage
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/sector-storage/stores"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+type checkTestApi struct {
+	storageMinerApi
+
+	ssize abi.SectorSize
+	pset  []abi.SectorNumber
+}
+
+func (a *checkTestApi) StateMinerSectorSize(context.Context, address.Address, types.TipSetKey) (abi.SectorSize, error) {
+	return a.ssize, nil
+}
+
+func (a *checkTestApi) StateMinerProvingSet(context.Context, address.Address, types.TipSetKey) ([]*api.ChainSectorInfo, error) {
+	var out []*api.ChainSectorInfo
+	for _, num := range a.pset {
+		out = append(out, &api.ChainSectorInfo{
+			ID: num,
+			Info: miner.SectorOnChainInfo{
+				Info: miner.SectorPreCommitInfo{SectorNumber: num},
+			},
+		})
+	}
+	return out, nil
+}
+
+type checkTest struct {
+	t *testing.T
+
+	dir   string
+	index *stores.Index
+	local testLocal
+	maddr address.Address
+}
+
+func newCheckTest(t *testing.T) *checkTest {
+	dir, err := ioutil.TempDir("", "sector-check")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	maddr, err := address.NewIDAddress(1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ct := &checkTest{
+		t:     t,
+		dir:   dir,
+		index: stores.NewIndex(),
+		local: testLocal{"local": dir},
+		maddr: maddr,
+	}
+
+	// files in the remote storage are on another machine
+	for _, id := range []stores.ID{"local", "remote"} {
+		if err := ct.index.StorageAttach(context.TODO(), stores.StorageInfo{ID: id, CanStore: true}, stores.FsStat{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, ft := range []stores.SectorFileType{stores.FTSealed, stores.FTCache} {
+		if err := os.MkdirAll(filepath.Join(dir, ft.String()), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return ct
+}
+
+func (ct *checkTest) close() {
+	_ = os.RemoveAll(ct.dir)
+}
+
+func (ct *checkTest) path(num abi.SectorNumber, ft stores.SectorFileType) string {
+	return filepath.Join(ct.dir, ft.String(), sectorName(abi.SectorID{Miner: 1000, Number: num}))
+}
+
+// declare declares the sealed file and cache of a sector in storage id
+func (ct *checkTest) declare(id stores.ID, num abi.SectorNumber) {
+	for _, ft := range []stores.SectorFileType{stores.FTSealed, stores.FTCache} {
+		if err := ct.index.StorageDeclareSector(context.TODO(), id, abi.SectorID{Miner: 1000, Number: num}, ft); err != nil {
+			ct.t.Fatal(err)
+		}
+	}
+}
+
+// writeSector writes a sealed file of size bytes and a cache with one file,
+// and declares them in local storage
+func (ct *checkTest) writeSector(num abi.SectorNumber, size int) {
+	if err := ioutil.WriteFile(ct.path(num, stores.FTSealed), make([]byte, size), 0644); err != nil {
+		ct.t.Fatal(err)
+	}
+	if err := os.MkdirAll(ct.path(num, stores.FTCache), 0755); err != nil {
+		ct.t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(ct.path(num, stores.FTCache), "p_aux"), []byte("aux"), 0644); err != nil {
+		ct.t.Fatal(err)
+	}
+
+	ct.declare("local", num)
+}
+
+func (ct *checkTest) checker(a *checkTestApi, local localStorage) *SectorChecker {
+	files := NewSectorFiles(ct.index, local, datastore.NewMapDatastore())
+	return NewSectorChecker(a, files, ct.maddr)
+}
+
+func checkResults(t *testing.T, health []api.SectorHealth, expect map[abi.SectorNumber]string) {
+	t.Helper()
+
+	if len(health) != len(expect) {
+		t.Fatalf("expected %d results, got %d", len(expect), len(health))
+	}
+
+	for _, h := range health {
+		e, ok := expect[h.Sector]
+		if !ok {
+			t.Errorf("unexpected sector %d", h.Sector)
+			continue
+		}
+
+		// expected faults are given as the status and an error substring
+		status, errPart := e, ""
+		if i := strings.Index(e, ": "); i >= 0 {
+			status, errPart = e[:i], e[i+2:]
+		}
+
+		if string(h.Status) != status {
+			t.Errorf("sector %d: expected %s, got %s (%s)", h.Sector, status, h.Status, h.Err)
+		}
+		if !strings.Contains(h.Err, errPart) {
+			t.Errorf("sector %d: expected error containing %q, got %q", h.Sector, errPart, h.Err)
+		}
+	}
+}
+
+func TestSectorCheck(t *testing.T) {
+	ct := newCheckTest(t)
+	defer ct.close()
+
+	const ssize = 2048
+
+	ct.writeSector(1, ssize)
+
+	// the sealed file is gone
+	ct.writeSector(2, ssize)
+	if err := os.Remove(ct.path(2, stores.FTSealed)); err != nil {
+		t.Fatal(err)
+	}
+
+	// the sealed file is truncated
+	ct.writeSector(3, ssize/2)
+
+	// the cache is empty
+	ct.writeSector(4, ssize)
+	if err := os.RemoveAll(filepath.Join(ct.path(4, stores.FTCache), "p_aux")); err != nil {
+		t.Fatal(err)
+	}
+
+	// the cache can't be listed
+	ct.writeSector(5, ssize)
+	if err := os.RemoveAll(ct.path(5, stores.FTCache)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(ct.path(5, stores.FTCache), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the files are on another machine
+	ct.declare("remote", 6)
+
+	a := &checkTestApi{ssize: ssize, pset: []abi.SectorNumber{1, 2, 3, 4, 5, 6}}
+	c := ct.checker(a, ct.local)
+
+	expect := map[abi.SectorNumber]string{
+		1: "ok",
+		2: "faulty: sealed file",
+		3: "faulty: has size 1024, expected 2048",
+		4: "faulty: is empty",
+		5: "faulty: cache dir",
+		6: "unchecked",
+	}
+
+	health, err := c.Check(context.TODO(), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkResults(t, health, expect)
+
+	health, err = c.Check(context.TODO(), nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkResults(t, health, expect)
+
+	// sectors missing from the index are faulty
+	health, err = c.Check(context.TODO(), []abi.SectorNumber{1, 7}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkResults(t, health, map[abi.SectorNumber]string{
+		1: "ok",
+		7: "faulty: no storage has the sealed file",
+	})
+
+	// without local storage nothing is checked
+	health, err = ct.checker(a, nil).Check(context.TODO(), []abi.SectorNumber{1, 2}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkResults(t, health, map[abi.SectorNumber]string{
+		1: "unchecked",
+		2: "unchecked",
+	})
+}
+
+func TestSectorCheckSample(t *testing.T) {
+	ct := newCheckTest(t)
+	defer ct.close()
+
+	// the sealed file is a directory, which has a size but can't be read
+	ct.writeSector(1, 0)
+	if err := os.Remove(ct.path(1, stores.FTSealed)); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(ct.path(1, stores.FTSealed), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := os.Stat(ct.path(1, stores.FTSealed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Size() < checkSampleSize {
+		t.Skipf("directory size %d is too small to sample", st.Size())
+	}
+
+	ct.writeSector(2, int(st.Size()))
+
+	a := &checkTestApi{ssize: abi.SectorSize(st.Size())}
+	c := ct.checker(a, ct.local)
+
+	// only the size is checked without sampling
+	health, err := c.Check(context.TODO(), []abi.SectorNumber{1, 2}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkResults(t, health, map[abi.SectorNumber]string{
+		1: "ok",
+		2: "ok",
+	})
+
+	health, err = c.Check(context.TODO(), []abi.SectorNumber{1, 2}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkResults(t, health, map[abi.SectorNumber]string{
+		1: "faulty: sampling sealed file",
+		2: "ok",
+	})
+}
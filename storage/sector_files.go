@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/sector-storage/stores"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+
+	"github.com/filecoin-project/lotus/api"
+)
+
+type localStorage interface {
+	StorageLocal(ctx context.Context) (map[stores.ID]string, error)
+}
+
+// SectorFiles finds, moves and removes sector files in the storage of this
+// machine. local is nil when the sector manager doesn't use local storage.
+type SectorFiles struct {
+	index stores.SectorIndex
+	local localStorage
+
+	moveDs  datastore.Batching
+	movesLk sync.Mutex
+	moves   map[abi.SectorNumber]*api.SectorMove
+}
+
+func NewSectorFiles(index stores.SectorIndex, local localStorage, ds datastore.Batching) *SectorFiles {
+	return &SectorFiles{
+		index: index,
+		local: local,
+
+		moveDs: namespace.Wrap(ds, datastore.NewKey("/sector-moves")),
+		moves:  map[abi.SectorNumber]*api.SectorMove{},
+	}
+}
+
+// Remove removes all files of a sector, and drops them from the index. Files
+// in storage of other machines can't be removed, so nothing is removed if
+// there are any.
+func (f *SectorFiles) Remove(ctx context.Context, sid abi.SectorID) error {
+	if f.local == nil {
+		return xerrors.Errorf("sector manager doesn't use local storage")
+	}
+
+	local, err := f.local.StorageLocal(ctx)
+	if err != nil {
+		return xerrors.Errorf("getting local storage: %w", err)
+	}
+
+	type file struct {
+		id   stores.ID
+		ft   stores.SectorFileType
+		path string
+	}
+
+	var files []file
+	for _, ft := range []stores.SectorFileType{stores.FTUnsealed, stores.FTSealed, stores.FTCache} {
+		si, err := f.index.StorageFindSector(ctx, sid, ft, false)
+		if err != nil {
+			return xerrors.Errorf("finding %s file: %w", ft, err)
+		}
+
+		for _, info := range si {
+			p, ok := local[info.ID]
+			if !ok {
+				return xerrors.Errorf("%s file is in storage %s of another machine", ft, info.ID)
+			}
+
+			files = append(files, file{
+				id:   info.ID,
+				ft:   ft,
+				path: filepath.Join(p, ft.String(), sectorName(sid)),
+			})
+		}
+	}
+
+	for _, file := range files {
+		log.Infow("removing sector file", "sector", sid, "type", file.ft, "path", file.path)
+
+		if err := os.RemoveAll(file.path); err != nil {
+			return xerrors.Errorf("removing %s file: %w", file.ft, err)
+		}
+
+		if err := f.index.StorageDropSector(ctx, file.id, sid, file.ft); err != nil {
+			return xerrors.Errorf("dropping %s file from index: %w", file.ft, err)
+		}
+	}
+
+	return nil
+}
+
+// findLocal returns the path of a sector file in local storage. An empty path
+// is returned if the file is only stored remotely.
+func (f *SectorFiles) findLocal(ctx context.Context, sid abi.SectorID, ft stores.SectorFileType, local map[stores.ID]string) (string, error) {
+	si, err := f.index.StorageFindSector(ctx, sid, ft, false)
+	if err != nil {
+		return "", xerrors.Errorf("finding %s file: %w", ft, err)
+	}
+
+	if len(si) == 0 {
+		return "", xerrors.Errorf("no storage has the %s file", ft)
+	}
+
+	for _, info := range si {
+		if p, ok := local[info.ID]; ok {
+			return filepath.Join(p, ft.String(), sectorName(sid)), nil
+		}
+	}
+
+	return "", nil
+}
+
+func sectorName(sid abi.SectorID) string {
+	return fmt.Sprintf("s-t0%d-%d", sid.Miner, sid.Number)
+}
@@ -0,0 +1,338 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/sector-storage/stores"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+
+	"github.com/filecoin-project/lotus/chain/actors"
+	"github.com/filecoin-project/lotus/chain/types"
+	sealing "github.com/filecoin-project/storage-fsm"
+)
+
+const (
+	MsgExtendSectors    MsgKind = "extend-sectors"
+	MsgTerminateSectors MsgKind = "terminate-sectors"
+)
+
+// number of extension messages pushed before waiting for them to land
+const extendBatchSize = 32
+
+// sectors in these states can be removed without forcing
+var removableStates = map[sealing.SectorState]struct{}{
+	sealing.SealFailed:          {},
+	sealing.PreCommitFailed:     {},
+	sealing.ComputeProofFailed:  {},
+	sealing.CommitFailed:        {},
+	sealing.FailedUnrecoverable: {},
+}
+
+// how often RemoveSector checks that the sealing state machine stopped working
+// on the sector
+var removePollInterval = 100 * time.Millisecond
+
+// RemoveSector removes the files and the sealing state of a sector which isn't
+// committed on chain. Unless force is set, only failed sectors without deals
+// can be removed.
+func (m *Miner) RemoveSector(ctx context.Context, num abi.SectorNumber, force bool) error {
+	info, err := m.sealing.GetSectorInfo(num)
+	if err != nil {
+		return xerrors.Errorf("getting sector info: %w", err)
+	}
+
+	if _, ok := removableStates[info.State]; !ok && !force {
+		return xerrors.Errorf("sector %d is in state %s, only failed sectors can be removed without forcing", num, info.State)
+	}
+
+	onChain, err := m.committedSectors(ctx, types.EmptyTSK)
+	if err != nil {
+		return err
+	}
+	if _, ok := onChain[num]; ok {
+		return xerrors.Errorf("sector %d is committed on chain, it has to be terminated first", num)
+	}
+
+	var active map[abi.DealID]struct{}
+	for _, piece := range info.Pieces {
+		if piece.DealInfo == nil {
+			continue
+		}
+
+		if active == nil {
+			if active, err = m.activeDeals(ctx); err != nil {
+				return err
+			}
+		}
+		if _, ok := active[piece.DealInfo.DealID]; ok {
+			return xerrors.Errorf("sector %d has active deal %d", num, piece.DealInfo.DealID)
+		}
+		if !force {
+			return xerrors.Errorf("sector %d has deal %d, it can only be removed when forcing", num, piece.DealInfo.DealID)
+		}
+	}
+
+	if err := m.stopSealing(ctx, num, info.State); err != nil {
+		return err
+	}
+
+	mid, err := address.IDFromAddress(m.maddr)
+	if err != nil {
+		return err
+	}
+
+	if err := m.files.Remove(ctx, abi.SectorID{Miner: abi.ActorID(mid), Number: num}); err != nil {
+		return xerrors.Errorf("removing sector files: %w", err)
+	}
+
+	// the state machine doesn't know about removed sectors, so their state
+	// record is deleted from its store
+	if err := m.ds.Delete(sectorStateKey(num)); err != nil {
+		return xerrors.Errorf("deleting sector state: %w", err)
+	}
+
+	return nil
+}
+
+// stopSealing moves the sector to FailedUnrecoverable, which has no handler,
+// and waits for the state machine to record it
+func (m *Miner) stopSealing(ctx context.Context, num abi.SectorNumber, state sealing.SectorState) error {
+	if state == sealing.FailedUnrecoverable {
+		return nil
+	}
+
+	if err := m.sealing.ForceSectorState(ctx, num, sealing.FailedUnrecoverable); err != nil {
+		return xerrors.Errorf("stopping sealing: %w", err)
+	}
+
+	for {
+		info, err := m.sealing.GetSectorInfo(num)
+		if err != nil {
+			return xerrors.Errorf("getting sector info: %w", err)
+		}
+		if info.State == sealing.FailedUnrecoverable {
+			return nil
+		}
+
+		select {
+		case <-time.After(removePollInterval):
+		case <-ctx.Done():
+			return xerrors.Errorf("waiting for sealing to stop: %w", ctx.Err())
+		}
+	}
+}
+
+func sectorStateKey(num abi.SectorNumber) datastore.Key {
+	return datastore.NewKey(sealing.SectorStorePrefix).ChildString(fmt.Sprint(num))
+}
+
+// ExtendSectors extends the expiration of committed sectors. A message is sent
+// for each sector; messages are sent in batches, waiting for each batch to
+// land before sending the next one.
+func (m *Miner) ExtendSectors(ctx context.Context, nums []abi.SectorNumber, newExpiration abi.ChainEpoch) ([]cid.Cid, error) {
+	onChain, err := m.committedSectors(ctx, types.EmptyTSK)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, num := range nums {
+		si, ok := onChain[num]
+		if !ok {
+			return nil, xerrors.Errorf("sector %d isn't committed on chain", num)
+		}
+		if newExpiration <= si.Info.Expiration {
+			return nil, xerrors.Errorf("sector %d expires at %d, after the new expiration %d", num, si.Info.Expiration, newExpiration)
+		}
+	}
+
+	var out []cid.Cid
+	for len(nums) > 0 {
+		batch := nums
+		if len(batch) > extendBatchSize {
+			batch = batch[:extendBatchSize]
+		}
+		nums = nums[len(batch):]
+
+		var sent []cid.Cid
+		for _, num := range batch {
+			params, aerr := actors.SerializeParams(&miner.ExtendSectorExpirationParams{
+				SectorNumber:  num,
+				NewExpiration: newExpiration,
+			})
+			if aerr != nil {
+				return out, xerrors.Errorf("serializing params: %w", aerr)
+			}
+
+			mcid, err := m.sendMinerMsg(ctx, MsgExtendSectors, builtin.MethodsMiner.ExtendSectorExpiration, params)
+			if err != nil {
+				return out, xerrors.Errorf("extending sector %d: %w", num, err)
+			}
+			sent = append(sent, mcid)
+		}
+
+		for i, mcid := range sent {
+			if err := m.waitMinerMsg(ctx, mcid); err != nil {
+				return append(out, sent...), xerrors.Errorf("extending sector %d: %w", batch[i], err)
+			}
+		}
+		out = append(out, sent...)
+	}
+
+	return out, nil
+}
+
+// TerminateSectors terminates committed sectors early. Unless force is set,
+// sectors with active deals aren't terminated.
+func (m *Miner) TerminateSectors(ctx context.Context, nums []abi.SectorNumber, force bool) (cid.Cid, error) {
+	onChain, err := m.committedSectors(ctx, types.EmptyTSK)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	var set []uint64
+	var active map[abi.DealID]struct{}
+	for _, num := range nums {
+		si, ok := onChain[num]
+		if !ok {
+			return cid.Undef, xerrors.Errorf("sector %d isn't committed on chain", num)
+		}
+
+		if !force {
+			for _, deal := range si.Info.DealIDs {
+				if active == nil {
+					if active, err = m.activeDeals(ctx); err != nil {
+						return cid.Undef, err
+					}
+				}
+				if _, ok := active[deal]; ok {
+					return cid.Undef, xerrors.Errorf("sector %d has active deal %d", num, deal)
+				}
+			}
+		}
+
+		set = append(set, uint64(num))
+	}
+
+	sectors := abi.BitFieldFromSet(set)
+	enc, aerr := actors.SerializeParams(&miner.TerminateSectorsParams{
+		Sectors: &sectors,
+	})
+	if aerr != nil {
+		return cid.Undef, xerrors.Errorf("serializing params: %w", aerr)
+	}
+
+	mcid, err := m.sendMinerMsg(ctx, MsgTerminateSectors, builtin.MethodsMiner.TerminateSectors, enc)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	return mcid, m.waitMinerMsg(ctx, mcid)
+}
+
+// MoveSector starts moving the files of a proving sector to the local storage
+// path dest
+func (m *Miner) MoveSector(ctx context.Context, num abi.SectorNumber, dest stores.ID) error {
+	info, err := m.sealing.GetSectorInfo(num)
+	if err != nil {
+		return xerrors.Errorf("getting sector info: %w", err)
+	}
+
+	// sealing writes to the files of sectors in other states
+	if info.State != sealing.Proving {
+		return xerrors.Errorf("sector %d is in state %s, only proving sectors can be moved", num, info.State)
+	}
+
+	mid, err := address.IDFromAddress(m.maddr)
+	if err != nil {
+		return err
+	}
+
+	return m.files.Move(ctx, abi.SectorID{Miner: abi.ActorID(mid), Number: num}, dest)
+}
+
+// committedSectors returns the sectors of the miner committed on chain
+func (m *Miner) committedSectors(ctx context.Context, tsk types.TipSetKey) (map[abi.SectorNumber]*miner.SectorOnChainInfo, error) {
+	sset, err := m.api.StateMinerSectors(ctx, m.maddr, tsk)
+	if err != nil {
+		return nil, xerrors.Errorf("getting miner sectors: %w", err)
+	}
+
+	out := make(map[abi.SectorNumber]*miner.SectorOnChainInfo, len(sset))
+	for _, s := range sset {
+		info := s.Info
+		out[info.Info.SectorNumber] = &info
+	}
+
+	return out, nil
+}
+
+// activeDeals returns the deals which are on chain, and haven't ended or been
+// slashed. Deals missing from the market state, e.g. because they were
+// removed after ending, aren't active.
+func (m *Miner) activeDeals(ctx context.Context) (map[abi.DealID]struct{}, error) {
+	head, err := m.api.ChainHead(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("getting chain head: %w", err)
+	}
+
+	deals, err := m.api.StateMarketDeals(ctx, head.Key())
+	if err != nil {
+		return nil, xerrors.Errorf("getting market deals: %w", err)
+	}
+
+	out := map[abi.DealID]struct{}{}
+	for k, md := range deals {
+		id, err := strconv.ParseUint(k, 10, 64)
+		if err != nil {
+			return nil, xerrors.Errorf("parsing deal id %q: %w", k, err)
+		}
+
+		if md.State.SlashEpoch == -1 && md.Proposal.EndEpoch > head.Height() {
+			out[abi.DealID(id)] = struct{}{}
+		}
+	}
+
+	return out, nil
+}
+
+func (m *Miner) sendMinerMsg(ctx context.Context, kind MsgKind, method abi.MethodNum, params []byte) (cid.Cid, error) {
+	msg := &types.Message{
+		To:       m.maddr,
+		Method:   method,
+		Params:   params,
+		Value:    types.NewInt(0),
+		GasLimit: 10000000,
+		GasPrice: types.NewInt(1),
+	}
+	m.policy.Apply(kind, m.worker, msg)
+
+	smsg, err := m.api.MpoolPushMessage(ctx, msg)
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("pushing message: %w", err)
+	}
+
+	return smsg.Cid(), nil
+}
+
+func (m *Miner) waitMinerMsg(ctx context.Context, mcid cid.Cid) error {
+	ml, err := m.api.StateWaitMsg(ctx, mcid)
+	if err != nil {
+		return xerrors.Errorf("waiting for message %s: %w", mcid, err)
+	}
+
+	if ml.Receipt.ExitCode != 0 {
+		return xerrors.Errorf("message %s failed: exit %d", mcid, ml.Receipt.ExitCode)
+	}
+
+	return nil
+}
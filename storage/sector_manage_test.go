@@ -0,0 +1,330 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"sort"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+
+	"github.com/filecoin-project/go-address"
+	cborutil "github.com/filecoin-project/go-cbor-util"
+	"github.com/filecoin-project/sector-storage/stores"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/builtin/market"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/types/mock"
+	sealing "github.com/filecoin-project/storage-fsm"
+)
+
+type manageTestApi struct {
+	storageMinerApi
+
+	sectors []*api.ChainSectorInfo
+	deals   map[string]api.MarketDeal
+	pushed  []*types.Message
+}
+
+func (a *manageTestApi) ChainHead(context.Context) (*types.TipSet, error) {
+	return mock.TipSet(mock.MkBlock(nil, 1, 1)), nil
+}
+
+func (a *manageTestApi) StateMarketDeals(context.Context, types.TipSetKey) (map[string]api.MarketDeal, error) {
+	return a.deals, nil
+}
+
+func (a *manageTestApi) StateMinerSectors(context.Context, address.Address, types.TipSetKey) ([]*api.ChainSectorInfo, error) {
+	return a.sectors, nil
+}
+
+func (a *manageTestApi) MpoolPushMessage(ctx context.Context, msg *types.Message) (*types.SignedMessage, error) {
+	a.pushed = append(a.pushed, msg)
+	return &types.SignedMessage{Message: *msg}, nil
+}
+
+func (a *manageTestApi) StateWaitMsg(context.Context, cid.Cid) (*api.MsgLookup, error) {
+	return &api.MsgLookup{}, nil
+}
+
+func marketDeal(end, slashed abi.ChainEpoch) api.MarketDeal {
+	return api.MarketDeal{
+		Proposal: market.DealProposal{EndEpoch: end},
+		State:    market.DealState{SlashEpoch: slashed},
+	}
+}
+
+func TestActiveDeals(t *testing.T) {
+	// the test head is at height 0
+	a := &manageTestApi{deals: map[string]api.MarketDeal{
+		"1": marketDeal(100, -1),
+		"2": marketDeal(0, -1),
+		"3": marketDeal(100, 5),
+	}}
+	m := &Miner{api: a}
+
+	active, err := m.activeDeals(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for deal, expect := range map[abi.DealID]bool{
+		1: true,
+		// ended
+		2: false,
+		// slashed
+		3: false,
+		// missing from the market state
+		4: false,
+	} {
+		if _, ok := active[deal]; ok != expect {
+			t.Errorf("deal %d: expected active %t, got %t", deal, expect, ok)
+		}
+	}
+}
+
+func TestTerminateSectorsDeals(t *testing.T) {
+	maddr, err := address.NewIDAddress(1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worker, err := address.NewIDAddress(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sector := func(num abi.SectorNumber, deals ...abi.DealID) *api.ChainSectorInfo {
+		return &api.ChainSectorInfo{
+			ID: num,
+			Info: miner.SectorOnChainInfo{
+				Info: miner.SectorPreCommitInfo{SectorNumber: num, DealIDs: deals},
+			},
+		}
+	}
+
+	a := &manageTestApi{
+		sectors: []*api.ChainSectorInfo{sector(1, 1), sector(2, 2)},
+		deals:   map[string]api.MarketDeal{"1": marketDeal(100, -1)},
+	}
+	m := &Miner{
+		api:    a,
+		maddr:  maddr,
+		worker: worker,
+		policy: MessagePolicy{MsgTerminateSectors: {GasLimit: 20000, GasPrice: types.NewInt(3)}},
+	}
+
+	if _, err := m.TerminateSectors(context.TODO(), []abi.SectorNumber{1}, false); err == nil {
+		t.Fatal("expected terminating a sector with an active deal to fail")
+	}
+	if len(a.pushed) != 0 {
+		t.Fatalf("expected no messages, got %d", len(a.pushed))
+	}
+
+	// deal 2 isn't in the market state anymore
+	if _, err := m.TerminateSectors(context.TODO(), []abi.SectorNumber{2}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.TerminateSectors(context.TODO(), []abi.SectorNumber{1}, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(a.pushed) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(a.pushed))
+	}
+	for _, msg := range a.pushed {
+		if msg.From != worker || msg.GasLimit != 20000 || !msg.GasPrice.Equals(types.NewInt(3)) {
+			t.Errorf("terminate message doesn't use the policy: %+v", msg)
+		}
+	}
+}
+
+func TestRemoveSector(t *testing.T) {
+	mt := newMoveTest(t)
+	defer mt.close()
+
+	maddr, err := address.NewIDAddress(1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := &manageTestApi{
+		sectors: []*api.ChainSectorInfo{{ID: 6, Info: miner.SectorOnChainInfo{Info: miner.SectorPreCommitInfo{SectorNumber: 6}}}},
+		deals:   map[string]api.MarketDeal{"1": marketDeal(100, -1)},
+	}
+	m := &Miner{
+		api:     a,
+		ds:      mt.ds,
+		maddr:   maddr,
+		files:   mt.files(),
+		sealing: sealing.New(nil, nil, maddr, address.Undef, mt.ds, nil, nil, nil, nil, nil),
+	}
+
+	deal := func(id abi.DealID) sealing.Piece {
+		return sealing.Piece{
+			Piece:    abi.PieceInfo{Size: 2048, PieceCID: builtin.AccountActorCodeID},
+			DealInfo: &sealing.DealInfo{DealID: id},
+		}
+	}
+
+	for _, s := range []sealing.SectorInfo{
+		{SectorNumber: 1, State: sealing.SealFailed},
+		{SectorNumber: 2, State: sealing.PreCommit1},
+		// deal 1 is active
+		{SectorNumber: 3, State: sealing.CommitFailed, Pieces: []sealing.Piece{deal(1)}},
+		// deal 2 isn't in the market state
+		{SectorNumber: 4, State: sealing.CommitFailed, Pieces: []sealing.Piece{deal(2)}},
+		{SectorNumber: 5, State: sealing.FailedUnrecoverable},
+		// committed on chain
+		{SectorNumber: 6, State: sealing.CommitFailed},
+	} {
+		b, err := cborutil.Dump(&s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := mt.ds.Put(sectorStateKey(s.SectorNumber), b); err != nil {
+			t.Fatal(err)
+		}
+
+		mt.sid = abi.SectorID{Miner: 1000, Number: s.SectorNumber}
+		mt.writeSector("from")
+	}
+
+	for _, c := range []struct {
+		num   abi.SectorNumber
+		force bool
+		ok    bool
+	}{
+		{num: 1, ok: true},
+		{num: 2},
+		{num: 2, force: true, ok: true},
+		{num: 3},
+		{num: 3, force: true},
+		{num: 4},
+		{num: 4, force: true, ok: true},
+		{num: 5, ok: true},
+		{num: 6, force: true},
+		// already removed
+		{num: 1, force: true},
+	} {
+		err := m.RemoveSector(context.TODO(), c.num, c.force)
+		if (err == nil) != c.ok {
+			t.Fatalf("sector %d (force %t): expected success %t, got %v", c.num, c.force, c.ok, err)
+		}
+	}
+
+	sectors, err := m.ListSectors()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var left []abi.SectorNumber
+	for _, s := range sectors {
+		left = append(left, s.SectorNumber)
+	}
+	sort.Slice(left, func(i, j int) bool { return left[i] < left[j] })
+	if len(left) != 2 || left[0] != 3 || left[1] != 6 {
+		t.Errorf("expected sectors 3 and 6 to be left, got %v", left)
+	}
+
+	for num := abi.SectorNumber(1); num <= 6; num++ {
+		mt.sid = abi.SectorID{Miner: 1000, Number: num}
+		_, err := os.Stat(mt.path("from", stores.FTSealed))
+		if kept := num == 3 || num == 6; kept != (err == nil) {
+			t.Errorf("sector %d: expected sealed file kept %t, got %v", num, kept, err)
+		}
+
+		si, err := mt.index.StorageFindSector(context.TODO(), mt.sid, stores.FTSealed, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if kept := num == 3 || num == 6; kept != (len(si) > 0) {
+			t.Errorf("sector %d: expected indexed file kept %t, got %d", num, kept, len(si))
+		}
+	}
+}
+
+type extendTestApi struct {
+	manageTestApi
+
+	// number of messages pushed when each message was waited for
+	waited []int
+}
+
+func (a *extendTestApi) StateWaitMsg(context.Context, cid.Cid) (*api.MsgLookup, error) {
+	a.waited = append(a.waited, len(a.pushed))
+	return &api.MsgLookup{}, nil
+}
+
+func TestExtendSectors(t *testing.T) {
+	maddr, err := address.NewIDAddress(1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	worker, err := address.NewIDAddress(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := &extendTestApi{}
+	var nums []abi.SectorNumber
+	for num := abi.SectorNumber(1); num <= extendBatchSize+8; num++ {
+		a.sectors = append(a.sectors, &api.ChainSectorInfo{
+			ID: num,
+			Info: miner.SectorOnChainInfo{
+				Info: miner.SectorPreCommitInfo{SectorNumber: num, Expiration: 1000},
+			},
+		})
+		nums = append(nums, num)
+	}
+	m := &Miner{
+		api:    a,
+		maddr:  maddr,
+		worker: worker,
+		policy: MessagePolicy{},
+	}
+
+	if _, err := m.ExtendSectors(context.TODO(), []abi.SectorNumber{1, 2}, 1000); err == nil {
+		t.Error("expected extending to the current expiration to fail")
+	}
+	if _, err := m.ExtendSectors(context.TODO(), []abi.SectorNumber{1, extendBatchSize + 9}, 2000); err == nil {
+		t.Error("expected extending a sector which isn't on chain to fail")
+	}
+	if len(a.pushed) != 0 {
+		t.Fatalf("expected no messages for invalid extensions, got %d", len(a.pushed))
+	}
+
+	sent, err := m.ExtendSectors(context.TODO(), nums, 2000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != len(nums) || len(a.pushed) != len(nums) {
+		t.Fatalf("expected %d messages, got %d (%d pushed)", len(nums), len(sent), len(a.pushed))
+	}
+
+	// the second batch is sent once the first one landed
+	for i, n := range a.waited {
+		expect := extendBatchSize
+		if i >= extendBatchSize {
+			expect = len(nums)
+		}
+		if n != expect {
+			t.Fatalf("message %d: expected to wait with %d messages pushed, got %d", i, expect, n)
+		}
+	}
+
+	for i, msg := range a.pushed {
+		var params miner.ExtendSectorExpirationParams
+		if err := params.UnmarshalCBOR(bytes.NewReader(msg.Params)); err != nil {
+			t.Fatal(err)
+		}
+		if params.SectorNumber != nums[i] || params.NewExpiration != 2000 || msg.Method != builtin.MethodsMiner.ExtendSectorExpiration {
+			t.Errorf("unexpected extension message %d: %+v", i, params)
+		}
+	}
+}
@@ -0,0 +1,452 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/sector-storage/stores"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+
+	"github.com/filecoin-project/lotus/api"
+)
+
+// Sector moves are journaled in the metadata datastore, so that moves
+// interrupted by a restart are resumed
+//
+//	/sector-moves/<sector number>    JSON api.SectorMove
+//
+// Files are copied to the destination under temporary names, verified, and
+// renamed. The journal is then moved to the removing stage, in which the
+// sector is dropped from the source storage in the index, and the source files
+// are removed. A move interrupted while copying is started over.
+
+const moveTmpSuffix = ".moving"
+
+func moveKey(num abi.SectorNumber) datastore.Key {
+	return datastore.NewKey(fmt.Sprint(num))
+}
+
+// Move starts moving the files of a sector from the local storage path with
+// its sealed file to the local storage path dest
+func (f *SectorFiles) Move(ctx context.Context, sid abi.SectorID, dest stores.ID) error {
+	if f.local == nil {
+		return xerrors.Errorf("sector manager doesn't use local storage")
+	}
+
+	local, err := f.local.StorageLocal(ctx)
+	if err != nil {
+		return xerrors.Errorf("getting local storage: %w", err)
+	}
+
+	if _, ok := local[dest]; !ok {
+		return xerrors.Errorf("storage %s isn't attached to this machine", dest)
+	}
+
+	info, err := f.index.StorageInfo(ctx, dest)
+	if err != nil {
+		return xerrors.Errorf("getting storage info: %w", err)
+	}
+	if !info.CanStore {
+		return xerrors.Errorf("storage %s isn't used for long-term storage", dest)
+	}
+
+	si, err := f.index.StorageFindSector(ctx, sid, stores.FTSealed, false)
+	if err != nil {
+		return xerrors.Errorf("finding sealed file: %w", err)
+	}
+
+	var from stores.ID
+	for _, info := range si {
+		if _, ok := local[info.ID]; ok {
+			from = info.ID
+			break
+		}
+	}
+
+	switch from {
+	case "":
+		return xerrors.Errorf("sealed file isn't in storage of this machine")
+	case dest:
+		return xerrors.Errorf("sector is already in storage %s", dest)
+	}
+
+	mv := &api.SectorMove{
+		Sector: sid,
+		From:   from,
+		To:     dest,
+		Stage:  api.SectorMoveCopying,
+		Start:  time.Now(),
+	}
+
+	f.movesLk.Lock()
+	if cur, ok := f.moves[sid.Number]; ok && !moveEnded(cur) {
+		f.movesLk.Unlock()
+		return xerrors.Errorf("sector is already being moved to %s", cur.To)
+	}
+	f.moves[sid.Number] = mv
+	f.movesLk.Unlock()
+
+	if err := f.journalMove(mv); err != nil {
+		f.setMoveErr(mv, err)
+		return xerrors.Errorf("journaling move: %w", err)
+	}
+
+	go f.runMove(mv)
+	return nil
+}
+
+// Moves returns moves in progress, and moves which ended since the miner
+// started, ordered by sector number
+func (f *SectorFiles) Moves() []api.SectorMove {
+	f.movesLk.Lock()
+	defer f.movesLk.Unlock()
+
+	out := make([]api.SectorMove, 0, len(f.moves))
+	for _, mv := range f.moves {
+		out = append(out, *mv)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Sector.Number < out[j].Sector.Number
+	})
+
+	return out
+}
+
+// ResumeMoves resumes the moves which were in progress when the miner stopped
+func (f *SectorFiles) ResumeMoves() error {
+	res, err := f.moveDs.Query(dsq.Query{})
+	if err != nil {
+		return err
+	}
+	defer res.Close()
+
+	var resumed []*api.SectorMove
+	for r := range res.Next() {
+		if r.Error != nil {
+			return r.Error
+		}
+
+		var mv api.SectorMove
+		if err := json.Unmarshal(r.Value, &mv); err != nil {
+			return xerrors.Errorf("decoding move %s: %w", r.Key, err)
+		}
+		resumed = append(resumed, &mv)
+	}
+
+	if len(resumed) > 0 && f.local == nil {
+		return xerrors.Errorf("can't resume %d sector moves, sector manager doesn't use local storage", len(resumed))
+	}
+
+	for _, mv := range resumed {
+		log.Infow("resuming sector move", "sector", mv.Sector, "from", mv.From, "to", mv.To, "stage", mv.Stage)
+
+		f.movesLk.Lock()
+		f.moves[mv.Sector.Number] = mv
+		f.movesLk.Unlock()
+
+		go f.runMove(mv)
+	}
+
+	return nil
+}
+
+func (f *SectorFiles) runMove(mv *api.SectorMove) {
+	// moves outlive the API calls starting them
+	ctx := context.TODO()
+
+	if err := f.move(ctx, mv); err != nil {
+		log.Errorw("moving sector failed", "sector", mv.Sector, "from", mv.From, "to", mv.To, "error", err)
+		f.setMoveErr(mv, err)
+		return
+	}
+
+	f.movesLk.Lock()
+	mv.Stage = api.SectorMoveDone
+	f.movesLk.Unlock()
+
+	if err := f.moveDs.Delete(moveKey(mv.Sector.Number)); err != nil {
+		log.Errorf("removing journal of sector %d move: %+v", mv.Sector.Number, err)
+	}
+
+	log.Infow("moved sector", "sector", mv.Sector, "from", mv.From, "to", mv.To)
+}
+
+func (f *SectorFiles) move(ctx context.Context, mv *api.SectorMove) error {
+	local, err := f.local.StorageLocal(ctx)
+	if err != nil {
+		return xerrors.Errorf("getting local storage: %w", err)
+	}
+
+	fromPath, ok := local[mv.From]
+	if !ok {
+		return xerrors.Errorf("source storage %s isn't attached anymore", mv.From)
+	}
+	toPath, ok := local[mv.To]
+	if !ok {
+		return xerrors.Errorf("destination storage %s isn't attached anymore", mv.To)
+	}
+
+	name := sectorName(mv.Sector)
+
+	f.movesLk.Lock()
+	stage := mv.Stage
+	f.movesLk.Unlock()
+
+	if stage == api.SectorMoveCopying {
+		var fts []stores.SectorFileType
+		var total uint64
+		for _, ft := range []stores.SectorFileType{stores.FTUnsealed, stores.FTSealed, stores.FTCache} {
+			size, err := pathSize(filepath.Join(fromPath, ft.String(), name))
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return xerrors.Errorf("getting size of %s file: %w", ft, err)
+			}
+
+			fts = append(fts, ft)
+			total += size
+		}
+
+		if len(fts) == 0 {
+			return xerrors.Errorf("no files of the sector in %s", fromPath)
+		}
+
+		f.movesLk.Lock()
+		mv.Copied = 0
+		mv.Total = total
+		f.movesLk.Unlock()
+
+		progress := func(n int) {
+			f.movesLk.Lock()
+			mv.Copied += uint64(n)
+			f.movesLk.Unlock()
+		}
+
+		if err := copyFiles(fromPath, toPath, name, fts, progress); err != nil {
+			// the files copied so far aren't used, the move starts over
+			for _, ft := range fts {
+				tmp := filepath.Join(toPath, ft.String(), name+moveTmpSuffix)
+				if rerr := os.RemoveAll(tmp); rerr != nil {
+					log.Errorf("removing %s: %+v", tmp, rerr)
+				}
+			}
+			return err
+		}
+
+		for _, ft := range fts {
+			dst := filepath.Join(toPath, ft.String(), name)
+
+			if err := os.RemoveAll(dst); err != nil {
+				return xerrors.Errorf("removing %s: %w", dst, err)
+			}
+			if err := os.Rename(dst+moveTmpSuffix, dst); err != nil {
+				return xerrors.Errorf("renaming %s file: %w", ft, err)
+			}
+
+			if err := f.index.StorageDeclareSector(ctx, mv.To, mv.Sector, ft); err != nil {
+				return xerrors.Errorf("declaring %s file: %w", ft, err)
+			}
+		}
+
+		f.movesLk.Lock()
+		mv.Stage = api.SectorMoveRemoving
+		f.movesLk.Unlock()
+
+		if err := f.journalMove(mv); err != nil {
+			return xerrors.Errorf("journaling move: %w", err)
+		}
+	}
+
+	for _, ft := range []stores.SectorFileType{stores.FTUnsealed, stores.FTSealed, stores.FTCache} {
+		si, err := f.index.StorageFindSector(ctx, mv.Sector, ft, false)
+		if err != nil {
+			return xerrors.Errorf("finding %s file: %w", ft, err)
+		}
+
+		for _, info := range si {
+			if info.ID != mv.From {
+				continue
+			}
+
+			if err := f.index.StorageDropSector(ctx, mv.From, mv.Sector, ft); err != nil {
+				return xerrors.Errorf("dropping %s file from index: %w", ft, err)
+			}
+		}
+
+		if err := os.RemoveAll(filepath.Join(fromPath, ft.String(), name)); err != nil {
+			return xerrors.Errorf("removing %s file: %w", ft, err)
+		}
+	}
+
+	return nil
+}
+
+// copyFiles copies the sector files of types fts to temporary names in toPath
+func copyFiles(fromPath, toPath, name string, fts []stores.SectorFileType, progress func(int)) error {
+	for _, ft := range fts {
+		src := filepath.Join(fromPath, ft.String(), name)
+		tmp := filepath.Join(toPath, ft.String(), name+moveTmpSuffix)
+
+		if err := os.RemoveAll(tmp); err != nil {
+			return xerrors.Errorf("removing leftover %s: %w", tmp, err)
+		}
+
+		if err := copyVerified(src, tmp, progress); err != nil {
+			return xerrors.Errorf("copying %s file: %w", ft, err)
+		}
+	}
+
+	return nil
+}
+
+func (f *SectorFiles) journalMove(mv *api.SectorMove) error {
+	f.movesLk.Lock()
+	b, err := json.Marshal(mv)
+	f.movesLk.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return f.moveDs.Put(moveKey(mv.Sector.Number), b)
+}
+
+// setMoveErr marks a move as failed. Moves failing while copying are dropped
+// from the journal, the source files are still in place. Moves failing while
+// removing are retried when the miner restarts.
+func (f *SectorFiles) setMoveErr(mv *api.SectorMove, err error) {
+	f.movesLk.Lock()
+	stage := mv.Stage
+	mv.Stage = api.SectorMoveFailed
+	mv.Err = err.Error()
+	f.movesLk.Unlock()
+
+	if stage != api.SectorMoveCopying {
+		return
+	}
+
+	if err := f.moveDs.Delete(moveKey(mv.Sector.Number)); err != nil {
+		log.Errorf("removing journal of sector %d move: %+v", mv.Sector.Number, err)
+	}
+}
+
+func moveEnded(mv *api.SectorMove) bool {
+	return mv.Stage == api.SectorMoveDone || mv.Stage == api.SectorMoveFailed
+}
+
+func pathSize(p string) (uint64, error) {
+	var size uint64
+	err := filepath.Walk(p, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += uint64(info.Size())
+		}
+		return nil
+	})
+
+	return size, err
+}
+
+// copyVerified copies the file or directory src to dst. Each file is synced,
+// read back, and compared with the source checksum.
+func copyVerified(src, dst string, progress func(int)) error {
+	return filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+
+		sum, err := copyFile(p, target, progress)
+		if err != nil {
+			return err
+		}
+
+		check, err := fileChecksum(target)
+		if err != nil {
+			return xerrors.Errorf("reading back %s: %w", target, err)
+		}
+
+		if !bytes.Equal(sum, check) {
+			return xerrors.Errorf("checksum of %s doesn't match %s", target, p)
+		}
+
+		return nil
+	})
+}
+
+type progressWriter func(int)
+
+func (pw progressWriter) Write(p []byte) (int, error) {
+	pw(len(p))
+	return len(p), nil
+}
+
+// copyFile copies src to dst and returns the checksum of src
+func copyFile(src, dst string, progress func(int)) ([]byte, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close() // nolint:errcheck
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(out, h, progressWriter(progress)), in); err != nil {
+		_ = out.Close()
+		return nil, err
+	}
+
+	if err := out.Sync(); err != nil {
+		_ = out.Close()
+		return nil, err
+	}
+
+	if err := out.Close(); err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
+
+func fileChecksum(p string) ([]byte, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() // nolint:errcheck
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,287 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+
+	"github.com/filecoin-project/sector-storage/stores"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+
+	"github.com/filecoin-project/lotus/api"
+)
+
+type testLocal map[stores.ID]string
+
+func (l testLocal) StorageLocal(ctx context.Context) (map[stores.ID]string, error) {
+	return l, nil
+}
+
+type moveTest struct {
+	t   *testing.T
+	dir string
+
+	index *stores.Index
+	local testLocal
+	ds    datastore.Batching
+
+	sid abi.SectorID
+}
+
+func newMoveTest(t *testing.T) *moveTest {
+	dir, err := ioutil.TempDir("", "sector-move")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mt := &moveTest{
+		t:     t,
+		dir:   dir,
+		index: stores.NewIndex(),
+		local: testLocal{},
+		ds:    dssync.MutexWrap(datastore.NewMapDatastore()),
+		sid:   abi.SectorID{Miner: 1000, Number: 1},
+	}
+
+	for _, id := range []stores.ID{"from", "to"} {
+		p := filepath.Join(dir, string(id))
+		for _, ft := range []stores.SectorFileType{stores.FTUnsealed, stores.FTSealed, stores.FTCache} {
+			if err := os.MkdirAll(filepath.Join(p, ft.String()), 0755); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		mt.local[id] = p
+		if err := mt.index.StorageAttach(context.TODO(), stores.StorageInfo{ID: id, CanStore: true}, stores.FsStat{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return mt
+}
+
+func (mt *moveTest) close() {
+	_ = os.RemoveAll(mt.dir)
+}
+
+func (mt *moveTest) files() *SectorFiles {
+	return NewSectorFiles(mt.index, mt.local, mt.ds)
+}
+
+func (mt *moveTest) path(id stores.ID, ft stores.SectorFileType, rel ...string) string {
+	return filepath.Join(append([]string{mt.local[id], ft.String(), sectorName(mt.sid)}, rel...)...)
+}
+
+// writeSector writes a sealed file and a cache directory of the sector, and
+// declares them in storage id
+func (mt *moveTest) writeSector(id stores.ID) {
+	if err := ioutil.WriteFile(mt.path(id, stores.FTSealed), []byte("sealed"), 0644); err != nil {
+		mt.t.Fatal(err)
+	}
+	if err := os.MkdirAll(mt.path(id, stores.FTCache), 0755); err != nil {
+		mt.t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(mt.path(id, stores.FTCache, "t_aux"), []byte("aux"), 0644); err != nil {
+		mt.t.Fatal(err)
+	}
+
+	for _, ft := range []stores.SectorFileType{stores.FTSealed, stores.FTCache} {
+		if err := mt.index.StorageDeclareSector(context.TODO(), id, mt.sid, ft); err != nil {
+			mt.t.Fatal(err)
+		}
+	}
+}
+
+func (mt *moveTest) journal(mv api.SectorMove) {
+	b, err := json.Marshal(&mv)
+	if err != nil {
+		mt.t.Fatal(err)
+	}
+	if err := mt.ds.Put(datastore.NewKey("/sector-moves").Child(moveKey(mv.Sector.Number)), b); err != nil {
+		mt.t.Fatal(err)
+	}
+}
+
+func (mt *moveTest) wait(f *SectorFiles) api.SectorMove {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		moves := f.Moves()
+		if len(moves) != 1 {
+			mt.t.Fatalf("expected 1 move, got %d", len(moves))
+		}
+		if moveEnded(&moves[0]) {
+			return moves[0]
+		}
+		if time.Now().After(deadline) {
+			mt.t.Fatalf("move didn't end, stage %s", moves[0].Stage)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// checkMoved checks that the files of the sector are only in the destination
+// storage, and that the journal is empty
+func (mt *moveTest) checkMoved() {
+	b, err := ioutil.ReadFile(mt.path("to", stores.FTCache, "t_aux"))
+	if err != nil {
+		mt.t.Fatal(err)
+	}
+	if string(b) != "aux" {
+		mt.t.Errorf("unexpected cache file content %q", b)
+	}
+	if _, err := os.Stat(mt.path("to", stores.FTSealed)); err != nil {
+		mt.t.Error(err)
+	}
+	if _, err := os.Stat(mt.path("to", stores.FTCache) + moveTmpSuffix); !os.IsNotExist(err) {
+		mt.t.Errorf("expected the temporary copy to be renamed, got %v", err)
+	}
+
+	for _, ft := range []stores.SectorFileType{stores.FTSealed, stores.FTCache} {
+		if _, err := os.Stat(mt.path("from", ft)); !os.IsNotExist(err) {
+			mt.t.Errorf("expected the source %s file to be removed, got %v", ft, err)
+		}
+
+		ids, err := mt.index.FindSector(mt.sid, ft)
+		if err != nil {
+			mt.t.Fatal(err)
+		}
+		if len(ids) != 1 || ids[0] != "to" {
+			mt.t.Errorf("expected the %s file only in the destination, got %v", ft, ids)
+		}
+	}
+
+	has, err := mt.ds.Has(datastore.NewKey("/sector-moves").Child(moveKey(mt.sid.Number)))
+	if err != nil {
+		mt.t.Fatal(err)
+	}
+	if has {
+		mt.t.Error("expected the journal to be removed")
+	}
+}
+
+func TestSectorMove(t *testing.T) {
+	mt := newMoveTest(t)
+	defer mt.close()
+	mt.writeSector("from")
+
+	f := mt.files()
+	if err := f.Move(context.TODO(), mt.sid, "to"); err != nil {
+		t.Fatal(err)
+	}
+
+	mv := mt.wait(f)
+	if mv.Stage != api.SectorMoveDone {
+		t.Fatalf("move failed: %s", mv.Err)
+	}
+	if mv.Copied != mv.Total || mv.Total != uint64(len("sealed")+len("aux")) {
+		t.Errorf("expected all %d bytes copied, got %d/%d", len("sealed")+len("aux"), mv.Copied, mv.Total)
+	}
+
+	mt.checkMoved()
+
+	if err := f.Move(context.TODO(), mt.sid, "to"); err == nil {
+		t.Error("expected moving to the storage with the sector to fail")
+	}
+}
+
+func TestSectorMoveResumeCopying(t *testing.T) {
+	mt := newMoveTest(t)
+	defer mt.close()
+	mt.writeSector("from")
+
+	// the move was interrupted while copying the cache
+	if err := os.MkdirAll(mt.path("to", stores.FTCache)+moveTmpSuffix, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(mt.path("to", stores.FTCache)+moveTmpSuffix, "t_aux"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mt.journal(api.SectorMove{Sector: mt.sid, From: "from", To: "to", Stage: api.SectorMoveCopying, Copied: 7})
+
+	f := mt.files()
+	if err := f.ResumeMoves(); err != nil {
+		t.Fatal(err)
+	}
+
+	if mv := mt.wait(f); mv.Stage != api.SectorMoveDone {
+		t.Fatalf("move failed: %s", mv.Err)
+	}
+	mt.checkMoved()
+}
+
+func TestSectorMoveResumeRemoving(t *testing.T) {
+	mt := newMoveTest(t)
+	defer mt.close()
+	mt.writeSector("from")
+	mt.writeSector("to")
+
+	// the move was interrupted after the copy was declared
+	mt.journal(api.SectorMove{Sector: mt.sid, From: "from", To: "to", Stage: api.SectorMoveRemoving})
+
+	f := mt.files()
+	if err := f.ResumeMoves(); err != nil {
+		t.Fatal(err)
+	}
+
+	if mv := mt.wait(f); mv.Stage != api.SectorMoveDone {
+		t.Fatalf("move failed: %s", mv.Err)
+	}
+	mt.checkMoved()
+}
+
+func TestSectorMoveFailedCopy(t *testing.T) {
+	mt := newMoveTest(t)
+	defer mt.close()
+	mt.writeSector("from")
+
+	// the destination path can't be written to
+	if err := os.RemoveAll(filepath.Join(mt.local["to"], stores.FTCache.String())); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(mt.local["to"], stores.FTCache.String()), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f := mt.files()
+	if err := f.Move(context.TODO(), mt.sid, "to"); err != nil {
+		t.Fatal(err)
+	}
+
+	if mv := mt.wait(f); mv.Stage != api.SectorMoveFailed || mv.Err == "" {
+		t.Fatalf("expected the move to fail, got stage %s", mv.Stage)
+	}
+
+	// the source is kept, and the failed copy isn't resumed
+	for _, ft := range []stores.SectorFileType{stores.FTSealed, stores.FTCache} {
+		ids, err := mt.index.FindSector(mt.sid, ft)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ids) != 1 || ids[0] != "from" {
+			t.Errorf("expected the %s file only in the source, got %v", ft, ids)
+		}
+	}
+	if _, err := os.Stat(mt.path("from", stores.FTSealed)); err != nil {
+		t.Error(err)
+	}
+	for _, p := range []string{mt.path("to", stores.FTSealed), mt.path("to", stores.FTSealed) + moveTmpSuffix} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed, got %v", p, err)
+		}
+	}
+
+	has, err := mt.ds.Has(datastore.NewKey("/sector-moves").Child(moveKey(mt.sid.Number)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if has {
+		t.Error("expected the failed move to be dropped from the journal")
+	}
+}
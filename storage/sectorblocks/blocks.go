@@ -12,6 +12,7 @@ import (
 	"github.com/ipfs/go-datastore/namespace"
 	"github.com/ipfs/go-datastore/query"
 	dshelp "github.com/ipfs/go-ipfs-ds-help"
+	logging "github.com/ipfs/go-log/v2"
 	"golang.org/x/xerrors"
 
 	cborutil "github.com/filecoin-project/go-cbor-util"
@@ -24,6 +25,8 @@ import (
 	"github.com/filecoin-project/lotus/storage"
 )
 
+var log = logging.Logger("sectorblocks")
+
 type SealSerialization uint8
 
 const (
@@ -0,0 +1,359 @@
+package sectorblocks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
+	"github.com/ipfs/go-datastore/query"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	sealing "github.com/filecoin-project/storage-fsm"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/node/config"
+)
+
+type packerApi interface {
+	ChainHead(context.Context) (*types.TipSet, error)
+}
+
+type pieceSealer interface {
+	AddPiece(ctx context.Context, size abi.UnpaddedPieceSize, r io.Reader, d sealing.DealInfo) (abi.SectorNumber, error)
+}
+
+// packedPiece is a deal waiting for packing. It is stored in the datastore,
+// and its piece data in the staging directory, until it's sealed.
+type packedPiece struct {
+	Deal  sealing.DealInfo
+	Size  abi.UnpaddedPieceSize
+	Added time.Time
+}
+
+// Packer holds deal pieces before they are handed to sealing. Waiting deals
+// are released together when their pieces fill a sector, when the oldest deal
+// waited for MaxWait, when a deal has to start sealing to be sealed before its
+// start epoch, or when SealNow is called. The sealing state machine still
+// seals each released deal in its own sector.
+//
+// Piece data of waiting deals is copied to the staging directory, so that
+// deals survive restarts of the miner.
+type Packer struct {
+	sealer  pieceSealer
+	api     packerApi
+	ds      datastore.Batching
+	staging string
+	ssize   abi.SectorSize
+	cfg     config.PackingConfig
+
+	lk      sync.Mutex
+	pending []*packedPiece // oldest first
+	sealNow bool
+
+	check chan struct{}
+}
+
+func NewPacker(sealer pieceSealer, api packerApi, ds datastore.Batching, staging string, ssize abi.SectorSize, cfg config.PackingConfig) (*Packer, error) {
+	if cfg.FillPercent == 0 || cfg.FillPercent > 100 {
+		return nil, xerrors.Errorf("fill percent must be between 1 and 100, got %d", cfg.FillPercent)
+	}
+
+	if err := os.MkdirAll(staging, 0755); err != nil {
+		return nil, xerrors.Errorf("creating staging directory: %w", err)
+	}
+
+	p := &Packer{
+		sealer:  sealer,
+		api:     api,
+		ds:      namespace.Wrap(ds, datastore.NewKey("/packer")),
+		staging: staging,
+		ssize:   ssize,
+		cfg:     cfg,
+
+		check: make(chan struct{}, 1),
+	}
+
+	if err := p.load(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// AddPiece queues the piece of a deal for packing, and returns once the piece
+// data is staged. With MaxWait 0, the piece is handed to sealing directly.
+func (p *Packer) AddPiece(ctx context.Context, size abi.UnpaddedPieceSize, r io.Reader, d sealing.DealInfo) error {
+	if p.cfg.MaxWait == 0 {
+		_, err := p.sealer.AddPiece(ctx, size, r, d)
+		return err
+	}
+
+	pp := &packedPiece{
+		Deal:  d,
+		Size:  size,
+		Added: time.Now(),
+	}
+
+	if err := p.stage(pp, r); err != nil {
+		return err
+	}
+
+	p.lk.Lock()
+	p.pending = append(p.pending, pp)
+	p.lk.Unlock()
+	p.wake()
+
+	log.Infow("deal waiting for packing", "deal", d.DealID, "size", size)
+	return nil
+}
+
+// SealNow releases all waiting deals
+func (p *Packer) SealNow() {
+	p.lk.Lock()
+	p.sealNow = true
+	p.lk.Unlock()
+
+	p.wake()
+}
+
+func (p *Packer) Status(ctx context.Context) (api.PackingStatus, error) {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+
+	st := api.PackingStatus{
+		SectorSize: p.ssize,
+	}
+
+	for i, pp := range p.pending {
+		st.Pieces = append(st.Pieces, api.PackingPiece{
+			DealID:     pp.Deal.DealID,
+			Size:       pp.Size,
+			StartEpoch: pp.Deal.DealSchedule.StartEpoch,
+			Added:      pp.Added,
+		})
+		st.Filled += pp.Size.Padded()
+
+		sealBy := pp.Deal.DealSchedule.StartEpoch - p.cfg.SealDuration
+		if i == 0 || sealBy < st.SealBy {
+			st.SealBy = sealBy
+		}
+	}
+
+	if len(p.pending) > 0 {
+		st.Deadline = p.pending[0].Added.Add(time.Duration(p.cfg.MaxWait))
+	}
+
+	return st, nil
+}
+
+func (p *Packer) Run(ctx context.Context) {
+	t := time.NewTicker(build.BlockDelay * time.Second)
+	defer t.Stop()
+
+	for {
+		for _, pp := range p.checkRelease(ctx) {
+			if err := p.seal(ctx, pp); err != nil {
+				log.Errorf("sealing packed deal: %+v", err)
+				p.requeue(pp)
+			}
+		}
+
+		select {
+		case <-t.C:
+		case <-p.check:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// checkRelease removes the deals to hand to sealing from the pending deals.
+// All deals are released once their pieces fill FillPercent of a sector, or
+// when one of them can't wait any longer.
+func (p *Packer) checkRelease(ctx context.Context) []*packedPiece {
+	// deals filling a sector or waiting for too long are released even when
+	// the chain head isn't known
+	head, err := p.api.ChainHead(ctx)
+	if err != nil {
+		log.Errorf("getting chain head: %+v", err)
+	}
+
+	p.lk.Lock()
+	defer p.lk.Unlock()
+
+	if len(p.pending) == 0 {
+		p.sealNow = false
+		return nil
+	}
+
+	if p.sealNow {
+		p.sealNow = false
+		return p.releaseAll("sealing requested")
+	}
+
+	if head != nil {
+		for _, pp := range p.pending {
+			if pp.Deal.DealSchedule.StartEpoch-p.cfg.SealDuration <= head.Height() {
+				return p.releaseAll("deal %d starts at epoch %d", pp.Deal.DealID, pp.Deal.DealSchedule.StartEpoch)
+			}
+		}
+	}
+
+	if time.Since(p.pending[0].Added) >= time.Duration(p.cfg.MaxWait) {
+		return p.releaseAll("deal %d waited %s", p.pending[0].Deal.DealID, p.cfg.MaxWait)
+	}
+
+	var filled abi.PaddedPieceSize
+	for _, pp := range p.pending {
+		filled += pp.Size.Padded()
+	}
+
+	if uint64(filled)*100 >= uint64(p.ssize)*p.cfg.FillPercent {
+		return p.releaseAll("pieces fill %d of %d bytes", filled, p.ssize)
+	}
+
+	return nil
+}
+
+// Must be called with p.lk held.
+func (p *Packer) releaseAll(reason string, args ...interface{}) []*packedPiece {
+	log.Infof("releasing %d deals for sealing: "+reason, append([]interface{}{len(p.pending)}, args...)...)
+
+	out := p.pending
+	p.pending = nil
+	return out
+}
+
+// seal hands a deal to sealing, and removes it from the datastore and the
+// staging directory
+func (p *Packer) seal(ctx context.Context, pp *packedPiece) error {
+	f, err := os.Open(p.stagingPath(pp.Deal.DealID))
+	if err != nil {
+		return xerrors.Errorf("opening staged piece of deal %d: %w", pp.Deal.DealID, err)
+	}
+	defer f.Close() // nolint: errcheck
+
+	sector, err := p.sealer.AddPiece(ctx, pp.Size, f, pp.Deal)
+	if err != nil {
+		return xerrors.Errorf("adding piece of deal %d: %w", pp.Deal.DealID, err)
+	}
+
+	log.Infow("deal handed to sealing", "deal", pp.Deal.DealID, "sector", sector)
+
+	if err := p.ds.Delete(dealKey(pp.Deal.DealID)); err != nil {
+		log.Errorf("removing packed deal %d: %s", pp.Deal.DealID, err)
+	}
+	if err := os.Remove(p.stagingPath(pp.Deal.DealID)); err != nil {
+		log.Errorf("removing staged piece of deal %d: %s", pp.Deal.DealID, err)
+	}
+
+	return nil
+}
+
+// requeue puts back a deal which failed to be handed to sealing, it is
+// retried with the next release
+func (p *Packer) requeue(pp *packedPiece) {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+
+	p.pending = append(p.pending, pp)
+	sortPieces(p.pending)
+}
+
+func (p *Packer) stage(pp *packedPiece, r io.Reader) error {
+	path := p.stagingPath(pp.Deal.DealID)
+
+	f, err := os.Create(path + ".tmp")
+	if err != nil {
+		return xerrors.Errorf("creating staged piece: %w", err)
+	}
+
+	if _, err := io.Copy(f, r); err != nil {
+		_ = f.Close()
+		return xerrors.Errorf("staging piece of deal %d: %w", pp.Deal.DealID, err)
+	}
+	if err := f.Close(); err != nil {
+		return xerrors.Errorf("closing staged piece: %w", err)
+	}
+
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return xerrors.Errorf("renaming staged piece: %w", err)
+	}
+
+	b, err := json.Marshal(pp)
+	if err != nil {
+		return xerrors.Errorf("marshaling packed deal: %w", err)
+	}
+
+	return p.ds.Put(dealKey(pp.Deal.DealID), b)
+}
+
+// load restores the deals which were waiting when the miner stopped
+func (p *Packer) load() error {
+	res, err := p.ds.Query(query.Query{})
+	if err != nil {
+		return xerrors.Errorf("querying packed deals: %w", err)
+	}
+
+	ents, err := res.Rest()
+	if err != nil {
+		return xerrors.Errorf("listing packed deals: %w", err)
+	}
+
+	for _, ent := range ents {
+		var pp packedPiece
+		if err := json.Unmarshal(ent.Value, &pp); err != nil {
+			return xerrors.Errorf("unmarshaling packed deal: %w", err)
+		}
+
+		if _, err := os.Stat(p.stagingPath(pp.Deal.DealID)); err != nil {
+			log.Errorf("dropping packed deal %d: %s", pp.Deal.DealID, err)
+			if err := p.ds.Delete(datastore.RawKey(ent.Key)); err != nil {
+				return xerrors.Errorf("removing packed deal: %w", err)
+			}
+			continue
+		}
+
+		p.pending = append(p.pending, &pp)
+	}
+
+	sortPieces(p.pending)
+
+	if len(p.pending) > 0 {
+		log.Infof("%d deals waiting for packing", len(p.pending))
+	}
+
+	return nil
+}
+
+func (p *Packer) stagingPath(deal abi.DealID) string {
+	return filepath.Join(p.staging, fmt.Sprint(deal))
+}
+
+func (p *Packer) wake() {
+	select {
+	case p.check <- struct{}{}:
+	default:
+	}
+}
+
+func dealKey(deal abi.DealID) datastore.Key {
+	return datastore.NewKey(fmt.Sprint(deal))
+}
+
+func sortPieces(pieces []*packedPiece) {
+	sort.SliceStable(pieces, func(i, j int) bool {
+		return pieces[i].Added.Before(pieces[j].Added)
+	})
+}
@@ -0,0 +1,260 @@
+package sectorblocks
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	sealing "github.com/filecoin-project/storage-fsm"
+
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/types/mock"
+	"github.com/filecoin-project/lotus/node/config"
+)
+
+const testSectorSize = abi.SectorSize(2048)
+
+type fakePackerApi struct {
+	height abi.ChainEpoch
+	err    error
+}
+
+func (a *fakePackerApi) ChainHead(context.Context) (*types.TipSet, error) {
+	if a.err != nil {
+		return nil, a.err
+	}
+
+	blk := mock.MkBlock(nil, 1, 1)
+	blk.Height = a.height
+	return mock.TipSet(blk), nil
+}
+
+type fakePieceSealer struct {
+	sectors []abi.DealID
+	data    map[abi.DealID][]byte
+}
+
+func (s *fakePieceSealer) AddPiece(ctx context.Context, size abi.UnpaddedPieceSize, r io.Reader, d sealing.DealInfo) (abi.SectorNumber, error) {
+	if s.data == nil {
+		s.data = map[abi.DealID][]byte{}
+	}
+
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	s.data[d.DealID] = b
+
+	s.sectors = append(s.sectors, d.DealID)
+	return abi.SectorNumber(len(s.sectors)), nil
+}
+
+type testPacker struct {
+	*Packer
+	sealer *fakePieceSealer
+	ds     datastore.Batching
+	dir    string
+}
+
+func newTestPacker(t *testing.T, api packerApi, cfg config.PackingConfig) *testPacker {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tp := &testPacker{
+		sealer: &fakePieceSealer{},
+		ds:     dssync.MutexWrap(datastore.NewMapDatastore()),
+		dir:    dir,
+	}
+
+	tp.Packer, err = NewPacker(tp.sealer, api, tp.ds, dir, testSectorSize, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tp
+}
+
+// testPiece returns a piece of the given padded size
+func testPiece(padded abi.PaddedPieceSize) (abi.UnpaddedPieceSize, io.Reader) {
+	size := padded.Unpadded()
+	return size, bytes.NewReader(bytes.Repeat([]byte{byte(padded >> 8)}, int(size)))
+}
+
+func (tp *testPacker) add(t *testing.T, deal abi.DealID, padded abi.PaddedPieceSize, start abi.ChainEpoch) {
+	size, r := testPiece(padded)
+	err := tp.AddPiece(context.TODO(), size, r, sealing.DealInfo{
+		DealID: deal,
+		DealSchedule: sealing.DealSchedule{
+			StartEpoch: start,
+			EndEpoch:   start + 1000,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func deals(pieces []*packedPiece) []abi.DealID {
+	var out []abi.DealID
+	for _, pp := range pieces {
+		out = append(out, pp.Deal.DealID)
+	}
+	return out
+}
+
+func TestPackerFill(t *testing.T) {
+	tp := newTestPacker(t, &fakePackerApi{height: 10}, config.PackingConfig{
+		MaxWait:      config.Duration(time.Hour),
+		FillPercent:  90,
+		SealDuration: 100,
+	})
+	defer os.RemoveAll(tp.dir) // nolint: errcheck
+
+	tp.add(t, 1, 1024, 1000)
+	tp.add(t, 2, 512, 1000)
+	tp.add(t, 3, 256, 1000)
+	if out := tp.checkRelease(context.TODO()); len(out) != 0 {
+		t.Fatalf("released deals filling 87%% of a sector: %v", deals(out))
+	}
+
+	tp.add(t, 4, 128, 1000)
+	out := deals(tp.checkRelease(context.TODO()))
+	if len(out) != 4 {
+		t.Fatalf("expected all deals to be released once they fill 93%% of a sector, got %v", out)
+	}
+
+	st, err := tp.Status(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(st.Pieces) != 0 {
+		t.Fatalf("expected no deals waiting, got %+v", st.Pieces)
+	}
+}
+
+func TestPackerStartEpoch(t *testing.T) {
+	api := &fakePackerApi{height: 10}
+	tp := newTestPacker(t, api, config.PackingConfig{
+		MaxWait:      config.Duration(time.Hour),
+		FillPercent:  100,
+		SealDuration: 100,
+	})
+	defer os.RemoveAll(tp.dir) // nolint: errcheck
+
+	tp.add(t, 1, 256, 1000)
+	tp.add(t, 2, 256, 200)
+	if out := tp.checkRelease(context.TODO()); len(out) != 0 {
+		t.Fatalf("released deals early: %v", deals(out))
+	}
+
+	api.height = 100
+	out := tp.checkRelease(context.TODO())
+	if len(out) != 2 {
+		t.Fatalf("expected all deals to be released, got %v", deals(out))
+	}
+}
+
+func TestPackerMaxWaitWithoutHead(t *testing.T) {
+	api := &fakePackerApi{err: xerrors.New("no chain head")}
+	tp := newTestPacker(t, api, config.PackingConfig{
+		MaxWait:      config.Duration(time.Hour),
+		FillPercent:  100,
+		SealDuration: 100,
+	})
+	defer os.RemoveAll(tp.dir) // nolint: errcheck
+
+	tp.add(t, 1, 256, 1000)
+	if out := tp.checkRelease(context.TODO()); len(out) != 0 {
+		t.Fatalf("released deals early: %v", deals(out))
+	}
+
+	tp.lk.Lock()
+	tp.pending[0].Added = time.Now().Add(-2 * time.Hour)
+	tp.lk.Unlock()
+
+	out := tp.checkRelease(context.TODO())
+	if len(out) != 1 {
+		t.Fatalf("expected the deal to be released after MaxWait, got %v", deals(out))
+	}
+}
+
+func TestPackerRestart(t *testing.T) {
+	cfg := config.PackingConfig{
+		MaxWait:      config.Duration(time.Hour),
+		FillPercent:  100,
+		SealDuration: 100,
+	}
+	tp := newTestPacker(t, &fakePackerApi{height: 10}, cfg)
+	defer os.RemoveAll(tp.dir) // nolint: errcheck
+
+	tp.add(t, 1, 512, 1000)
+	tp.add(t, 2, 1024, 1000)
+
+	// the deals are restored by a new packer
+	p, err := NewPacker(tp.sealer, tp.api, tp.ds, tp.dir, testSectorSize, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p.SealNow()
+	out := p.checkRelease(context.TODO())
+	if len(out) != 2 {
+		t.Fatalf("expected the restored deals to be released, got %v", deals(out))
+	}
+
+	for _, pp := range out {
+		if err := p.seal(context.TODO(), pp); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(tp.sealer.sectors) != 2 {
+		t.Fatalf("expected each deal to be sealed in a sector, got %v", tp.sealer.sectors)
+	}
+	for deal, padded := range map[abi.DealID]abi.PaddedPieceSize{1: 512, 2: 1024} {
+		_, r := testPiece(padded)
+		expect, _ := ioutil.ReadAll(r)
+		if !bytes.Equal(tp.sealer.data[deal], expect) {
+			t.Errorf("sealed data of deal %d doesn't match", deal)
+		}
+	}
+
+	// sealed deals are forgotten
+	p, err = NewPacker(tp.sealer, tp.api, tp.ds, tp.dir, testSectorSize, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.pending) != 0 {
+		t.Errorf("expected no deals waiting, got %d", len(p.pending))
+	}
+	files, err := ioutil.ReadDir(tp.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 0 {
+		t.Errorf("expected staged pieces to be removed, got %d files", len(files))
+	}
+}
+
+func TestNewPackerFillPercent(t *testing.T) {
+	for _, fill := range []uint64{0, 101} {
+		_, err := NewPacker(&fakePieceSealer{}, &fakePackerApi{}, datastore.NewMapDatastore(), os.TempDir(), testSectorSize, config.PackingConfig{
+			MaxWait:     config.Duration(time.Hour),
+			FillPercent: fill,
+		})
+		if err == nil {
+			t.Errorf("expected fill percent %d to be rejected", fill)
+		}
+	}
+}